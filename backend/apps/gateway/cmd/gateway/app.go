@@ -3,6 +3,7 @@ package main
 import (
 	"StructForge/backend/apps/gateway/internal/conf"
 	"StructForge/backend/apps/gateway/internal/handler"
+	"StructForge/backend/apps/gateway/internal/server"
 
 	"github.com/go-kratos/kratos/v2"
 	"github.com/go-kratos/kratos/v2/log"
@@ -15,7 +16,10 @@ func newApp(
 	httpSrv *http.Server,
 	gatewayHandler *handler.GatewayHandler,
 	dashboardHandler *handler.DashboardHandler,
+	adminSrv *server.AdminServer,
+	adminHandler *handler.AdminHandler,
 	logger log.Logger,
+	reloader *configReloader,
 ) *kratos.App {
 	// 注册路由
 	gatewayHandler.RegisterRoutes(httpSrv, dashboardHandler)
@@ -24,6 +28,15 @@ func newApp(
 	opts := []kratos.Option{
 		kratos.Logger(logger),
 		kratos.Server(httpSrv),
+		// 服务器启动后开始监听配置变化，停止服务器前停止监听
+		kratos.AfterStart(reloader.Start),
+		kratos.BeforeStop(reloader.Stop),
+	}
+
+	// 启用管理接口时注册管理路由并启动独立的管理端口
+	if adminSrv != nil {
+		adminHandler.RegisterRoutes(adminSrv.Server)
+		opts = append(opts, kratos.Server(adminSrv.Server))
 	}
 
 	// 如果配置中有服务信息，添加到应用实例
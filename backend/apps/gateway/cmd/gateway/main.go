@@ -155,7 +155,9 @@ func main() {
 	// ========== 第四步：加载完整配置（Bootstrap）==========
 	// 如果启用了 Nacos 配置中心，从 Nacos 获取配置
 	// 否则直接从本地文件加载配置
+	// 同时创建配置变更来源，服务启动后监听配置变化并热更新路由和服务
 	var bc *conf.Bootstrap
+	var source configSource
 
 	if startupConfig.Nacos.ConfigCenter.Enabled {
 		// 从 Nacos 获取配置
@@ -170,6 +172,7 @@ func main() {
 		}
 		defer dm.Close()
 		bc = &bcTemp
+		source = &nacosConfigSource{manager: dm}
 
 		log.Info(ctx, "从 Nacos 获取配置成功",
 			log.String("data_id", startupConfig.Nacos.ConfigCenter.DataId),
@@ -190,6 +193,7 @@ func main() {
 		log.Info(ctx, "从本地文件加载配置成功",
 			log.String("config_path", configPath),
 		)
+		source = newFileConfigSource(configPath)
 	}
 
 	// ========== 第五步：更新日志系统配置（如果需要）==========
@@ -271,7 +275,7 @@ func main() {
 	// ========== 第六步：使用Wire进行依赖注入，创建应用实例 ==========
 	log.Info(ctx, "正在初始化应用实例")
 
-	app, cleanup, err := wireApp(bc, bc.Redis)
+	app, cleanup, err := wireApp(bc, bc.Redis, source, &startupConfig)
 	if err != nil {
 		log.Error(ctx, "gateway 服务初始化失败",
 			log.ErrorField(err),
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kratos/kratos/v2/config"
+	"github.com/go-kratos/kratos/v2/config/file"
+	"gopkg.in/yaml.v3"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	"StructForge/backend/apps/gateway/internal/router"
+	"StructForge/backend/common/log"
+	nacosClient "StructForge/backend/common/middleware/nacos"
+)
+
+// configSource 网关配置变更来源（Nacos 配置中心或本地配置文件）
+type configSource interface {
+	// Watch 开始监听配置变化，配置变化时回调解析后的完整配置
+	Watch(onChange func(*conf.Bootstrap)) error
+	// Stop 停止监听
+	Stop() error
+}
+
+// nacosConfigSource 监听 Nacos 配置中心的配置变化
+type nacosConfigSource struct {
+	manager *nacosClient.ConfigManager
+}
+
+// Watch 监听 Nacos 配置变化
+func (s *nacosConfigSource) Watch(onChange func(*conf.Bootstrap)) error {
+	return s.manager.Watch(func(content string) {
+		var bc conf.Bootstrap
+		if err := yaml.Unmarshal([]byte(content), &bc); err != nil {
+			log.Warn(context.Background(), "解析 Nacos 配置失败，忽略本次配置变化",
+				log.ErrorField(err),
+			)
+			return
+		}
+		onChange(&bc)
+	})
+}
+
+// Stop 取消监听 Nacos 配置变化
+func (s *nacosConfigSource) Stop() error {
+	return s.manager.Close()
+}
+
+// fileConfigSource 监听本地配置文件的变化
+type fileConfigSource struct {
+	path   string
+	config config.Config
+}
+
+// newFileConfigSource 创建本地配置文件变更来源
+func newFileConfigSource(path string) *fileConfigSource {
+	return &fileConfigSource{path: path}
+}
+
+// Watch 监听配置文件中 gateway 配置的变化
+func (s *fileConfigSource) Watch(onChange func(*conf.Bootstrap)) error {
+	c := config.New(config.WithSource(file.NewSource(s.path)))
+	if err := c.Load(); err != nil {
+		return err
+	}
+	err := c.Watch("gateway", func(key string, value config.Value) {
+		var bc conf.Bootstrap
+		if err := c.Scan(&bc); err != nil {
+			log.Warn(context.Background(), "解析配置文件失败，忽略本次配置变化",
+				log.String("config_path", s.path),
+				log.ErrorField(err),
+			)
+			return
+		}
+		onChange(&bc)
+	})
+	if err != nil {
+		c.Close()
+		return err
+	}
+	s.config = c
+	return nil
+}
+
+// Stop 停止监听配置文件
+func (s *fileConfigSource) Stop() error {
+	if s.config == nil {
+		return nil
+	}
+	return s.config.Close()
+}
+
+// configReloader 配置热更新器：配置变化时热更新路由和服务
+type configReloader struct {
+	source configSource
+	router *router.Router
+}
+
+// newConfigReloader 创建配置热更新器（source 为空时不监听配置变化）
+func newConfigReloader(source configSource, r *router.Router) *configReloader {
+	return &configReloader{
+		source: source,
+		router: r,
+	}
+}
+
+// Start 开始监听配置变化（服务器启动后调用）
+// 监听失败不影响服务运行，只记录警告
+func (c *configReloader) Start(ctx context.Context) error {
+	if c.source == nil {
+		return nil
+	}
+	if err := c.source.Watch(c.reload); err != nil {
+		log.Warn(ctx, "监听网关配置变化失败，配置热更新不可用",
+			log.ErrorField(err),
+		)
+		return nil
+	}
+	log.Info(ctx, "已开始监听网关配置变化")
+	return nil
+}
+
+// Stop 停止监听配置变化（服务器停止前调用）
+func (c *configReloader) Stop(ctx context.Context) error {
+	if c.source == nil {
+		return nil
+	}
+	if err := c.source.Stop(); err != nil {
+		return fmt.Errorf("停止监听网关配置变化失败: %w", err)
+	}
+	return nil
+}
+
+// reload 应用新的网关配置（验证失败时由路由管理器拒绝并记录变更内容）
+func (c *configReloader) reload(bc *conf.Bootstrap) {
+	if bc.Gateway == nil {
+		log.Warn(context.Background(), "新配置缺少 gateway 配置，忽略本次配置变化")
+		return
+	}
+	c.router.Reload(bc.Gateway)
+}
@@ -11,18 +11,20 @@ import (
 	"StructForge/backend/apps/gateway/internal/handler"
 	"StructForge/backend/apps/gateway/internal/router"
 	"StructForge/backend/apps/gateway/internal/server"
+	"StructForge/backend/common/middleware/nacos"
 )
 
 // wireApp 初始化应用（由 Wire 生成）
 // 注意：此文件只在 wireinject 构建标签下编译
 // 运行 wire 命令后会生成 wire_gen.go 文件
-func wireApp(bc *conf.Bootstrap, redis *conf.Redis) (*kratos.App, func(), error) {
+func wireApp(bc *conf.Bootstrap, redis *conf.Redis, source configSource, nacosConfig *nacos.StartupConfig) (*kratos.App, func(), error) {
 	panic(wire.Build(
 		server.ProviderSet,
 		handler.ProviderSet,
 		router.ProviderSet,
 		newLogger,
 		newApp,
+		newConfigReloader,
 		getGatewayConfig, // 从 Bootstrap 获取 Gateway 配置
 	))
 }
@@ -12,6 +12,7 @@ import (
 	"StructForge/backend/apps/gateway/internal/middleware/metrics"
 	"StructForge/backend/apps/gateway/internal/router"
 	"StructForge/backend/apps/gateway/internal/server"
+	"StructForge/backend/common/middleware/nacos"
 	"github.com/go-kratos/kratos/v2"
 )
 
@@ -20,22 +21,31 @@ import (
 // wireApp 初始化应用（由 Wire 生成）
 // 注意：此文件只在 wireinject 构建标签下编译
 // 运行 wire 命令后会生成 wire_gen.go 文件
-func wireApp(bc *conf.Bootstrap, redis *conf.Redis) (*kratos.App, func(), error) {
+func wireApp(bc *conf.Bootstrap, redis *conf.Redis, source configSource, nacosConfig *nacos.StartupConfig) (*kratos.App, func(), error) {
 	gatewayConfig := getGatewayConfig(bc)
 	corsHandler := router.NewCORSHandlerFromConfig(gatewayConfig)
 	httpServer := server.NewHTTPServer(bc, corsHandler)
-	staticDiscovery := router.NewStaticDiscovery()
-	routerRouter, err := router.LoadRouterFromConfig(gatewayConfig, staticDiscovery)
+	serviceDiscovery, cleanup, err := router.NewServiceDiscovery(gatewayConfig, nacosConfig)
 	if err != nil {
 		return nil, nil, err
 	}
-	manager := router.NewJWTManagerFromConfig(gatewayConfig)
 	metricsMetrics := metrics.NewMetrics()
+	routerRouter, cleanup2, err := router.LoadRouterFromConfig(gatewayConfig, serviceDiscovery, metricsMetrics)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	manager := router.NewJWTManagerFromConfig(gatewayConfig)
 	metricsMiddleware := metrics.NewMetricsMiddleware(metricsMetrics)
 	gatewayHandler := handler.NewGatewayHandler(routerRouter, manager, corsHandler, metricsMiddleware)
 	dashboardHandler := handler.NewDashboardHandler()
+	adminServer := server.NewAdminServer(bc)
+	adminHandler := handler.NewAdminHandler(routerRouter, gatewayHandler)
 	logger := newLogger()
-	app := newApp(bc, httpServer, gatewayHandler, dashboardHandler, logger)
+	mainConfigReloader := newConfigReloader(source, routerRouter)
+	app := newApp(bc, httpServer, gatewayHandler, dashboardHandler, adminServer, adminHandler, logger, mainConfigReloader)
 	return app, func() {
+		cleanup2()
+		cleanup()
 	}, nil
 }
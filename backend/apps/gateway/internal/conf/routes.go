@@ -7,21 +7,171 @@ type RouteConfig struct {
 
 // RouteRule 路由规则配置
 type RouteRule struct {
-	Path                string                `yaml:"path" json:"path"`
-	MatchType           string                `yaml:"match_type" json:"match_type"`
-	Service             string                `yaml:"service" json:"service"`
-	TargetPath          string                `yaml:"target_path" json:"target_path"`
-	RequireAuth         bool                  `yaml:"require_auth" json:"require_auth"`
-	Timeout             int                   `yaml:"timeout" json:"timeout"`
-	Retries             int                   `yaml:"retries" json:"retries"`
-	LoadBalanceStrategy string                `yaml:"load_balance_strategy" json:"load_balance_strategy"`
-	RateLimit           *RateLimitConfig      `yaml:"rate_limit" json:"rate_limit"`
-	CircuitBreaker      *CircuitBreakerConfig `yaml:"circuit_breaker" json:"circuit_breaker"`
-	Cache               *CacheConfig          `yaml:"cache" json:"cache"`
+	Path                string                 `yaml:"path" json:"path"`
+	MatchType           string                 `yaml:"match_type" json:"match_type"`
+	Methods             []string               `yaml:"methods" json:"methods"`
+	Headers             []RouteMatchCondition  `yaml:"headers" json:"headers"`
+	Query               []RouteMatchCondition  `yaml:"query" json:"query"`
+	Host                string                 `yaml:"host" json:"host"`
+	Priority            int                    `yaml:"priority" json:"priority"`
+	Service             string                 `yaml:"service" json:"service"`
+	TargetPath          string                 `yaml:"target_path" json:"target_path"`
+	Rewrite             string                 `yaml:"rewrite" json:"rewrite"`
+	StripPrefix         string                 `yaml:"strip_prefix" json:"strip_prefix"`
+	AddPrefix           string                 `yaml:"add_prefix" json:"add_prefix"`
+	HostRewrite         string                 `yaml:"host_rewrite" json:"host_rewrite"`
+	RequireAuth         bool                   `yaml:"require_auth" json:"require_auth"`
+	WebSocket           bool                   `yaml:"websocket" json:"websocket"`
+	Timeout             int                    `yaml:"timeout" json:"timeout"`
+	IdleTimeout         int                    `yaml:"idle_timeout" json:"idle_timeout"`
+	Retries             int                    `yaml:"retries" json:"retries"`
+	LoadBalanceStrategy string                 `yaml:"load_balance_strategy" json:"load_balance_strategy"`
+	HashOn              string                 `yaml:"hash_on" json:"hash_on"`
+	RateLimit           *RateLimitConfig       `yaml:"rate_limit" json:"rate_limit"`
+	CircuitBreaker      *CircuitBreakerConfig  `yaml:"circuit_breaker" json:"circuit_breaker"`
+	Cache               *CacheConfig           `yaml:"cache" json:"cache"`
+	TrafficSplit        *TrafficSplitConfig    `yaml:"traffic_split" json:"traffic_split"`
+	RequestHeaders      *HeaderTransformConfig `yaml:"request_headers" json:"request_headers"`
+	ResponseHeaders     *HeaderTransformConfig `yaml:"response_headers" json:"response_headers"`
+	Concurrency         *ConcurrencyConfig     `yaml:"concurrency" json:"concurrency"`
+	Retry               *RetryPolicyConfig     `yaml:"retry" json:"retry"`
+	Hedging             *HedgingConfig         `yaml:"hedging" json:"hedging"`
+	// 负载削减优先级：critical（最后削减，如健康检查、认证）、normal（默认）、low（最先削减）
+	ShedPriority string `yaml:"shed_priority" json:"shed_priority"`
+}
+
+// ConcurrencyConfig 并发限制配置
+type ConcurrencyConfig struct {
+	// 最大并发请求数（0 表示不限制）
+	MaxConcurrency int `yaml:"max_concurrency" json:"max_concurrency"`
+	// 等待队列长度（0 表示不排队，并发数已满时直接返回 503）
+	QueueSize int `yaml:"queue_size" json:"queue_size"`
+	// 排队超时时间（秒），默认5秒
+	QueueTimeout int `yaml:"queue_timeout" json:"queue_timeout"`
+}
+
+// AdaptiveConcurrencyConfig 自适应并发限制配置（根据下游延迟自动调整并发上限）
+type AdaptiveConcurrencyConfig struct {
+	// 是否启用
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// 初始并发上限，默认20
+	InitialLimit int `yaml:"initial_limit" json:"initial_limit"`
+	// 并发上限的最小值，默认5
+	MinLimit int `yaml:"min_limit" json:"min_limit"`
+	// 并发上限的最大值，默认1000
+	MaxLimit int `yaml:"max_limit" json:"max_limit"`
+	// 延迟容忍倍数，p90 延迟超过基线延迟的此倍数时收缩并发上限，默认2
+	Tolerance float64 `yaml:"tolerance" json:"tolerance"`
+	// 收缩系数（0-1），默认0.9
+	Backoff float64 `yaml:"backoff" json:"backoff"`
+	// 每个统计窗口的样本数，默认50
+	WindowSize int `yaml:"window_size" json:"window_size"`
+}
+
+// OutlierDetectionConfig 异常实例检测配置（被动健康检查，按实例统计请求结果并暂时驱逐异常实例）
+type OutlierDetectionConfig struct {
+	// 是否启用
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// 连续返回 5xx 的次数阈值，默认5
+	Consecutive5xx int `yaml:"consecutive_5xx" json:"consecutive_5xx"`
+	// 连续连接错误（连接失败、超时）的次数阈值，默认5
+	ConsecutiveConnectionErrors int `yaml:"consecutive_connection_errors" json:"consecutive_connection_errors"`
+	// 延迟倍数阈值，实例平均延迟超过同服务其他实例平均延迟的此倍数时驱逐（0 表示不检测延迟）
+	LatencyFactor float64 `yaml:"latency_factor" json:"latency_factor"`
+	// 参与延迟检测所需的最少请求数，默认20
+	LatencyMinRequests int `yaml:"latency_min_requests" json:"latency_min_requests"`
+	// 基础驱逐时间（秒），每次被驱逐后加倍，默认30秒
+	BaseEjectionTime int `yaml:"base_ejection_time" json:"base_ejection_time"`
+	// 最大驱逐时间（秒），默认300秒
+	MaxEjectionTime int `yaml:"max_ejection_time" json:"max_ejection_time"`
+	// 同一服务最多可同时驱逐的实例百分比（1-100），默认50
+	MaxEjectionPercent int `yaml:"max_ejection_percent" json:"max_ejection_percent"`
+}
+
+// HealthCheckConfig 主动健康检查配置（定期请求服务实例的健康检查接口，不健康的实例不参与负载均衡）
+type HealthCheckConfig struct {
+	// 是否启用
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// 健康检查路径，默认 /health
+	Path string `yaml:"path" json:"path"`
+	// 视为健康的响应状态码，默认200
+	ExpectedStatus []int `yaml:"expected_status" json:"expected_status"`
+	// 检查间隔（秒），默认10秒
+	Interval int `yaml:"interval" json:"interval"`
+	// 单次检查超时时间（秒），默认2秒
+	Timeout int `yaml:"timeout" json:"timeout"`
+	// 不健康的实例连续检查成功多少次后恢复，默认2
+	HealthyThreshold int `yaml:"healthy_threshold" json:"healthy_threshold"`
+	// 健康的实例连续检查失败多少次后标记为不健康，默认3
+	UnhealthyThreshold int `yaml:"unhealthy_threshold" json:"unhealthy_threshold"`
+}
+
+// RetryPolicyConfig 路由重试策略配置
+type RetryPolicyConfig struct {
+	// 最大重试次数（不含首次请求），为 0 时使用路由的 retries
+	Attempts int `yaml:"attempts" json:"attempts"`
+	// 可重试的错误类型：connect_failure（连接失败）、reset（连接被重置），默认两者都重试
+	RetryOn []string `yaml:"retry_on" json:"retry_on"`
+	// 可重试的上游响应状态码，默认 502、503、504
+	RetryOnStatus []int `yaml:"retry_on_status" json:"retry_on_status"`
+	// 是否允许重试非幂等请求（POST、PATCH），默认只重试幂等方法或携带 Idempotency-Key 的请求
+	RetryNonIdempotent bool `yaml:"retry_non_idempotent" json:"retry_non_idempotent"`
+	// 允许缓冲以便重放的最大请求体大小（字节），超过此大小的请求不重试，默认 64KB
+	MaxBodySize int64 `yaml:"max_body_size" json:"max_body_size"`
+	// 退避基础间隔（毫秒），每次重试加倍并加入随机抖动，默认25毫秒
+	BaseInterval int `yaml:"base_interval" json:"base_interval"`
+	// 退避最大间隔（毫秒），默认1000毫秒
+	MaxInterval int `yaml:"max_interval" json:"max_interval"`
+}
+
+// HedgingConfig 对冲请求配置（只对 GET、HEAD 请求生效）
+// 等待一段时间仍未收到响应时向其他实例发送相同的请求，采用第一个成功的响应并取消其他请求
+type HedgingConfig struct {
+	// 是否启用
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// 发送对冲请求前的等待时间（毫秒），为 0 时使用路由最近请求延迟的 p95（样本不足时不发送对冲请求）
+	Delay int `yaml:"delay" json:"delay"`
+	// 使用 p95 时的最小等待时间（毫秒）
+	MinDelay int `yaml:"min_delay" json:"min_delay"`
+	// 最多发送的对冲请求数，默认1
+	MaxHedges int `yaml:"max_hedges" json:"max_hedges"`
+}
+
+// RetryBudgetConfig 全局重试预算配置（限制重试流量占比，避免重试风暴）
+type RetryBudgetConfig struct {
+	// 重试请求数占请求总数的最大百分比，默认20
+	Percent float64 `yaml:"percent" json:"percent"`
+	// 每秒最少允许的重试次数，默认10
+	MinRetriesPerSecond int `yaml:"min_retries_per_second" json:"min_retries_per_second"`
+	// 统计时间窗口（秒），默认10秒
+	Window int `yaml:"window" json:"window"`
+}
+
+// RouteMatchCondition 请求头/查询参数匹配条件
+type RouteMatchCondition struct {
+	// 请求头或查询参数名称
+	Name string `yaml:"name" json:"name"`
+	// 匹配方式：exact（精确匹配，默认）、regex（正则匹配）、present（存在即可）
+	Type string `yaml:"type" json:"type"`
+	// 匹配值（present 方式不需要）
+	Value string `yaml:"value" json:"value"`
 }
 
 // RateLimitConfig 限流配置
 type RateLimitConfig struct {
+	// 限流器类型：local（默认，本地令牌桶）、distributed（共享缓存滑动窗口）
+	Type  string `yaml:"type" json:"type"`
+	QPS   int    `yaml:"qps" json:"qps"`
+	Burst int    `yaml:"burst" json:"burst"`
+	// 限流维度：route、ip、user、header:<name>，可组合（为空时整个路由共享配额）
+	KeyBy []string `yaml:"key_by" json:"key_by"`
+	// 未认证 / 已认证调用方的独立配额（为空时使用上面的 QPS、Burst）
+	Anonymous     *RateLimitTier `yaml:"anonymous" json:"anonymous"`
+	Authenticated *RateLimitTier `yaml:"authenticated" json:"authenticated"`
+}
+
+// RateLimitTier 限流配额档位
+type RateLimitTier struct {
 	QPS   int `yaml:"qps" json:"qps"`
 	Burst int `yaml:"burst" json:"burst"`
 }
@@ -62,6 +212,41 @@ type CacheConfig struct {
 	IncludeQueryParams bool `yaml:"include_query_params" json:"include_query_params"`
 	// 是否包含请求头在缓存键中（用于区分不同用户）
 	IncludeHeaders []string `yaml:"include_headers" json:"include_headers"`
+	// 允许缓存的最大响应体大小（字节），超过此大小的响应只转发不缓存，默认 1MB
+	MaxBodySize int64 `yaml:"max_body_size" json:"max_body_size"`
+}
+
+// HeaderTransformConfig 请求头/响应头转换配置
+// 值支持模板变量：${trace_id}、${client_ip}、${user_id}、${username}、${method}、${path}、${host}
+type HeaderTransformConfig struct {
+	// 设置（覆盖）的请求头，值展开后为空时删除该请求头
+	Set map[string]string `yaml:"set" json:"set"`
+	// 追加的请求头
+	Add map[string]string `yaml:"add" json:"add"`
+	// 删除的请求头
+	Remove []string `yaml:"remove" json:"remove"`
+	// 重命名的请求头（原名称 -> 新名称）
+	Rename map[string]string `yaml:"rename" json:"rename"`
+}
+
+// TrafficSplitConfig 流量拆分配置（按实例元数据中的版本分配流量）
+type TrafficSplitConfig struct {
+	// 实例元数据中表示版本的键，默认 version
+	MetadataKey string `yaml:"metadata_key" json:"metadata_key"`
+	// 各版本的流量权重
+	Targets []TrafficSplitTarget `yaml:"targets" json:"targets"`
+	// 粘性分配方式：user_id（按用户ID）、cookie（按 Cookie），为空则每个请求独立分配
+	Sticky string `yaml:"sticky" json:"sticky"`
+	// 粘性分配使用的 Cookie 名称，默认 gateway_version
+	CookieName string `yaml:"cookie_name" json:"cookie_name"`
+}
+
+// TrafficSplitTarget 流量拆分目标版本
+type TrafficSplitTarget struct {
+	// 版本（与实例元数据中的值匹配）
+	Version string `yaml:"version" json:"version"`
+	// 流量权重
+	Weight int `yaml:"weight" json:"weight"`
 }
 
 // ServiceConfig 服务配置（静态服务发现）
@@ -79,6 +264,53 @@ type ServiceInstance struct {
 	Metadata map[string]string `yaml:"metadata" json:"metadata"`
 }
 
+// DiscoveryConfig 服务发现配置
+type DiscoveryConfig struct {
+	// 服务发现类型：static（默认，使用 services 中的静态实例）、nacos、dns、file
+	Type string `yaml:"type" json:"type"`
+	// Nacos 服务实例快照目录（type 为 nacos 时使用），Nacos 不可用时使用快照启动和转发请求
+	// 默认 data/gateway/discovery，不要使用重启后会被清空的临时目录
+	SnapshotDir string `yaml:"snapshot_dir" json:"snapshot_dir"`
+	// 快照最大有效期（秒），超过有效期的快照不再使用，默认 86400（24小时）
+	SnapshotMaxAge int `yaml:"snapshot_max_age" json:"snapshot_max_age"`
+	// DNS 服务发现配置（type 为 dns 时使用）
+	DNS *DNSDiscoveryConfig `yaml:"dns" json:"dns"`
+	// 文件服务发现配置（type 为 file 时使用）
+	File *FileDiscoveryConfig `yaml:"file" json:"file"`
+}
+
+// DNSDiscoveryConfig DNS 服务发现配置（按 SRV 或 A/AAAA 记录解析服务实例，按记录的 TTL 刷新）
+type DNSDiscoveryConfig struct {
+	// DNS 服务器地址（host:port），为空时使用 /etc/resolv.conf 中的服务器
+	Servers []string `yaml:"servers" json:"servers"`
+	// 单次查询超时时间（秒），默认2秒
+	Timeout int `yaml:"timeout" json:"timeout"`
+	// 最小刷新间隔（秒），TTL 小于该值或查询失败时按该值刷新，默认5秒
+	MinRefresh int `yaml:"min_refresh" json:"min_refresh"`
+	// 最大刷新间隔（秒），默认300秒
+	MaxRefresh int `yaml:"max_refresh" json:"max_refresh"`
+	// 服务的 DNS 记录（key 为服务名）
+	Services map[string]*DNSServiceConfig `yaml:"services" json:"services"`
+}
+
+// DNSServiceConfig 服务的 DNS 记录配置
+type DNSServiceConfig struct {
+	// 记录类型：srv（默认，使用记录中的端口和权重）、a（A/AAAA 记录，每个地址为一个实例）
+	Type string `yaml:"type" json:"type"`
+	// 查询的域名，如 _http._tcp.user-service.internal 或 user-service.internal
+	Name string `yaml:"name" json:"name"`
+	// 实例端口（a 记录必填）
+	Port int `yaml:"port" json:"port"`
+}
+
+// FileDiscoveryConfig 文件服务发现配置（实例文件 key 为服务名，value 为实例列表，格式与 services 相同）
+type FileDiscoveryConfig struct {
+	// 实例文件路径（.json、.yaml 或 .yml）
+	Path string `yaml:"path" json:"path"`
+	// 检查文件变化的间隔（秒），默认5秒
+	Interval int `yaml:"interval" json:"interval"`
+}
+
 // GatewayConfig 网关完整配置
 type GatewayConfig struct {
 	// JWT 配置
@@ -87,10 +319,37 @@ type GatewayConfig struct {
 	Routes *RouteConfig `yaml:"routes" json:"routes"`
 	// 服务配置（静态服务发现）
 	Services *ServiceConfig `yaml:"services" json:"services"`
+	// 服务发现配置（未配置时使用 services 中的静态实例）
+	Discovery *DiscoveryConfig `yaml:"discovery" json:"discovery"`
 	// 前端配置
 	Frontend *FrontendConfig `yaml:"frontend" json:"frontend"`
 	// CORS 配置
 	CORS *CORSConfig `yaml:"cors" json:"cors"`
+	// 可信代理列表（CIDR 或 IP），只有直连地址属于可信代理时才信任 X-Forwarded-For 等请求头
+	TrustedProxies []string `yaml:"trusted_proxies" json:"trusted_proxies"`
+	// 服务级并发限制（key 为服务名，同一服务的所有路由共享）
+	ServiceConcurrency map[string]*ConcurrencyConfig `yaml:"service_concurrency" json:"service_concurrency"`
+	// 服务级自适应并发限制（key 为服务名）
+	AdaptiveConcurrency map[string]*AdaptiveConcurrencyConfig `yaml:"adaptive_concurrency" json:"adaptive_concurrency"`
+	// 服务级异常实例检测（key 为服务名）
+	OutlierDetection map[string]*OutlierDetectionConfig `yaml:"outlier_detection" json:"outlier_detection"`
+	// 服务级主动健康检查（key 为服务名）
+	HealthCheck map[string]*HealthCheckConfig `yaml:"health_check" json:"health_check"`
+	// 全局重试预算
+	RetryBudget *RetryBudgetConfig `yaml:"retry_budget" json:"retry_budget"`
+	// 管理接口（独立监听端口）
+	Admin *AdminConfig `yaml:"admin" json:"admin"`
+}
+
+// AdminConfig 管理接口配置
+// 管理接口在独立端口上提供路由、服务实例、熔断器、限流器和缓存的查看与控制，请求需携带 Authorization: Bearer <token>
+type AdminConfig struct {
+	// 是否启用管理接口
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	// 监听地址，默认 127.0.0.1:9001（只允许本机访问）
+	Addr string `yaml:"addr" json:"addr"`
+	// 访问令牌（启用时必须配置）
+	Token string `yaml:"token" json:"token"`
 }
 
 // FrontendConfig 前端配置
@@ -121,4 +380,6 @@ type CORSConfig struct {
 type JWTConfig struct {
 	SecretKey     string `yaml:"secret_key" json:"secret_key"`
 	TokenDuration string `yaml:"token_duration" json:"token_duration"` // 如: "24h", "7d"
+	// 向下游服务传递身份信息的签名密钥（需与下游服务 auth.internal_secret 一致），为空时不传递签名身份信息
+	InternalSecret string `yaml:"internal_secret" json:"internal_secret"`
 }
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	cacheMiddleware "StructForge/backend/apps/gateway/internal/middleware/cache"
+	"StructForge/backend/apps/gateway/internal/router"
+	"StructForge/backend/common/log"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// AdminHandler 管理接口处理器（查看和控制网关运行状态）
+type AdminHandler struct {
+	router  *router.Router
+	gateway *GatewayHandler
+}
+
+// NewAdminHandler 创建管理接口处理器
+func NewAdminHandler(router *router.Router, gatewayHandler *GatewayHandler) *AdminHandler {
+	return &AdminHandler{
+		router:  router,
+		gateway: gatewayHandler,
+	}
+}
+
+// RouteCacheStats 路由的缓存统计
+type RouteCacheStats struct {
+	// 路由标识
+	Route string `json:"route"`
+	cacheMiddleware.CacheStats
+}
+
+// circuitBreakerRequest 强制设置或重置熔断器请求
+type circuitBreakerRequest struct {
+	// 熔断器标识（服务名/实例标识）
+	Key string `json:"key"`
+	// 强制设置的状态：open、closed（重置时不需要）
+	State string `json:"state"`
+}
+
+// cachePurgeRequest 清除缓存请求
+type cachePurgeRequest struct {
+	// 路径模式（支持精确路径、/api/* 前缀和 *.json 后缀，* 清除全部）
+	Pattern string `json:"pattern"`
+	// 只清除指定路由（路由标识）的缓存，为空时清除所有路由的缓存
+	Route string `json:"route"`
+}
+
+// instanceRequest 摘除或恢复服务实例请求
+type instanceRequest struct {
+	// 服务名
+	Service string `json:"service"`
+	// 实例标识（实例ID，没有ID时为 host:port）
+	Instance string `json:"instance"`
+}
+
+// RegisterRoutes 注册管理接口路由
+func (h *AdminHandler) RegisterRoutes(srv *kratosHttp.Server) {
+	admin := srv.Route("/admin")
+	admin.GET("/routes", h.ListRoutes)
+	admin.GET("/services", h.ListServices)
+	admin.GET("/circuit-breakers", h.ListCircuitBreakers)
+	admin.POST("/circuit-breakers/force", h.ForceCircuitBreaker)
+	admin.POST("/circuit-breakers/reset", h.ResetCircuitBreaker)
+	admin.GET("/rate-limits", h.ListRateLimits)
+	admin.GET("/cache", h.CacheStats)
+	admin.POST("/cache/purge", h.PurgeCache)
+	admin.POST("/instances/drain", h.DrainInstance)
+	admin.POST("/instances/undrain", h.UndrainInstance)
+}
+
+// ListRoutes 列出当前生效的路由（按匹配优先级排序）
+func (h *AdminHandler) ListRoutes(ctx kratosHttp.Context) error {
+	return ctx.JSON(200, SuccessResponse(ctx.Request().Context(), h.router.Routes()))
+}
+
+// ListServices 列出各服务的实例及健康状态（包含主动健康检查结果和摘除状态）
+func (h *AdminHandler) ListServices(ctx kratosHttp.Context) error {
+	requestCtx := ctx.Request().Context()
+	return ctx.JSON(200, SuccessResponse(requestCtx, h.gateway.checkDownstreamServices(requestCtx)))
+}
+
+// ListCircuitBreakers 列出所有熔断器的状态
+func (h *AdminHandler) ListCircuitBreakers(ctx kratosHttp.Context) error {
+	return ctx.JSON(200, SuccessResponse(ctx.Request().Context(), h.router.GetCircuitBreakerStats()))
+}
+
+// ForceCircuitBreaker 强制打开或关闭熔断器（直到重置）
+func (h *AdminHandler) ForceCircuitBreaker(ctx kratosHttp.Context) error {
+	requestCtx := ctx.Request().Context()
+	var req circuitBreakerRequest
+	if err := decodeAdminRequest(ctx, &req); err != nil {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "无效的请求", err, ErrorTypeBusiness))
+	}
+	if req.Key == "" || (req.State != "open" && req.State != "closed") {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "必须指定熔断器标识 key 和状态 state（open 或 closed）", nil, ErrorTypeBusiness))
+	}
+
+	if err := h.router.ForceCircuitBreaker(req.Key, req.State == "open"); err != nil {
+		return ctx.JSON(404, ErrorResponse(requestCtx, CodeNotFound, "强制设置熔断器状态失败", err, ErrorTypeNotFound))
+	}
+	log.Warn(requestCtx, "管理接口强制设置熔断器状态",
+		log.String("breaker", req.Key),
+		log.String("state", req.State),
+	)
+	return ctx.JSON(200, SuccessResponse(requestCtx, h.router.GetCircuitBreakerStats()[req.Key]))
+}
+
+// ResetCircuitBreaker 取消熔断器的强制状态并重置统计
+func (h *AdminHandler) ResetCircuitBreaker(ctx kratosHttp.Context) error {
+	requestCtx := ctx.Request().Context()
+	var req circuitBreakerRequest
+	if err := decodeAdminRequest(ctx, &req); err != nil {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "无效的请求", err, ErrorTypeBusiness))
+	}
+	if req.Key == "" {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "必须指定熔断器标识 key", nil, ErrorTypeBusiness))
+	}
+
+	if err := h.router.ResetCircuitBreaker(req.Key); err != nil {
+		return ctx.JSON(404, ErrorResponse(requestCtx, CodeNotFound, "重置熔断器失败", err, ErrorTypeNotFound))
+	}
+	return ctx.JSON(200, SuccessResponse(requestCtx, h.router.GetCircuitBreakerStats()[req.Key]))
+}
+
+// ListRateLimits 列出限流器的使用统计
+func (h *AdminHandler) ListRateLimits(ctx kratosHttp.Context) error {
+	return ctx.JSON(200, SuccessResponse(ctx.Request().Context(), h.gateway.rateLimitMgr.Stats()))
+}
+
+// CacheStats 列出各路由的缓存统计
+func (h *AdminHandler) CacheStats(ctx kratosHttp.Context) error {
+	cacheMiddlewares := h.routeCacheMiddlewares()
+	stats := make([]RouteCacheStats, 0, len(cacheMiddlewares))
+	for route, middleware := range cacheMiddlewares {
+		stats = append(stats, RouteCacheStats{Route: route, CacheStats: middleware.Stats()})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Route < stats[j].Route
+	})
+	return ctx.JSON(200, SuccessResponse(ctx.Request().Context(), stats))
+}
+
+// PurgeCache 按路径模式清除缓存
+func (h *AdminHandler) PurgeCache(ctx kratosHttp.Context) error {
+	requestCtx := ctx.Request().Context()
+	var req cachePurgeRequest
+	if err := decodeAdminRequest(ctx, &req); err != nil {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "无效的请求", err, ErrorTypeBusiness))
+	}
+	if req.Pattern == "" {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "必须指定路径模式 pattern", nil, ErrorTypeBusiness))
+	}
+
+	cacheMiddlewares := h.routeCacheMiddlewares()
+	if req.Route != "" {
+		middleware, exists := cacheMiddlewares[req.Route]
+		if !exists {
+			return ctx.JSON(404, ErrorResponse(requestCtx, CodeNotFound, fmt.Sprintf("路由 %s 没有缓存", req.Route), nil, ErrorTypeNotFound))
+		}
+		cacheMiddlewares = map[string]*cacheMiddleware.CacheMiddleware{req.Route: middleware}
+	}
+
+	purged := make([]string, 0, len(cacheMiddlewares))
+	for route, middleware := range cacheMiddlewares {
+		if err := middleware.InvalidateByPath(requestCtx, req.Pattern); err != nil {
+			return ctx.JSON(500, ErrorResponse(requestCtx, CodeCacheError, "清除缓存失败", err, ErrorTypeInternal))
+		}
+		purged = append(purged, route)
+	}
+	sort.Strings(purged)
+
+	log.Info(requestCtx, "管理接口清除缓存",
+		log.String("pattern", req.Pattern),
+		log.Int("routes", len(purged)),
+	)
+	return ctx.JSON(200, SuccessResponse(requestCtx, map[string]interface{}{
+		"pattern": req.Pattern,
+		"routes":  purged,
+	}))
+}
+
+// DrainInstance 摘除服务实例（不再向实例转发新请求）
+func (h *AdminHandler) DrainInstance(ctx kratosHttp.Context) error {
+	requestCtx := ctx.Request().Context()
+	var req instanceRequest
+	if err := decodeAdminRequest(ctx, &req); err != nil {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "无效的请求", err, ErrorTypeBusiness))
+	}
+	if req.Service == "" || req.Instance == "" {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "必须指定服务名 service 和实例标识 instance", nil, ErrorTypeBusiness))
+	}
+	if !slices.Contains(h.router.GetAllServiceNames(), req.Service) {
+		return ctx.JSON(404, ErrorResponse(requestCtx, CodeNotFound, fmt.Sprintf("服务 %s 不存在", req.Service), nil, ErrorTypeNotFound))
+	}
+
+	h.router.DrainInstance(req.Service, req.Instance)
+	return ctx.JSON(200, SuccessResponse(requestCtx, map[string]interface{}{
+		"service": req.Service,
+		"drained": h.router.DrainedInstances(req.Service),
+	}))
+}
+
+// UndrainInstance 恢复被摘除的服务实例
+func (h *AdminHandler) UndrainInstance(ctx kratosHttp.Context) error {
+	requestCtx := ctx.Request().Context()
+	var req instanceRequest
+	if err := decodeAdminRequest(ctx, &req); err != nil {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "无效的请求", err, ErrorTypeBusiness))
+	}
+	if req.Service == "" || req.Instance == "" {
+		return ctx.JSON(400, ErrorResponse(requestCtx, CodeBadRequest, "必须指定服务名 service 和实例标识 instance", nil, ErrorTypeBusiness))
+	}
+
+	if !h.router.UndrainInstance(req.Service, req.Instance) {
+		return ctx.JSON(404, ErrorResponse(requestCtx, CodeNotFound, fmt.Sprintf("实例 %s 未被摘除", req.Instance), nil, ErrorTypeNotFound))
+	}
+	return ctx.JSON(200, SuccessResponse(requestCtx, map[string]interface{}{
+		"service": req.Service,
+		"drained": h.router.DrainedInstances(req.Service),
+	}))
+}
+
+// routeCacheMiddlewares 获取各路由的缓存中间件（key 为路由标识）
+func (h *AdminHandler) routeCacheMiddlewares() map[string]*cacheMiddleware.CacheMiddleware {
+	h.gateway.cacheMu.Lock()
+	defer h.gateway.cacheMu.Unlock()
+
+	middlewares := make(map[string]*cacheMiddleware.CacheMiddleware, len(h.gateway.cacheHandlers))
+	for route, entry := range h.gateway.cacheHandlers {
+		middlewares[route] = entry.handler.Middleware()
+	}
+	return middlewares
+}
+
+// decodeAdminRequest 解析管理接口的 JSON 请求体
+func decodeAdminRequest(ctx kratosHttp.Context, v interface{}) error {
+	decoder := json.NewDecoder(ctx.Request().Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,244 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	corsMiddleware "StructForge/backend/apps/gateway/internal/middleware/cors"
+	"StructForge/backend/apps/gateway/internal/router"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// testBreakerKey 测试上游实例的熔断器标识
+const testBreakerKey = "user-service/user-1"
+
+// newTestGateway 创建转发到测试上游服务的网关服务器（上游在响应头中返回收到的请求方法和路径）
+func newTestGateway(t *testing.T) (*kratosHttp.Server, *GatewayHandler, *router.Router) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Upstream-Method", req.Method)
+		w.Header().Set("X-Upstream-Path", req.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(upstream.Close)
+
+	staticDiscovery := discovery.NewStaticDiscovery()
+	staticDiscovery.RegisterService("user-service", []discovery.Instance{
+		{ID: "user-1", Host: "127.0.0.1", Port: upstream.Listener.Addr().(*net.TCPAddr).Port, Healthy: true},
+	})
+	gatewayRouter := router.NewRouter(staticDiscovery)
+	gatewayRouter.AddRoute(&router.Route{
+		Path:           "/api/v1/users",
+		MatchType:      "prefix",
+		Service:        "user-service",
+		CircuitBreaker: &conf.CircuitBreakerConfig{Enabled: true},
+	})
+
+	gatewayHandler := NewGatewayHandler(gatewayRouter, nil, corsMiddleware.NewCORSHandler(nil), nil)
+	srv := kratosHttp.NewServer()
+	gatewayHandler.RegisterRoutes(srv, NewDashboardHandler())
+	return srv, gatewayHandler, gatewayRouter
+}
+
+// newTestAdmin 创建注册了管理接口的服务器，并通过网关转发一次请求以创建实例熔断器
+func newTestAdmin(t *testing.T) (*kratosHttp.Server, *kratosHttp.Server, *router.Router) {
+	gatewaySrv, gatewayHandler, gatewayRouter := newTestGateway(t)
+	if resp := serve(gatewaySrv, http.MethodGet, "/api/v1/users/profile", ""); resp.Code != http.StatusOK {
+		t.Fatalf("转发请求失败: %d %s", resp.Code, resp.Body.String())
+	}
+
+	adminSrv := kratosHttp.NewServer()
+	NewAdminHandler(gatewayRouter, gatewayHandler).RegisterRoutes(adminSrv)
+	return adminSrv, gatewaySrv, gatewayRouter
+}
+
+// serve 向服务器发送请求并返回响应
+func serve(srv http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+// decodeData 解析统一响应中的 data 字段
+func decodeData(t *testing.T, resp *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	var body struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("解析响应失败: %v (%s)", err, resp.Body.String())
+	}
+	if err := json.Unmarshal(body.Data, v); err != nil {
+		t.Fatalf("解析响应数据失败: %v (%s)", err, body.Data)
+	}
+}
+
+// TestAdminHandlerList 测试查看路由、服务、熔断器、限流器和缓存
+func TestAdminHandlerList(t *testing.T) {
+	adminSrv, _, _ := newTestAdmin(t)
+
+	resp := serve(adminSrv, http.MethodGet, "/admin/routes", "")
+	var routes []router.Route
+	decodeData(t, resp, &routes)
+	if resp.Code != http.StatusOK || len(routes) != 1 || routes[0].Path != "/api/v1/users" {
+		t.Errorf("路由列表不正确: %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp = serve(adminSrv, http.MethodGet, "/admin/services", "")
+	var services map[string]ServiceHealth
+	decodeData(t, resp, &services)
+	if service := services["user-service"]; resp.Code != http.StatusOK || service.InstanceCount != 1 || service.Instances[0].ID != "user-1" {
+		t.Errorf("服务列表不正确: %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp = serve(adminSrv, http.MethodGet, "/admin/circuit-breakers", "")
+	var breakers map[string]map[string]interface{}
+	decodeData(t, resp, &breakers)
+	if resp.Code != http.StatusOK || breakers[testBreakerKey]["state"] != "closed" {
+		t.Errorf("熔断器列表不正确: %d %s", resp.Code, resp.Body.String())
+	}
+
+	for _, path := range []string{"/admin/rate-limits", "/admin/cache"} {
+		if resp := serve(adminSrv, http.MethodGet, path, ""); resp.Code != http.StatusOK {
+			t.Errorf("%s 应该返回 200，实际 %d", path, resp.Code)
+		}
+	}
+
+	if resp := serve(adminSrv, http.MethodGet, "/admin/unknown", ""); resp.Code != http.StatusNotFound {
+		t.Errorf("不存在的管理接口应该返回 404，实际 %d", resp.Code)
+	}
+}
+
+// TestAdminHandlerCircuitBreaker 测试强制设置和重置熔断器
+func TestAdminHandlerCircuitBreaker(t *testing.T) {
+	adminSrv, gatewaySrv, gatewayRouter := newTestAdmin(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"无效JSON", "/admin/circuit-breakers/force", `{`, http.StatusBadRequest},
+		{"未知字段", "/admin/circuit-breakers/force", `{"key":"` + testBreakerKey + `","state":"open","extra":1}`, http.StatusBadRequest},
+		{"缺少标识", "/admin/circuit-breakers/force", `{"state":"open"}`, http.StatusBadRequest},
+		{"无效状态", "/admin/circuit-breakers/force", `{"key":"` + testBreakerKey + `","state":"half_open"}`, http.StatusBadRequest},
+		{"熔断器不存在", "/admin/circuit-breakers/force", `{"key":"user-service/unknown","state":"open"}`, http.StatusNotFound},
+		{"重置缺少标识", "/admin/circuit-breakers/reset", `{}`, http.StatusBadRequest},
+		{"重置不存在的熔断器", "/admin/circuit-breakers/reset", `{"key":"user-service/unknown"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := serve(adminSrv, http.MethodPost, tt.path, tt.body); resp.Code != tt.status {
+				t.Errorf("应该返回 %d，实际 %d (%s)", tt.status, resp.Code, resp.Body.String())
+			}
+		})
+	}
+
+	// 强制打开后请求被熔断
+	resp := serve(adminSrv, http.MethodPost, "/admin/circuit-breakers/force", `{"key":"`+testBreakerKey+`","state":"open"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("强制打开熔断器失败: %d %s", resp.Code, resp.Body.String())
+	}
+	if stats := gatewayRouter.GetCircuitBreakerStats()[testBreakerKey]; stats["state"] != "open" || stats["forced"] != true {
+		t.Errorf("熔断器应该被强制打开: %v", stats)
+	}
+	if resp := serve(gatewaySrv, http.MethodGet, "/api/v1/users/profile", ""); resp.Code == http.StatusOK {
+		t.Error("熔断器强制打开后请求不应该被转发")
+	}
+
+	// 重置后恢复转发
+	resp = serve(adminSrv, http.MethodPost, "/admin/circuit-breakers/reset", `{"key":"`+testBreakerKey+`"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("重置熔断器失败: %d %s", resp.Code, resp.Body.String())
+	}
+	if stats := gatewayRouter.GetCircuitBreakerStats()[testBreakerKey]; stats["state"] != "closed" || stats["forced"] != false {
+		t.Errorf("熔断器应该被重置: %v", stats)
+	}
+	if resp := serve(gatewaySrv, http.MethodGet, "/api/v1/users/profile", ""); resp.Code != http.StatusOK {
+		t.Errorf("熔断器重置后请求应该被转发，实际 %d", resp.Code)
+	}
+}
+
+// TestAdminHandlerDrainInstance 测试摘除和恢复服务实例
+func TestAdminHandlerDrainInstance(t *testing.T) {
+	adminSrv, gatewaySrv, gatewayRouter := newTestAdmin(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"无效JSON", "/admin/instances/drain", `not json`, http.StatusBadRequest},
+		{"缺少实例", "/admin/instances/drain", `{"service":"user-service"}`, http.StatusBadRequest},
+		{"服务不存在", "/admin/instances/drain", `{"service":"unknown","instance":"user-1"}`, http.StatusNotFound},
+		{"恢复缺少服务", "/admin/instances/undrain", `{"instance":"user-1"}`, http.StatusBadRequest},
+		{"恢复未摘除的实例", "/admin/instances/undrain", `{"service":"user-service","instance":"user-1"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := serve(adminSrv, http.MethodPost, tt.path, tt.body); resp.Code != tt.status {
+				t.Errorf("应该返回 %d，实际 %d (%s)", tt.status, resp.Code, resp.Body.String())
+			}
+		})
+	}
+
+	// 摘除唯一的实例后请求不再转发到该实例
+	resp := serve(adminSrv, http.MethodPost, "/admin/instances/drain", `{"service":"user-service","instance":"user-1"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("摘除实例失败: %d %s", resp.Code, resp.Body.String())
+	}
+	if drained := gatewayRouter.DrainedInstances("user-service"); !slices.Equal(drained, []string{"user-1"}) {
+		t.Errorf("实例应该被摘除: %v", drained)
+	}
+	if resp := serve(gatewaySrv, http.MethodGet, "/api/v1/users/profile", ""); resp.Code == http.StatusOK {
+		t.Error("实例摘除后请求不应该被转发")
+	}
+
+	// 恢复后重新转发
+	resp = serve(adminSrv, http.MethodPost, "/admin/instances/undrain", `{"service":"user-service","instance":"user-1"}`)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("恢复实例失败: %d %s", resp.Code, resp.Body.String())
+	}
+	if drained := gatewayRouter.DrainedInstances("user-service"); len(drained) != 0 {
+		t.Errorf("实例应该被恢复: %v", drained)
+	}
+	if resp := serve(gatewaySrv, http.MethodGet, "/api/v1/users/profile", ""); resp.Code != http.StatusOK {
+		t.Errorf("实例恢复后请求应该被转发，实际 %d", resp.Code)
+	}
+}
+
+// TestAdminHandlerPurgeCache 测试清除缓存的参数校验
+func TestAdminHandlerPurgeCache(t *testing.T) {
+	adminSrv, _, _ := newTestAdmin(t)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"无效JSON", `[]`, http.StatusBadRequest},
+		{"缺少路径模式", `{"route":"/api/v1/users"}`, http.StatusBadRequest},
+		{"路由没有缓存", `{"pattern":"*","route":"/api/v1/users"}`, http.StatusNotFound},
+		{"清除全部", `{"pattern":"*"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := serve(adminSrv, http.MethodPost, "/admin/cache/purge", tt.body); resp.Code != tt.status {
+				t.Errorf("应该返回 %d，实际 %d (%s)", tt.status, resp.Code, resp.Body.String())
+			}
+		})
+	}
+}
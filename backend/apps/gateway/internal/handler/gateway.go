@@ -2,18 +2,30 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"StructForge/backend/apps/gateway/internal/conf"
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
 	cacheMiddleware "StructForge/backend/apps/gateway/internal/middleware/cache"
+	"StructForge/backend/apps/gateway/internal/middleware/concurrency"
 	corsMiddleware "StructForge/backend/apps/gateway/internal/middleware/cors"
 	jwtMiddleware "StructForge/backend/apps/gateway/internal/middleware/jwt"
 	loggingMiddleware "StructForge/backend/apps/gateway/internal/middleware/logging"
 	metricsMiddleware "StructForge/backend/apps/gateway/internal/middleware/metrics"
 	ratelimit "StructForge/backend/apps/gateway/internal/middleware/ratelimit"
 	"StructForge/backend/apps/gateway/internal/router"
+	"StructForge/backend/apps/gateway/internal/router/healthcheck"
 	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
 
 	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
 )
@@ -23,10 +35,19 @@ type GatewayHandler struct {
 	router        *router.Router
 	jwtManager    *jwtMiddleware.Manager
 	rateLimitMgr  *ratelimit.RateLimitManager
+	concurrency   *concurrency.Manager
+	adaptive      *concurrency.AdaptiveManager
 	corsHandler   *corsMiddleware.CORSHandler
 	requestLogger *loggingMiddleware.RequestLogger
 	metrics       *metricsMiddleware.MetricsMiddleware
-	cacheHandlers map[string]*cacheMiddleware.CacheHandler // 按路由路径存储缓存处理器
+	cacheHandlers map[string]*routeCacheHandler // 按路由标识存储缓存处理器
+	cacheMu       sync.Mutex
+}
+
+// routeCacheHandler 路由的缓存处理器及创建它的缓存配置（配置热更新后缓存配置变化时重新创建）
+type routeCacheHandler struct {
+	config  *conf.CacheConfig
+	handler *cacheMiddleware.CacheHandler
 }
 
 // HealthResponse 健康检查响应
@@ -38,28 +59,69 @@ type HealthResponse struct {
 	Uptime          string                            `json:"uptime,omitempty"`           // 运行时间（可选）
 	Services        map[string]ServiceHealth          `json:"services,omitempty"`         // 下游服务健康状态（可选）
 	CircuitBreakers map[string]map[string]interface{} `json:"circuit_breakers,omitempty"` // 熔断器状态（可选）
+	// 异常实例检测状态（按服务、实例，可选）
+	OutlierDetection map[string]map[string]map[string]interface{} `json:"outlier_detection,omitempty"`
 }
 
 // ServiceHealth 服务健康状态
 type ServiceHealth struct {
-	Status        string `json:"status"`         // 状态：ok, degraded, down
-	InstanceCount int    `json:"instance_count"` // 可用实例数
-	LastCheck     string `json:"last_check"`     // 最后检查时间
+	Status        string           `json:"status"`              // 状态：ok, degraded, down
+	InstanceCount int              `json:"instance_count"`      // 可用实例数
+	LastCheck     string           `json:"last_check"`          // 最后检查时间
+	Instances     []InstanceHealth `json:"instances,omitempty"` // 实例健康状态（可选）
+}
+
+// InstanceHealth 实例健康状态
+type InstanceHealth struct {
+	ID      string `json:"id"`      // 实例标识
+	Address string `json:"address"` // 实例地址
+	Healthy bool   `json:"healthy"` // 是否健康（包含主动健康检查结果）
+	// 主动健康检查状态（未启用主动健康检查或尚未检查时为空）
+	ActiveCheck *healthcheck.Status `json:"active_check,omitempty"`
+	// 是否已通过管理接口摘除（摘除的实例不再接收新请求）
+	Drained bool `json:"drained,omitempty"`
 }
 
 // NewGatewayHandler 创建Gateway处理器
 func NewGatewayHandler(router *router.Router, jwtManager *jwtMiddleware.Manager, corsHandler *corsMiddleware.CORSHandler, metrics *metricsMiddleware.MetricsMiddleware) *GatewayHandler {
+	// 并发数和等待队列长度变化时上报指标
+	concurrencyMgr := concurrency.NewManager(func(name string, inFlight, queued int) {
+		if metrics != nil {
+			metrics.SetConcurrency(name, inFlight, queued)
+		}
+	})
+
+	// 自适应并发上限调整时上报指标
+	adaptiveMgr := concurrency.NewAdaptiveManager(func(service string, limit int, p90 time.Duration) {
+		if metrics != nil {
+			metrics.SetAdaptiveLimit(service, limit, p90)
+		}
+	})
+
 	return &GatewayHandler{
 		router:        router,
 		jwtManager:    jwtManager,
 		rateLimitMgr:  ratelimit.NewRateLimitManager(),
+		concurrency:   concurrencyMgr,
+		adaptive:      adaptiveMgr,
 		corsHandler:   corsHandler,
 		requestLogger: loggingMiddleware.NewRequestLogger(),
 		metrics:       metrics,
-		cacheHandlers: make(map[string]*cacheMiddleware.CacheHandler),
+		cacheHandlers: make(map[string]*routeCacheHandler),
 	}
 }
 
+// proxyMethods 通用路由转发支持的 HTTP 方法（OPTIONS 也由 Proxy 处理）
+var proxyMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodPatch,
+	http.MethodHead,
+	http.MethodOptions,
+}
+
 // RegisterRoutes 注册路由
 func (h *GatewayHandler) RegisterRoutes(srv *kratosHttp.Server, dashboardHandler *DashboardHandler) {
 	// 注册健康检查路由（不需要认证）
@@ -70,6 +132,9 @@ func (h *GatewayHandler) RegisterRoutes(srv *kratosHttp.Server, dashboardHandler
 	srv.Route("/").GET("/ready", h.Readiness)
 	srv.Route("/").GET("/live", h.Liveness)
 
+	// 注册指标路由
+	h.RegisterMetricsRoutes(srv)
+
 	// 注册 Dashboard 路由（直接处理，不需要代理）
 	dashboardRoute := srv.Route("/api/v1/dashboard")
 	dashboardRoute.GET("/stats", dashboardHandler.GetStats)
@@ -78,32 +143,12 @@ func (h *GatewayHandler) RegisterRoutes(srv *kratosHttp.Server, dashboardHandler
 	dashboardRoute.GET("/error-trend", dashboardHandler.GetErrorTrend)
 	dashboardRoute.GET("/execution-duration", dashboardHandler.GetExecutionDuration)
 
-	// 注册通用路由转发（使用通配符匹配所有 /api/v1/* 请求）
-	// 注意：Kratos 的路由匹配机制
-	// 由于 {path} 可能无法匹配多级路径（如 /api/v1/users/register），
-	// 我们需要注册更具体的路由，或者使用不同的路由语法
-	apiRoute := srv.Route("/api/v1")
-
-	// 注册 users 服务的路由
-	// 注意：Kratos 的 {path} 参数可能只能匹配单级路径
-	// 对于多级路径，需要注册更具体的路由
-	usersRoute := srv.Route("/api/v1/users")
-	usersRoute.GET("/{path}", h.Proxy)
-	usersRoute.POST("/{path}", h.Proxy)
-	usersRoute.PUT("/{path}", h.Proxy)
-	usersRoute.DELETE("/{path}", h.Proxy)
-	usersRoute.PATCH("/{path}", h.Proxy)
-	usersRoute.HEAD("/{path}", h.Proxy)
-	usersRoute.OPTIONS("/{path}", h.Proxy)
-
-	// 同时注册根路径的通配符路由，作为后备
-	apiRoute.GET("/{path}", h.Proxy)
-	apiRoute.POST("/{path}", h.Proxy)
-	apiRoute.PUT("/{path}", h.Proxy)
-	apiRoute.DELETE("/{path}", h.Proxy)
-	apiRoute.PATCH("/{path}", h.Proxy)
-	apiRoute.HEAD("/{path}", h.Proxy)
-	apiRoute.OPTIONS("/{path}", h.Proxy) // OPTIONS 也由 Proxy 处理
+	// 注册通用路由转发（{path:.*} 可匹配多级路径，必须在其他路由之后注册）
+	// 具体转发到哪个服务完全由配置的路由规则（Router.FindRoute）决定，新增服务只需修改配置
+	proxyRoute := srv.Route("/")
+	for _, method := range proxyMethods {
+		proxyRoute.Handle(method, "/{path:.*}", h.Proxy)
+	}
 }
 
 // Health 健康检查接口
@@ -143,6 +188,19 @@ func (h *GatewayHandler) Health(ctx kratosHttp.Context) error {
 		}
 	}
 
+	// 获取异常实例检测状态（有实例被驱逐时网关状态为 degraded）
+	outlierStats := h.router.GetOutlierStats()
+	if len(outlierStats) > 0 {
+		response.OutlierDetection = outlierStats
+		for _, instances := range outlierStats {
+			for _, stats := range instances {
+				if ejected, ok := stats["ejected"].(bool); ok && ejected && response.Status == "ok" {
+					response.Status = "degraded"
+				}
+			}
+		}
+	}
+
 	return ctx.JSON(200, response)
 }
 
@@ -187,12 +245,25 @@ func (h *GatewayHandler) checkDownstreamServices(ctx context.Context) map[string
 			continue
 		}
 
-		// 统计健康实例数
+		// 统计健康实例数，并附带各实例的主动健康检查状态
 		healthyCount := 0
+		checkStatuses := h.router.GetHealthCheckStatus(serviceName)
+		drained := h.router.DrainedInstances(serviceName)
+		instanceHealth := make([]InstanceHealth, 0, len(instances))
 		for _, instance := range instances {
 			if instance.Healthy {
 				healthyCount++
 			}
+			item := InstanceHealth{
+				ID:      instance.Key(),
+				Address: net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port)),
+				Healthy: instance.Healthy,
+				Drained: slices.Contains(drained, instance.Key()),
+			}
+			if status, ok := checkStatuses[instance.Key()]; ok {
+				item.ActiveCheck = &status
+			}
+			instanceHealth = append(instanceHealth, item)
 		}
 
 		// 确定服务状态
@@ -207,6 +278,7 @@ func (h *GatewayHandler) checkDownstreamServices(ctx context.Context) map[string
 			Status:        status,
 			InstanceCount: healthyCount,
 			LastCheck:     time.Now().Format(time.RFC3339),
+			Instances:     instanceHealth,
 		}
 	}
 
@@ -248,8 +320,10 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 	// 将 TraceID 注入到 Context 中
 	requestCtx = context.WithValue(requestCtx, log.CtxTraceID, traceID)
 	requestCtx = context.WithValue(requestCtx, log.CtxRequestID, traceID)
-	// 注意：Kratos 的 Context 不支持直接更新 Request 的 Context
-	// 我们通过传递 requestCtx 来使用带 TraceID 的 Context
+	// 解析客户端真实IP（根据可信代理配置）并注入到 Context 中
+	requestCtx = clientip.NewContext(requestCtx, h.router.ClientIP(ctx.Request()))
+	// 同时更新请求的 Context，转发时可以获取 TraceID（日志、请求头模板）
+	ctx.Reset(ctx.Response(), ctx.Request().WithContext(requestCtx))
 
 	// 在响应头中添加 TraceID
 	ctx.Response().Header().Set("X-Trace-ID", traceID)
@@ -279,7 +353,7 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 	}
 
 	// 查找匹配的路由
-	route := h.router.FindRoute(path)
+	route := h.router.FindRoute(ctx.Request())
 	if route == nil {
 		log.Warn(requestCtx, "未找到匹配的路由",
 			log.String("path", path),
@@ -291,37 +365,14 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 		return ctx.JSON(404, ErrNotFound(requestCtx))
 	}
 
-	// 检查缓存（仅在 GET 请求且配置了缓存时）
+	// WebSocket 升级请求（仅在路由启用 websocket 时处理）
+	webSocketUpgrade := route.WebSocket && router.IsWebSocketUpgrade(ctx.Request())
+
+	// 检查缓存（仅在 GET 请求且配置了缓存时，SSE 事件流和 WebSocket 请求不走缓存）
 	var cacheHandler *cacheMiddleware.CacheHandler
-	if route.Cache != nil && route.Cache.Enabled {
+	if route.Cache != nil && route.Cache.Enabled && !webSocketUpgrade && !router.AcceptsEventStream(ctx.Request()) {
 		// 获取或创建缓存处理器
-		cacheHandlerKey := route.Path
-		if handler, exists := h.cacheHandlers[cacheHandlerKey]; exists {
-			cacheHandler = handler
-		} else {
-			// 转换配置类型
-			cacheConfig := &cacheMiddleware.CacheConfig{
-				Enabled:            route.Cache.Enabled,
-				TTL:                route.Cache.TTL,
-				KeyPrefix:          route.Cache.KeyPrefix,
-				Methods:            route.Cache.Methods,
-				Paths:              route.Cache.Paths,
-				ExcludePaths:       route.Cache.ExcludePaths,
-				IncludeQueryParams: route.Cache.IncludeQueryParams,
-				IncludeHeaders:     route.Cache.IncludeHeaders,
-			}
-			// 创建新的缓存中间件和处理器
-			cacheMid, err := cacheMiddleware.NewCacheMiddleware(cacheConfig)
-			if err != nil {
-				log.Warn(ctx, "创建缓存中间件失败",
-					log.ErrorField(err),
-					log.String("path", path),
-				)
-			} else {
-				cacheHandler = cacheMiddleware.NewCacheHandler(cacheMid)
-				h.cacheHandlers[cacheHandlerKey] = cacheHandler
-			}
-		}
+		cacheHandler = h.routeCacheHandler(ctx, route)
 
 		// 检查缓存
 		if cacheHandler != nil {
@@ -367,15 +418,22 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 		}
 	}
 
-	// 检查限流（在认证之前检查，避免浪费资源）
+	// 解析身份（携带 Token 时先验证，限流可以按已认证用户区分配额）
+	claims, authErrResp := h.authenticate(requestCtx, ctx.Request())
+	if claims != nil {
+		// 将用户身份存入请求 Context（供限流、流量拆分、请求头模板等按用户处理的逻辑使用）
+		requestCtx = gatewayMiddleware.WithUser(requestCtx, claims.UserID, claims.Username, claims.Roles)
+		ctx.Reset(ctx.Response(), ctx.Request().WithContext(requestCtx))
+	}
+
+	// 检查限流（在认证失败返回之前检查，避免无效 Token 绕过限流）
 	if route.RateLimit != nil {
-		allowed, err := ratelimit.CheckRateLimit(
+		result, err := ratelimit.CheckRateLimit(
 			requestCtx,
 			h.rateLimitMgr,
+			ctx.Request(),
 			path,
-			route.RateLimit.QPS,
-			route.RateLimit.Burst,
-			route.RequireAuth,
+			rateLimitPolicy(route.RateLimit),
 		)
 		if err != nil {
 			log.Warn(requestCtx, "限流检查失败",
@@ -383,7 +441,9 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 				log.String("path", path),
 			)
 		}
-		if !allowed {
+		// 写入 RateLimit-* / Retry-After 响应头，客户端据此退避
+		ratelimit.SetHeaders(ctx.Response().Header(), result)
+		if !result.Allowed {
 			if h.metrics != nil {
 				h.metrics.RecordRateLimit(requestCtx, path)
 				duration := time.Since(startTime)
@@ -394,41 +454,39 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 	}
 
 	// 检查是否需要认证
-	if route.RequireAuth {
-		// 获取 Authorization 头
-		authHeader := ctx.Request().Header.Get("Authorization")
-		if authHeader == "" {
-			if h.metrics != nil {
-				duration := time.Since(startTime)
-				h.metrics.RecordRequest(requestCtx, method, path, 401, duration, requestSize, 0)
-			}
-			return ctx.JSON(401, ErrUnauthorized(requestCtx))
+	if route.RequireAuth && claims == nil {
+		if h.metrics != nil {
+			duration := time.Since(startTime)
+			h.metrics.RecordRequest(requestCtx, method, path, 401, duration, requestSize, 0)
 		}
+		return ctx.JSON(401, authErrResp)
+	}
 
-		// 解析 Bearer Token
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			if h.metrics != nil {
-				duration := time.Since(startTime)
-				h.metrics.RecordRequest(requestCtx, method, path, 401, duration, requestSize, 0)
-			}
-			return ctx.JSON(401, ErrInvalidAuth(requestCtx))
+	// 并发限制（路由级、服务级）：并发数已满时排队等待，队列已满或排队超时返回 503
+	release, overloadResp := h.acquireConcurrency(requestCtx, route)
+	if overloadResp != nil {
+		if h.metrics != nil {
+			duration := time.Since(startTime)
+			h.metrics.RecordRequest(requestCtx, method, path, 503, duration, requestSize, 0)
 		}
+		return ctx.JSON(503, overloadResp)
+	}
+	defer release()
 
-		token := parts[1]
-
-		// 验证 Token
-		_, err := h.jwtManager.ValidateToken(token)
-		if err != nil {
-			log.Warn(requestCtx, "Token 验证失败",
-				log.ErrorField(err),
-			)
-			if h.metrics != nil {
-				duration := time.Since(startTime)
-				h.metrics.RecordRequest(requestCtx, method, path, 401, duration, requestSize, 0)
-			}
-			return ctx.JSON(401, ErrInvalidToken(requestCtx, err))
+	// 自适应并发限制（按服务，下游延迟升高时按优先级削减请求）
+	adaptiveDone, overloadResp := h.acquireAdaptive(requestCtx, route)
+	if overloadResp != nil {
+		if h.metrics != nil {
+			duration := time.Since(startTime)
+			h.metrics.RecordRequest(requestCtx, method, path, 503, duration, requestSize, 0)
 		}
+		return ctx.JSON(503, overloadResp)
+	}
+
+	// WebSocket 升级：限流、认证和并发限制已在握手阶段完成，建立双向隧道
+	// 隧道占用路由级、服务级并发名额直到关闭；自适应并发槽位在握手完成后释放并按握手耗时统计延迟
+	if webSocketUpgrade {
+		return h.proxyWebSocket(ctx, route, requestCtx, startTime, requestSize, adaptiveDone)
 	}
 
 	// 转发请求
@@ -447,6 +505,12 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 	err := h.router.Forward(ctx, route, cacheCallback)
 	downstreamDuration := time.Since(downstreamStartTime)
 
+	// 反馈下游延迟（与 RecordDownstream 使用相同的耗时）；SSE 事件流（按上游响应的 Content-Type 判断）
+	// 和非超时的失败不计入延迟统计
+	if adaptiveDone != nil {
+		adaptiveDone(downstreamDuration, !router.IsEventStream(ctx.Response().Header()) && (err == nil || isTimeoutError(err)))
+	}
+
 	if err != nil {
 		// 根据错误类型确定状态码和错误响应
 		statusCode := 500
@@ -510,3 +574,237 @@ func (h *GatewayHandler) Proxy(ctx kratosHttp.Context) error {
 
 	return nil
 }
+
+// routeCacheHandler 获取或创建路由的缓存处理器（路由缓存配置变化时重新创建，创建失败时返回 nil）
+func (h *GatewayHandler) routeCacheHandler(ctx context.Context, route *router.Route) *cacheMiddleware.CacheHandler {
+	h.cacheMu.Lock()
+	defer h.cacheMu.Unlock()
+
+	cacheHandlerKey := route.Key
+	if entry, exists := h.cacheHandlers[cacheHandlerKey]; exists {
+		if entry.config == route.Cache {
+			return entry.handler
+		}
+		// 路由热更新后缓存配置内容未变化时沿用原缓存处理器（保留已缓存的响应）
+		if reflect.DeepEqual(entry.config, route.Cache) {
+			entry.config = route.Cache
+			return entry.handler
+		}
+	}
+
+	// 转换配置类型
+	cacheConfig := &cacheMiddleware.CacheConfig{
+		Enabled:            route.Cache.Enabled,
+		TTL:                route.Cache.TTL,
+		KeyPrefix:          route.Cache.KeyPrefix,
+		Methods:            route.Cache.Methods,
+		Paths:              route.Cache.Paths,
+		ExcludePaths:       route.Cache.ExcludePaths,
+		IncludeQueryParams: route.Cache.IncludeQueryParams,
+		IncludeHeaders:     route.Cache.IncludeHeaders,
+	}
+	// 创建新的缓存中间件和处理器
+	cacheMid, err := cacheMiddleware.NewCacheMiddleware(cacheConfig)
+	if err != nil {
+		log.Warn(ctx, "创建缓存中间件失败",
+			log.ErrorField(err),
+			log.String("path", route.Path),
+		)
+		return nil
+	}
+	cacheHandler := cacheMiddleware.NewCacheHandler(cacheMid)
+	h.cacheHandlers[cacheHandlerKey] = &routeCacheHandler{config: route.Cache, handler: cacheHandler}
+	return cacheHandler
+}
+
+// authenticate 解析并验证请求中的 Bearer Token
+// 验证成功返回声明；未携带或验证失败时返回 nil 和需要认证时应返回的错误响应
+func (h *GatewayHandler) authenticate(requestCtx context.Context, req *http.Request) (*jwtMiddleware.JWTClaims, *StandardResponse) {
+	// 获取 Authorization 头
+	authHeader := req.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, ErrUnauthorized(requestCtx)
+	}
+
+	// 解析 Bearer Token
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return nil, ErrInvalidAuth(requestCtx)
+	}
+
+	// 验证 Token
+	claims, err := h.jwtManager.ValidateToken(parts[1])
+	if err != nil {
+		log.Warn(requestCtx, "Token 验证失败",
+			log.ErrorField(err),
+		)
+		return nil, ErrInvalidToken(requestCtx, err)
+	}
+
+	return claims, nil
+}
+
+// acquireConcurrency 依次获取路由级和服务级并发槽位
+// 成功时返回释放函数；任一限制器拒绝时释放已获取的槽位并返回错误响应
+func (h *GatewayHandler) acquireConcurrency(requestCtx context.Context, route *router.Route) (func(), *StandardResponse) {
+	limits := []struct {
+		name   string
+		config *conf.ConcurrencyConfig
+	}{
+		{"route:" + route.Key, route.Concurrency},
+		{"service:" + route.Service, h.router.ServiceConcurrency(route.Service)},
+	}
+
+	releases := make([]func(), 0, len(limits))
+	releaseAll := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	for _, limit := range limits {
+		if limit.config == nil || limit.config.MaxConcurrency <= 0 {
+			continue
+		}
+
+		limiter := h.concurrency.GetLimiter(limit.name, &concurrency.Config{
+			MaxConcurrency: limit.config.MaxConcurrency,
+			QueueSize:      limit.config.QueueSize,
+			QueueTimeout:   time.Duration(limit.config.QueueTimeout) * time.Second,
+		})
+		release, wait, err := limiter.Acquire(requestCtx)
+		if wait > 0 && h.metrics != nil {
+			h.metrics.RecordConcurrencyWait(requestCtx, limit.name, wait)
+		}
+		if err != nil {
+			releaseAll()
+			if h.metrics != nil {
+				h.metrics.RecordConcurrencyRejected(requestCtx, limit.name, concurrencyRejectReason(err))
+			}
+			return nil, ErrConcurrencyLimit(requestCtx, fmt.Errorf("%s: %w", limit.name, err))
+		}
+		releases = append(releases, release)
+	}
+
+	return releaseAll, nil
+}
+
+// acquireAdaptive 获取服务的自适应并发槽位（未启用时返回 nil 完成回调）
+func (h *GatewayHandler) acquireAdaptive(requestCtx context.Context, route *router.Route) (func(time.Duration, bool), *StandardResponse) {
+	config := h.router.AdaptiveConcurrency(route.Service)
+	if config == nil {
+		return nil, nil
+	}
+
+	limiter := h.adaptive.GetLimiter(route.Service, &concurrency.AdaptiveConfig{
+		InitialLimit: config.InitialLimit,
+		MinLimit:     config.MinLimit,
+		MaxLimit:     config.MaxLimit,
+		Tolerance:    config.Tolerance,
+		Backoff:      config.Backoff,
+		WindowSize:   config.WindowSize,
+	})
+
+	priority := route.ShedPriority
+	if priority == "" {
+		priority = concurrency.PriorityNormal
+	}
+	done, err := limiter.Acquire(priority)
+	if err != nil {
+		if h.metrics != nil {
+			h.metrics.RecordAdaptiveShed(requestCtx, route.Service, priority)
+		}
+		return nil, ErrConcurrencyLimit(requestCtx, fmt.Errorf("service:%s: %w", route.Service, err))
+	}
+	return done, nil
+}
+
+// concurrencyRejectReason 并发限制拒绝原因（用于指标标签）
+func concurrencyRejectReason(err error) string {
+	switch {
+	case errors.Is(err, concurrency.ErrQueueFull):
+		return "queue_full"
+	case errors.Is(err, concurrency.ErrQueueTimeout):
+		return "queue_timeout"
+	default:
+		return "canceled"
+	}
+}
+
+// rateLimitPolicy 将路由限流配置转换为限流策略
+func rateLimitPolicy(config *router.RateLimitConfig) *ratelimit.Policy {
+	policy := &ratelimit.Policy{
+		Type:  config.Type,
+		QPS:   config.QPS,
+		Burst: config.Burst,
+		KeyBy: config.KeyBy,
+	}
+	if config.Anonymous != nil {
+		policy.Anonymous = &ratelimit.Tier{QPS: config.Anonymous.QPS, Burst: config.Anonymous.Burst}
+	}
+	if config.Authenticated != nil {
+		policy.Authenticated = &ratelimit.Tier{QPS: config.Authenticated.QPS, Burst: config.Authenticated.Burst}
+	}
+	return policy
+}
+
+// proxyWebSocket 代理 WebSocket 连接（握手失败时返回错误响应，隧道建立后阻塞直到连接关闭）
+// adaptiveDone 不为 nil 时在握手完成后（或握手失败时）调用，隧道存续期间不占用自适应并发槽位
+func (h *GatewayHandler) proxyWebSocket(ctx kratosHttp.Context, route *router.Route, requestCtx context.Context, startTime time.Time, requestSize int64, adaptiveDone func(time.Duration, bool)) error {
+	method := ctx.Request().Method
+	path := ctx.Request().URL.Path
+
+	opened := false
+	err := h.router.ForwardWebSocket(ctx, route, func() {
+		opened = true
+		if adaptiveDone != nil {
+			adaptiveDone(time.Since(startTime), true)
+		}
+		if h.metrics != nil {
+			h.metrics.RecordDownstream(requestCtx, route.Service, 101, time.Since(startTime))
+			h.metrics.RecordRequest(requestCtx, method, path, 101, time.Since(startTime), requestSize, 0)
+			h.metrics.RecordWebSocketOpened(requestCtx, route.Service)
+		}
+	})
+
+	if opened {
+		if h.metrics != nil {
+			h.metrics.RecordWebSocketClosed(requestCtx, route.Service)
+		}
+		h.requestLogger.LogRequest(ctx, startTime)
+		return nil
+	}
+
+	// 未建立隧道时释放自适应并发槽位，握手失败或被拒绝不计入延迟统计
+	if adaptiveDone != nil {
+		adaptiveDone(time.Since(startTime), false)
+	}
+
+	if err != nil {
+		statusCode := 502
+		errorResp := NewErrorResponse(requestCtx, statusCode, "WebSocket 连接失败", err)
+		if strings.Contains(err.Error(), "没有可用实例") {
+			statusCode = 503
+			errorResp = ErrNoServiceInstance(requestCtx, route.Service)
+		}
+
+		log.Error(requestCtx, "WebSocket 转发失败",
+			log.ErrorField(err),
+			log.String("path", path),
+			log.String("service", route.Service),
+		)
+		h.requestLogger.LogError(ctx, err, startTime)
+		if h.metrics != nil {
+			h.metrics.RecordDownstream(requestCtx, route.Service, statusCode, time.Since(startTime))
+			h.metrics.RecordRequest(requestCtx, method, path, statusCode, time.Since(startTime), requestSize, 0)
+		}
+		return ctx.JSON(statusCode, errorResp)
+	}
+
+	// 上游拒绝升级，响应已写回客户端
+	h.requestLogger.LogRequest(ctx, startTime)
+	if h.metrics != nil {
+		h.metrics.RecordRequest(requestCtx, method, path, 200, time.Since(startTime), requestSize, 0)
+	}
+	return nil
+}
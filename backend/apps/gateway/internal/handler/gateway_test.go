@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	"StructForge/backend/apps/gateway/internal/router"
+)
+
+// TestRegisterRoutesProxy 测试所有转发方法和多级路径都由通用路由转发
+func TestRegisterRoutesProxy(t *testing.T) {
+	srv, _, _ := newTestGateway(t)
+
+	methods := []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	paths := []string{"/api/v1/users", "/api/v1/users/1", "/api/v1/users/1/profile/settings"}
+	for _, method := range methods {
+		for _, path := range paths {
+			resp := serve(srv, method, path, "")
+			if resp.Code != http.StatusOK {
+				t.Errorf("%s %s 应该被转发，实际 %d (%s)", method, path, resp.Code, resp.Body.String())
+				continue
+			}
+			if got := resp.Header().Get("X-Upstream-Method"); got != method {
+				t.Errorf("%s %s 转发的方法不正确: %s", method, path, got)
+			}
+			if got := resp.Header().Get("X-Upstream-Path"); got != path {
+				t.Errorf("%s %s 转发的路径不正确: %s", method, path, got)
+			}
+		}
+	}
+
+	// OPTIONS 预检请求由 Proxy 交给 CORS 处理器处理
+	if resp := serve(srv, http.MethodOptions, "/api/v1/users/1/profile", ""); resp.Code == http.StatusNotFound || resp.Code == http.StatusMethodNotAllowed {
+		t.Errorf("OPTIONS 请求应该由 Proxy 处理，实际 %d", resp.Code)
+	}
+
+	// 没有匹配的路由时返回 404
+	if resp := serve(srv, http.MethodGet, "/api/v2/orders/1", ""); resp.Code != http.StatusNotFound || resp.Header().Get("X-Trace-ID") == "" {
+		t.Errorf("未配置的路径应该由 Proxy 返回 404，实际 %d", resp.Code)
+	}
+}
+
+// TestRegisterRoutesOwnHandlers 测试健康检查、指标和 Dashboard 路由不被通用路由覆盖
+func TestRegisterRoutesOwnHandlers(t *testing.T) {
+	srv, _, _ := newTestGateway(t)
+
+	paths := []string{
+		"/health",
+		"/api/v1/health",
+		"/ready",
+		"/live",
+		"/metrics",
+		"/api/v1/dashboard/stats",
+		"/api/v1/dashboard/executions",
+		"/api/v1/dashboard/success-rate",
+		"/api/v1/dashboard/error-trend",
+		"/api/v1/dashboard/execution-duration",
+	}
+	for _, path := range paths {
+		resp := serve(srv, http.MethodGet, path, "")
+		if resp.Code != http.StatusOK {
+			t.Errorf("%s 应该返回 200，实际 %d (%s)", path, resp.Code, resp.Body.String())
+		}
+		// Proxy 处理的请求会设置 X-Trace-ID 响应头
+		if resp.Header().Get("X-Trace-ID") != "" || resp.Header().Get("X-Upstream-Path") != "" {
+			t.Errorf("%s 不应该由 Proxy 处理", path)
+		}
+	}
+}
+
+// TestAcquireConcurrencyPerRoute 测试同一路径的多条路由分别使用各自的并发限制
+func TestAcquireConcurrencyPerRoute(t *testing.T) {
+	_, gatewayHandler, gatewayRouter := newTestGateway(t)
+	canary := &router.Route{
+		Path:        "/api/v1/users",
+		MatchType:   "prefix",
+		Service:     "user-service",
+		Headers:     []conf.RouteMatchCondition{{Name: "X-Canary", Value: "true"}},
+		Concurrency: &conf.ConcurrencyConfig{MaxConcurrency: 1},
+	}
+	stable := &router.Route{
+		Path:        "/api/v1/users",
+		MatchType:   "prefix",
+		Service:     "user-service",
+		Concurrency: &conf.ConcurrencyConfig{MaxConcurrency: 2},
+	}
+	gatewayRouter.AddRoute(canary)
+	gatewayRouter.AddRoute(stable)
+	if canary.Key == stable.Key {
+		t.Fatalf("同一路径的路由标识应该不同: %s", canary.Key)
+	}
+
+	ctx := context.Background()
+	acquire := func(route *router.Route) bool {
+		_, errResp := gatewayHandler.acquireConcurrency(ctx, route)
+		return errResp == nil
+	}
+
+	// 交替获取两条路由的槽位，限制器不应该互相覆盖
+	if !acquire(canary) || !acquire(stable) || !acquire(stable) {
+		t.Fatal("并发数未满时应该获取成功")
+	}
+	if acquire(canary) {
+		t.Error("灰度路由的并发数已满，应该被拒绝")
+	}
+	if acquire(stable) {
+		t.Error("稳定路由的并发数已满，应该被拒绝")
+	}
+}
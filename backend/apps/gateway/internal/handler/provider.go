@@ -10,6 +10,7 @@ import (
 var ProviderSet = wire.NewSet(
 	NewGatewayHandler,
 	NewDashboardHandler,
+	NewAdminHandler,
 	metricsMiddleware.NewMetrics,
 	metricsMiddleware.NewMetricsMiddleware,
 	// 注意：router.ProviderSet 在 wire.go 中已经包含，这里不需要重复引入
@@ -34,6 +34,7 @@ const (
 	ErrorTypeAuth         ErrorType = "auth"          // 认证错误
 	ErrorTypeRateLimit    ErrorType = "rate_limit"    // 限流错误
 	ErrorTypeCircuitBreak ErrorType = "circuit_break" // 熔断错误
+	ErrorTypeOverload     ErrorType = "overload"      // 过载错误（并发数已满）
 	ErrorTypeNotFound     ErrorType = "not_found"     // 未找到错误
 	ErrorTypeInternal     ErrorType = "internal"      // 内部错误
 )
@@ -64,6 +65,7 @@ const (
 	CodeInvalidToken       = 2007 // 无效或过期的令牌
 	CodeCacheError         = 2008 // 缓存错误
 	CodeConfigError        = 2009 // 配置错误
+	CodeConcurrencyLimit   = 2010 // 并发请求数已达上限
 )
 
 // generateTraceID 生成追踪ID
@@ -167,6 +169,8 @@ func formatError(err error, errorType ErrorType) string {
 		return fmt.Sprintf("认证错误: %s", errMsg)
 	case ErrorTypeRateLimit:
 		return fmt.Sprintf("限流错误: %s", errMsg)
+	case ErrorTypeOverload:
+		return fmt.Sprintf("服务过载: %s", errMsg)
 	case ErrorTypeNotFound:
 		return fmt.Sprintf("资源不存在: %s", errMsg)
 	default:
@@ -256,6 +260,8 @@ func getErrorCode(httpCode int, errorType ErrorType) int {
 		return CodeInvalidToken
 	case ErrorTypeRateLimit:
 		return CodeRateLimit
+	case ErrorTypeOverload:
+		return CodeConcurrencyLimit
 	case ErrorTypeBusiness:
 		return CodeDownstreamError
 	default:
@@ -291,6 +297,10 @@ func ErrRateLimit(ctx context.Context) *StandardResponse {
 	return ErrorResponse(ctx, CodeRateLimit, "请求过于频繁，请稍后再试", nil, ErrorTypeRateLimit)
 }
 
+func ErrConcurrencyLimit(ctx context.Context, err error) *StandardResponse {
+	return ErrorResponse(ctx, CodeConcurrencyLimit, "服务繁忙，请稍后再试", err, ErrorTypeOverload)
+}
+
 func ErrServiceUnavailable(ctx context.Context, err error) *StandardResponse {
 	return ErrorResponse(ctx, CodeServiceUnavailable, "服务暂时不可用", err, ErrorTypeInternal)
 }
@@ -25,6 +25,21 @@ func GetUsername(ctx context.Context) (string, bool) {
 	return username, ok
 }
 
+// GetRoles 从 Context 中获取用户角色
+type rolesKey struct{}
+
+func GetRoles(ctx context.Context) ([]string, bool) {
+	roles, ok := ctx.Value(rolesKey{}).([]string)
+	return roles, ok
+}
+
+// WithUser 将用户ID、用户名和角色存入 Context
+func WithUser(ctx context.Context, userID int64, username string, roles []string) context.Context {
+	ctx = context.WithValue(ctx, userIDKey{}, userID)
+	ctx = context.WithValue(ctx, usernameKey{}, username)
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
 // AuthMiddleware JWT 认证中间件（用于 Kratos HTTP 中间件链）
 func AuthMiddleware(jwtManager *jwtMiddleware.Manager) middleware.Middleware {
 	return func(handler middleware.Handler) middleware.Handler {
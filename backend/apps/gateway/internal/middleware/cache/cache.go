@@ -5,9 +5,12 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"StructForge/backend/common/cache"
@@ -50,6 +53,34 @@ func DefaultCacheConfig() *CacheConfig {
 type CacheMiddleware struct {
 	config *CacheConfig
 	cache  cache.Cache
+
+	// 按请求路径记录本实例写入的缓存键及其过期时间（缓存键为哈希值，按路径使缓存失效时需要此映射）
+	entries map[string]map[string]time.Time
+	mu      sync.Mutex
+
+	// 统计
+	hits        atomic.Int64
+	misses      atomic.Int64
+	stores      atomic.Int64
+	invalidated atomic.Int64
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	// 命中次数
+	Hits int64 `json:"hits"`
+	// 未命中次数
+	Misses int64 `json:"misses"`
+	// 命中率
+	HitRate float64 `json:"hit_rate"`
+	// 写入次数
+	Stores int64 `json:"stores"`
+	// 被清除的缓存条目数
+	Invalidated int64 `json:"invalidated"`
+	// 本实例写入且未过期的缓存条目数
+	Entries int `json:"entries"`
+	// 有缓存条目的请求路径数
+	Paths int `json:"paths"`
 }
 
 // NewCacheMiddleware 创建缓存中间件
@@ -76,8 +107,9 @@ func NewCacheMiddleware(config *CacheConfig) (*CacheMiddleware, error) {
 	}
 
 	return &CacheMiddleware{
-		config: config,
-		cache:  cacheInstance,
+		config:  config,
+		cache:   cacheInstance,
+		entries: make(map[string]map[string]time.Time),
 	}, nil
 }
 
@@ -87,6 +119,8 @@ type CachedResponse struct {
 	Headers    map[string][]string `json:"headers"`
 	Body       []byte              `json:"body"`
 	CachedAt   time.Time           `json:"cached_at"`
+	// 请求路径（用于按路径使缓存失效）
+	Path string `json:"path,omitempty"`
 }
 
 // ShouldCache 检查是否应该缓存此请求
@@ -216,10 +250,12 @@ func (m *CacheMiddleware) Get(ctx context.Context, key string) (*CachedResponse,
 	data, err := m.cache.Get(ctx, key)
 	if err != nil {
 		if err == cache.ErrNotFound {
+			m.misses.Add(1)
 			return nil, nil // 缓存未命中
 		}
 		return nil, err
 	}
+	m.hits.Add(1)
 
 	// 反序列化
 	var cachedResp CachedResponse
@@ -238,7 +274,34 @@ func (m *CacheMiddleware) Set(ctx context.Context, key string, response *CachedR
 		return fmt.Errorf("序列化缓存数据失败: %w", err)
 	}
 
-	return m.cache.Set(ctx, key, data, time.Duration(m.config.TTL)*time.Second)
+	ttl := time.Duration(m.config.TTL) * time.Second
+	if err := m.cache.Set(ctx, key, data, ttl); err != nil {
+		return err
+	}
+	m.stores.Add(1)
+	if response.Path != "" {
+		m.track(response.Path, key, time.Now().Add(ttl))
+	}
+	return nil
+}
+
+// track 记录请求路径对应的缓存键，并清理该路径下已过期的记录
+func (m *CacheMiddleware) track(path, key string, expiresAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys, exists := m.entries[path]
+	if !exists {
+		keys = make(map[string]time.Time)
+		m.entries[path] = keys
+	}
+	now := time.Now()
+	for k, expiry := range keys {
+		if now.After(expiry) {
+			delete(keys, k)
+		}
+	}
+	keys[key] = expiresAt
 }
 
 // Delete 删除缓存
@@ -246,14 +309,67 @@ func (m *CacheMiddleware) Delete(ctx context.Context, key string) error {
 	return m.cache.Delete(ctx, key)
 }
 
-// InvalidateByPath 根据路径模式使缓存失效
+// InvalidateByPath 根据路径模式使缓存失效（支持精确路径、/api/* 前缀和 *.json 后缀，* 清除全部）
+// 只能清除本实例写入的缓存条目，多个网关实例共享缓存时需要在每个实例上执行
 func (m *CacheMiddleware) InvalidateByPath(ctx context.Context, pathPattern string) error {
-	// 注意：这是一个简化实现
-	// 实际应该维护路径到缓存键的映射，或者使用支持模式匹配的缓存系统
-	log.Warn(ctx, "按路径使缓存失效功能需要维护路径到缓存键的映射",
+	m.mu.Lock()
+	var keys []string
+	for path, pathKeys := range m.entries {
+		if !m.matchPath(path, pathPattern) {
+			continue
+		}
+		for key := range pathKeys {
+			keys = append(keys, key)
+		}
+		delete(m.entries, path)
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		if err := m.cache.Delete(ctx, key); err != nil && !errors.Is(err, cache.ErrNotFound) {
+			errs = append(errs, err)
+		}
+	}
+	m.invalidated.Add(int64(len(keys)))
+
+	log.Info(ctx, "已按路径清除缓存",
 		log.String("pattern", pathPattern),
+		log.Int("keys", len(keys)),
+		log.Int("failed", len(errs)),
 	)
-	return nil
+	return errors.Join(errs...)
+}
+
+// Stats 获取缓存统计
+func (m *CacheMiddleware) Stats() CacheStats {
+	stats := CacheStats{
+		Hits:        m.hits.Load(),
+		Misses:      m.misses.Load(),
+		Stores:      m.stores.Load(),
+		Invalidated: m.invalidated.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for path, keys := range m.entries {
+		for key, expiry := range keys {
+			if now.After(expiry) {
+				delete(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			delete(m.entries, path)
+			continue
+		}
+		stats.Entries += len(keys)
+		stats.Paths++
+	}
+	return stats
 }
 
 // CacheHandler 缓存处理器（用于 HTTP 响应）
@@ -268,6 +384,11 @@ func NewCacheHandler(middleware *CacheMiddleware) *CacheHandler {
 	}
 }
 
+// Middleware 获取缓存中间件
+func (h *CacheHandler) Middleware() *CacheMiddleware {
+	return h.middleware
+}
+
 // HandleRequest 处理请求（检查缓存）
 func (h *CacheHandler) HandleRequest(ctx context.Context, req *http.Request) (*CachedResponse, bool) {
 	if !h.middleware.ShouldCache(req.Method, req.URL.Path) {
@@ -338,6 +459,7 @@ func (h *CacheHandler) HandleResponse(ctx context.Context, req *http.Request, st
 		Headers:    headers,
 		Body:       body,
 		CachedAt:   time.Now(),
+		Path:       req.URL.Path,
 	}
 
 	// 写入缓存
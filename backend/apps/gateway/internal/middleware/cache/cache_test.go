@@ -267,6 +267,62 @@ func TestCacheHandler(t *testing.T) {
 	}
 }
 
+// TestInvalidateByPath 测试按路径清除缓存和缓存统计
+func TestInvalidateByPath(t *testing.T) {
+	originalCache := cache.GetGlobalCache()
+	defer cache.SetGlobalCache(originalCache)
+
+	mock := newMockCache()
+	cache.SetGlobalCache(mock)
+
+	middleware, err := NewCacheMiddleware(DefaultCacheConfig())
+	if err != nil {
+		t.Fatalf("创建缓存中间件失败: %v", err)
+	}
+	handler := NewCacheHandler(middleware)
+	ctx := context.Background()
+
+	usersReq, _ := http.NewRequest("GET", "/api/v1/users?id=1", nil)
+	otherUserReq, _ := http.NewRequest("GET", "/api/v1/users?id=2", nil)
+	ordersReq, _ := http.NewRequest("GET", "/api/v1/orders", nil)
+	for _, req := range []*http.Request{usersReq, otherUserReq, ordersReq} {
+		handler.HandleResponse(ctx, req, 200, map[string][]string{}, []byte("test"))
+	}
+	handler.HandleRequest(ctx, usersReq)
+
+	stats := middleware.Stats()
+	if stats.Entries != 3 || stats.Paths != 2 || stats.Stores != 3 || stats.Hits != 1 {
+		t.Errorf("缓存统计不正确: %+v", stats)
+	}
+
+	// 清除 /api/v1/users 下的所有缓存条目（不同查询参数的条目一并清除）
+	if err := middleware.InvalidateByPath(ctx, "/api/v1/users"); err != nil {
+		t.Fatalf("清除缓存失败: %v", err)
+	}
+	if _, hit := handler.HandleRequest(ctx, usersReq); hit {
+		t.Error("清除后不应该命中缓存")
+	}
+	if _, hit := handler.HandleRequest(ctx, otherUserReq); hit {
+		t.Error("清除后不应该命中缓存")
+	}
+	if _, hit := handler.HandleRequest(ctx, ordersReq); !hit {
+		t.Error("其他路径的缓存不应该被清除")
+	}
+
+	// 前缀模式
+	if err := middleware.InvalidateByPath(ctx, "/api/*"); err != nil {
+		t.Fatalf("清除缓存失败: %v", err)
+	}
+	if _, hit := handler.HandleRequest(ctx, ordersReq); hit {
+		t.Error("前缀匹配的缓存应该被清除")
+	}
+
+	stats = middleware.Stats()
+	if stats.Entries != 0 || stats.Invalidated != 3 {
+		t.Errorf("清除后缓存统计不正确: %+v", stats)
+	}
+}
+
 // TestCacheExpiration 测试缓存过期
 func TestCacheExpiration(t *testing.T) {
 	originalCache := cache.GetGlobalCache()
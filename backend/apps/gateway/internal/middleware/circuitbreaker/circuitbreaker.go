@@ -2,6 +2,7 @@ package circuitbreaker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -83,6 +84,7 @@ type CircuitBreaker struct {
 	lastFailure   time.Time // 最后失败时间
 	lastStateTime time.Time // 状态切换时间
 	halfOpenCount int       // 半开状态请求计数
+	forced        bool      // 是否由管理接口强制设置了状态（强制期间不按失败率自动切换）
 
 	// 时间窗口内的请求记录
 	requests []time.Time
@@ -117,6 +119,11 @@ func (cb *CircuitBreaker) Execute(ctx context.Context, fn func() error) error {
 	// 执行请求
 	err := fn()
 
+	// 请求被取消（如客户端断开连接）时无法判断服务是否正常，不计入统计
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+
 	// 计算请求耗时
 	duration := time.Since(startTime)
 
@@ -144,6 +151,10 @@ func (cb *CircuitBreaker) allowRequest() bool {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
 
+	if cb.forced {
+		return cb.state != StateOpen
+	}
+
 	switch cb.state {
 	case StateClosed:
 		return true
@@ -192,6 +203,11 @@ func (cb *CircuitBreaker) recordResult(result RequestResult) {
 		cb.lastFailure = now
 	}
 
+	// 强制状态期间只统计，不切换状态
+	if cb.forced {
+		return
+	}
+
 	// 根据状态更新逻辑
 	switch cb.state {
 	case StateClosed:
@@ -291,9 +307,18 @@ func (cb *CircuitBreaker) resetStats() {
 }
 
 // GetState 获取当前状态
+// 打开状态持续时间已到时返回半开状态（实际切换在下一个请求到来时进行）
 func (cb *CircuitBreaker) GetState() State {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
+	return cb.effectiveState()
+}
+
+// effectiveState 获取考虑打开持续时间后的状态（调用方需持有锁）
+func (cb *CircuitBreaker) effectiveState() State {
+	if cb.state == StateOpen && !cb.forced && time.Since(cb.lastStateTime) >= time.Duration(cb.config.OpenDuration)*time.Second {
+		return StateHalfOpen
+	}
 	return cb.state
 }
 
@@ -303,7 +328,8 @@ func (cb *CircuitBreaker) GetStats() map[string]interface{} {
 	defer cb.mu.RUnlock()
 
 	return map[string]interface{}{
-		"state":          cb.state.String(),
+		"state":          cb.effectiveState().String(),
+		"forced":         cb.forced,
 		"failures":       cb.failures,
 		"successes":      cb.successes,
 		"total_requests": len(cb.results),
@@ -313,6 +339,32 @@ func (cb *CircuitBreaker) GetStats() map[string]interface{} {
 	}
 }
 
+// Force 强制设置熔断器状态（StateOpen 拒绝所有请求，StateClosed 放行所有请求），直到调用 Reset
+func (cb *CircuitBreaker) Force(state State) error {
+	if state != StateOpen && state != StateClosed {
+		return fmt.Errorf("只能强制打开或关闭熔断器")
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = state
+	cb.forced = true
+	cb.lastStateTime = time.Now()
+	cb.halfOpenCount = 0
+	return nil
+}
+
+// Reset 取消强制状态并重置为关闭状态，恢复按失败率自动切换
+func (cb *CircuitBreaker) Reset() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = StateClosed
+	cb.forced = false
+	cb.lastStateTime = time.Now()
+	cb.halfOpenCount = 0
+	cb.resetStats()
+}
+
 // CircuitBreakerManager 熔断器管理器
 type CircuitBreakerManager struct {
 	breakers map[string]*CircuitBreaker
@@ -364,6 +416,61 @@ func (m *CircuitBreakerManager) GetBreakerStats() map[string]map[string]interfac
 	return stats
 }
 
+// Force 强制设置熔断器状态（熔断器不存在时返回错误）
+func (m *CircuitBreakerManager) Force(serviceName string, state State) error {
+	m.mu.RLock()
+	breaker, exists := m.breakers[serviceName]
+	m.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("熔断器 %s 不存在", serviceName)
+	}
+
+	if err := breaker.Force(state); err != nil {
+		return err
+	}
+	log.Warn(context.Background(), "熔断器状态已被强制设置",
+		log.String("breaker", serviceName),
+		log.String("state", state.String()),
+	)
+	return nil
+}
+
+// Reset 取消熔断器的强制状态并重置统计（熔断器不存在时返回错误）
+func (m *CircuitBreakerManager) Reset(serviceName string) error {
+	m.mu.RLock()
+	breaker, exists := m.breakers[serviceName]
+	m.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("熔断器 %s 不存在", serviceName)
+	}
+
+	breaker.Reset()
+	log.Info(context.Background(), "熔断器已重置",
+		log.String("breaker", serviceName),
+	)
+	return nil
+}
+
+// Retain 服务实例列表变化时删除已下线实例的熔断器
+// 实例熔断器的键为 服务名/实例标识，instanceKeys 为服务当前的实例标识（为空时删除该服务的所有实例熔断器）
+func (m *CircuitBreakerManager) Retain(serviceName string, instanceKeys []string) {
+	current := make(map[string]bool, len(instanceKeys))
+	for _, key := range instanceKeys {
+		current[key] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := serviceName + "/"
+	for name := range m.breakers {
+		instanceKey, ok := strings.CutPrefix(name, prefix)
+		if ok && !current[instanceKey] {
+			delete(m.breakers, name)
+		}
+	}
+}
+
 // IsOpen 检查服务是否处于打开状态
 func (m *CircuitBreakerManager) IsOpen(serviceName string) bool {
 	m.mu.RLock()
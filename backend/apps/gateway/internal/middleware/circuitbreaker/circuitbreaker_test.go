@@ -194,3 +194,179 @@ func TestCircuitBreakerManager(t *testing.T) {
 		t.Errorf("应该有2个服务的统计信息，实际 %d", len(stats))
 	}
 }
+
+// TestCircuitBreakerForce 测试强制打开、强制关闭和重置熔断器
+func TestCircuitBreakerForce(t *testing.T) {
+	mgr := NewCircuitBreakerManager()
+	config := &Config{
+		FailureThreshold: 0.5,
+		MinRequests:      2,
+		WindowSize:       60,
+		OpenDuration:     30,
+		HalfOpenRequests: 1,
+	}
+
+	if err := mgr.Force("user-service", StateOpen); err == nil {
+		t.Error("不存在的熔断器应该返回错误")
+	}
+
+	breaker := mgr.GetBreaker("user-service", config)
+	if err := mgr.Force("user-service", StateHalfOpen); err == nil {
+		t.Error("不能强制设置为半开状态")
+	}
+
+	// 强制打开后拒绝所有请求
+	if err := mgr.Force("user-service", StateOpen); err != nil {
+		t.Fatalf("强制打开失败: %v", err)
+	}
+	if !mgr.IsOpen("user-service") {
+		t.Error("强制打开后熔断器应该处于打开状态")
+	}
+	if err := breaker.Execute(context.Background(), func() error { return nil }); !IsCircuitBreakerError(err) {
+		t.Errorf("强制打开后应该拒绝请求，实际 %v", err)
+	}
+
+	// 强制关闭后失败率超过阈值也不打开
+	if err := mgr.Force("user-service", StateClosed); err != nil {
+		t.Fatalf("强制关闭失败: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		breaker.Execute(context.Background(), func() error { return errors.New("test error") })
+	}
+	if breaker.GetState() != StateClosed {
+		t.Errorf("强制关闭后应该保持关闭状态，实际 %s", breaker.GetState())
+	}
+
+	// 重置后恢复按失败率自动切换
+	if err := mgr.Reset("user-service"); err != nil {
+		t.Fatalf("重置失败: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		breaker.Execute(context.Background(), func() error { return errors.New("test error") })
+	}
+	if breaker.GetState() != StateOpen {
+		t.Errorf("重置后失败率超过阈值应该打开，实际 %s", breaker.GetState())
+	}
+}
+
+// TestCircuitBreakerManagerRetain 测试实例下线后删除对应的熔断器
+func TestCircuitBreakerManagerRetain(t *testing.T) {
+	mgr := NewCircuitBreakerManager()
+	config := &Config{FailureThreshold: 0.5, MinRequests: 10, WindowSize: 60, OpenDuration: 30, HalfOpenRequests: 3}
+
+	for _, key := range []string{"user-service/user-1", "user-service/user-2", "user-service-v2/user-1", "order-service/order-1"} {
+		mgr.GetBreaker(key, config)
+	}
+
+	// user-2 下线，其他服务（包括同前缀的 user-service-v2）不受影响
+	mgr.Retain("user-service", []string{"user-1"})
+	stats := mgr.GetBreakerStats()
+	if _, exists := stats["user-service/user-2"]; exists {
+		t.Error("已下线实例的熔断器应该被删除")
+	}
+	for _, key := range []string{"user-service/user-1", "user-service-v2/user-1", "order-service/order-1"} {
+		if _, exists := stats[key]; !exists {
+			t.Errorf("熔断器 %s 不应该被删除", key)
+		}
+	}
+
+	// 服务移除后删除其所有实例熔断器
+	mgr.Retain("order-service", nil)
+	if len(mgr.GetBreakerStats()) != 2 {
+		t.Errorf("应该剩余2个熔断器，实际 %d", len(mgr.GetBreakerStats()))
+	}
+}
+
+// TestOutlierDetectorConsecutive5xx 测试连续 5xx 驱逐实例及驱逐时间指数增长
+func TestOutlierDetectorConsecutive5xx(t *testing.T) {
+	detector := NewOutlierDetector(&OutlierConfig{
+		Consecutive5xx:   3,
+		BaseEjectionTime: 50 * time.Millisecond,
+		MaxEjectionTime:  time.Second,
+	})
+	detector.SetHostCount(3)
+
+	// 中间出现成功响应时重新计数
+	detector.Record("a", InstanceResult{StatusCode: 503})
+	detector.Record("a", InstanceResult{StatusCode: 503})
+	detector.Record("a", InstanceResult{StatusCode: 200})
+	detector.Record("a", InstanceResult{StatusCode: 503})
+	if detector.IsEjected("a") {
+		t.Fatal("连续 5xx 未达到阈值，不应该驱逐")
+	}
+
+	detector.Record("a", InstanceResult{StatusCode: 503})
+	ejected, reason := detector.Record("a", InstanceResult{StatusCode: 500})
+	if !ejected || reason != EjectReason5xx || !detector.IsEjected("a") {
+		t.Fatalf("连续 3 次 5xx 应该驱逐实例，实际 ejected=%v reason=%s", ejected, reason)
+	}
+
+	// 驱逐结束后再次被驱逐，驱逐时间加倍
+	time.Sleep(60 * time.Millisecond)
+	if detector.IsEjected("a") {
+		t.Fatal("驱逐时间结束后应该恢复")
+	}
+	for i := 0; i < 3; i++ {
+		detector.Record("a", InstanceResult{StatusCode: 502})
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !detector.IsEjected("a") {
+		t.Error("第二次驱逐时间应该加倍")
+	}
+}
+
+// TestOutlierDetectorMaxEjectionPercent 测试最大驱逐比例
+func TestOutlierDetectorMaxEjectionPercent(t *testing.T) {
+	detector := NewOutlierDetector(&OutlierConfig{
+		ConsecutiveConnectionErrors: 1,
+		MaxEjectionPercent:          50,
+	})
+	detector.SetHostCount(4)
+
+	for _, id := range []string{"a", "b", "c"} {
+		detector.Record(id, InstanceResult{ConnectionError: true})
+	}
+
+	ejected := 0
+	for _, id := range []string{"a", "b", "c"} {
+		if detector.IsEjected(id) {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Errorf("4 个实例最多驱逐 50%%（2 个），实际驱逐 %d 个", ejected)
+	}
+
+	// 只有一个实例时不驱逐
+	single := NewOutlierDetector(&OutlierConfig{ConsecutiveConnectionErrors: 1})
+	single.SetHostCount(1)
+	single.Record("only", InstanceResult{ConnectionError: true})
+	if single.IsEjected("only") {
+		t.Error("只有一个实例时不应该驱逐")
+	}
+}
+
+// TestOutlierDetectorLatency 测试延迟异常实例驱逐
+func TestOutlierDetectorLatency(t *testing.T) {
+	detector := NewOutlierDetector(&OutlierConfig{
+		LatencyFactor:      3,
+		LatencyMinRequests: 5,
+	})
+	detector.SetHostCount(3)
+
+	for i := 0; i < 5; i++ {
+		detector.Record("fast-1", InstanceResult{StatusCode: 200, Duration: 10 * time.Millisecond})
+		detector.Record("fast-2", InstanceResult{StatusCode: 200, Duration: 12 * time.Millisecond})
+	}
+	var ejected bool
+	var reason string
+	for i := 0; i < 5; i++ {
+		ejected, reason = detector.Record("slow", InstanceResult{StatusCode: 200, Duration: 200 * time.Millisecond})
+	}
+	if !ejected || reason != EjectReasonLatency {
+		t.Errorf("延迟明显高于其他实例时应该驱逐，实际 ejected=%v reason=%s", ejected, reason)
+	}
+	if detector.IsEjected("fast-1") || detector.IsEjected("fast-2") {
+		t.Error("正常实例不应该被驱逐")
+	}
+}
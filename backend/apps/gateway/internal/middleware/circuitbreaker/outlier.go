@@ -0,0 +1,285 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// 实例驱逐原因
+const (
+	// EjectReason5xx 连续返回 5xx
+	EjectReason5xx = "consecutive_5xx"
+	// EjectReasonConnection 连续连接错误（连接失败、超时）
+	EjectReasonConnection = "consecutive_connection_errors"
+	// EjectReasonLatency 延迟明显高于同服务其他实例
+	EjectReasonLatency = "latency"
+)
+
+// latencyEWMAAlpha 实例延迟指数加权移动平均的平滑系数
+const latencyEWMAAlpha = 0.1
+
+// OutlierConfig 异常实例检测配置
+type OutlierConfig struct {
+	// 连续 5xx 次数阈值（0 表示使用默认值5）
+	Consecutive5xx int
+	// 连续连接错误次数阈值（0 表示使用默认值5）
+	ConsecutiveConnectionErrors int
+	// 延迟倍数阈值：实例平均延迟超过同服务其他实例平均延迟的此倍数时驱逐（0 表示不检测延迟）
+	LatencyFactor float64
+	// 参与延迟检测所需的最少请求数
+	LatencyMinRequests int
+	// 基础驱逐时间，每次被驱逐后加倍
+	BaseEjectionTime time.Duration
+	// 最大驱逐时间
+	MaxEjectionTime time.Duration
+	// 同一服务最多可同时驱逐的实例比例（0-100）
+	MaxEjectionPercent int
+}
+
+// withDefaults 返回填充默认值后的配置
+func (c *OutlierConfig) withDefaults() OutlierConfig {
+	result := *c
+	if result.Consecutive5xx <= 0 {
+		result.Consecutive5xx = 5
+	}
+	if result.ConsecutiveConnectionErrors <= 0 {
+		result.ConsecutiveConnectionErrors = 5
+	}
+	if result.LatencyMinRequests <= 0 {
+		result.LatencyMinRequests = 20
+	}
+	if result.BaseEjectionTime <= 0 {
+		result.BaseEjectionTime = 30 * time.Second
+	}
+	if result.MaxEjectionTime <= 0 {
+		result.MaxEjectionTime = 300 * time.Second
+	}
+	if result.MaxEjectionTime < result.BaseEjectionTime {
+		result.MaxEjectionTime = result.BaseEjectionTime
+	}
+	if result.MaxEjectionPercent <= 0 || result.MaxEjectionPercent > 100 {
+		result.MaxEjectionPercent = 50
+	}
+	return result
+}
+
+// InstanceResult 单次请求在某个实例上的结果
+type InstanceResult struct {
+	// 上游响应状态码（连接错误时为 0）
+	StatusCode int
+	// 是否为连接错误（连接失败、连接重置、超时）
+	ConnectionError bool
+	// 请求耗时（到收到响应头为止）
+	Duration time.Duration
+}
+
+// instanceStats 实例统计信息
+type instanceStats struct {
+	consecutive5xx   int
+	consecutiveConn  int
+	latencyEWMA      float64 // 秒
+	samples          int
+	ejections        int // 累计驱逐次数，决定下次驱逐时长
+	ejectedUntil     time.Time
+	lastEjectionTime time.Duration
+	lastReason       string
+}
+
+// ejected 判断实例当前是否处于驱逐状态
+func (s *instanceStats) ejected(now time.Time) bool {
+	return now.Before(s.ejectedUntil)
+}
+
+// OutlierDetector 异常实例检测器（被动健康检查）
+// 按服务创建，根据每个实例的请求结果将异常实例暂时从负载均衡候选列表中驱逐
+type OutlierDetector struct {
+	config    OutlierConfig
+	instances map[string]*instanceStats
+	// 服务当前的实例总数（用于计算最大驱逐比例）
+	hosts int
+	mu    sync.Mutex
+}
+
+// NewOutlierDetector 创建异常实例检测器
+func NewOutlierDetector(config *OutlierConfig) *OutlierDetector {
+	if config == nil {
+		config = &OutlierConfig{}
+	}
+	return &OutlierDetector{
+		config:    config.withDefaults(),
+		instances: make(map[string]*instanceStats),
+	}
+}
+
+// SetHostCount 更新服务的实例总数
+func (d *OutlierDetector) SetHostCount(hosts int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hosts = hosts
+}
+
+// IsEjected 判断实例是否处于驱逐状态
+func (d *OutlierDetector) IsEjected(instanceID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stats, exists := d.instances[instanceID]
+	return exists && stats.ejected(time.Now())
+}
+
+// Record 记录实例的请求结果，返回本次是否触发驱逐及驱逐原因
+func (d *OutlierDetector) Record(instanceID string, result InstanceResult) (bool, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	stats, exists := d.instances[instanceID]
+	if !exists {
+		stats = &instanceStats{}
+		d.instances[instanceID] = stats
+	}
+
+	// 驱逐期间的请求（如驱逐前已发出的请求）不参与统计
+	if stats.ejected(now) {
+		return false, ""
+	}
+
+	// 驱逐结束后持续健康超过上次驱逐时长，重置驱逐次数
+	if stats.ejections > 0 && now.After(stats.ejectedUntil.Add(stats.lastEjectionTime)) {
+		stats.ejections = 0
+	}
+
+	reason := ""
+	switch {
+	case result.ConnectionError:
+		stats.consecutiveConn++
+		stats.consecutive5xx = 0
+		if stats.consecutiveConn >= d.config.ConsecutiveConnectionErrors {
+			reason = EjectReasonConnection
+		}
+	case result.StatusCode >= 500:
+		stats.consecutive5xx++
+		stats.consecutiveConn = 0
+		if stats.consecutive5xx >= d.config.Consecutive5xx {
+			reason = EjectReason5xx
+		}
+	default:
+		stats.consecutive5xx = 0
+		stats.consecutiveConn = 0
+	}
+
+	if !result.ConnectionError && result.Duration > 0 {
+		seconds := result.Duration.Seconds()
+		if stats.samples == 0 {
+			stats.latencyEWMA = seconds
+		} else {
+			stats.latencyEWMA = latencyEWMAAlpha*seconds + (1-latencyEWMAAlpha)*stats.latencyEWMA
+		}
+		stats.samples++
+		if reason == "" && d.isLatencyOutlier(instanceID, stats) {
+			reason = EjectReasonLatency
+		}
+	}
+
+	if reason == "" || !d.canEject(now) {
+		return false, ""
+	}
+
+	d.eject(stats, now, reason)
+	return true, reason
+}
+
+// isLatencyOutlier 判断实例延迟是否明显高于同服务其他实例的平均延迟
+func (d *OutlierDetector) isLatencyOutlier(instanceID string, stats *instanceStats) bool {
+	if d.config.LatencyFactor <= 0 || stats.samples < d.config.LatencyMinRequests {
+		return false
+	}
+
+	var total float64
+	peers := 0
+	for id, other := range d.instances {
+		if id == instanceID || other.samples < d.config.LatencyMinRequests {
+			continue
+		}
+		total += other.latencyEWMA
+		peers++
+	}
+	if peers == 0 {
+		return false
+	}
+
+	return stats.latencyEWMA > total/float64(peers)*d.config.LatencyFactor
+}
+
+// canEject 判断驱逐一个实例后是否超过最大驱逐比例
+// 服务有多个实例时至少允许驱逐一个，只有一个实例时不驱逐
+func (d *OutlierDetector) canEject(now time.Time) bool {
+	hosts := max(d.hosts, len(d.instances))
+	if hosts <= 1 {
+		return false
+	}
+
+	allowed := max(hosts*d.config.MaxEjectionPercent/100, 1)
+	ejected := 0
+	for _, stats := range d.instances {
+		if stats.ejected(now) {
+			ejected++
+		}
+	}
+	return ejected < allowed
+}
+
+// eject 驱逐实例，驱逐时长按驱逐次数指数增长
+func (d *OutlierDetector) eject(stats *instanceStats, now time.Time, reason string) {
+	duration := d.config.MaxEjectionTime
+	if stats.ejections < 16 {
+		duration = min(d.config.BaseEjectionTime<<stats.ejections, d.config.MaxEjectionTime)
+	}
+
+	stats.ejections++
+	stats.ejectedUntil = now.Add(duration)
+	stats.lastEjectionTime = duration
+	stats.lastReason = reason
+	stats.consecutive5xx = 0
+	stats.consecutiveConn = 0
+}
+
+// Retain 服务实例列表变化时清除已下线实例的统计信息，并更新实例总数
+func (d *OutlierDetector) Retain(instanceIDs []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	current := make(map[string]bool, len(instanceIDs))
+	for _, id := range instanceIDs {
+		current[id] = true
+	}
+	for id := range d.instances {
+		if !current[id] {
+			delete(d.instances, id)
+		}
+	}
+	d.hosts = len(instanceIDs)
+}
+
+// GetStats 获取各实例的检测统计信息
+func (d *OutlierDetector) GetStats() map[string]map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	stats := make(map[string]map[string]interface{}, len(d.instances))
+	for id, instance := range d.instances {
+		entry := map[string]interface{}{
+			"ejected":                       instance.ejected(now),
+			"ejections":                     instance.ejections,
+			"consecutive_5xx":               instance.consecutive5xx,
+			"consecutive_connection_errors": instance.consecutiveConn,
+			"latency_ewma_ms":               instance.latencyEWMA * 1000,
+		}
+		if instance.ejected(now) {
+			entry["ejected_until"] = instance.ejectedUntil.Format(time.RFC3339)
+			entry["reason"] = instance.lastReason
+		}
+		stats[id] = entry
+	}
+	return stats
+}
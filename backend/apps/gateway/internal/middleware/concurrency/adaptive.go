@@ -0,0 +1,251 @@
+package concurrency
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrOverloaded 下游延迟升高，请求被自适应并发限制削减
+var ErrOverloaded = errors.New("服务负载过高，请求被削减")
+
+// 请求优先级（负载升高时低优先级请求先被削减）
+const (
+	// PriorityCritical 关键请求（健康检查、认证等），最后被削减
+	PriorityCritical = "critical"
+	// PriorityNormal 普通请求（默认）
+	PriorityNormal = "normal"
+	// PriorityLow 低优先级请求，最先被削减
+	PriorityLow = "low"
+)
+
+// priorityShares 各优先级可使用的并发配额比例
+var priorityShares = map[string]float64{
+	PriorityCritical: 1.0,
+	PriorityNormal:   0.9,
+	PriorityLow:      0.7,
+}
+
+// ValidPriority 判断优先级是否有效（空表示 normal）
+func ValidPriority(priority string) bool {
+	if priority == "" {
+		return true
+	}
+	_, ok := priorityShares[priority]
+	return ok
+}
+
+// AdaptiveConfig 自适应并发限制配置
+type AdaptiveConfig struct {
+	// 初始并发上限
+	InitialLimit int
+	// 并发上限的最小值 / 最大值
+	MinLimit int
+	MaxLimit int
+	// 延迟容忍倍数：窗口 p90 延迟超过基线延迟的此倍数时收缩并发上限
+	Tolerance float64
+	// 收缩系数（乘性减小）
+	Backoff float64
+	// 每个统计窗口的样本数
+	WindowSize int
+}
+
+// withDefaults 返回填充默认值后的配置
+func (c *AdaptiveConfig) withDefaults() AdaptiveConfig {
+	result := *c
+	if result.MinLimit <= 0 {
+		result.MinLimit = 5
+	}
+	if result.MaxLimit <= 0 {
+		result.MaxLimit = 1000
+	}
+	if result.InitialLimit <= 0 {
+		result.InitialLimit = 20
+	}
+	result.InitialLimit = min(max(result.InitialLimit, result.MinLimit), result.MaxLimit)
+	if result.Tolerance <= 1 {
+		result.Tolerance = 2.0
+	}
+	if result.Backoff <= 0 || result.Backoff >= 1 {
+		result.Backoff = 0.9
+	}
+	if result.WindowSize <= 0 {
+		result.WindowSize = 50
+	}
+	return result
+}
+
+// AdaptiveLimiter 自适应并发限制器（AIMD）
+// 按窗口统计下游延迟的 p90：超过基线延迟 × 容忍倍数时乘性收缩并发上限，
+// 否则在并发上限被充分使用时加性增长；基线延迟取观测到的最低 p90 并缓慢上浮，适应下游的正常变化
+type AdaptiveLimiter struct {
+	config AdaptiveConfig
+	mu     sync.Mutex
+	// 当前并发上限
+	limit float64
+	// 当前并发数
+	inFlight int
+	// 当前窗口内的最大并发数
+	peakInFlight int
+	// 当前窗口的延迟样本
+	samples []time.Duration
+	// 基线延迟
+	baseline time.Duration
+	// 并发上限调整时的回调（用于上报指标）
+	onChange func(limit int, p90 time.Duration)
+}
+
+// NewAdaptiveLimiter 创建自适应并发限制器
+func NewAdaptiveLimiter(config *AdaptiveConfig, onChange func(limit int, p90 time.Duration)) *AdaptiveLimiter {
+	c := config.withDefaults()
+	return &AdaptiveLimiter{
+		config:   c,
+		limit:    float64(c.InitialLimit),
+		samples:  make([]time.Duration, 0, c.WindowSize),
+		onChange: onChange,
+	}
+}
+
+// Acquire 按优先级获取执行槽位
+// 成功时返回完成回调：latency 为下游延迟，sample 为 false 时不计入延迟统计（如流式响应、非超时的失败）
+func (l *AdaptiveLimiter) Acquire(priority string) (func(latency time.Duration, sample bool), error) {
+	share, ok := priorityShares[priority]
+	if !ok {
+		share = priorityShares[PriorityNormal]
+	}
+
+	l.mu.Lock()
+	// 至少保留一个槽位，避免并发上限很小时请求全部被拒绝
+	allowed := max(int(math.Floor(l.limit*share)), 1)
+	if l.inFlight >= allowed {
+		l.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+	l.inFlight++
+	l.peakInFlight = max(l.peakInFlight, l.inFlight)
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func(latency time.Duration, sample bool) {
+		once.Do(func() {
+			l.done(latency, sample)
+		})
+	}, nil
+}
+
+// done 请求完成，记录延迟样本并在窗口结束时调整并发上限
+func (l *AdaptiveLimiter) done(latency time.Duration, sample bool) {
+	l.mu.Lock()
+	l.inFlight--
+	if !sample || latency <= 0 {
+		l.mu.Unlock()
+		return
+	}
+
+	l.samples = append(l.samples, latency)
+	if len(l.samples) < l.config.WindowSize {
+		l.mu.Unlock()
+		return
+	}
+
+	p90 := percentile(l.samples, 0.9)
+	l.samples = l.samples[:0]
+	l.adjust(p90)
+	l.peakInFlight = l.inFlight
+	limit, onChange := int(l.limit), l.onChange
+	l.mu.Unlock()
+
+	if onChange != nil {
+		onChange(limit, p90)
+	}
+}
+
+// adjust 根据窗口 p90 延迟调整并发上限（调用方持有锁）
+func (l *AdaptiveLimiter) adjust(p90 time.Duration) {
+	// 更新基线：取最低值，并缓慢上浮（避免偶然的低延迟窗口长期压低基线）
+	if l.baseline == 0 || p90 < l.baseline {
+		l.baseline = p90
+	} else {
+		l.baseline += (p90 - l.baseline) / 100
+	}
+
+	if float64(p90) > float64(l.baseline)*l.config.Tolerance {
+		// 延迟升高：乘性减小
+		l.limit = max(l.limit*l.config.Backoff, float64(l.config.MinLimit))
+		return
+	}
+
+	// 延迟正常且并发上限被充分使用：加性增长
+	if float64(l.peakInFlight) >= l.limit*priorityShares[PriorityNormal] {
+		l.limit = min(l.limit+math.Max(1, math.Sqrt(l.limit)), float64(l.config.MaxLimit))
+	}
+}
+
+// Limit 当前并发上限
+func (l *AdaptiveLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight 当前并发数
+func (l *AdaptiveLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// percentile 计算延迟分位数
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(index, 0), len(sorted)-1)]
+}
+
+// AdaptiveManager 自适应并发限制器管理器（按服务管理）
+type AdaptiveManager struct {
+	limiters map[string]*AdaptiveLimiter
+	mu       sync.RWMutex
+	// 并发上限调整时的回调
+	onChange func(service string, limit int, p90 time.Duration)
+}
+
+// NewAdaptiveManager 创建自适应并发限制器管理器
+func NewAdaptiveManager(onChange func(service string, limit int, p90 time.Duration)) *AdaptiveManager {
+	return &AdaptiveManager{
+		limiters: make(map[string]*AdaptiveLimiter),
+		onChange: onChange,
+	}
+}
+
+// GetLimiter 获取或创建服务的限制器（配置变化时重新创建）
+func (m *AdaptiveManager) GetLimiter(service string, config *AdaptiveConfig) *AdaptiveLimiter {
+	m.mu.RLock()
+	limiter, exists := m.limiters[service]
+	m.mu.RUnlock()
+
+	if exists && limiter.config == config.withDefaults() {
+		return limiter
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 双重检查
+	if limiter, exists = m.limiters[service]; !exists || limiter.config != config.withDefaults() {
+		var onChange func(limit int, p90 time.Duration)
+		if m.onChange != nil {
+			onChange = func(limit int, p90 time.Duration) {
+				m.onChange(service, limit, p90)
+			}
+		}
+		limiter = NewAdaptiveLimiter(config, onChange)
+		m.limiters[service] = limiter
+	}
+
+	return limiter
+}
@@ -0,0 +1,178 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull 并发数已满且等待队列已满（或未配置等待队列）
+	ErrQueueFull = errors.New("并发请求数已达上限")
+	// ErrQueueTimeout 在等待队列中超时
+	ErrQueueTimeout = errors.New("排队等待超时")
+)
+
+// Config 并发限制配置
+type Config struct {
+	// 最大并发请求数
+	MaxConcurrency int
+	// 等待队列长度（0 表示不排队，并发数已满时直接拒绝）
+	QueueSize int
+	// 排队超时时间
+	QueueTimeout time.Duration
+}
+
+// Limiter 并发限制器（信号量 + 有界等待队列）
+type Limiter struct {
+	// 执行槽位
+	slots chan struct{}
+	// 等待队列
+	queue   chan struct{}
+	timeout time.Duration
+	// 并发数或队列长度变化时的回调（用于上报指标）
+	onChange func(inFlight, queued int)
+}
+
+// NewLimiter 创建并发限制器
+func NewLimiter(config *Config, onChange func(inFlight, queued int)) *Limiter {
+	c := config.withDefaults()
+	return &Limiter{
+		slots:    make(chan struct{}, c.MaxConcurrency),
+		queue:    make(chan struct{}, c.QueueSize),
+		timeout:  c.QueueTimeout,
+		onChange: onChange,
+	}
+}
+
+// withDefaults 返回填充默认值后的配置
+func (c *Config) withDefaults() Config {
+	result := *c
+	if result.MaxConcurrency <= 0 {
+		result.MaxConcurrency = 100 // 默认值
+	}
+	if result.QueueSize < 0 {
+		result.QueueSize = 0
+	}
+	if result.QueueTimeout <= 0 {
+		result.QueueTimeout = 5 * time.Second // 默认值
+	}
+	return result
+}
+
+// Acquire 获取执行槽位
+// 成功时返回释放函数（请求结束后必须调用）和排队等待时间；
+// 并发数已满时进入等待队列，队列已满、排队超时或 Context 取消时返回错误
+func (l *Limiter) Acquire(ctx context.Context) (func(), time.Duration, error) {
+	// 快速路径：有空闲槽位
+	select {
+	case l.slots <- struct{}{}:
+		l.notify()
+		return l.releaseOnce(), 0, nil
+	default:
+	}
+
+	// 进入等待队列
+	select {
+	case l.queue <- struct{}{}:
+		l.notify()
+	default:
+		return nil, 0, ErrQueueFull
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	defer func() {
+		<-l.queue
+		l.notify()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.releaseOnce(), time.Since(start), nil
+	case <-timer.C:
+		return nil, time.Since(start), ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, time.Since(start), ctx.Err()
+	}
+}
+
+// releaseOnce 返回只生效一次的槽位释放函数
+func (l *Limiter) releaseOnce() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.slots
+			l.notify()
+		})
+	}
+}
+
+// InFlight 当前并发请求数
+func (l *Limiter) InFlight() int {
+	return len(l.slots)
+}
+
+// Queued 当前排队请求数
+func (l *Limiter) Queued() int {
+	return len(l.queue)
+}
+
+// notify 上报并发数和队列长度
+func (l *Limiter) notify() {
+	if l.onChange != nil {
+		l.onChange(len(l.slots), len(l.queue))
+	}
+}
+
+// Manager 并发限制器管理器（按路由或服务管理多个限制器）
+type Manager struct {
+	limiters map[string]*Limiter
+	mu       sync.RWMutex
+	// 并发数或队列长度变化时的回调（name 为限制器名称）
+	onChange func(name string, inFlight, queued int)
+}
+
+// NewManager 创建并发限制器管理器
+func NewManager(onChange func(name string, inFlight, queued int)) *Manager {
+	return &Manager{
+		limiters: make(map[string]*Limiter),
+		onChange: onChange,
+	}
+}
+
+// GetLimiter 获取或创建限制器（配置变化时重新创建）
+func (m *Manager) GetLimiter(name string, config *Config) *Limiter {
+	m.mu.RLock()
+	limiter, exists := m.limiters[name]
+	m.mu.RUnlock()
+
+	if exists && limiter.matches(config) {
+		return limiter
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 双重检查
+	if limiter, exists = m.limiters[name]; !exists || !limiter.matches(config) {
+		var onChange func(inFlight, queued int)
+		if m.onChange != nil {
+			onChange = func(inFlight, queued int) {
+				m.onChange(name, inFlight, queued)
+			}
+		}
+		limiter = NewLimiter(config, onChange)
+		m.limiters[name] = limiter
+	}
+
+	return limiter
+}
+
+// matches 判断限制器是否与配置一致
+func (l *Limiter) matches(config *Config) bool {
+	c := config.withDefaults()
+	return cap(l.slots) == c.MaxConcurrency && cap(l.queue) == c.QueueSize && l.timeout == c.QueueTimeout
+}
@@ -0,0 +1,256 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLimiterRejectWithoutQueue 测试未配置等待队列时直接拒绝
+func TestLimiterRejectWithoutQueue(t *testing.T) {
+	limiter := NewLimiter(&Config{MaxConcurrency: 2}, nil)
+	ctx := context.Background()
+
+	release1, _, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("第 1 个请求应该获取成功: %v", err)
+	}
+	release2, _, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("第 2 个请求应该获取成功: %v", err)
+	}
+	if limiter.InFlight() != 2 {
+		t.Errorf("并发数应该是 2，实际 %d", limiter.InFlight())
+	}
+
+	if _, _, err := limiter.Acquire(ctx); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("并发数已满时应该返回 ErrQueueFull，实际 %v", err)
+	}
+
+	// 释放后可以再次获取（重复释放不影响计数）
+	release1()
+	release1()
+	if limiter.InFlight() != 1 {
+		t.Errorf("释放后并发数应该是 1，实际 %d", limiter.InFlight())
+	}
+	release3, _, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Errorf("释放后应该获取成功: %v", err)
+	}
+	release2()
+	release3()
+}
+
+// TestLimiterQueue 测试排队等待
+func TestLimiterQueue(t *testing.T) {
+	limiter := NewLimiter(&Config{MaxConcurrency: 1, QueueSize: 1, QueueTimeout: time.Second}, nil)
+	ctx := context.Background()
+
+	release, _, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("获取失败: %v", err)
+	}
+
+	// 第 2 个请求进入队列，槽位释放后获取成功
+	done := make(chan time.Duration)
+	go func() {
+		release2, wait, err := limiter.Acquire(ctx)
+		if err != nil {
+			t.Errorf("排队的请求应该获取成功: %v", err)
+			close(done)
+			return
+		}
+		release2()
+		done <- wait
+	}()
+
+	// 等待第 2 个请求进入队列
+	deadline := time.Now().Add(time.Second)
+	for limiter.Queued() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if limiter.Queued() != 1 {
+		t.Fatalf("队列长度应该是 1，实际 %d", limiter.Queued())
+	}
+
+	// 队列已满，第 3 个请求被拒绝
+	if _, _, err := limiter.Acquire(ctx); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("队列已满时应该返回 ErrQueueFull，实际 %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	release()
+	if wait := <-done; wait < 20*time.Millisecond {
+		t.Errorf("排队等待时间应该不少于 20ms，实际 %v", wait)
+	}
+	if limiter.Queued() != 0 || limiter.InFlight() != 0 {
+		t.Errorf("结束后队列和并发数应该为 0，实际 %d/%d", limiter.Queued(), limiter.InFlight())
+	}
+}
+
+// TestLimiterQueueTimeout 测试排队超时
+func TestLimiterQueueTimeout(t *testing.T) {
+	limiter := NewLimiter(&Config{MaxConcurrency: 1, QueueSize: 1, QueueTimeout: 50 * time.Millisecond}, nil)
+	ctx := context.Background()
+
+	release, _, _ := limiter.Acquire(ctx)
+	defer release()
+
+	_, wait, err := limiter.Acquire(ctx)
+	if !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("应该返回 ErrQueueTimeout，实际 %v", err)
+	}
+	if wait < 50*time.Millisecond {
+		t.Errorf("等待时间应该不少于排队超时时间，实际 %v", wait)
+	}
+	if limiter.Queued() != 0 {
+		t.Errorf("超时后应该离开队列，实际队列长度 %d", limiter.Queued())
+	}
+
+	// Context 取消时立即返回
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := limiter.Acquire(cancelCtx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Context 取消时应该返回 context.Canceled，实际 %v", err)
+	}
+}
+
+// TestManager 测试限制器管理和指标回调
+func TestManager(t *testing.T) {
+	var lastName string
+	var lastInFlight int
+	mgr := NewManager(func(name string, inFlight, queued int) {
+		lastName = name
+		lastInFlight = inFlight
+	})
+
+	config := &Config{MaxConcurrency: 3}
+	limiter := mgr.GetLimiter("route:/api/v1/workflows", config)
+	if mgr.GetLimiter("route:/api/v1/workflows", config) != limiter {
+		t.Error("相同名称和配置应该返回同一个限制器")
+	}
+	if mgr.GetLimiter("route:/api/v1/workflows", &Config{MaxConcurrency: 5}) == limiter {
+		t.Error("配置变化时应该重新创建限制器")
+	}
+
+	release, _, _ := mgr.GetLimiter("service:workflow-service", config).Acquire(context.Background())
+	if lastName != "service:workflow-service" || lastInFlight != 1 {
+		t.Errorf("回调参数不正确: %s %d", lastName, lastInFlight)
+	}
+	release()
+	if lastInFlight != 0 {
+		t.Errorf("释放后回调的并发数应该是 0，实际 %d", lastInFlight)
+	}
+}
+
+// runAdaptiveWindow 以固定并发数和延迟完成一个统计窗口
+func runAdaptiveWindow(t *testing.T, limiter *AdaptiveLimiter, concurrent, windowSize int, latency time.Duration) {
+	t.Helper()
+	for done := 0; done < windowSize; {
+		callbacks := make([]func(time.Duration, bool), 0, concurrent)
+		for i := 0; i < concurrent; i++ {
+			callback, err := limiter.Acquire(PriorityCritical)
+			if err != nil {
+				break
+			}
+			callbacks = append(callbacks, callback)
+		}
+		for _, callback := range callbacks {
+			callback(latency, true)
+			done++
+		}
+	}
+}
+
+// TestAdaptiveLimiterAIMD 测试延迟升高时收缩、恢复后增长
+func TestAdaptiveLimiterAIMD(t *testing.T) {
+	var reported int
+	limiter := NewAdaptiveLimiter(&AdaptiveConfig{InitialLimit: 20, MinLimit: 5, MaxLimit: 100, WindowSize: 20}, func(limit int, p90 time.Duration) {
+		reported = limit
+	})
+
+	// 建立基线（并发充分使用，上限增长）
+	runAdaptiveWindow(t, limiter, 20, 20, 10*time.Millisecond)
+	grown := limiter.Limit()
+	if grown <= 20 {
+		t.Errorf("延迟正常且并发充分使用时上限应该增长，实际 %d", grown)
+	}
+	if reported != grown {
+		t.Errorf("回调上报的上限应该是 %d，实际 %d", grown, reported)
+	}
+
+	// 延迟升高到基线的 5 倍，上限收缩
+	runAdaptiveWindow(t, limiter, 10, 20, 50*time.Millisecond)
+	runAdaptiveWindow(t, limiter, 10, 20, 50*time.Millisecond)
+	shrunk := limiter.Limit()
+	if shrunk >= grown {
+		t.Errorf("延迟升高时上限应该收缩，%d -> %d", grown, shrunk)
+	}
+
+	// 持续高延迟不会低于最小值
+	for i := 0; i < 30; i++ {
+		runAdaptiveWindow(t, limiter, 5, 20, time.Second)
+	}
+	if limiter.Limit() != 5 {
+		t.Errorf("上限不应该低于最小值 5，实际 %d", limiter.Limit())
+	}
+
+	// 延迟恢复后上限增长
+	for i := 0; i < 5; i++ {
+		runAdaptiveWindow(t, limiter, limiter.Limit(), 20, 10*time.Millisecond)
+	}
+	if limiter.Limit() <= 5 {
+		t.Errorf("延迟恢复后上限应该增长，实际 %d", limiter.Limit())
+	}
+}
+
+// TestAdaptiveLimiterPriority 测试按优先级削减（低优先级最先被削减，关键请求最后）
+func TestAdaptiveLimiterPriority(t *testing.T) {
+	limiter := NewAdaptiveLimiter(&AdaptiveConfig{InitialLimit: 10, MinLimit: 1, MaxLimit: 10}, nil)
+
+	admitted := map[string]int{}
+	callbacks := make([]func(time.Duration, bool), 0)
+	for _, priority := range []string{PriorityLow, PriorityNormal, PriorityCritical} {
+		for {
+			callback, err := limiter.Acquire(priority)
+			if err != nil {
+				if !errors.Is(err, ErrOverloaded) {
+					t.Fatalf("应该返回 ErrOverloaded，实际 %v", err)
+				}
+				break
+			}
+			callbacks = append(callbacks, callback)
+			admitted[priority]++
+		}
+	}
+
+	// 上限 10：low 可用 7 个槽位，normal 到 9，critical 到 10
+	if admitted[PriorityLow] != 7 || admitted[PriorityNormal] != 2 || admitted[PriorityCritical] != 1 {
+		t.Errorf("各优先级获取的槽位数不正确: %v", admitted)
+	}
+
+	// 完成回调只生效一次
+	callbacks[0](0, false)
+	callbacks[0](0, false)
+	if limiter.InFlight() != 9 {
+		t.Errorf("释放一次后并发数应该是 9，实际 %d", limiter.InFlight())
+	}
+	for _, callback := range callbacks[1:] {
+		callback(0, false)
+	}
+}
+
+// TestPercentile 测试分位数计算
+func TestPercentile(t *testing.T) {
+	samples := make([]time.Duration, 0, 10)
+	for i := 10; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	if p90 := percentile(samples, 0.9); p90 != 9*time.Millisecond {
+		t.Errorf("p90 应该是 9ms，实际 %v", p90)
+	}
+	if p50 := percentile(samples, 0.5); p50 != 5*time.Millisecond {
+		t.Errorf("p50 应该是 5ms，实际 %v", p50)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
 
 	"github.com/go-kratos/kratos/v2/transport/http"
 )
@@ -42,6 +43,7 @@ func (l *RequestLogger) LogRequest(ctx http.Context, startTime time.Time) {
 	path := req.URL.Path
 	query := req.URL.RawQuery
 	remoteAddr := req.RemoteAddr
+	clientIP, _ := clientip.FromContext(req.Context())
 	userAgent := req.UserAgent()
 
 	// 记录基本信息（不记录状态码，因为此时响应可能还未完成）
@@ -50,6 +52,7 @@ func (l *RequestLogger) LogRequest(ctx http.Context, startTime time.Time) {
 		log.String("path", path),
 		log.String("query", query),
 		log.String("remote_addr", remoteAddr),
+		log.String("client_ip", clientIP),
 		log.String("user_agent", userAgent),
 		log.Duration("duration", duration),
 	)
@@ -37,6 +37,38 @@ type Metrics struct {
 	cacheHits *prometheus.CounterVec
 	// 缓存未命中数（按路径）
 	cacheMisses *prometheus.CounterVec
+	// WebSocket 活跃连接数（按服务）
+	websocketConnections *prometheus.GaugeVec
+	// WebSocket 连接总数（按服务）
+	websocketConnectionsTotal *prometheus.CounterVec
+	// 流量拆分请求数（按服务、版本）
+	trafficSplitTotal *prometheus.CounterVec
+	// 并发限制器当前并发数（按限制器）
+	concurrencyInFlight *prometheus.GaugeVec
+	// 并发限制器等待队列长度（按限制器）
+	concurrencyQueueDepth *prometheus.GaugeVec
+	// 并发限制器排队等待时间（按限制器）
+	concurrencyQueueWait *prometheus.HistogramVec
+	// 并发限制拒绝的请求数（按限制器、原因）
+	concurrencyRejected *prometheus.CounterVec
+	// 自适应并发上限（按服务）
+	adaptiveLimit *prometheus.GaugeVec
+	// 自适应并发限制统计窗口的 p90 延迟（按服务）
+	adaptiveLatencyP90 *prometheus.GaugeVec
+	// 自适应并发限制削减的请求数（按服务、优先级）
+	adaptiveShed *prometheus.CounterVec
+	// 异常实例驱逐次数（按服务、原因）
+	outlierEjections *prometheus.CounterVec
+	// 主动健康检查的实例健康状态（按服务、实例，1 健康 0 不健康）
+	instanceHealthy *prometheus.GaugeVec
+	// 重试次数（按服务、原因）
+	retriesTotal *prometheus.CounterVec
+	// 重试预算耗尽而放弃重试的次数（按服务）
+	retryBudgetExhausted *prometheus.CounterVec
+	// 对冲请求数（按服务）
+	hedgedRequests *prometheus.CounterVec
+	// 对冲请求先于原请求返回的次数（按服务）
+	hedgeWins *prometheus.CounterVec
 }
 
 // NewMetrics 创建指标收集器
@@ -141,6 +173,135 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"path"},
 		),
+		// WebSocket 活跃连接数
+		websocketConnections: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_websocket_connections",
+				Help: "Number of WebSocket connections currently open",
+			},
+			[]string{"service"},
+		),
+		// WebSocket 连接总数
+		websocketConnectionsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_websocket_connections_total",
+				Help: "Total number of WebSocket connections established",
+			},
+			[]string{"service"},
+		),
+		// 流量拆分请求数
+		trafficSplitTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_traffic_split_total",
+				Help: "Total number of requests assigned by traffic split",
+			},
+			[]string{"service", "version"},
+		),
+		// 并发限制器当前并发数
+		concurrencyInFlight: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_concurrency_in_flight",
+				Help: "Number of requests holding a concurrency limiter slot",
+			},
+			[]string{"limiter"},
+		),
+		// 并发限制器等待队列长度
+		concurrencyQueueDepth: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_concurrency_queue_depth",
+				Help: "Number of requests waiting in a concurrency limiter queue",
+			},
+			[]string{"limiter"},
+		),
+		// 并发限制器排队等待时间
+		concurrencyQueueWait: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "gateway_concurrency_queue_wait_seconds",
+				Help:    "Time requests spent waiting in a concurrency limiter queue",
+				Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+			},
+			[]string{"limiter"},
+		),
+		// 并发限制拒绝的请求数
+		concurrencyRejected: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_concurrency_rejected_total",
+				Help: "Total number of requests rejected by concurrency limiter",
+			},
+			[]string{"limiter", "reason"},
+		),
+		// 自适应并发上限
+		adaptiveLimit: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_adaptive_concurrency_limit",
+				Help: "Current adaptive concurrency limit per service",
+			},
+			[]string{"service"},
+		),
+		// 自适应并发限制统计窗口的 p90 延迟
+		adaptiveLatencyP90: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_adaptive_concurrency_latency_p90_seconds",
+				Help: "p90 downstream latency of the last adaptive concurrency window",
+			},
+			[]string{"service"},
+		),
+		// 自适应并发限制削减的请求数
+		adaptiveShed: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_adaptive_concurrency_shed_total",
+				Help: "Total number of requests shed by adaptive concurrency limiter",
+			},
+			[]string{"service", "priority"},
+		),
+		// 异常实例驱逐次数
+		outlierEjections: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_outlier_ejections_total",
+				Help: "Total number of upstream instances ejected by outlier detection",
+			},
+			[]string{"service", "reason"},
+		),
+		// 主动健康检查的实例健康状态
+		instanceHealthy: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gateway_upstream_instance_healthy",
+				Help: "Health of upstream instances reported by active health checks (1 healthy, 0 unhealthy)",
+			},
+			[]string{"service", "instance"},
+		),
+		// 重试次数
+		retriesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_retries_total",
+				Help: "Total number of retried downstream requests",
+			},
+			[]string{"service", "reason"},
+		),
+		// 重试预算耗尽次数
+		retryBudgetExhausted: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_retry_budget_exhausted_total",
+				Help: "Total number of retries skipped because the retry budget was exhausted",
+			},
+			[]string{"service"},
+		),
+		// 对冲请求数
+		hedgedRequests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_hedged_requests_total",
+				Help: "Total number of hedged requests sent to another instance",
+			},
+			[]string{"service"},
+		),
+		// 对冲请求胜出次数
+		hedgeWins: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gateway_hedge_wins_total",
+				Help: "Total number of hedged requests that returned before the original request",
+			},
+			[]string{"service"},
+		),
 	}
 }
 
@@ -207,6 +368,83 @@ func (m *Metrics) RecordCacheMiss(path string) {
 	m.cacheMisses.WithLabelValues(path).Inc()
 }
 
+// RecordWebSocketOpened 记录 WebSocket 连接建立
+func (m *Metrics) RecordWebSocketOpened(service string) {
+	m.websocketConnections.WithLabelValues(service).Inc()
+	m.websocketConnectionsTotal.WithLabelValues(service).Inc()
+}
+
+// RecordWebSocketClosed 记录 WebSocket 连接关闭
+func (m *Metrics) RecordWebSocketClosed(service string) {
+	m.websocketConnections.WithLabelValues(service).Dec()
+}
+
+// RecordTrafficSplit 记录流量拆分结果
+func (m *Metrics) RecordTrafficSplit(service, version string) {
+	m.trafficSplitTotal.WithLabelValues(service, version).Inc()
+}
+
+// SetConcurrency 设置并发限制器的当前并发数和等待队列长度
+func (m *Metrics) SetConcurrency(limiter string, inFlight, queued int) {
+	m.concurrencyInFlight.WithLabelValues(limiter).Set(float64(inFlight))
+	m.concurrencyQueueDepth.WithLabelValues(limiter).Set(float64(queued))
+}
+
+// RecordConcurrencyWait 记录排队等待时间
+func (m *Metrics) RecordConcurrencyWait(limiter string, wait time.Duration) {
+	m.concurrencyQueueWait.WithLabelValues(limiter).Observe(wait.Seconds())
+}
+
+// RecordConcurrencyRejected 记录并发限制拒绝的请求
+func (m *Metrics) RecordConcurrencyRejected(limiter, reason string) {
+	m.concurrencyRejected.WithLabelValues(limiter, reason).Inc()
+}
+
+// SetAdaptiveLimit 设置自适应并发上限和窗口 p90 延迟
+func (m *Metrics) SetAdaptiveLimit(service string, limit int, p90 time.Duration) {
+	m.adaptiveLimit.WithLabelValues(service).Set(float64(limit))
+	m.adaptiveLatencyP90.WithLabelValues(service).Set(p90.Seconds())
+}
+
+// RecordAdaptiveShed 记录自适应并发限制削减的请求
+func (m *Metrics) RecordAdaptiveShed(service, priority string) {
+	m.adaptiveShed.WithLabelValues(service, priority).Inc()
+}
+
+// RecordOutlierEjection 记录异常实例驱逐
+func (m *Metrics) RecordOutlierEjection(service, reason string) {
+	m.outlierEjections.WithLabelValues(service, reason).Inc()
+}
+
+// SetInstanceHealth 设置实例的主动健康检查状态
+func (m *Metrics) SetInstanceHealth(service, instance string, healthy bool) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	m.instanceHealthy.WithLabelValues(service, instance).Set(value)
+}
+
+// RecordRetry 记录重试
+func (m *Metrics) RecordRetry(service, reason string) {
+	m.retriesTotal.WithLabelValues(service, reason).Inc()
+}
+
+// RecordRetryBudgetExhausted 记录重试预算耗尽
+func (m *Metrics) RecordRetryBudgetExhausted(service string) {
+	m.retryBudgetExhausted.WithLabelValues(service).Inc()
+}
+
+// RecordHedge 记录对冲请求
+func (m *Metrics) RecordHedge(service string) {
+	m.hedgedRequests.WithLabelValues(service).Inc()
+}
+
+// RecordHedgeWin 记录对冲请求胜出
+func (m *Metrics) RecordHedgeWin(service string) {
+	m.hedgeWins.WithLabelValues(service).Inc()
+}
+
 // GetRegistry 获取 Prometheus 注册表（用于暴露指标）
 // 注意：promauto 使用默认注册表，这里返回 nil 表示使用默认注册表
 func (m *Metrics) GetRegistry() *prometheus.Registry {
@@ -279,3 +517,45 @@ func (m *MetricsMiddleware) RecordCacheMiss(ctx context.Context, path string) {
 	m.metrics.RecordCacheMiss(path)
 }
 
+// RecordWebSocketOpened 记录 WebSocket 连接建立
+func (m *MetricsMiddleware) RecordWebSocketOpened(ctx context.Context, service string) {
+	m.metrics.RecordWebSocketOpened(service)
+}
+
+// RecordWebSocketClosed 记录 WebSocket 连接关闭
+func (m *MetricsMiddleware) RecordWebSocketClosed(ctx context.Context, service string) {
+	m.metrics.RecordWebSocketClosed(service)
+}
+
+// SetConcurrency 设置并发限制器的当前并发数和等待队列长度
+func (m *MetricsMiddleware) SetConcurrency(limiter string, inFlight, queued int) {
+	m.metrics.SetConcurrency(limiter, inFlight, queued)
+}
+
+// RecordConcurrencyWait 记录排队等待时间
+func (m *MetricsMiddleware) RecordConcurrencyWait(ctx context.Context, limiter string, wait time.Duration) {
+	m.metrics.RecordConcurrencyWait(limiter, wait)
+}
+
+// RecordConcurrencyRejected 记录并发限制拒绝的请求
+func (m *MetricsMiddleware) RecordConcurrencyRejected(ctx context.Context, limiter, reason string) {
+	m.metrics.RecordConcurrencyRejected(limiter, reason)
+	log.Warn(ctx, "请求被并发限制拒绝",
+		log.String("limiter", limiter),
+		log.String("reason", reason),
+	)
+}
+
+// SetAdaptiveLimit 设置自适应并发上限和窗口 p90 延迟
+func (m *MetricsMiddleware) SetAdaptiveLimit(service string, limit int, p90 time.Duration) {
+	m.metrics.SetAdaptiveLimit(service, limit, p90)
+}
+
+// RecordAdaptiveShed 记录自适应并发限制削减的请求
+func (m *MetricsMiddleware) RecordAdaptiveShed(ctx context.Context, service, priority string) {
+	m.metrics.RecordAdaptiveShed(service, priority)
+	log.Warn(ctx, "下游延迟升高，请求被削减",
+		log.String("service", service),
+		log.String("priority", priority),
+	)
+}
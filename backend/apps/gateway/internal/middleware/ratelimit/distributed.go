@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"StructForge/backend/common/cache"
+	"StructForge/backend/common/log"
+)
+
+// 限流器类型
+const (
+	// TypeLocal 本地令牌桶（每个网关实例独立计数）
+	TypeLocal = "local"
+	// TypeDistributed 分布式滑动窗口（计数存储在共享缓存中，多个网关实例共享配额）
+	TypeDistributed = "distributed"
+)
+
+// DistributedLimiter 分布式限流器（滑动窗口计数）
+// 窗口长度为 burst/qps 秒，窗口内最多允许 burst 个请求；
+// 当前窗口计数 + 上一窗口计数 × 剩余比例 作为估算值，避免固定窗口边界处的突发流量；
+// 共享缓存不可用时降级为本地令牌桶，缓存恢复后自动切回
+type DistributedLimiter struct {
+	store  cache.Cache
+	prefix string
+	// 窗口内允许的请求数
+	limit int64
+	// 窗口长度
+	window time.Duration
+	// 降级使用的本地限流器
+	fallback *TokenBucketLimiter
+	// 是否处于降级状态
+	degraded atomic.Bool
+}
+
+// NewDistributedLimiter 创建分布式限流器
+// prefix 用于区分不同路由的计数键
+func NewDistributedLimiter(store cache.Cache, prefix string, qps, burst int) *DistributedLimiter {
+	if qps <= 0 {
+		qps = 100 // 默认值
+	}
+	if burst <= 0 {
+		burst = 200 // 默认值
+	}
+
+	limit := int64(burst)
+	window := time.Duration(burst) * time.Second / time.Duration(qps)
+	if window < time.Second {
+		// 窗口过短时计数误差较大，按1秒窗口、QPS 限制
+		window = time.Second
+		limit = int64(qps)
+	}
+
+	return &DistributedLimiter{
+		store:    store,
+		prefix:   prefix,
+		limit:    limit,
+		window:   window,
+		fallback: NewTokenBucketLimiter(qps, burst),
+	}
+}
+
+// Allow 检查是否允许请求
+func (l *DistributedLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	result, err := l.Take(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return result.Allowed, nil
+}
+
+// Take 检查是否允许请求，并返回剩余配额和重置时间
+func (l *DistributedLimiter) Take(ctx context.Context, key string) (*Result, error) {
+	now := time.Now()
+	index := now.UnixNano() / int64(l.window)
+	currKey := l.windowKey(key, index)
+	prevKey := l.windowKey(key, index-1)
+
+	count, err := l.store.Increment(ctx, currKey, 1)
+	if err != nil {
+		return l.allowLocal(ctx, key, err)
+	}
+	if count == 1 {
+		// 保留两个窗口，供下一个窗口计算估算值
+		if err := l.store.Expire(ctx, currKey, 2*l.window); err != nil {
+			return l.allowLocal(ctx, key, err)
+		}
+	}
+
+	values, err := l.store.MGet(ctx, prevKey)
+	if err != nil {
+		return l.allowLocal(ctx, key, err)
+	}
+	var prev int64
+	if value, ok := values[prevKey]; ok {
+		prev, _ = strconv.ParseInt(string(value), 10, 64)
+	}
+
+	l.recover(ctx)
+
+	// 当前窗口已经过的比例
+	elapsed := float64(now.UnixNano()%int64(l.window)) / float64(l.window)
+	estimated := float64(prev)*(1-elapsed) + float64(count)
+
+	// 当前窗口剩余时间；上一窗口计数在当前窗口结束时清零，当前窗口计数在下一窗口结束时清零
+	windowLeft := time.Duration((1 - elapsed) * float64(l.window))
+	result := &Result{
+		Limit: int(l.limit),
+		Reset: windowLeft + l.window,
+	}
+
+	if estimated > float64(l.limit) {
+		// 被拒绝的请求不计入窗口
+		if _, err := l.store.Decrement(ctx, currKey, 1); err != nil {
+			log.Debug(ctx, "回滚限流计数失败",
+				log.ErrorField(err),
+				log.String("key", currKey),
+			)
+		}
+		result.RetryAfter = l.retryAfter(float64(prev), float64(count-1), elapsed)
+		return result, nil
+	}
+
+	result.Allowed = true
+	result.Remaining = max(int(float64(l.limit)-estimated), 0)
+	return result, nil
+}
+
+// retryAfter 估算估算值降到配额以下（可以再放行一个请求）所需的时间
+// prev、curr 为上一窗口和当前窗口的计数（不含被拒绝的请求），elapsed 为当前窗口已经过的比例
+func (l *DistributedLimiter) retryAfter(prev, curr, elapsed float64) time.Duration {
+	limit := float64(l.limit)
+	window := float64(l.window)
+
+	// 当前窗口内，上一窗口计数按时间线性衰减
+	if over := prev*(1-elapsed) + curr + 1 - limit; prev > 0 && over <= prev*(1-elapsed) {
+		return time.Duration(over / prev * window)
+	}
+
+	// 当前窗口结束后，当前窗口计数在下一窗口内线性衰减
+	wait := (1 - elapsed) * window
+	if curr+1 > limit && curr > 0 {
+		wait += (curr + 1 - limit) / curr * window
+	}
+	return time.Duration(wait)
+}
+
+// Reset 重置限流器（清除指定key的计数）
+func (l *DistributedLimiter) Reset(key string) {
+	index := time.Now().UnixNano() / int64(l.window)
+	_ = l.store.MDelete(context.Background(), l.windowKey(key, index), l.windowKey(key, index-1))
+	l.fallback.Reset(key)
+}
+
+// Degraded 是否已降级为本地限流
+func (l *DistributedLimiter) Degraded() bool {
+	return l.degraded.Load()
+}
+
+// windowKey 生成窗口计数键
+func (l *DistributedLimiter) windowKey(key string, index int64) string {
+	return fmt.Sprintf("%s:%s:%d", l.prefix, key, index)
+}
+
+// allowLocal 共享缓存不可用时使用本地令牌桶
+func (l *DistributedLimiter) allowLocal(ctx context.Context, key string, cause error) (*Result, error) {
+	if l.degraded.CompareAndSwap(false, true) {
+		log.Warn(ctx, "共享缓存不可用，限流降级为本地令牌桶",
+			log.ErrorField(cause),
+			log.String("prefix", l.prefix),
+		)
+	}
+	return l.fallback.Take(ctx, key)
+}
+
+// recover 共享缓存恢复后切回分布式限流
+func (l *DistributedLimiter) recover(ctx context.Context) {
+	if l.degraded.CompareAndSwap(true, false) {
+		log.Info(ctx, "共享缓存已恢复，切回分布式限流",
+			log.String("prefix", l.prefix),
+		)
+	}
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 限流响应头
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// SetHeaders 将限流结果写入响应头（未限流时不写入）
+// RateLimit-Reset 和 Retry-After 为秒数（向上取整），Retry-After 仅在请求被拒绝时写入
+func SetHeaders(header http.Header, result *Result) {
+	if result == nil || result.Limit <= 0 {
+		return
+	}
+
+	header.Set(HeaderLimit, strconv.Itoa(result.Limit))
+	header.Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+	header.Set(HeaderReset, strconv.Itoa(ceilSeconds(result.Reset)))
+	if !result.Allowed {
+		header.Set(HeaderRetryAfter, strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
+	}
+}
+
+// ceilSeconds 将时间向上取整为秒数
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
+	"StructForge/backend/common/middleware/clientip"
+)
+
+// 限流维度
+const (
+	// KeyByRoute 整个路由共享配额
+	KeyByRoute = "route"
+	// KeyByIP 按客户端真实IP
+	KeyByIP = "ip"
+	// KeyByUser 按已认证用户（未认证时按客户端IP）
+	KeyByUser = "user"
+	// KeyByHeaderPrefix 按请求头的值（如 header:X-API-Key，请求头缺失时按客户端IP）
+	KeyByHeaderPrefix = "header:"
+)
+
+// 配额档位
+const (
+	TierAnonymous     = "anonymous"
+	TierAuthenticated = "authenticated"
+)
+
+// Tier 配额档位
+type Tier struct {
+	QPS   int
+	Burst int
+}
+
+// Policy 路由限流策略
+type Policy struct {
+	// 限流器类型（local 或 distributed）
+	Type string
+	// 默认配额
+	QPS   int
+	Burst int
+	// 限流维度（可组合，为空时整个路由共享配额）
+	KeyBy []string
+	// 未认证调用方的配额（为空时使用默认配额）
+	Anonymous *Tier
+	// 已认证调用方的配额（为空时使用默认配额）
+	Authenticated *Tier
+}
+
+// Quota 根据调用方是否已认证选择配额，返回档位名称（未配置档位时为空）
+func (p *Policy) Quota(ctx context.Context) (string, int, int) {
+	if _, ok := gatewayMiddleware.GetUserID(ctx); ok {
+		if p.Authenticated != nil {
+			return TierAuthenticated, p.Authenticated.QPS, p.Authenticated.Burst
+		}
+	} else if p.Anonymous != nil {
+		return TierAnonymous, p.Anonymous.QPS, p.Anonymous.Burst
+	}
+	return "", p.QPS, p.Burst
+}
+
+// ValidKeyBy 判断限流维度是否有效
+func ValidKeyBy(keyBy string) bool {
+	switch keyBy {
+	case KeyByRoute, KeyByIP, KeyByUser:
+		return true
+	}
+	name, ok := strings.CutPrefix(keyBy, KeyByHeaderPrefix)
+	return ok && strings.TrimSpace(name) != ""
+}
+
+// ExtractKey 提取限流key（支持路由、IP、用户、请求头维度及其组合）
+// 客户端IP 使用根据可信代理解析出的真实IP，用户使用已验证的 JWT 主体
+func ExtractKey(ctx context.Context, req *http.Request, path string, keyBy []string) string {
+	parts := make([]string, 0, len(keyBy))
+	for _, dimension := range keyBy {
+		switch {
+		case dimension == KeyByRoute:
+			continue
+		case dimension == KeyByIP:
+			parts = append(parts, "ip="+requestIP(ctx, req))
+		case dimension == KeyByUser:
+			if userID, ok := gatewayMiddleware.GetUserID(ctx); ok {
+				parts = append(parts, "user="+strconv.FormatInt(userID, 10))
+			} else {
+				parts = append(parts, "anonymous="+requestIP(ctx, req))
+			}
+		case strings.HasPrefix(dimension, KeyByHeaderPrefix):
+			name := strings.TrimSpace(strings.TrimPrefix(dimension, KeyByHeaderPrefix))
+			if value := requestHeader(req, name); value != "" {
+				// 请求头可能是 API Key 等敏感信息，使用摘要作为key
+				sum := sha256.Sum256([]byte(value))
+				parts = append(parts, strings.ToLower(name)+"="+hex.EncodeToString(sum[:8]))
+			} else {
+				parts = append(parts, "ip="+requestIP(ctx, req))
+			}
+		}
+	}
+
+	// 默认使用路径作为key（API级别限流）
+	if len(parts) == 0 {
+		return path
+	}
+	return path + "|" + strings.Join(parts, "|")
+}
+
+// requestIP 获取客户端真实IP（优先使用网关解析后存入 Context 的地址）
+func requestIP(ctx context.Context, req *http.Request) string {
+	if ip, ok := clientip.FromContext(ctx); ok {
+		return ip
+	}
+	if req != nil {
+		return clientip.HostOnly(req.RemoteAddr)
+	}
+	return ""
+}
+
+// requestHeader 获取请求头的值
+func requestHeader(req *http.Request, name string) string {
+	if req == nil {
+		return ""
+	}
+	return req.Header.Get(name)
+}
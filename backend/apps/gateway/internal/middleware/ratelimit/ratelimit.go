@@ -3,9 +3,13 @@ package ratelimit
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"StructForge/backend/common/cache"
 	"StructForge/backend/common/log"
 )
 
@@ -13,10 +17,26 @@ import (
 type Limiter interface {
 	// Allow 检查是否允许请求
 	Allow(ctx context.Context, key string) (bool, error)
+	// Take 检查是否允许请求，并返回剩余配额和重置时间
+	Take(ctx context.Context, key string) (*Result, error)
 	// Reset 重置限流器
 	Reset(key string)
 }
 
+// Result 限流检查结果
+type Result struct {
+	// 是否允许请求
+	Allowed bool
+	// 配额（桶容量或窗口内允许的请求数，0 表示未限流）
+	Limit int
+	// 剩余配额
+	Remaining int
+	// 配额完全恢复所需时间
+	Reset time.Duration
+	// 被拒绝时建议的重试等待时间
+	RetryAfter time.Duration
+}
+
 // TokenBucketLimiter Token Bucket 限流器
 type TokenBucketLimiter struct {
 	// QPS: 每秒允许的请求数
@@ -70,6 +90,15 @@ func NewTokenBucketLimiter(qps, burst int) *TokenBucketLimiter {
 
 // Allow 检查是否允许请求
 func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	result, err := l.Take(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return result.Allowed, nil
+}
+
+// Take 检查是否允许请求，并返回剩余令牌数和重置时间
+func (l *TokenBucketLimiter) Take(ctx context.Context, key string) (*Result, error) {
 	l.mu.RLock()
 	bucket, exists := l.buckets[key]
 	l.mu.RUnlock()
@@ -103,14 +132,30 @@ func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (bool, error
 	bucket.tokens = min(bucket.capacity, bucket.tokens+tokensToAdd)
 	bucket.lastUpdate = now
 
+	result := &Result{
+		Limit: l.burst,
+	}
+
 	// 检查是否有足够的令牌
 	if bucket.tokens >= 1.0 {
 		bucket.tokens -= 1.0
-		return true, nil
+		result.Allowed = true
+	} else {
+		// 被限流，等待下一个令牌
+		result.RetryAfter = tokenDuration(1.0-bucket.tokens, bucket.rate)
 	}
 
-	// 被限流
-	return false, nil
+	result.Remaining = int(bucket.tokens)
+	result.Reset = tokenDuration(bucket.capacity-bucket.tokens, bucket.rate)
+	return result, nil
+}
+
+// tokenDuration 按速率补充指定数量令牌所需的时间
+func tokenDuration(tokens, rate float64) time.Duration {
+	if tokens <= 0 || rate <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / rate * float64(time.Second))
 }
 
 // Reset 重置限流器（清除指定key的令牌桶）
@@ -120,6 +165,13 @@ func (l *TokenBucketLimiter) Reset(key string) {
 	delete(l.buckets, key)
 }
 
+// ActiveKeys 当前活跃的限流key数量（令牌桶数量）
+func (l *TokenBucketLimiter) ActiveKeys() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.buckets)
+}
+
 // cleanup 定期清理不活跃的令牌桶
 func (l *TokenBucketLimiter) cleanup() {
 	ticker := time.NewTicker(l.cleanupInterval)
@@ -150,28 +202,78 @@ func min(a, b float64) float64 {
 
 // RateLimitManager 限流管理器（管理多个限流器）
 type RateLimitManager struct {
-	limiters map[string]Limiter // key: route path, value: limiter
+	limiters map[string]*limiterEntry // key: 类型、路由路径和配额
 	mu       sync.RWMutex
+	// 分布式限流使用的共享缓存（为空时使用全局缓存）
+	store cache.Cache
 }
 
-// NewRateLimitManager 创建限流管理器
+// limiterEntry 限流器及其使用统计
+type limiterEntry struct {
+	limiter Limiter
+	path    string
+	typ     string
+	qps     int
+	burst   int
+	// 放行和拒绝的请求数
+	allowed  atomic.Int64
+	rejected atomic.Int64
+}
+
+// LimiterStats 限流器使用统计
+type LimiterStats struct {
+	// 路由路径（分档位配额时为 路径#档位）
+	Path string `json:"path"`
+	// 限流器类型
+	Type  string `json:"type"`
+	QPS   int    `json:"qps"`
+	Burst int    `json:"burst"`
+	// 放行的请求数
+	Allowed int64 `json:"allowed"`
+	// 被拒绝的请求数
+	Rejected int64 `json:"rejected"`
+	// 活跃的限流key数量（仅本地令牌桶）
+	ActiveKeys int `json:"active_keys,omitempty"`
+	// 是否已降级为本地限流（仅分布式限流）
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// NewRateLimitManager 创建限流管理器（分布式限流使用全局缓存）
 func NewRateLimitManager() *RateLimitManager {
 	return &RateLimitManager{
-		limiters: make(map[string]Limiter),
+		limiters: make(map[string]*limiterEntry),
+	}
+}
+
+// NewRateLimitManagerWithStore 创建使用指定共享缓存的限流管理器
+func NewRateLimitManagerWithStore(store cache.Cache) *RateLimitManager {
+	return &RateLimitManager{
+		limiters: make(map[string]*limiterEntry),
+		store:    store,
 	}
 }
 
 // GetLimiter 获取或创建限流器
-func (m *RateLimitManager) GetLimiter(path string, qps, burst int) Limiter {
+// limiterType 为 distributed 时使用共享缓存计数，共享缓存未初始化时退化为本地令牌桶
+func (m *RateLimitManager) GetLimiter(path, limiterType string, qps, burst int) Limiter {
+	return m.getEntry(path, limiterType, qps, burst).limiter
+}
+
+// getEntry 获取或创建限流器及其使用统计
+func (m *RateLimitManager) getEntry(path, limiterType string, qps, burst int) *limiterEntry {
+	if limiterType == "" {
+		limiterType = TypeLocal
+	}
+
 	// 生成限流器key
-	key := fmt.Sprintf("%s:qps:%d:burst:%d", path, qps, burst)
+	key := fmt.Sprintf("%s:%s:qps:%d:burst:%d", limiterType, path, qps, burst)
 
 	m.mu.RLock()
-	limiter, exists := m.limiters[key]
+	entry, exists := m.limiters[key]
 	m.mu.RUnlock()
 
 	if exists {
-		return limiter
+		return entry
 	}
 
 	// 创建新的限流器
@@ -179,59 +281,122 @@ func (m *RateLimitManager) GetLimiter(path string, qps, burst int) Limiter {
 	defer m.mu.Unlock()
 
 	// 双重检查
-	if limiter, exists = m.limiters[key]; !exists {
-		limiter = NewTokenBucketLimiter(qps, burst)
-		m.limiters[key] = limiter
+	if entry, exists = m.limiters[key]; !exists {
+		entry = &limiterEntry{
+			limiter: m.newLimiter(path, limiterType, qps, burst),
+			path:    path,
+			typ:     limiterType,
+			qps:     qps,
+			burst:   burst,
+		}
+		m.limiters[key] = entry
 	}
 
-	return limiter
+	return entry
 }
 
-// ExtractKey 提取限流key（支持IP、用户、API级别）
-func ExtractKey(ctx context.Context, path string, requireAuth bool) string {
-	// 默认使用路径作为key（API级别限流）
-	key := path
+// Stats 获取所有限流器的使用统计（按路径排序）
+func (m *RateLimitManager) Stats() []LimiterStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := make([]LimiterStats, 0, len(m.limiters))
+	for _, entry := range m.limiters {
+		item := LimiterStats{
+			Path:     entry.path,
+			Type:     entry.typ,
+			QPS:      entry.qps,
+			Burst:    entry.burst,
+			Allowed:  entry.allowed.Load(),
+			Rejected: entry.rejected.Load(),
+		}
+		switch limiter := entry.limiter.(type) {
+		case *TokenBucketLimiter:
+			item.ActiveKeys = limiter.ActiveKeys()
+		case *DistributedLimiter:
+			item.Degraded = limiter.Degraded()
+		}
+		stats = append(stats, item)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Path != stats[j].Path {
+			return stats[i].Path < stats[j].Path
+		}
+		return stats[i].QPS < stats[j].QPS
+	})
+	return stats
+}
+
+// newLimiter 根据类型创建限流器
+func (m *RateLimitManager) newLimiter(path, limiterType string, qps, burst int) Limiter {
+	if limiterType != TypeDistributed {
+		return NewTokenBucketLimiter(qps, burst)
+	}
 
-	// 如果支持IP级别限流，可以从请求中提取IP
-	// 如果支持用户级别限流，可以从JWT中提取用户ID
-	// 这里先实现API级别，后续可以扩展
+	store := m.store
+	if store == nil {
+		store = cache.GetGlobalCache()
+	}
+	if store == nil {
+		log.Warn(context.Background(), "共享缓存未初始化，分布式限流退化为本地令牌桶",
+			log.String("path", path),
+		)
+		return NewTokenBucketLimiter(qps, burst)
+	}
 
-	return key
+	prefix := fmt.Sprintf("ratelimit:%s:%d:%d", path, qps, burst)
+	return NewDistributedLimiter(store, prefix, qps, burst)
 }
 
 // CheckRateLimit 检查限流（在handler中调用）
-func CheckRateLimit(ctx context.Context, manager *RateLimitManager, path string, qps, burst int, requireAuth bool) (bool, error) {
+// 根据调用方是否已认证选择配额档位，并按 policy.KeyBy 从请求中提取限流key
+// 返回的结果总是非空，Limit 为 0 表示未限流
+func CheckRateLimit(ctx context.Context, manager *RateLimitManager, req *http.Request, path string, policy *Policy) (*Result, error) {
+	if policy == nil {
+		return &Result{Allowed: true}, nil
+	}
+
+	// 选择配额档位
+	tier, qps, burst := policy.Quota(ctx)
 	if qps <= 0 || burst <= 0 {
 		// 如果没有配置限流，直接通过
-		return true, nil
+		return &Result{Allowed: true}, nil
 	}
 
 	// 提取限流key
-	key := ExtractKey(ctx, path, requireAuth)
+	key := ExtractKey(ctx, req, path, policy.KeyBy)
 
-	// 获取限流器
-	limiter := manager.GetLimiter(path, qps, burst)
+	// 获取限流器（不同档位使用不同的限流器）
+	limiterName := path
+	if tier != "" {
+		limiterName = path + "#" + tier
+	}
+	entry := manager.getEntry(limiterName, policy.Type, qps, burst)
 
 	// 检查是否允许
-	allowed, err := limiter.Allow(ctx, key)
+	result, err := entry.limiter.Take(ctx, key)
 	if err != nil {
 		log.Error(ctx, "限流检查失败",
 			log.ErrorField(err),
 			log.String("path", path),
 		)
 		// 出错时允许通过，避免影响正常请求
-		return true, nil
+		return &Result{Allowed: true}, nil
 	}
 
-	if !allowed {
+	if !result.Allowed {
+		entry.rejected.Add(1)
 		log.Warn(ctx, "请求被限流",
 			log.String("path", path),
 			log.String("key", key),
+			log.String("tier", tier),
 			log.Int("qps", qps),
 			log.Int("burst", burst),
+			log.Duration("retry_after", result.RetryAfter),
 		)
-		return false, fmt.Errorf("请求过于频繁，请稍后再试")
+		return result, fmt.Errorf("请求过于频繁，请稍后再试")
 	}
 
-	return true, nil
+	entry.allowed.Add(1)
+	return result, nil
 }
@@ -2,8 +2,15 @@ package ratelimit
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
+	"StructForge/backend/common/cache"
+	"StructForge/backend/common/middleware/clientip"
 )
 
 // TestTokenBucket 测试令牌桶算法
@@ -73,32 +80,41 @@ func TestRateLimitManager(t *testing.T) {
 	path2 := "/api/v1/orders"
 
 	// 为不同路径创建限流器
-	allowed1, err := CheckRateLimit(context.Background(), mgr, path1, 10, 5, false)
+	result1, err := CheckRateLimit(context.Background(), mgr, nil, path1, &Policy{Type: TypeLocal, QPS: 10, Burst: 5})
 	if err != nil {
 		t.Fatalf("限流检查失败: %v", err)
 	}
-	if !allowed1 {
+	if !result1.Allowed {
 		t.Error("第一次请求应该允许")
 	}
 
-	allowed2, err := CheckRateLimit(context.Background(), mgr, path2, 10, 5, false)
+	result2, err := CheckRateLimit(context.Background(), mgr, nil, path2, &Policy{Type: TypeLocal, QPS: 10, Burst: 5})
 	if err != nil {
 		t.Fatalf("限流检查失败: %v", err)
 	}
-	if !allowed2 {
+	if !result2.Allowed {
 		t.Error("不同路径的请求应该允许")
 	}
 
 	// 快速请求，应该触发限流
 	for i := 0; i < 10; i++ {
-		CheckRateLimit(context.Background(), mgr, path1, 10, 5, false)
+		CheckRateLimit(context.Background(), mgr, nil, path1, &Policy{Type: TypeLocal, QPS: 10, Burst: 5})
 	}
 
 	// 第11次应该被限流（超过burst）
-	allowed, _ := CheckRateLimit(context.Background(), mgr, path1, 10, 5, false)
-	if allowed {
+	result, _ := CheckRateLimit(context.Background(), mgr, nil, path1, &Policy{Type: TypeLocal, QPS: 10, Burst: 5})
+	if result.Allowed {
 		t.Error("超过burst后应该被限流")
 	}
+
+	// 使用统计
+	stats := mgr.Stats()
+	if len(stats) != 2 || stats[0].Path != path2 || stats[1].Path != path1 {
+		t.Fatalf("限流器统计不正确: %+v", stats)
+	}
+	if stats[1].Allowed+stats[1].Rejected != 12 || stats[1].Rejected == 0 || stats[1].ActiveKeys != 1 {
+		t.Errorf("限流器使用统计不正确: %+v", stats[1])
+	}
 }
 
 // TestRateLimitCleanup 测试限流器清理
@@ -108,7 +124,7 @@ func TestRateLimitCleanup(t *testing.T) {
 	// 创建多个限流器
 	for i := 0; i < 10; i++ {
 		path := "/api/v1/test" + string(rune(i))
-		CheckRateLimit(context.Background(), mgr, path, 10, 5, false)
+		CheckRateLimit(context.Background(), mgr, nil, path, &Policy{Type: TypeLocal, QPS: 10, Burst: 5})
 	}
 
 	// 等待清理时间（默认5分钟）
@@ -121,3 +137,294 @@ func TestRateLimitCleanup(t *testing.T) {
 		t.Error("应该有多个限流器")
 	}
 }
+
+// newMemoryStore 创建内存缓存（测试用）
+func newMemoryStore(t *testing.T) cache.Cache {
+	config := cache.DefaultConfig()
+	config.AdapterType = cache.AdapterMemory
+	store, err := cache.NewCache(config)
+	if err != nil {
+		t.Fatalf("创建内存缓存失败: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+// failingStore 不可用的共享缓存（测试降级）
+type failingStore struct {
+	cache.Cache
+}
+
+func (s *failingStore) Increment(ctx context.Context, key string, delta int64) (int64, error) {
+	return 0, cache.ErrConnection
+}
+
+// TestDistributedLimiter 测试分布式限流（多个限流器共享计数）
+func TestDistributedLimiter(t *testing.T) {
+	store := newMemoryStore(t)
+	ctx := context.Background()
+
+	// 模拟两个网关实例
+	limiterA := NewDistributedLimiter(store, "test", 5, 5)
+	limiterB := NewDistributedLimiter(store, "test", 5, 5)
+
+	allowedCount := 0
+	for i := 0; i < 10; i++ {
+		limiter := limiterA
+		if i%2 == 1 {
+			limiter = limiterB
+		}
+		allowed, err := limiter.Allow(ctx, "key")
+		if err != nil {
+			t.Fatalf("限流检查失败: %v", err)
+		}
+		if allowed {
+			allowedCount++
+		}
+	}
+	if allowedCount > 5 {
+		t.Errorf("两个实例共享配额，最多允许 5 个请求，实际 %d", allowedCount)
+	}
+	if allowedCount == 0 {
+		t.Error("至少应该允许部分请求")
+	}
+
+	// 不同key独立计数
+	allowed, _ := limiterA.Allow(ctx, "other")
+	if !allowed {
+		t.Error("不同key应该独立计数")
+	}
+
+	// 重置后应该允许
+	limiterA.Reset("key")
+	allowed, _ = limiterA.Allow(ctx, "key")
+	if !allowed {
+		t.Error("重置后应该允许")
+	}
+}
+
+// TestDistributedLimiterFallback 测试共享缓存不可用时降级为本地限流
+func TestDistributedLimiterFallback(t *testing.T) {
+	limiter := NewDistributedLimiter(&failingStore{Cache: newMemoryStore(t)}, "test", 10, 3)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(ctx, "key")
+		if err != nil {
+			t.Fatalf("降级后不应该返回错误: %v", err)
+		}
+		if !allowed {
+			t.Errorf("第 %d 次应该允许", i+1)
+		}
+	}
+	if !limiter.Degraded() {
+		t.Error("应该处于降级状态")
+	}
+
+	// 本地令牌桶仍然生效
+	allowed, _ := limiter.Allow(ctx, "key")
+	if allowed {
+		t.Error("应该被本地限流器拒绝")
+	}
+}
+
+// TestRateLimitManagerType 测试按类型创建限流器
+func TestRateLimitManagerType(t *testing.T) {
+	mgr := NewRateLimitManagerWithStore(newMemoryStore(t))
+
+	if _, ok := mgr.GetLimiter("/api/a", TypeLocal, 10, 5).(*TokenBucketLimiter); !ok {
+		t.Error("local 类型应该创建令牌桶限流器")
+	}
+	if _, ok := mgr.GetLimiter("/api/a", TypeDistributed, 10, 5).(*DistributedLimiter); !ok {
+		t.Error("distributed 类型应该创建分布式限流器")
+	}
+	if _, ok := mgr.GetLimiter("/api/a", "", 10, 5).(*TokenBucketLimiter); !ok {
+		t.Error("未指定类型时应该使用令牌桶限流器")
+	}
+}
+
+// TestExtractKey 测试限流维度
+func TestExtractKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.RemoteAddr = "203.0.113.9:4567"
+	req.Header.Set("X-API-Key", "secret-key")
+
+	anonCtx := clientip.NewContext(context.Background(), "198.51.100.7")
+	userCtx := gatewayMiddleware.WithUser(anonCtx, 42, "alice", nil)
+
+	testCases := []struct {
+		name  string
+		ctx   context.Context
+		keyBy []string
+		want  string
+	}{
+		{"默认按路由", anonCtx, nil, "/api/v1/users"},
+		{"显式按路由", anonCtx, []string{KeyByRoute}, "/api/v1/users"},
+		{"按客户端IP", anonCtx, []string{KeyByIP}, "/api/v1/users|ip=198.51.100.7"},
+		{"按用户", userCtx, []string{KeyByUser}, "/api/v1/users|user=42"},
+		{"未认证按用户退化为IP", anonCtx, []string{KeyByUser}, "/api/v1/users|anonymous=198.51.100.7"},
+		{"组合维度", userCtx, []string{KeyByUser, KeyByIP}, "/api/v1/users|user=42|ip=198.51.100.7"},
+		{"请求头缺失退化为IP", anonCtx, []string{"header:X-Tenant"}, "/api/v1/users|ip=198.51.100.7"},
+		{"Context 中无IP时使用直连地址", context.Background(), []string{KeyByIP}, "/api/v1/users|ip=203.0.113.9"},
+	}
+
+	for _, tc := range testCases {
+		if got := ExtractKey(tc.ctx, req, "/api/v1/users", tc.keyBy); got != tc.want {
+			t.Errorf("%s: 期望 %s, 实际 %s", tc.name, tc.want, got)
+		}
+	}
+
+	// 请求头维度不应包含原始值
+	key := ExtractKey(anonCtx, req, "/api/v1/users", []string{"header:X-API-Key"})
+	if strings.Contains(key, "secret-key") || !strings.HasPrefix(key, "/api/v1/users|x-api-key=") {
+		t.Errorf("请求头维度应该使用摘要: %s", key)
+	}
+}
+
+// TestRateLimitPerClient 测试按客户端限流（一个客户端耗尽配额不影响其他客户端）
+func TestRateLimitPerClient(t *testing.T) {
+	mgr := NewRateLimitManager()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders", nil)
+	policy := &Policy{Type: TypeLocal, QPS: 1, Burst: 2, KeyBy: []string{KeyByIP}}
+
+	abusive := clientip.NewContext(context.Background(), "198.51.100.1")
+	for i := 0; i < 2; i++ {
+		CheckRateLimit(abusive, mgr, req, "/api/v1/orders", policy)
+	}
+	if result, _ := CheckRateLimit(abusive, mgr, req, "/api/v1/orders", policy); result.Allowed {
+		t.Error("耗尽配额的客户端应该被限流")
+	}
+
+	other := clientip.NewContext(context.Background(), "198.51.100.2")
+	if result, _ := CheckRateLimit(other, mgr, req, "/api/v1/orders", policy); !result.Allowed {
+		t.Error("其他客户端不应该受影响")
+	}
+}
+
+// TestRateLimitTiers 测试未认证 / 已认证调用方的独立配额
+func TestRateLimitTiers(t *testing.T) {
+	policy := &Policy{
+		QPS:           10,
+		Burst:         10,
+		Anonymous:     &Tier{QPS: 1, Burst: 1},
+		Authenticated: &Tier{QPS: 100, Burst: 200},
+	}
+
+	tier, qps, burst := policy.Quota(context.Background())
+	if tier != TierAnonymous || qps != 1 || burst != 1 {
+		t.Errorf("未认证调用方应该使用 anonymous 档位，实际 %s %d/%d", tier, qps, burst)
+	}
+
+	userCtx := gatewayMiddleware.WithUser(context.Background(), 1, "alice", nil)
+	tier, qps, burst = policy.Quota(userCtx)
+	if tier != TierAuthenticated || qps != 100 || burst != 200 {
+		t.Errorf("已认证调用方应该使用 authenticated 档位，实际 %s %d/%d", tier, qps, burst)
+	}
+
+	// 未配置档位时使用默认配额
+	tier, qps, burst = (&Policy{QPS: 10, Burst: 20}).Quota(userCtx)
+	if tier != "" || qps != 10 || burst != 20 {
+		t.Errorf("未配置档位时应该使用默认配额，实际 %s %d/%d", tier, qps, burst)
+	}
+
+	// 匿名配额耗尽不影响已认证用户
+	mgr := NewRateLimitManager()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/search", nil)
+	CheckRateLimit(context.Background(), mgr, req, "/api/v1/search", policy)
+	if result, _ := CheckRateLimit(context.Background(), mgr, req, "/api/v1/search", policy); result.Allowed {
+		t.Error("匿名配额耗尽后应该被限流")
+	}
+	if result, _ := CheckRateLimit(userCtx, mgr, req, "/api/v1/search", policy); !result.Allowed {
+		t.Error("已认证用户使用独立配额，不应该被限流")
+	}
+}
+
+// TestValidKeyBy 测试限流维度校验
+func TestValidKeyBy(t *testing.T) {
+	for _, keyBy := range []string{"route", "ip", "user", "header:X-API-Key"} {
+		if !ValidKeyBy(keyBy) {
+			t.Errorf("%s 应该有效", keyBy)
+		}
+	}
+	for _, keyBy := range []string{"", "tenant", "header:", "header: "} {
+		if ValidKeyBy(keyBy) {
+			t.Errorf("%q 应该无效", keyBy)
+		}
+	}
+}
+
+// TestTokenBucketResult 测试令牌桶返回剩余配额和重置时间
+func TestTokenBucketResult(t *testing.T) {
+	limiter := NewTokenBucketLimiter(10, 5) // QPS=10, Burst=5
+	ctx := context.Background()
+
+	result, err := limiter.Take(ctx, "test")
+	if err != nil {
+		t.Fatalf("限流检查失败: %v", err)
+	}
+	if !result.Allowed || result.Limit != 5 || result.Remaining != 4 {
+		t.Errorf("期望允许且剩余 4/5，实际 %+v", result)
+	}
+	if result.Reset <= 0 || result.Reset > 150*time.Millisecond {
+		t.Errorf("补充 1 个令牌约需 100ms，实际 %v", result.Reset)
+	}
+
+	for i := 0; i < 4; i++ {
+		limiter.Take(ctx, "test")
+	}
+	result, _ = limiter.Take(ctx, "test")
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("令牌耗尽后应该被拒绝，实际 %+v", result)
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 100*time.Millisecond {
+		t.Errorf("重试等待时间应该在 100ms 以内，实际 %v", result.RetryAfter)
+	}
+}
+
+// TestDistributedLimiterResult 测试分布式限流返回剩余配额和重试时间
+func TestDistributedLimiterResult(t *testing.T) {
+	limiter := NewDistributedLimiter(newMemoryStore(t), "test", 3, 3) // 1秒窗口允许3个请求
+	ctx := context.Background()
+
+	var result *Result
+	for i := 0; i < 4; i++ {
+		result, _ = limiter.Take(ctx, "key")
+		if result.Limit != 3 {
+			t.Fatalf("配额应该是 3，实际 %d", result.Limit)
+		}
+	}
+	if result.Allowed {
+		t.Fatal("超过配额后应该被拒绝")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 2*time.Second {
+		t.Errorf("重试等待时间应该在两个窗口以内，实际 %v", result.RetryAfter)
+	}
+	if result.Reset <= time.Second || result.Reset > 2*time.Second {
+		t.Errorf("重置时间应该在一到两个窗口之间，实际 %v", result.Reset)
+	}
+}
+
+// TestSetHeaders 测试限流响应头
+func TestSetHeaders(t *testing.T) {
+	header := http.Header{}
+	SetHeaders(header, &Result{Allowed: true, Limit: 100, Remaining: 99, Reset: 1500 * time.Millisecond})
+	if header.Get(HeaderLimit) != "100" || header.Get(HeaderRemaining) != "99" || header.Get(HeaderReset) != "2" {
+		t.Errorf("响应头不正确: %v", header)
+	}
+	if header.Get(HeaderRetryAfter) != "" {
+		t.Error("允许的请求不应该包含 Retry-After")
+	}
+
+	header = http.Header{}
+	SetHeaders(header, &Result{Limit: 100, RetryAfter: 10 * time.Millisecond})
+	if header.Get(HeaderRetryAfter) != "1" || header.Get(HeaderRemaining) != "0" {
+		t.Errorf("被拒绝的请求应该包含 Retry-After（至少1秒）: %v", header)
+	}
+
+	// 未限流时不写入
+	header = http.Header{}
+	SetHeaders(header, &Result{Allowed: true})
+	if len(header) != 0 {
+		t.Errorf("未限流时不应该写入响应头: %v", header)
+	}
+}
@@ -0,0 +1,101 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+// BudgetConfig 重试预算配置
+type BudgetConfig struct {
+	// 重试请求数占请求总数的最大百分比
+	Percent float64
+	// 每秒最少允许的重试次数（低流量时保证基本的重试能力）
+	MinRetriesPerSecond int
+	// 统计时间窗口
+	Window time.Duration
+}
+
+// withDefaults 返回填充默认值后的配置
+func (c *BudgetConfig) withDefaults() BudgetConfig {
+	result := *c
+	if result.Percent <= 0 {
+		result.Percent = 20
+	}
+	if result.MinRetriesPerSecond <= 0 {
+		result.MinRetriesPerSecond = 10
+	}
+	if result.Window < time.Second {
+		result.Window = 10 * time.Second
+	}
+	return result
+}
+
+// budgetBucket 每秒的请求数和重试数
+type budgetBucket struct {
+	second   int64
+	requests int
+	retries  int
+}
+
+// Budget 全局重试预算
+// 时间窗口内的重试次数不超过请求总数的一定比例，避免下游故障时重试放大流量（重试风暴）
+type Budget struct {
+	config  BudgetConfig
+	buckets []budgetBucket
+	mu      sync.Mutex
+}
+
+// NewBudget 创建重试预算
+func NewBudget(config *BudgetConfig) *Budget {
+	if config == nil {
+		config = &BudgetConfig{}
+	}
+	resolved := config.withDefaults()
+	return &Budget{
+		config:  resolved,
+		buckets: make([]budgetBucket, int(resolved.Window/time.Second)),
+	}
+}
+
+// bucket 获取当前秒的计数桶（调用方需持有锁）
+func (b *Budget) bucket(now time.Time) *budgetBucket {
+	second := now.Unix()
+	bucket := &b.buckets[int(second%int64(len(b.buckets)))]
+	if bucket.second != second {
+		*bucket = budgetBucket{second: second}
+	}
+	return bucket
+}
+
+// RecordRequest 记录一个请求（首次请求，不含重试）
+func (b *Budget) RecordRequest() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket(time.Now()).requests++
+}
+
+// TryRetry 尝试消耗一次重试预算，预算不足时返回 false
+func (b *Budget) TryRetry() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	current := b.bucket(now)
+	oldest := now.Unix() - int64(len(b.buckets)) + 1
+
+	requests, retries := 0, 0
+	for _, bucket := range b.buckets {
+		if bucket.second >= oldest {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+
+	allowed := max(float64(requests)*b.config.Percent/100, float64(b.config.MinRetriesPerSecond*len(b.buckets)))
+	if float64(retries) >= allowed {
+		return false
+	}
+
+	current.retries++
+	return true
+}
@@ -0,0 +1,184 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 可重试的错误类型
+const (
+	// OnConnectFailure 连接失败（连接被拒绝、DNS 解析失败、网络不可达），请求尚未发送到上游
+	OnConnectFailure = "connect_failure"
+	// OnReset 连接被重置（请求可能已被上游处理，只对幂等请求重试）
+	OnReset = "reset"
+)
+
+// IdempotencyKeyHeader 幂等键请求头，携带此请求头的非幂等请求也允许重试
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentMethods 幂等的 HTTP 方法
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// ValidRetryOn 判断错误类型是否有效
+func ValidRetryOn(on string) bool {
+	return on == OnConnectFailure || on == OnReset
+}
+
+// Config 重试策略配置
+type Config struct {
+	// 最大重试次数（不含首次请求）
+	MaxRetries int
+	// 可重试的错误类型，为空时默认 connect_failure、reset
+	RetryOn []string
+	// 可重试的上游响应状态码，为空时默认 502、503、504
+	RetryOnStatus []int
+	// 是否允许重试非幂等请求（POST、PATCH）
+	RetryNonIdempotent bool
+	// 允许缓冲以便重放的最大请求体大小，超过此大小的请求不重试
+	MaxBodySize int64
+	// 退避基础间隔和最大间隔
+	BaseInterval time.Duration
+	MaxInterval  time.Duration
+}
+
+// Policy 重试策略
+type Policy struct {
+	MaxRetries         int
+	MaxBodySize        int64
+	retryOn            map[string]bool
+	retryOnStatus      map[int]bool
+	retryNonIdempotent bool
+	baseInterval       time.Duration
+	maxInterval        time.Duration
+}
+
+// NewPolicy 根据配置创建重试策略（填充默认值）
+func NewPolicy(config *Config) *Policy {
+	if config == nil {
+		config = &Config{}
+	}
+
+	policy := &Policy{
+		MaxRetries:         max(config.MaxRetries, 0),
+		MaxBodySize:        config.MaxBodySize,
+		retryOn:            make(map[string]bool),
+		retryOnStatus:      make(map[int]bool),
+		retryNonIdempotent: config.RetryNonIdempotent,
+		baseInterval:       config.BaseInterval,
+		maxInterval:        config.MaxInterval,
+	}
+
+	retryOn := config.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = []string{OnConnectFailure, OnReset}
+	}
+	for _, on := range retryOn {
+		policy.retryOn[on] = true
+	}
+
+	retryOnStatus := config.RetryOnStatus
+	if len(retryOnStatus) == 0 {
+		retryOnStatus = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	for _, status := range retryOnStatus {
+		policy.retryOnStatus[status] = true
+	}
+
+	if policy.MaxBodySize <= 0 {
+		policy.MaxBodySize = 64 << 10
+	}
+	if policy.baseInterval <= 0 {
+		policy.baseInterval = 25 * time.Millisecond
+	}
+	if policy.maxInterval <= 0 {
+		policy.maxInterval = time.Second
+	}
+	if policy.maxInterval < policy.baseInterval {
+		policy.maxInterval = policy.baseInterval
+	}
+
+	return policy
+}
+
+// AllowsRequest 判断请求在上游可能已处理后是否允许重试
+// 只重试幂等方法，或携带 Idempotency-Key 的请求，或策略允许重试非幂等请求
+func (p *Policy) AllowsRequest(req *http.Request) bool {
+	return p.retryNonIdempotent || idempotentMethods[req.Method] || req.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// RetryableStatus 判断上游响应状态码是否可重试
+func (p *Policy) RetryableStatus(statusCode int) bool {
+	return p.retryOnStatus[statusCode]
+}
+
+// RetryableError 判断请求错误是否可重试，返回错误类型
+func (p *Policy) RetryableError(err error) (string, bool) {
+	on := Classify(err)
+	if on == "" || !p.retryOn[on] {
+		return on, false
+	}
+	return on, true
+}
+
+// Backoff 计算第 attempt 次重试前的等待时间（指数退避 + 全抖动）
+func (p *Policy) Backoff(attempt int) time.Duration {
+	ceiling := p.maxInterval
+	if attempt < 16 {
+		ceiling = min(p.baseInterval<<max(attempt-1, 0), p.maxInterval)
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// Classify 判断请求错误的类型（无法识别时返回空字符串）
+func Classify(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EHOSTUNREACH) {
+		return OnConnectFailure
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return OnReset
+	}
+
+	errStr := strings.ToLower(err.Error())
+	for _, pattern := range []string{"connection refused", "no such host", "network is unreachable", "no route to host"} {
+		if strings.Contains(errStr, pattern) {
+			return OnConnectFailure
+		}
+	}
+	for _, pattern := range []string{"connection reset", "broken pipe", "server closed idle connection"} {
+		if strings.Contains(errStr, pattern) {
+			return OnReset
+		}
+	}
+	return ""
+}
+
+// Wait 等待指定时间，context 取消时立即返回错误
+func Wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
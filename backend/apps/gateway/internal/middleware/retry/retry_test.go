@@ -0,0 +1,127 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestPolicyAllowsRequest 测试只重试幂等请求或携带幂等键的请求
+func TestPolicyAllowsRequest(t *testing.T) {
+	policy := NewPolicy(&Config{MaxRetries: 2})
+
+	tests := []struct {
+		method string
+		key    string
+		want   bool
+	}{
+		{http.MethodGet, "", true},
+		{http.MethodPut, "", true},
+		{http.MethodDelete, "", true},
+		{http.MethodPost, "", false},
+		{http.MethodPatch, "", false},
+		{http.MethodPost, "order-1", true},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "http://example.com", nil)
+		if tt.key != "" {
+			req.Header.Set(IdempotencyKeyHeader, tt.key)
+		}
+		if got := policy.AllowsRequest(req); got != tt.want {
+			t.Errorf("%s (Idempotency-Key=%q) 是否允许重试: 期望 %v，实际 %v", tt.method, tt.key, tt.want, got)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	if !NewPolicy(&Config{RetryNonIdempotent: true}).AllowsRequest(req) {
+		t.Error("retry_non_idempotent 开启时应该允许重试 POST")
+	}
+}
+
+// TestPolicyRetryable 测试可重试的状态码和错误类型
+func TestPolicyRetryable(t *testing.T) {
+	policy := NewPolicy(nil)
+	if !policy.RetryableStatus(503) || policy.RetryableStatus(500) || policy.RetryableStatus(404) {
+		t.Error("默认只重试 502、503、504")
+	}
+
+	custom := NewPolicy(&Config{RetryOn: []string{OnConnectFailure}, RetryOnStatus: []int{500}})
+	if !custom.RetryableStatus(500) || custom.RetryableStatus(503) {
+		t.Error("应该只重试配置的状态码")
+	}
+
+	refused := fmt.Errorf("dial tcp 127.0.0.1:1: %w", syscall.ECONNREFUSED)
+	if on, ok := custom.RetryableError(refused); !ok || on != OnConnectFailure {
+		t.Errorf("连接被拒绝应该可以重试，实际 %s %v", on, ok)
+	}
+	reset := fmt.Errorf("read: %w", syscall.ECONNRESET)
+	if on, ok := custom.RetryableError(reset); ok || on != OnReset {
+		t.Errorf("未配置 reset 时连接重置不应该重试，实际 %s %v", on, ok)
+	}
+	if _, ok := policy.RetryableError(context.DeadlineExceeded); ok {
+		t.Error("超时不应该重试")
+	}
+}
+
+// TestPolicyBackoff 测试指数退避和抖动上限
+func TestPolicyBackoff(t *testing.T) {
+	policy := NewPolicy(&Config{BaseInterval: 10 * time.Millisecond, MaxInterval: 50 * time.Millisecond})
+	for i := 0; i < 100; i++ {
+		if d := policy.Backoff(1); d < 0 || d > 10*time.Millisecond {
+			t.Fatalf("第 1 次重试的退避时间应该在 0-10ms 之间，实际 %v", d)
+		}
+		if d := policy.Backoff(3); d > 40*time.Millisecond {
+			t.Fatalf("第 3 次重试的退避时间应该不超过 40ms，实际 %v", d)
+		}
+		if d := policy.Backoff(64); d > 50*time.Millisecond {
+			t.Fatalf("退避时间不应该超过最大间隔，实际 %v", d)
+		}
+	}
+}
+
+// TestWaitCanceled 测试等待期间 context 取消时立即返回
+func TestWaitCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if err := Wait(ctx, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("应该返回 context.Canceled，实际 %v", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("context 取消后应该立即返回")
+	}
+}
+
+// TestBudget 测试重试预算
+func TestBudget(t *testing.T) {
+	budget := NewBudget(&BudgetConfig{Percent: 20, MinRetriesPerSecond: 1, Window: 10 * time.Second})
+
+	// 低流量时保证每秒最少重试次数（10 秒窗口内 10 次）
+	for i := 0; i < 10; i++ {
+		if !budget.TryRetry() {
+			t.Fatalf("第 %d 次重试应该在最少重试次数内", i+1)
+		}
+	}
+	if budget.TryRetry() {
+		t.Fatal("超过最少重试次数且请求数不足时不应该允许重试")
+	}
+
+	// 100 个请求最多允许 20 次重试
+	for i := 0; i < 100; i++ {
+		budget.RecordRequest()
+	}
+	allowed := 10
+	for i := 0; i < 50; i++ {
+		if budget.TryRetry() {
+			allowed++
+		}
+	}
+	if allowed != 20 {
+		t.Errorf("100 个请求应该最多允许 20 次重试，实际 %d", allowed)
+	}
+}
@@ -3,6 +3,9 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"maps"
+	"sort"
+	"strconv"
 	"sync"
 
 	"StructForge/backend/common/log"
@@ -24,6 +27,34 @@ type Instance struct {
 	Metadata map[string]string `json:"metadata"`
 }
 
+// Key 获取实例标识（未配置ID时使用 host:port）
+func (i Instance) Key() string {
+	if i.ID != "" {
+		return i.ID
+	}
+	return i.Host + ":" + strconv.Itoa(i.Port)
+}
+
+// sortInstances 按实例标识排序（便于比较实例列表是否变化）
+func sortInstances(instances []Instance) {
+	sort.Slice(instances, func(i, j int) bool { return instances[i].Key() < instances[j].Key() })
+}
+
+// sameInstances 判断两个已排序的实例列表是否相同
+func sameInstances(a, b []Instance) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Host != b[i].Host || a[i].Port != b[i].Port ||
+			a[i].Weight != b[i].Weight || a[i].Healthy != b[i].Healthy ||
+			!maps.Equal(a[i].Metadata, b[i].Metadata) {
+			return false
+		}
+	}
+	return true
+}
+
 // ServiceDiscovery 服务发现接口
 type ServiceDiscovery interface {
 	// GetInstances 获取服务实例列表
@@ -71,6 +102,26 @@ func (d *StaticDiscovery) RegisterService(serviceName string, instances []Instan
 	)
 }
 
+// RemoveService 移除服务（配置热更新删除服务时调用，监听者收到空实例列表）
+func (d *StaticDiscovery) RemoveService(serviceName string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.services[serviceName]; !exists {
+		return
+	}
+	delete(d.services, serviceName)
+
+	// 通知监听者
+	for _, watcher := range d.watchers[serviceName] {
+		watcher(nil)
+	}
+
+	log.Info(context.Background(), "服务已移除（静态）",
+		log.String("service", serviceName),
+	)
+}
+
 // GetInstances 获取服务实例
 func (d *StaticDiscovery) GetInstances(ctx context.Context, serviceName string) ([]Instance, error) {
 	d.mu.RLock()
@@ -0,0 +1,269 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"StructForge/backend/common/log"
+)
+
+// DNS 记录类型
+const (
+	// DNSRecordSRV SRV 记录（记录中包含实例的主机、端口和权重）
+	DNSRecordSRV = "srv"
+	// DNSRecordA A/AAAA 记录（每个地址为一个实例，端口由配置指定）
+	DNSRecordA = "a"
+)
+
+// DNSService 服务的 DNS 记录配置
+type DNSService struct {
+	// 记录类型：srv（默认）、a
+	Type string
+	// 查询的域名
+	Name string
+	// 实例端口（A/AAAA 记录使用）
+	Port int
+}
+
+// DNSConfig DNS 服务发现配置
+type DNSConfig struct {
+	// 服务的 DNS 记录（key 为服务名）
+	Services map[string]DNSService
+	// 最小刷新间隔（TTL 小于该值或查询失败时按该值刷新）
+	MinRefresh time.Duration
+	// 最大刷新间隔
+	MaxRefresh time.Duration
+}
+
+// withDefaults 返回填充默认值后的配置
+func (c *DNSConfig) withDefaults() DNSConfig {
+	result := *c
+	if result.MinRefresh <= 0 {
+		result.MinRefresh = 5 * time.Second
+	}
+	if result.MaxRefresh <= 0 {
+		result.MaxRefresh = 5 * time.Minute
+	}
+	if result.MaxRefresh < result.MinRefresh {
+		result.MaxRefresh = result.MinRefresh
+	}
+	return result
+}
+
+// DNSDiscovery DNS 服务发现
+// 按 SRV 或 A/AAAA 记录解析服务实例，按记录的 TTL 定期刷新，实例变化时通知监听器；
+// 查询失败时保留上一次解析的实例列表
+type DNSDiscovery struct {
+	config    DNSConfig
+	resolver  Resolver
+	instances map[string][]Instance
+	resolved  map[string]bool
+	watchers  map[string][]func([]Instance)
+	mu        sync.RWMutex
+	stop      chan struct{}
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewDNSDiscovery 创建 DNS 服务发现（调用 Start 后开始定期刷新）
+func NewDNSDiscovery(config *DNSConfig, resolver Resolver) *DNSDiscovery {
+	if config == nil {
+		config = &DNSConfig{}
+	}
+	return &DNSDiscovery{
+		config:    config.withDefaults(),
+		resolver:  resolver,
+		instances: make(map[string][]Instance),
+		resolved:  make(map[string]bool),
+		watchers:  make(map[string][]func([]Instance)),
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start 启动所有服务的后台刷新（立即解析一次）
+func (d *DNSDiscovery) Start() {
+	for serviceName := range d.config.Services {
+		d.wg.Add(1)
+		go d.refreshLoop(serviceName)
+	}
+}
+
+// Stop 停止后台刷新
+func (d *DNSDiscovery) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+	d.wg.Wait()
+}
+
+// refreshLoop 按 TTL 定期刷新服务实例
+func (d *DNSDiscovery) refreshLoop(serviceName string) {
+	defer d.wg.Done()
+
+	for {
+		interval := d.refresh(serviceName)
+		timer := time.NewTimer(interval)
+		select {
+		case <-d.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// refresh 解析服务实例并更新缓存，返回下一次刷新的间隔
+func (d *DNSDiscovery) refresh(serviceName string) time.Duration {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-d.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	instances, ttl, err := d.resolve(ctx, serviceName)
+	if err != nil {
+		log.Warn(ctx, "DNS 解析服务实例失败，保留当前实例列表",
+			log.String("service", serviceName),
+			log.ErrorField(err),
+		)
+		return d.config.MinRefresh
+	}
+	d.update(serviceName, instances)
+
+	return min(max(ttl, d.config.MinRefresh), d.config.MaxRefresh)
+}
+
+// resolve 按服务的 DNS 记录解析实例列表
+func (d *DNSDiscovery) resolve(ctx context.Context, serviceName string) ([]Instance, time.Duration, error) {
+	service, exists := d.config.Services[serviceName]
+	if !exists {
+		return nil, 0, fmt.Errorf("服务 %s 未配置 DNS 记录", serviceName)
+	}
+
+	if service.Type == DNSRecordA {
+		ips, ttl, err := d.resolver.LookupIP(ctx, service.Name)
+		if err != nil {
+			return nil, 0, err
+		}
+		instances := make([]Instance, 0, len(ips))
+		for _, ip := range ips {
+			instances = append(instances, Instance{
+				ID:       net.JoinHostPort(ip.String(), strconv.Itoa(service.Port)),
+				Host:     ip.String(),
+				Port:     service.Port,
+				Weight:   1,
+				Healthy:  true,
+				Metadata: map[string]string{"dns_name": service.Name},
+			})
+		}
+		return instances, ttl, nil
+	}
+
+	records, ttl, err := d.resolver.LookupSRV(ctx, service.Name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return srvInstances(service.Name, records), ttl, nil
+}
+
+// srvInstances 将 SRV 记录转换为实例列表
+// 只使用优先级最高（Priority 最小）的记录，其余记录作为备用不参与负载均衡
+func srvInstances(name string, records []*net.SRV) []Instance {
+	if len(records) == 0 {
+		return []Instance{}
+	}
+
+	priority := records[0].Priority
+	for _, record := range records {
+		priority = min(priority, record.Priority)
+	}
+
+	instances := make([]Instance, 0, len(records))
+	for _, record := range records {
+		if record.Priority != priority {
+			continue
+		}
+		host := strings.TrimSuffix(record.Target, ".")
+		instances = append(instances, Instance{
+			ID:       net.JoinHostPort(host, strconv.Itoa(int(record.Port))),
+			Host:     host,
+			Port:     int(record.Port),
+			Weight:   int(record.Weight),
+			Healthy:  true,
+			Metadata: map[string]string{"dns_name": name},
+		})
+	}
+	return instances
+}
+
+// update 更新服务实例缓存，实例变化时通知监听器
+func (d *DNSDiscovery) update(serviceName string, instances []Instance) {
+	sortInstances(instances)
+
+	d.mu.Lock()
+	changed := !d.resolved[serviceName] || !sameInstances(d.instances[serviceName], instances)
+	d.instances[serviceName] = instances
+	d.resolved[serviceName] = true
+	watchers := make([]func([]Instance), len(d.watchers[serviceName]))
+	copy(watchers, d.watchers[serviceName])
+	d.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	log.Info(context.Background(), "DNS 服务实例已更新",
+		log.String("service", serviceName),
+		log.Int("instances", len(instances)),
+	)
+	for _, watcher := range watchers {
+		watcher(instances)
+	}
+}
+
+// GetInstances 获取服务实例（尚未解析时立即解析一次）
+func (d *DNSDiscovery) GetInstances(ctx context.Context, serviceName string) ([]Instance, error) {
+	d.mu.RLock()
+	instances, resolved := d.instances[serviceName], d.resolved[serviceName]
+	d.mu.RUnlock()
+
+	if !resolved {
+		var err error
+		instances, _, err = d.resolve(ctx, serviceName)
+		if err != nil {
+			return nil, err
+		}
+		d.update(serviceName, instances)
+	}
+
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("服务 %s 没有可用的实例", serviceName)
+	}
+	return instances, nil
+}
+
+// Watch 监听服务实例变化
+func (d *DNSDiscovery) Watch(serviceName string, callback func([]Instance)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watchers[serviceName] = append(d.watchers[serviceName], callback)
+	return nil
+}
+
+// Register DNS 服务发现不支持注册实例（实例由 DNS 记录管理）
+func (d *DNSDiscovery) Register(ctx context.Context, serviceName string, instance Instance) error {
+	return fmt.Errorf("DNS 服务发现不支持注册实例")
+}
+
+// Deregister DNS 服务发现不支持注销实例（实例由 DNS 记录管理）
+func (d *DNSDiscovery) Deregister(ctx context.Context, serviceName string, instanceID string) error {
+	return fmt.Errorf("DNS 服务发现不支持注销实例")
+}
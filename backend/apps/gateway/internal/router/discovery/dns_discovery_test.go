@@ -0,0 +1,186 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubResolver 测试用 DNS 解析器
+type stubResolver struct {
+	mu  sync.Mutex
+	srv []*net.SRV
+	ips []net.IP
+	ttl time.Duration
+	err error
+}
+
+func (r *stubResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.srv, r.ttl, r.err
+}
+
+func (r *stubResolver) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ips, r.ttl, r.err
+}
+
+func TestDNSDiscoverySRV(t *testing.T) {
+	resolver := &stubResolver{
+		srv: []*net.SRV{
+			{Target: "b.user.internal.", Port: 8080, Priority: 10, Weight: 1},
+			{Target: "a.user.internal.", Port: 8080, Priority: 10, Weight: 3},
+			{Target: "backup.user.internal.", Port: 8080, Priority: 20, Weight: 1},
+		},
+		ttl: 30 * time.Second,
+	}
+	d := NewDNSDiscovery(&DNSConfig{
+		Services: map[string]DNSService{
+			"user-service": {Type: DNSRecordSRV, Name: "_http._tcp.user.internal"},
+		},
+	}, resolver)
+
+	var mu sync.Mutex
+	notified := 0
+	d.Watch("user-service", func(instances []Instance) {
+		mu.Lock()
+		defer mu.Unlock()
+		notified++
+	})
+
+	if interval := d.refresh("user-service"); interval != 30*time.Second {
+		t.Errorf("Expected refresh interval from TTL 30s, got %v", interval)
+	}
+	instances, err := d.GetInstances(context.Background(), "user-service")
+	if err != nil {
+		t.Fatalf("GetInstances failed: %v", err)
+	}
+	// 只使用优先级最高的记录
+	if len(instances) != 2 || instances[0].Host != "a.user.internal" || instances[0].Weight != 3 {
+		t.Errorf("Unexpected instances %+v", instances)
+	}
+
+	// 实例未变化时不通知监听器
+	d.refresh("user-service")
+	// 查询失败时保留当前实例列表，并按最小刷新间隔重试
+	resolver.mu.Lock()
+	resolver.err = errors.New("timeout")
+	resolver.mu.Unlock()
+	if interval := d.refresh("user-service"); interval != 5*time.Second {
+		t.Errorf("Expected min refresh interval after failure, got %v", interval)
+	}
+	if instances, err := d.GetInstances(context.Background(), "user-service"); err != nil || len(instances) != 2 {
+		t.Errorf("Expected last known instances, got %+v, err %v", instances, err)
+	}
+
+	mu.Lock()
+	if notified != 1 {
+		t.Errorf("Expected 1 notification, got %d", notified)
+	}
+	mu.Unlock()
+}
+
+func TestDNSDiscoveryA(t *testing.T) {
+	resolver := &stubResolver{
+		ips: []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.1")},
+		ttl: time.Second,
+	}
+	d := NewDNSDiscovery(&DNSConfig{
+		Services: map[string]DNSService{
+			"order-service": {Type: DNSRecordA, Name: "order.internal", Port: 9000},
+		},
+		MaxRefresh: time.Minute,
+	}, resolver)
+
+	// TTL 小于最小刷新间隔时按最小刷新间隔刷新
+	if interval := d.refresh("order-service"); interval != 5*time.Second {
+		t.Errorf("Expected min refresh interval, got %v", interval)
+	}
+	instances, err := d.GetInstances(context.Background(), "order-service")
+	if err != nil {
+		t.Fatalf("GetInstances failed: %v", err)
+	}
+	if len(instances) != 2 || instances[0].Host != "10.0.0.1" || instances[0].Port != 9000 {
+		t.Errorf("Unexpected instances %+v", instances)
+	}
+
+	if _, err := d.GetInstances(context.Background(), "unknown-service"); err == nil {
+		t.Error("Expected error for service without DNS record")
+	}
+}
+
+// serveDNS 启动本地 UDP DNS 服务器，按查询类型返回固定记录
+func serveDNS(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var request dnsmessage.Message
+			if err := request.Unpack(buf[:n]); err != nil {
+				continue
+			}
+
+			question := request.Questions[0]
+			response := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: request.ID, Response: true},
+				Questions: request.Questions,
+			}
+			header := dnsmessage.ResourceHeader{Name: question.Name, Type: question.Type, Class: dnsmessage.ClassINET, TTL: 60}
+			switch question.Type {
+			case dnsmessage.TypeSRV:
+				target := dnsmessage.MustNewName("a.user.internal.")
+				response.Answers = append(response.Answers, dnsmessage.Resource{
+					Header: header,
+					Body:   &dnsmessage.SRVResource{Priority: 1, Weight: 5, Port: 8080, Target: target},
+				})
+			case dnsmessage.TypeA:
+				header.TTL = 20
+				response.Answers = append(response.Answers, dnsmessage.Resource{
+					Header: header,
+					Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}},
+				})
+			}
+			packed, _ := response.Pack()
+			conn.WriteTo(packed, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSClient(t *testing.T) {
+	client := NewDNSClient([]string{serveDNS(t)}, time.Second)
+	ctx := context.Background()
+
+	records, ttl, err := client.LookupSRV(ctx, "_http._tcp.user.internal")
+	if err != nil {
+		t.Fatalf("LookupSRV failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Target != "a.user.internal." || records[0].Port != 8080 || ttl != time.Minute {
+		t.Errorf("Unexpected SRV records %+v, ttl %v", records, ttl)
+	}
+
+	ips, ttl, err := client.LookupIP(ctx, "user.internal")
+	if err != nil {
+		t.Fatalf("LookupIP failed: %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.0.0.1")) || ttl != 20*time.Second {
+		t.Errorf("Unexpected IPs %v, ttl %v", ips, ttl)
+	}
+}
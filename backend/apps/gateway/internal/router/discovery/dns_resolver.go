@@ -0,0 +1,251 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// resolvConfPath 系统 DNS 配置文件路径
+const resolvConfPath = "/etc/resolv.conf"
+
+// Resolver DNS 解析器（返回记录和记录的 TTL）
+type Resolver interface {
+	// LookupSRV 查询 SRV 记录
+	LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error)
+	// LookupIP 查询 A 和 AAAA 记录
+	LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error)
+}
+
+// DNSClient 直接向 DNS 服务器发送查询的解析器
+// 标准库的 net.Resolver 不返回记录的 TTL，DNS 服务发现需要按 TTL 刷新实例列表；
+// 使用 UDP 查询，响应被截断时改用 TCP 重新查询
+type DNSClient struct {
+	servers []string
+	timeout time.Duration
+}
+
+// NewDNSClient 创建 DNS 解析器（servers 为空时使用 /etc/resolv.conf 中的服务器）
+func NewDNSClient(servers []string, timeout time.Duration) *DNSClient {
+	servers = append([]string(nil), servers...)
+	if len(servers) == 0 {
+		servers = systemNameservers()
+	}
+	for i, server := range servers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			servers[i] = net.JoinHostPort(server, "53")
+		}
+	}
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &DNSClient{
+		servers: servers,
+		timeout: timeout,
+	}
+}
+
+// systemNameservers 读取 /etc/resolv.conf 中的 DNS 服务器（读取失败时使用本机）
+func systemNameservers() []string {
+	servers := make([]string, 0)
+	file, err := os.Open(resolvConfPath)
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, net.JoinHostPort(fields[1], "53"))
+			}
+		}
+	}
+	if len(servers) == 0 {
+		servers = append(servers, "127.0.0.1:53")
+	}
+	return servers
+}
+
+// LookupSRV 查询 SRV 记录
+func (c *DNSClient) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	answers, err := c.query(ctx, name, dnsmessage.TypeSRV)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	records := make([]*net.SRV, 0, len(answers))
+	var ttl time.Duration
+	for _, answer := range answers {
+		srv, ok := answer.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+		records = append(records, &net.SRV{
+			Target:   srv.Target.String(),
+			Port:     srv.Port,
+			Priority: srv.Priority,
+			Weight:   srv.Weight,
+		})
+		ttl = minTTL(ttl, answer.Header.TTL)
+	}
+	return records, ttl, nil
+}
+
+// LookupIP 查询 A 和 AAAA 记录（任一类型查询成功即返回）
+func (c *DNSClient) LookupIP(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	ips := make([]net.IP, 0)
+	var ttl time.Duration
+	var lastErr error
+	succeeded := false
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := c.query(ctx, host, qtype)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		succeeded = true
+		for _, answer := range answers {
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				ips = append(ips, net.IP(body.A[:]))
+			case *dnsmessage.AAAAResource:
+				ips = append(ips, net.IP(body.AAAA[:]))
+			default:
+				continue
+			}
+			ttl = minTTL(ttl, answer.Header.TTL)
+		}
+	}
+	if !succeeded {
+		return nil, 0, lastErr
+	}
+	return ips, ttl, nil
+}
+
+// minTTL 取较小的 TTL（current 为 0 表示尚未设置）
+func minTTL(current time.Duration, ttl uint32) time.Duration {
+	d := time.Duration(ttl) * time.Second
+	if current == 0 || d < current {
+		return d
+	}
+	return current
+}
+
+// query 依次向 DNS 服务器查询，返回应答记录（域名不存在时返回空列表）
+func (c *DNSClient) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的域名 %s: %w", name, err)
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	request := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	packed, err := request.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, server := range c.servers {
+		response, err := c.exchange(ctx, "udp", server, packed, id)
+		if err == nil && response.Truncated {
+			response, err = c.exchange(ctx, "tcp", server, packed, id)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		switch response.RCode {
+		case dnsmessage.RCodeSuccess:
+			return response.Answers, nil
+		case dnsmessage.RCodeNameError:
+			return nil, nil
+		default:
+			lastErr = fmt.Errorf("DNS 查询 %s 失败: %s", name, response.RCode)
+		}
+	}
+	return nil, lastErr
+}
+
+// exchange 向 DNS 服务器发送一次查询
+func (c *DNSClient) exchange(ctx context.Context, network, server string, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		return exchangeTCP(conn, packed, id)
+	}
+
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var response dnsmessage.Message
+		if err := response.Unpack(buf[:n]); err != nil || response.ID != id {
+			// 忽略无法解析或不匹配的响应，继续等待
+			continue
+		}
+		return &response, nil
+	}
+}
+
+// exchangeTCP 通过 TCP 连接发送查询（消息前带 2 字节长度）
+func exchangeTCP(conn net.Conn, packed []byte, id uint16) (*dnsmessage.Message, error) {
+	request := make([]byte, 2+len(packed))
+	binary.BigEndian.PutUint16(request, uint16(len(packed)))
+	copy(request[2:], packed)
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, err
+	}
+
+	var response dnsmessage.Message
+	if err := response.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if response.ID != id {
+		return nil, errors.New("DNS 响应 ID 不匹配")
+	}
+	return &response, nil
+}
@@ -0,0 +1,259 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"StructForge/backend/common/log"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileConfig 文件服务发现配置
+type FileConfig struct {
+	// 实例文件路径（.json、.yaml 或 .yml）
+	Path string
+	// 检查文件变化的间隔
+	Interval time.Duration
+}
+
+// fileInstance 实例文件中的实例（未配置 healthy 时视为健康）
+type fileInstance struct {
+	ID       string            `json:"id" yaml:"id"`
+	Host     string            `json:"host" yaml:"host"`
+	Port     int               `json:"port" yaml:"port"`
+	Weight   int               `json:"weight" yaml:"weight"`
+	Healthy  *bool             `json:"healthy" yaml:"healthy"`
+	Metadata map[string]string `json:"metadata" yaml:"metadata"`
+}
+
+// FileDiscovery 文件服务发现
+// 从 JSON/YAML 实例文件（key 为服务名，value 为实例列表）加载服务实例，
+// 定期检查文件的修改时间和大小，文件变化时重新加载并通知实例发生变化的服务的监听器；
+// 文件无法解析时保留上一次加载的实例列表
+type FileDiscovery struct {
+	path     string
+	interval time.Duration
+	services map[string][]Instance
+	watchers map[string][]func([]Instance)
+	modTime  time.Time
+	size     int64
+	mu       sync.RWMutex
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewFileDiscovery 创建文件服务发现（立即加载实例文件，调用 Start 后开始检查文件变化）
+func NewFileDiscovery(config *FileConfig) (*FileDiscovery, error) {
+	if config == nil || config.Path == "" {
+		return nil, fmt.Errorf("实例文件路径为空")
+	}
+	interval := config.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	d := &FileDiscovery{
+		path:     config.Path,
+		interval: interval,
+		services: make(map[string][]Instance),
+		watchers: make(map[string][]func([]Instance)),
+		stop:     make(chan struct{}),
+	}
+	if err := d.reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Start 启动后台检查文件变化
+func (d *FileDiscovery) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.checkFile()
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查
+func (d *FileDiscovery) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+	d.wg.Wait()
+}
+
+// checkFile 文件的修改时间或大小变化时重新加载
+func (d *FileDiscovery) checkFile() {
+	ctx := context.Background()
+	info, err := os.Stat(d.path)
+	if err != nil {
+		log.Warn(ctx, "读取实例文件失败，保留当前实例列表",
+			log.String("path", d.path),
+			log.ErrorField(err),
+		)
+		return
+	}
+
+	d.mu.RLock()
+	unchanged := info.ModTime().Equal(d.modTime) && info.Size() == d.size
+	d.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	if err := d.reload(); err != nil {
+		log.Warn(ctx, "加载实例文件失败，保留当前实例列表",
+			log.String("path", d.path),
+			log.ErrorField(err),
+		)
+	}
+}
+
+// reload 加载实例文件并通知实例发生变化的服务的监听器
+func (d *FileDiscovery) reload() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return fmt.Errorf("读取实例文件失败: %w", err)
+	}
+	services, err := parseInstanceFile(d.path)
+	if err != nil {
+		return err
+	}
+
+	type change struct {
+		watchers  []func([]Instance)
+		instances []Instance
+	}
+	changes := make(map[string]change)
+
+	d.mu.Lock()
+	for serviceName, instances := range services {
+		if old, exists := d.services[serviceName]; !exists || !sameInstances(old, instances) {
+			changes[serviceName] = change{instances: instances}
+		}
+	}
+	for serviceName := range d.services {
+		if _, exists := services[serviceName]; !exists {
+			changes[serviceName] = change{instances: []Instance{}}
+		}
+	}
+	for serviceName, c := range changes {
+		c.watchers = append(c.watchers, d.watchers[serviceName]...)
+		changes[serviceName] = c
+	}
+	d.services = services
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+	d.mu.Unlock()
+
+	ctx := context.Background()
+	for serviceName, c := range changes {
+		log.Info(ctx, "文件服务实例已更新",
+			log.String("service", serviceName),
+			log.Int("instances", len(c.instances)),
+		)
+		for _, watcher := range c.watchers {
+			watcher(c.instances)
+		}
+	}
+	return nil
+}
+
+// parseInstanceFile 解析实例文件（按扩展名选择 JSON 或 YAML）
+func parseInstanceFile(path string) (map[string][]Instance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取实例文件失败: %w", err)
+	}
+
+	var raw map[string][]fileInstance
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("不支持的实例文件格式: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析实例文件失败: %w", err)
+	}
+
+	services := make(map[string][]Instance, len(raw))
+	for serviceName, fileInstances := range raw {
+		instances := make([]Instance, 0, len(fileInstances))
+		for i, fi := range fileInstances {
+			if fi.Host == "" || fi.Port <= 0 || fi.Port > 65535 {
+				return nil, fmt.Errorf("服务 %s 的实例 [索引 %d] 地址无效", serviceName, i)
+			}
+			instances = append(instances, Instance{
+				ID:       fi.ID,
+				Host:     fi.Host,
+				Port:     fi.Port,
+				Weight:   fi.Weight,
+				Healthy:  fi.Healthy == nil || *fi.Healthy,
+				Metadata: fi.Metadata,
+			})
+		}
+		sortInstances(instances)
+		services[serviceName] = instances
+	}
+	return services, nil
+}
+
+// GetInstances 获取服务的健康实例
+func (d *FileDiscovery) GetInstances(ctx context.Context, serviceName string) ([]Instance, error) {
+	d.mu.RLock()
+	instances, exists := d.services[serviceName]
+	d.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("服务 %s 未找到", serviceName)
+	}
+
+	healthyInstances := make([]Instance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Healthy {
+			healthyInstances = append(healthyInstances, instance)
+		}
+	}
+	if len(healthyInstances) == 0 {
+		return nil, fmt.Errorf("服务 %s 没有健康的实例", serviceName)
+	}
+	return healthyInstances, nil
+}
+
+// Watch 监听服务实例变化
+func (d *FileDiscovery) Watch(serviceName string, callback func([]Instance)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watchers[serviceName] = append(d.watchers[serviceName], callback)
+	return nil
+}
+
+// Register 文件服务发现不支持注册实例（实例由实例文件管理）
+func (d *FileDiscovery) Register(ctx context.Context, serviceName string, instance Instance) error {
+	return fmt.Errorf("文件服务发现不支持注册实例")
+}
+
+// Deregister 文件服务发现不支持注销实例（实例由实例文件管理）
+func (d *FileDiscovery) Deregister(ctx context.Context, serviceName string, instanceID string) error {
+	return fmt.Errorf("文件服务发现不支持注销实例")
+}
@@ -0,0 +1,94 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileDiscovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.yaml")
+	writeFile := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	writeFile(`
+user-service:
+  - id: user-1
+    host: 10.0.0.1
+    port: 8080
+  - id: user-2
+    host: 10.0.0.2
+    port: 8080
+    healthy: false
+order-service:
+  - host: 10.0.1.1
+    port: 9000
+`)
+
+	d, err := NewFileDiscovery(&FileConfig{Path: path, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewFileDiscovery failed: %v", err)
+	}
+
+	instances, err := d.GetInstances(context.Background(), "user-service")
+	if err != nil || len(instances) != 1 || instances[0].ID != "user-1" {
+		t.Fatalf("Expected healthy instance user-1, got %+v, err %v", instances, err)
+	}
+
+	var mu sync.Mutex
+	updates := make(map[string][]Instance)
+	for _, service := range []string{"user-service", "order-service"} {
+		service := service
+		d.Watch(service, func(instances []Instance) {
+			mu.Lock()
+			defer mu.Unlock()
+			updates[service] = instances
+		})
+	}
+
+	// 无法解析的文件不影响当前实例列表
+	writeFile("user-service: [")
+	d.checkFile()
+	if _, err := d.GetInstances(context.Background(), "user-service"); err != nil {
+		t.Errorf("Expected last loaded instances after invalid file, got err %v", err)
+	}
+
+	writeFile(`
+user-service:
+  - id: user-1
+    host: 10.0.0.1
+    port: 8080
+  - id: user-3
+    host: 10.0.0.3
+    port: 8080
+`)
+	d.Start()
+	defer d.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		user, order := updates["user-service"], updates["order-service"]
+		_, orderNotified := updates["order-service"]
+		mu.Unlock()
+		if len(user) == 2 && orderNotified {
+			if len(order) != 0 {
+				t.Errorf("Expected removed service to be notified with no instances, got %+v", order)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for file change, got %+v", updates)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := d.GetInstances(context.Background(), "order-service"); err == nil {
+		t.Error("Expected error for removed service")
+	}
+}
@@ -3,27 +3,64 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"StructForge/backend/common/log"
 	nacosClient "StructForge/backend/common/middleware/nacos"
 
+	"github.com/nacos-group/nacos-sdk-go/model"
 	"github.com/nacos-group/nacos-sdk-go/vo"
 )
 
+// defaultNacosGroup Nacos 默认分组
+const defaultNacosGroup = "DEFAULT_GROUP"
+
+// namingClient Nacos 命名客户端（由 common/middleware/nacos.NacosNamingClient 实现）
+type namingClient interface {
+	SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error)
+	RegisterInstance(param vo.RegisterInstanceParam) (bool, error)
+	DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error)
+	Subscribe(param *vo.SubscribeParam) error
+	Unsubscribe(param *vo.SubscribeParam) error
+}
+
 // NacosDiscovery Nacos服务发现实现
+// 通过 Nacos 订阅接收服务实例变化推送，并将最近一次有效的实例列表保存到磁盘，
+// Nacos 暂时不可用时使用快照启动和转发请求
 type NacosDiscovery struct {
-	nacosClient *nacosClient.NacosNamingClient // 使用封装的客户端
-	instances   map[string][]Instance          // 服务实例缓存
-	watchers    map[string][]func([]Instance)  // 服务监听器回调函数
-	subscribes  map[string]*vo.SubscribeParam  // 已订阅的服务
+	nacosClient namingClient                  // 使用封装的客户端
+	instances   map[string][]Instance         // 服务实例缓存
+	watchers    map[string][]func([]Instance) // 服务监听器回调函数
+	subscribes  map[string]*vo.SubscribeParam // 已订阅的服务
+	snapshotDir string                        // 实例快照目录（为空时不保存快照）
+	snapshotAge time.Duration                 // 快照最大有效期（超过后不再使用，0 表示不限制）
 	mu          sync.RWMutex
 	stopCh      chan struct{}
+	stopOnce    sync.Once
+}
+
+// NacosOption Nacos 服务发现选项
+type NacosOption func(*NacosDiscovery)
+
+// WithSnapshotDir 设置实例快照目录（为空时不保存快照）
+func WithSnapshotDir(dir string) NacosOption {
+	return func(d *NacosDiscovery) {
+		d.snapshotDir = dir
+	}
+}
+
+// WithSnapshotMaxAge 设置快照最大有效期（超过有效期的快照不再使用，0 表示不限制）
+func WithSnapshotMaxAge(maxAge time.Duration) NacosOption {
+	return func(d *NacosDiscovery) {
+		d.snapshotAge = maxAge
+	}
 }
 
 // NewNacosDiscovery 创建Nacos服务发现
-func NewNacosDiscovery(nacosConfig *nacosClient.StartupConfig) (*NacosDiscovery, error) {
+func NewNacosDiscovery(nacosConfig *nacosClient.StartupConfig, opts ...NacosOption) (*NacosDiscovery, error) {
 	if nacosConfig == nil || len(nacosConfig.Nacos.ServerConfigs) == 0 {
 		return nil, fmt.Errorf("Nacos配置为空")
 	}
@@ -34,21 +71,32 @@ func NewNacosDiscovery(nacosConfig *nacosClient.StartupConfig) (*NacosDiscovery,
 		return nil, fmt.Errorf("创建Nacos命名客户端失败: %w", err)
 	}
 
+	discovery := newNacosDiscovery(nacosNamingClient, opts...)
+
+	// 启动订阅失败时的降级轮询
+	go discovery.watchServices()
+
+	return discovery, nil
+}
+
+// newNacosDiscovery 使用命名客户端创建Nacos服务发现
+func newNacosDiscovery(client namingClient, opts ...NacosOption) *NacosDiscovery {
 	discovery := &NacosDiscovery{
-		nacosClient: nacosNamingClient,
+		nacosClient: client,
 		instances:   make(map[string][]Instance),
 		watchers:    make(map[string][]func([]Instance)),
 		subscribes:  make(map[string]*vo.SubscribeParam),
+		snapshotDir: filepath.Join(os.TempDir(), "structforge-gateway", "discovery"),
 		stopCh:      make(chan struct{}),
 	}
-
-	// 启动服务监听
-	go discovery.watchServices()
-
-	return discovery, nil
+	for _, opt := range opts {
+		opt(discovery)
+	}
+	return discovery
 }
 
 // GetInstances 获取服务实例
+// 缓存中没有时从Nacos获取，Nacos不可用时使用磁盘上最近一次有效的实例快照
 func (d *NacosDiscovery) GetInstances(ctx context.Context, serviceName string) ([]Instance, error) {
 	d.mu.RLock()
 	instances, exists := d.instances[serviceName]
@@ -59,7 +107,38 @@ func (d *NacosDiscovery) GetInstances(ctx context.Context, serviceName string) (
 	}
 
 	// 如果缓存中没有，从Nacos获取
-	return d.fetchInstancesFromNacos(ctx, serviceName)
+	instances, err := d.fetchInstancesFromNacos(ctx, serviceName)
+	if err == nil {
+		return instances, nil
+	}
+
+	snapshot, savedAt, snapshotErr := d.loadSnapshot(serviceName)
+	if snapshotErr != nil || len(snapshot) == 0 {
+		return nil, err
+	}
+	age := time.Since(savedAt).Round(time.Second)
+	if d.snapshotAge > 0 && age > d.snapshotAge {
+		log.Error(ctx, "Nacos不可用，本地实例快照已过期，不再使用",
+			log.String("service", serviceName),
+			log.String("snapshot_age", age.String()),
+			log.String("max_age", d.snapshotAge.String()),
+			log.ErrorField(err),
+		)
+		return nil, err
+	}
+	log.Warn(ctx, "Nacos不可用，使用本地实例快照",
+		log.String("service", serviceName),
+		log.Int("instances", len(snapshot)),
+		log.String("snapshot_age", age.String()),
+		log.ErrorField(err),
+	)
+
+	d.mu.Lock()
+	if len(d.instances[serviceName]) == 0 {
+		d.instances[serviceName] = snapshot
+	}
+	d.mu.Unlock()
+	return snapshot, nil
 }
 
 // fetchInstancesFromNacos 从Nacos获取服务实例
@@ -67,7 +146,7 @@ func (d *NacosDiscovery) fetchInstancesFromNacos(ctx context.Context, serviceNam
 	// 使用 NacosNamingClient 获取服务实例
 	instances, err := d.nacosClient.SelectInstances(vo.SelectInstancesParam{
 		ServiceName: serviceName,
-		GroupName:   "DEFAULT_GROUP",
+		GroupName:   defaultNacosGroup,
 		HealthyOnly: true,
 	})
 	if err != nil {
@@ -81,30 +160,49 @@ func (d *NacosDiscovery) fetchInstancesFromNacos(ctx context.Context, serviceNam
 	// 转换为内部Instance格式
 	result := make([]Instance, 0, len(instances))
 	for _, s := range instances {
-		// 转换元数据
-		metadata := make(map[string]string)
-		if s.Metadata != nil {
-			metadata = s.Metadata
+		if !s.Enable {
+			continue
 		}
-
-		result = append(result, Instance{
-			ID:       s.InstanceId,
-			Host:     s.Ip,
-			Port:     int(s.Port),
-			Weight:   int(s.Weight),
-			Healthy:  s.Healthy,
-			Metadata: metadata,
-		})
+		result = append(result, newNacosInstance(s.InstanceId, s.Ip, s.Port, s.Weight, s.Healthy, s.Metadata))
 	}
 
 	// 更新缓存
 	d.mu.Lock()
 	d.instances[serviceName] = result
 	d.mu.Unlock()
+	d.saveSnapshot(ctx, serviceName, result)
 
 	return result, nil
 }
 
+// newNacosInstance 将Nacos实例转换为内部Instance格式
+func newNacosInstance(id, ip string, port uint64, weight float64, healthy bool, metadata map[string]string) Instance {
+	// 转换元数据
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	return Instance{
+		ID:       id,
+		Host:     ip,
+		Port:     int(port),
+		Weight:   int(weight),
+		Healthy:  healthy,
+		Metadata: metadata,
+	}
+}
+
+// convertSubscribeServices 将Nacos订阅推送的实例转换为内部Instance格式（跳过已下线的实例）
+func convertSubscribeServices(services []model.SubscribeService) []Instance {
+	result := make([]Instance, 0, len(services))
+	for _, s := range services {
+		if !s.Enable {
+			continue
+		}
+		result = append(result, newNacosInstance(s.InstanceId, s.Ip, s.Port, s.Weight, s.Healthy, s.Metadata))
+	}
+	return result
+}
+
 // RegisterService 注册服务（用于服务注册）
 func (d *NacosDiscovery) RegisterService(serviceName string, instances []Instance) {
 	// Nacos服务发现模式下，服务应该自己注册到Nacos
@@ -115,11 +213,9 @@ func (d *NacosDiscovery) RegisterService(serviceName string, instances []Instanc
 }
 
 // Watch 监听服务实例变化（实现ServiceDiscovery接口）
-// 使用 Nacos 真正的订阅机制，而不是轮询
+// 使用 Nacos 订阅机制接收实例变化推送，订阅失败时降级为轮询并定期重试订阅
 func (d *NacosDiscovery) Watch(serviceName string, callback func([]Instance)) error {
 	d.mu.Lock()
-	defer d.mu.Unlock()
-
 	// 保存回调函数
 	if d.watchers[serviceName] == nil {
 		d.watchers[serviceName] = make([]func([]Instance), 0)
@@ -128,53 +224,104 @@ func (d *NacosDiscovery) Watch(serviceName string, callback func([]Instance)) er
 
 	// 如果已经订阅过该服务，直接返回
 	if _, exists := d.subscribes[serviceName]; exists {
+		d.mu.Unlock()
 		log.Info(context.Background(), "服务已订阅，添加新的监听器",
 			log.String("service", serviceName),
 		)
 		return nil
 	}
+	d.mu.Unlock()
+
+	d.subscribe(serviceName)
+	return nil
+}
 
-	// TODO: 实现真正的 Nacos 订阅
-	// 注意：Nacos SDK 的 SubscribeCallback 类型签名与我们的接口不匹配
-	// SubscribeCallback 期望的是 func(services []model.SubscribeService, err error)
-	// 但我们使用的是 func(services []model.Instance, err error)
-	// 暂时使用轮询方式，后续需要适配 Nacos SDK 的类型
-	//
-	// 创建订阅参数（暂时不设置 SubscribeCallback，避免类型不匹配）
-	// subscribeParam := &vo.SubscribeParam{
-	// 	ServiceName:       serviceName,
-	// 	GroupName:         "DEFAULT_GROUP",
-	// 	SubscribeCallback: d.createSubscribeCallback(serviceName),
-	// }
-	//
-	// err := d.nacosClient.Subscribe(subscribeParam)
-	// if err != nil {
-	// 	log.Warn(context.Background(), "订阅服务失败，将使用轮询方式",
-	// 		log.String("service", serviceName),
-	// 		log.ErrorField(err),
-	// 	)
-	// 	return nil
-	// }
-	//
-	// d.subscribes[serviceName] = subscribeParam
-
-	log.Info(context.Background(), "已注册服务监听器（使用轮询方式）",
+// subscribe 订阅服务实例变化，失败时由降级轮询定期重试
+func (d *NacosDiscovery) subscribe(serviceName string) bool {
+	subscribeParam := &vo.SubscribeParam{
+		ServiceName:       serviceName,
+		GroupName:         defaultNacosGroup,
+		SubscribeCallback: d.createSubscribeCallback(serviceName),
+	}
+
+	if err := d.nacosClient.Subscribe(subscribeParam); err != nil {
+		log.Warn(context.Background(), "订阅服务失败，将使用轮询方式",
+			log.String("service", serviceName),
+			log.ErrorField(err),
+		)
+		return false
+	}
+
+	d.mu.Lock()
+	d.subscribes[serviceName] = subscribeParam
+	d.mu.Unlock()
+
+	log.Info(context.Background(), "已订阅服务实例变化",
 		log.String("service", serviceName),
 	)
-
-	return nil
+	return true
 }
 
 // createSubscribeCallback 创建 Nacos 订阅回调函数
-// TODO: 需要适配 Nacos SDK 的 SubscribeService 类型
-// 当前 Nacos SDK 的 SubscribeCallback 期望的是 func(services []model.SubscribeService, err error)
-// 但我们的实现使用的是 model.Instance，需要类型转换
-// 暂时注释掉，使用轮询方式
-// func (d *NacosDiscovery) createSubscribeCallback(serviceName string) func(services []model.SubscribeService, err error) {
-// 	return func(services []model.SubscribeService, err error) {
-// 		// 转换逻辑...
-// 	}
-// }
+// Nacos 推送服务的全部实例，转换后更新缓存、保存快照并通知所有监听器
+func (d *NacosDiscovery) createSubscribeCallback(serviceName string) func(services []model.SubscribeService, err error) {
+	return func(services []model.SubscribeService, err error) {
+		ctx := context.Background()
+		if err != nil {
+			log.Warn(ctx, "接收服务实例推送失败，保留当前实例列表",
+				log.String("service", serviceName),
+				log.ErrorField(err),
+			)
+			return
+		}
+
+		instances := convertSubscribeServices(services)
+		log.Info(ctx, "收到服务实例变化推送",
+			log.String("service", serviceName),
+			log.Int("instances", len(instances)),
+		)
+		d.updateInstances(ctx, serviceName, instances)
+	}
+}
+
+// updateInstances 更新服务实例缓存和快照，并通知监听器
+func (d *NacosDiscovery) updateInstances(ctx context.Context, serviceName string, instances []Instance) {
+	d.mu.Lock()
+	d.instances[serviceName] = instances
+	watchers := make([]func([]Instance), len(d.watchers[serviceName]))
+	copy(watchers, d.watchers[serviceName])
+	d.mu.Unlock()
+
+	d.saveSnapshot(ctx, serviceName, instances)
+
+	// 在锁外调用监听器，避免监听器回调中访问服务发现时死锁
+	for _, watcher := range watchers {
+		if watcher != nil {
+			watcher(instances)
+		}
+	}
+}
+
+// saveSnapshot 保存服务实例快照（实例列表为空时保留上一次的快照）
+func (d *NacosDiscovery) saveSnapshot(ctx context.Context, serviceName string, instances []Instance) {
+	if d.snapshotDir == "" || len(instances) == 0 {
+		return
+	}
+	if err := saveSnapshot(d.snapshotDir, serviceName, instances); err != nil {
+		log.Warn(ctx, "保存服务实例快照失败",
+			log.String("service", serviceName),
+			log.ErrorField(err),
+		)
+	}
+}
+
+// loadSnapshot 读取服务实例快照及其保存时间
+func (d *NacosDiscovery) loadSnapshot(serviceName string) ([]Instance, time.Time, error) {
+	if d.snapshotDir == "" {
+		return nil, time.Time{}, fmt.Errorf("未配置快照目录")
+	}
+	return loadSnapshot(d.snapshotDir, serviceName)
+}
 
 // Register 注册服务实例（实现ServiceDiscovery接口）
 func (d *NacosDiscovery) Register(ctx context.Context, serviceName string, instance Instance) error {
@@ -249,8 +396,8 @@ func (d *NacosDiscovery) Deregister(ctx context.Context, serviceName string, ins
 	return nil
 }
 
-// watchServices 监听服务变化
-// 对于未订阅的服务，使用轮询方式作为降级方案
+// watchServices 订阅失败时的降级方案
+// 定期重试订阅有监听器但尚未订阅成功的服务，并轮询刷新未订阅服务的实例列表
 func (d *NacosDiscovery) watchServices() {
 	ticker := time.NewTicker(30 * time.Second) // 每30秒刷新一次（作为降级方案）
 	defer ticker.Stop()
@@ -258,7 +405,6 @@ func (d *NacosDiscovery) watchServices() {
 	for {
 		select {
 		case <-ticker.C:
-			// 只刷新未订阅的服务（降级方案）
 			d.refreshUnsubscribedServices()
 		case <-d.stopCh:
 			return
@@ -266,45 +412,38 @@ func (d *NacosDiscovery) watchServices() {
 	}
 }
 
-// refreshUnsubscribedServices 刷新未订阅的服务实例列表（降级方案）
+// refreshUnsubscribedServices 重试订阅并刷新未订阅的服务实例列表（降级方案）
 func (d *NacosDiscovery) refreshUnsubscribedServices() {
 	d.mu.RLock()
-	serviceNames := make([]string, 0)
-	watchersCopy := make(map[string][]func([]Instance))
-
-	// 只处理未订阅的服务
+	serviceNames := make(map[string]bool)
 	for serviceName := range d.instances {
 		if _, subscribed := d.subscribes[serviceName]; !subscribed {
-			serviceNames = append(serviceNames, serviceName)
+			serviceNames[serviceName] = false
 		}
 	}
-
-	for serviceName, watchers := range d.watchers {
+	for serviceName := range d.watchers {
 		if _, subscribed := d.subscribes[serviceName]; !subscribed {
-			watchersCopy[serviceName] = make([]func([]Instance), len(watchers))
-			copy(watchersCopy[serviceName], watchers)
+			serviceNames[serviceName] = true
 		}
 	}
 	d.mu.RUnlock()
 
 	ctx := context.Background()
-	for _, serviceName := range serviceNames {
-		instances, err := d.fetchInstancesFromNacos(ctx, serviceName)
-		if err == nil {
-			log.Debug(ctx, "刷新服务实例（轮询方式）",
-				log.String("service", serviceName),
-				log.Int("instances", len(instances)),
-			)
+	for serviceName, watched := range serviceNames {
+		// 订阅成功后由推送更新实例列表
+		if watched && d.subscribe(serviceName) {
+			continue
+		}
 
-			// 调用监听器回调
-			if watchers, exists := watchersCopy[serviceName]; exists {
-				for _, watcher := range watchers {
-					if watcher != nil {
-						watcher(instances)
-					}
-				}
-			}
+		instances, err := d.fetchInstancesFromNacos(ctx, serviceName)
+		if err != nil {
+			continue
 		}
+		log.Debug(ctx, "刷新服务实例（轮询方式）",
+			log.String("service", serviceName),
+			log.Int("instances", len(instances)),
+		)
+		d.updateInstances(ctx, serviceName, instances)
 	}
 }
 
@@ -332,5 +471,7 @@ func (d *NacosDiscovery) Stop() {
 	d.subscribes = make(map[string]*vo.SubscribeParam)
 
 	// 停止轮询 goroutine
-	close(d.stopCh)
+	d.stopOnce.Do(func() {
+		close(d.stopCh)
+	})
 }
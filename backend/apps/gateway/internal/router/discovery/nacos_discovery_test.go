@@ -0,0 +1,165 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// fakeNamingClient 测试用 Nacos 命名客户端
+type fakeNamingClient struct {
+	mu         sync.Mutex
+	instances  []model.Instance
+	selectErr  error
+	callbacks  map[string]func([]model.SubscribeService, error)
+	subscribed int
+}
+
+func newFakeNamingClient() *fakeNamingClient {
+	return &fakeNamingClient{callbacks: make(map[string]func([]model.SubscribeService, error))}
+}
+
+func (c *fakeNamingClient) SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.instances, c.selectErr
+}
+
+func (c *fakeNamingClient) RegisterInstance(param vo.RegisterInstanceParam) (bool, error) {
+	return true, nil
+}
+
+func (c *fakeNamingClient) DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error) {
+	return true, nil
+}
+
+func (c *fakeNamingClient) Subscribe(param *vo.SubscribeParam) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed++
+	c.callbacks[param.ServiceName] = param.SubscribeCallback
+	return nil
+}
+
+func (c *fakeNamingClient) Unsubscribe(param *vo.SubscribeParam) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.callbacks, param.ServiceName)
+	return nil
+}
+
+// push 模拟 Nacos 推送服务实例变化
+func (c *fakeNamingClient) push(service string, services []model.SubscribeService, err error) {
+	c.mu.Lock()
+	callback := c.callbacks[service]
+	c.mu.Unlock()
+	callback(services, err)
+}
+
+func TestNacosDiscoverySubscribe(t *testing.T) {
+	client := newFakeNamingClient()
+	d := newNacosDiscovery(client, WithSnapshotDir(t.TempDir()))
+	defer d.Stop()
+
+	var mu sync.Mutex
+	received := make([][]Instance, 2)
+	for i := range received {
+		i := i
+		if err := d.Watch("user-service", func(instances []Instance) {
+			mu.Lock()
+			defer mu.Unlock()
+			received[i] = instances
+		}); err != nil {
+			t.Fatalf("Watch failed: %v", err)
+		}
+	}
+	if client.subscribed != 1 {
+		t.Fatalf("Expected 1 subscription, got %d", client.subscribed)
+	}
+
+	client.push("user-service", []model.SubscribeService{
+		{InstanceId: "a", Ip: "10.0.0.1", Port: 8080, Weight: 2, Healthy: true, Enable: true},
+		{InstanceId: "b", Ip: "10.0.0.2", Port: 8080, Weight: 1, Healthy: true, Enable: false},
+	}, nil)
+
+	mu.Lock()
+	for i, instances := range received {
+		if len(instances) != 1 || instances[0].ID != "a" || instances[0].Weight != 2 {
+			t.Errorf("Watcher %d: unexpected instances %+v", i, instances)
+		}
+	}
+	mu.Unlock()
+
+	// 推送失败时保留当前实例列表
+	client.push("user-service", nil, errors.New("push failed"))
+	instances, err := d.GetInstances(context.Background(), "user-service")
+	if err != nil || len(instances) != 1 || instances[0].ID != "a" {
+		t.Errorf("Expected cached instance a, got %+v, err %v", instances, err)
+	}
+}
+
+func TestNacosDiscoverySnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	client := newFakeNamingClient()
+	client.instances = []model.Instance{
+		{InstanceId: "a", Ip: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Enable: true},
+	}
+	d := newNacosDiscovery(client, WithSnapshotDir(dir))
+	if _, err := d.GetInstances(context.Background(), "user-service"); err != nil {
+		t.Fatalf("GetInstances failed: %v", err)
+	}
+	d.Stop()
+
+	// Nacos 不可用时使用快照启动
+	unavailable := newFakeNamingClient()
+	unavailable.selectErr = errors.New("connection refused")
+	d = newNacosDiscovery(unavailable, WithSnapshotDir(dir))
+	defer d.Stop()
+
+	instances, err := d.GetInstances(context.Background(), "user-service")
+	if err != nil {
+		t.Fatalf("Expected snapshot fallback, got error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Host != "10.0.0.1" || instances[0].Port != 8080 {
+		t.Errorf("Unexpected snapshot instances %+v", instances)
+	}
+
+	if _, err := d.GetInstances(context.Background(), "order-service"); err == nil {
+		t.Error("Expected error for service without snapshot")
+	}
+}
+
+func TestNacosDiscoverySnapshotMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	if err := saveSnapshot(dir, "user-service", []Instance{{ID: "a", Host: "10.0.0.1", Port: 8080, Healthy: true}}); err != nil {
+		t.Fatalf("saveSnapshot failed: %v", err)
+	}
+
+	unavailable := newFakeNamingClient()
+	unavailable.selectErr = errors.New("connection refused")
+
+	// 有效期内的快照可以使用
+	d := newNacosDiscovery(unavailable, WithSnapshotDir(dir), WithSnapshotMaxAge(time.Hour))
+	if _, err := d.GetInstances(context.Background(), "user-service"); err != nil {
+		t.Fatalf("Expected fresh snapshot to be used, got error: %v", err)
+	}
+	d.Stop()
+
+	// 超过有效期的快照不再使用
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(snapshotPath(dir, "user-service"), old, old); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	d = newNacosDiscovery(unavailable, WithSnapshotDir(dir), WithSnapshotMaxAge(time.Hour))
+	defer d.Stop()
+	if _, err := d.GetInstances(context.Background(), "user-service"); err == nil {
+		t.Error("Expected expired snapshot to be rejected")
+	}
+}
@@ -0,0 +1,61 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotPath 获取服务实例快照文件路径
+func snapshotPath(dir, serviceName string) string {
+	return filepath.Join(dir, url.PathEscape(serviceName)+".json")
+}
+
+// saveSnapshot 将服务实例列表保存为快照文件（先写临时文件再重命名，避免写入中断导致快照损坏）
+func saveSnapshot(dir, serviceName string, instances []Instance) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("创建快照目录失败: %w", err)
+	}
+
+	data, err := json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化服务实例失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return fmt.Errorf("创建快照临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入快照失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入快照失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), snapshotPath(dir, serviceName))
+}
+
+// loadSnapshot 读取服务实例快照文件，返回实例列表和快照保存时间（文件修改时间）
+func loadSnapshot(dir, serviceName string) ([]Instance, time.Time, error) {
+	path := snapshotPath(dir, serviceName)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var instances []Instance
+	if err := json.Unmarshal(data, &instances); err != nil {
+		return nil, time.Time{}, fmt.Errorf("解析快照失败: %w", err)
+	}
+	return instances, info.ModTime(), nil
+}
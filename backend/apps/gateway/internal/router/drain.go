@@ -0,0 +1,73 @@
+package router
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/common/log"
+)
+
+// DrainInstance 摘除服务实例：不再向实例转发新请求，已转发的请求正常完成
+// instance 为实例标识（实例ID，没有ID时为 host:port）；摘除状态在恢复前一直保留，实例重新注册后仍处于摘除状态
+func (r *Router) DrainInstance(service, instance string) {
+	r.mu.Lock()
+	if r.drained[service] == nil {
+		r.drained[service] = make(map[string]bool)
+	}
+	r.drained[service][instance] = true
+	r.mu.Unlock()
+
+	log.Warn(context.Background(), "服务实例已摘除",
+		log.String("service", service),
+		log.String("instance", instance),
+	)
+}
+
+// UndrainInstance 恢复被摘除的服务实例，实例未被摘除时返回 false
+func (r *Router) UndrainInstance(service, instance string) bool {
+	r.mu.Lock()
+	if !r.drained[service][instance] {
+		r.mu.Unlock()
+		return false
+	}
+	delete(r.drained[service], instance)
+	if len(r.drained[service]) == 0 {
+		delete(r.drained, service)
+	}
+	r.mu.Unlock()
+
+	log.Info(context.Background(), "服务实例已恢复",
+		log.String("service", service),
+		log.String("instance", instance),
+	)
+	return true
+}
+
+// DrainedInstances 获取服务被摘除的实例标识（按标识排序）
+func (r *Router) DrainedInstances(service string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	instances := make([]string, 0, len(r.drained[service]))
+	for instance := range r.drained[service] {
+		instances = append(instances, instance)
+	}
+	sort.Strings(instances)
+	return instances
+}
+
+// excludeDrained 排除被摘除的实例（没有被摘除的实例时返回原列表）
+func (r *Router) excludeDrained(service string, instances []discovery.Instance) []discovery.Instance {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	drained := r.drained[service]
+	if len(drained) == 0 {
+		return instances
+	}
+	return slices.DeleteFunc(slices.Clone(instances), func(instance discovery.Instance) bool {
+		return drained[instance.Key()]
+	})
+}
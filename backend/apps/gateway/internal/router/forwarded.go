@@ -0,0 +1,55 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"StructForge/backend/common/middleware/clientip"
+)
+
+// ClientIP 解析请求的客户端真实IP（根据可信代理配置）
+func (r *Router) ClientIP(req *http.Request) string {
+	return r.clientIP.FromRequest(req)
+}
+
+// setForwardedHeaders 设置转发请求头（X-Forwarded-For、X-Real-IP、X-Forwarded-Proto、X-Forwarded-Host、Forwarded）
+// 直连地址为可信代理时在已有的转发链后追加，否则丢弃客户端传入的转发请求头（防止伪造）
+func (r *Router) setForwardedHeaders(req *http.Request, header http.Header) {
+	remote := clientip.HostOnly(req.RemoteAddr)
+	trusted := r.clientIP.IsTrusted(remote)
+
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+
+	if prior := clientip.JoinValues(header, clientip.HeaderXForwardedFor); trusted && prior != "" {
+		header.Set(clientip.HeaderXForwardedFor, prior+", "+remote)
+	} else {
+		header.Set(clientip.HeaderXForwardedFor, remote)
+	}
+
+	header.Set(clientip.HeaderXRealIP, requestClientIP(req))
+
+	if !trusted || header.Get(clientip.HeaderXForwardedProto) == "" {
+		header.Set(clientip.HeaderXForwardedProto, proto)
+	}
+	if !trusted || header.Get(clientip.HeaderXForwardedHost) == "" {
+		header.Set(clientip.HeaderXForwardedHost, req.Host)
+	}
+
+	element := "for=" + forwardedNode(remote) + ";host=\"" + req.Host + "\";proto=" + proto
+	if prior := clientip.JoinValues(header, clientip.HeaderForwarded); trusted && prior != "" {
+		header.Set(clientip.HeaderForwarded, prior+", "+element)
+	} else {
+		header.Set(clientip.HeaderForwarded, element)
+	}
+}
+
+// forwardedNode 格式化 Forwarded 请求头中的地址（IPv6 需要加方括号和引号）
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "\"[" + ip + "]\""
+	}
+	return ip
+}
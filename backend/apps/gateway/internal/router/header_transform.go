@@ -0,0 +1,144 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
+	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
+)
+
+// hopByHopHeaders RFC 7230 定义的逐跳请求头，只在单个连接上有效，代理时不应转发
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// headerTemplatePattern 匹配请求头模板变量（${trace_id}）
+var headerTemplatePattern = regexp.MustCompile(`\$\{([a-z_]+)\}`)
+
+// headerTemplateVariables 请求头模板支持的变量
+var headerTemplateVariables = map[string]bool{
+	"trace_id":  true,
+	"client_ip": true,
+	"user_id":   true,
+	"username":  true,
+	"method":    true,
+	"path":      true,
+	"host":      true,
+}
+
+// removeHopByHopHeaders 删除逐跳请求头（包括 Connection 中声明的请求头）
+func removeHopByHopHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+}
+
+// headerTemplateValues 获取请求头模板变量的值（TraceID、客户端IP、JWT 声明等）
+func headerTemplateValues(req *http.Request) map[string]string {
+	ctx := req.Context()
+	values := map[string]string{
+		"client_ip": requestClientIP(req),
+		"method":    req.Method,
+		"path":      req.URL.Path,
+		"host":      req.Host,
+	}
+	if traceID, ok := ctx.Value(log.CtxTraceID).(string); ok {
+		values["trace_id"] = traceID
+	}
+	if userID, ok := gatewayMiddleware.GetUserID(ctx); ok {
+		values["user_id"] = strconv.FormatInt(userID, 10)
+	}
+	if username, ok := gatewayMiddleware.GetUsername(ctx); ok {
+		values["username"] = username
+	}
+	return values
+}
+
+// requestClientIP 获取请求的客户端IP（由网关入口解析并存入 Context，未解析时使用直连地址）
+func requestClientIP(req *http.Request) string {
+	if ip, ok := clientip.FromContext(req.Context()); ok {
+		return ip
+	}
+	return clientip.HostOnly(req.RemoteAddr)
+}
+
+// expandHeaderTemplate 替换请求头值中的模板变量，未知变量保持原样
+func expandHeaderTemplate(value string, values map[string]string) string {
+	if !strings.Contains(value, "${") {
+		return value
+	}
+	return headerTemplatePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := match[2 : len(match)-1]
+		if !headerTemplateVariables[name] {
+			return match
+		}
+		return values[name]
+	})
+}
+
+// applyHeaderTransform 按配置转换请求头或响应头
+// 处理顺序：删除、重命名、设置、追加；设置的值展开后为空时删除该请求头（如未认证请求不携带 X-User-ID）
+func applyHeaderTransform(header http.Header, transform *conf.HeaderTransformConfig, values map[string]string) {
+	if transform == nil {
+		return
+	}
+
+	for _, name := range transform.Remove {
+		header.Del(name)
+	}
+
+	for from, to := range transform.Rename {
+		if existing := header.Values(from); len(existing) > 0 {
+			header.Del(from)
+			header.Del(to)
+			for _, value := range existing {
+				header.Add(to, value)
+			}
+		}
+	}
+
+	for name, value := range transform.Set {
+		if expanded := expandHeaderTemplate(value, values); expanded != "" {
+			header.Set(name, expanded)
+		} else {
+			header.Del(name)
+		}
+	}
+
+	for name, value := range transform.Add {
+		if expanded := expandHeaderTemplate(value, values); expanded != "" {
+			header.Add(name, expanded)
+		}
+	}
+}
+
+// validateHeaderTemplate 验证请求头模板中的变量是否支持
+func validateHeaderTemplate(value string) error {
+	for _, match := range headerTemplatePattern.FindAllStringSubmatch(value, -1) {
+		if !headerTemplateVariables[match[1]] {
+			return fmt.Errorf("不支持的模板变量: ${%s} (支持: trace_id, client_ip, user_id, username, method, path, host)", match[1])
+		}
+	}
+	return nil
+}
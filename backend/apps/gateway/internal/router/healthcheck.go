@@ -0,0 +1,111 @@
+package router
+
+import (
+	"context"
+	"time"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/apps/gateway/internal/router/healthcheck"
+	"StructForge/backend/common/log"
+)
+
+// startHealthCheckers 根据服务级配置创建并启动主动健康检查器（未启用的服务不创建）
+func (r *Router) startHealthCheckers(configs map[string]*conf.HealthCheckConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for service, config := range configs {
+		if config == nil || !config.Enabled {
+			continue
+		}
+		r.healthCheckers[service] = r.startHealthChecker(service, config)
+	}
+}
+
+// startHealthChecker 创建并启动服务的主动健康检查器
+func (r *Router) startHealthChecker(service string, config *conf.HealthCheckConfig) *healthcheck.Checker {
+	checker := healthcheck.NewChecker(service, &healthcheck.Config{
+		Path:               config.Path,
+		ExpectedStatus:     config.ExpectedStatus,
+		Interval:           time.Duration(config.Interval) * time.Second,
+		Timeout:            time.Duration(config.Timeout) * time.Second,
+		HealthyThreshold:   config.HealthyThreshold,
+		UnhealthyThreshold: config.UnhealthyThreshold,
+	}, func(ctx context.Context) ([]discovery.Instance, error) {
+		return r.discovery.GetInstances(ctx, service)
+	}, func(instance discovery.Instance, status healthcheck.Status) {
+		r.onInstanceHealthChange(service, instance, status)
+	})
+	checker.Start()
+
+	log.Info(context.Background(), "主动健康检查已启动",
+		log.String("service", service),
+	)
+	return checker
+}
+
+// onInstanceHealthChange 实例健康状态变化时记录日志和指标
+func (r *Router) onInstanceHealthChange(service string, instance discovery.Instance, status healthcheck.Status) {
+	if status.Healthy {
+		log.Info(context.Background(), "实例主动健康检查已恢复",
+			log.String("service", service),
+			log.String("instance", instance.Key()),
+		)
+	} else {
+		log.Warn(context.Background(), "实例主动健康检查失败，已标记为不健康",
+			log.String("service", service),
+			log.String("instance", instance.Key()),
+			log.Int("status_code", status.LastStatusCode),
+			log.String("error", status.LastError),
+		)
+	}
+	if r.metrics != nil {
+		r.metrics.SetInstanceHealth(service, instance.Key(), status.Healthy)
+	}
+}
+
+// healthChecker 获取服务的主动健康检查器（未启用时返回 nil）
+func (r *Router) healthChecker(service string) *healthcheck.Checker {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.healthCheckers[service]
+}
+
+// applyHealthChecks 按主动健康检查结果设置实例的健康状态
+func (r *Router) applyHealthChecks(service string, instances []discovery.Instance) []discovery.Instance {
+	checker := r.healthChecker(service)
+	if checker == nil {
+		return instances
+	}
+
+	result := make([]discovery.Instance, len(instances))
+	copy(result, instances)
+	for i := range result {
+		if !checker.IsHealthy(result[i].Key()) {
+			result[i].Healthy = false
+		}
+	}
+	return result
+}
+
+// GetHealthCheckStatus 获取服务各实例的主动健康检查状态（未启用时返回 nil）
+func (r *Router) GetHealthCheckStatus(service string) map[string]healthcheck.Status {
+	checker := r.healthChecker(service)
+	if checker == nil {
+		return nil
+	}
+	return checker.Statuses()
+}
+
+// Close 停止路由管理器的后台任务（主动健康检查）
+func (r *Router) Close() {
+	r.mu.Lock()
+	checkers := r.healthCheckers
+	r.healthCheckers = make(map[string]*healthcheck.Checker)
+	r.mu.Unlock()
+
+	for _, checker := range checkers {
+		checker.Stop()
+	}
+}
@@ -0,0 +1,290 @@
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+)
+
+// userAgent 健康检查请求的 User-Agent
+const userAgent = "StructForge-Gateway-HealthCheck"
+
+// Config 主动健康检查配置
+type Config struct {
+	// 健康检查路径
+	Path string
+	// 视为健康的响应状态码
+	ExpectedStatus []int
+	// 检查间隔
+	Interval time.Duration
+	// 单次检查超时时间
+	Timeout time.Duration
+	// 不健康的实例连续检查成功多少次后恢复为健康
+	HealthyThreshold int
+	// 健康的实例连续检查失败多少次后标记为不健康
+	UnhealthyThreshold int
+}
+
+// withDefaults 返回填充默认值后的配置
+func (c *Config) withDefaults() Config {
+	result := *c
+	if result.Path == "" {
+		result.Path = "/health"
+	}
+	if len(result.ExpectedStatus) == 0 {
+		result.ExpectedStatus = []int{http.StatusOK}
+	}
+	if result.Interval <= 0 {
+		result.Interval = 10 * time.Second
+	}
+	if result.Timeout <= 0 {
+		result.Timeout = 2 * time.Second
+	}
+	if result.Timeout > result.Interval {
+		result.Timeout = result.Interval
+	}
+	if result.HealthyThreshold <= 0 {
+		result.HealthyThreshold = 2
+	}
+	if result.UnhealthyThreshold <= 0 {
+		result.UnhealthyThreshold = 3
+	}
+	return result
+}
+
+// Status 实例的主动健康检查状态
+type Status struct {
+	// 是否健康
+	Healthy bool `json:"healthy"`
+	// 连续检查成功次数
+	ConsecutiveSuccesses int `json:"consecutive_successes"`
+	// 连续检查失败次数
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// 最后一次检查时间
+	LastCheck time.Time `json:"last_check"`
+	// 最后一次检查的响应状态码（请求失败时为 0）
+	LastStatusCode int `json:"last_status_code,omitempty"`
+	// 最后一次检查失败的原因
+	LastError string `json:"last_error,omitempty"`
+}
+
+// InstanceLister 获取需要检查的服务实例
+type InstanceLister func(ctx context.Context) ([]discovery.Instance, error)
+
+// ChangeFunc 实例健康状态变化回调
+type ChangeFunc func(instance discovery.Instance, status Status)
+
+// Checker 服务的主动健康检查器
+// 按固定间隔探测服务每个实例的健康检查接口，连续失败达到阈值时标记为不健康，
+// 连续成功达到阈值时恢复；尚未完成检查的实例视为健康
+type Checker struct {
+	service  string
+	config   Config
+	list     InstanceLister
+	onChange ChangeFunc
+	client   *http.Client
+	statuses map[string]*Status
+	mu       sync.RWMutex
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewChecker 创建主动健康检查器（调用 Start 后开始检查）
+func NewChecker(service string, config *Config, list InstanceLister, onChange ChangeFunc) *Checker {
+	if config == nil {
+		config = &Config{}
+	}
+	return &Checker{
+		service:  service,
+		config:   config.withDefaults(),
+		list:     list,
+		onChange: onChange,
+		client: &http.Client{
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 1,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// 不跟随重定向，按健康检查接口的原始响应判断
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		statuses: make(map[string]*Status),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Service 获取检查的服务名称
+func (c *Checker) Service() string {
+	return c.service
+}
+
+// Start 启动后台检查（立即执行一次检查）
+func (c *Checker) Start() {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.config.Interval)
+		defer ticker.Stop()
+		for {
+			c.CheckOnce()
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查并等待正在进行的检查结束
+func (c *Checker) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+	c.wg.Wait()
+	c.client.CloseIdleConnections()
+}
+
+// CheckOnce 并发检查服务的所有实例
+func (c *Checker) CheckOnce() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	instances, err := c.list(ctx)
+	if err != nil {
+		return
+	}
+	c.retain(instances)
+
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance discovery.Instance) {
+			defer wg.Done()
+			statusCode, err := c.probe(ctx, instance)
+			if ctx.Err() != nil {
+				return
+			}
+			c.record(instance, statusCode, err)
+		}(instance)
+	}
+	wg.Wait()
+}
+
+// probe 请求实例的健康检查接口
+func (c *Checker) probe(ctx context.Context, instance discovery.Instance) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	url := "http://" + net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port)) + c.config.Path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	for _, expected := range c.config.ExpectedStatus {
+		if resp.StatusCode == expected {
+			return resp.StatusCode, nil
+		}
+	}
+	return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+}
+
+// record 记录一次检查结果，健康状态变化时回调
+func (c *Checker) record(instance discovery.Instance, statusCode int, err error) {
+	c.mu.Lock()
+	key := instance.Key()
+	status, exists := c.statuses[key]
+	if !exists {
+		status = &Status{Healthy: true}
+		c.statuses[key] = status
+	}
+
+	status.LastCheck = time.Now()
+	status.LastStatusCode = statusCode
+	changed := false
+	if err == nil {
+		status.LastError = ""
+		status.ConsecutiveSuccesses++
+		status.ConsecutiveFailures = 0
+		if !status.Healthy && status.ConsecutiveSuccesses >= c.config.HealthyThreshold {
+			status.Healthy = true
+			changed = true
+		}
+	} else {
+		status.LastError = err.Error()
+		status.ConsecutiveFailures++
+		status.ConsecutiveSuccesses = 0
+		if status.Healthy && status.ConsecutiveFailures >= c.config.UnhealthyThreshold {
+			status.Healthy = false
+			changed = true
+		}
+	}
+	snapshot := *status
+	c.mu.Unlock()
+
+	if changed && c.onChange != nil {
+		c.onChange(instance, snapshot)
+	}
+}
+
+// retain 清除已下线实例的检查状态
+func (c *Checker) retain(instances []discovery.Instance) {
+	current := make(map[string]bool, len(instances))
+	for i := range instances {
+		current[instances[i].Key()] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.statuses {
+		if !current[key] {
+			delete(c.statuses, key)
+		}
+	}
+}
+
+// IsHealthy 判断实例是否健康（尚未检查的实例视为健康）
+func (c *Checker) IsHealthy(key string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	status, exists := c.statuses[key]
+	return !exists || status.Healthy
+}
+
+// Statuses 获取所有实例的检查状态（key 为实例标识）
+func (c *Checker) Statuses() map[string]Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	statuses := make(map[string]Status, len(c.statuses))
+	for key, status := range c.statuses {
+		statuses[key] = *status
+	}
+	return statuses
+}
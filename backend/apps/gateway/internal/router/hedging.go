@@ -0,0 +1,252 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdHttp "net/http"
+	"sort"
+	"sync"
+	"time"
+
+	circuitbreaker "StructForge/backend/apps/gateway/internal/middleware/circuitbreaker"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
+	"StructForge/backend/common/log"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+const (
+	// hedgeLatencySamples 计算路由 p95 延迟保留的最近样本数
+	hedgeLatencySamples = 200
+	// hedgeLatencyMinSamples 使用 p95 作为对冲等待时间所需的最少样本数（样本不足时不发送对冲请求）
+	hedgeLatencyMinSamples = 20
+	// hedgeLatencyRefresh 每收集多少个样本重新计算一次 p95
+	hedgeLatencyRefresh = 10
+)
+
+// errHedgeLost 对冲请求中落败的请求被取消（不计入熔断器和异常实例统计）
+var errHedgeLost = fmt.Errorf("hedged request lost: %w", context.Canceled)
+
+// hedgingEnabled 判断请求是否可以发送对冲请求（只对冲 GET、HEAD 等只读请求）
+func hedgingEnabled(route *Route, req *stdHttp.Request) bool {
+	if route.Hedging == nil || !route.Hedging.Enabled {
+		return false
+	}
+	return req.Method == stdHttp.MethodGet || req.Method == stdHttp.MethodHead
+}
+
+// hedgeDelay 获取发送对冲请求前的等待时间（0 表示不发送对冲请求）
+// 未配置固定等待时间时使用路由最近请求延迟的 p95
+func hedgeDelay(route *Route) time.Duration {
+	if route.Hedging.Delay > 0 {
+		return time.Duration(route.Hedging.Delay) * time.Millisecond
+	}
+	if route.hedgeLatency == nil {
+		return 0
+	}
+	p95 := route.hedgeLatency.P95()
+	if p95 <= 0 {
+		return 0
+	}
+	return max(p95, time.Duration(route.Hedging.MinDelay)*time.Millisecond)
+}
+
+// hedgeResult 一路请求的结果
+type hedgeResult struct {
+	resp    *stdHttp.Response
+	err     error
+	cancel  context.CancelCauseFunc
+	index   int
+	hedge   bool
+	latency time.Duration
+}
+
+// succeeded 判断请求是否成功（上游返回非 5xx 响应）
+func (h hedgeResult) succeeded() bool {
+	return h.err == nil && h.resp != nil && h.resp.StatusCode < 500
+}
+
+// release 关闭响应体并取消请求
+func (h hedgeResult) release() {
+	if h.resp != nil {
+		h.resp.Body.Close()
+	}
+	h.cancel(errHedgeLost)
+}
+
+// cancelOnClose 响应体关闭时取消对应请求的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+// Close 关闭响应体
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel(nil)
+	return err
+}
+
+// forwardHedged 发送请求，等待一段时间仍未收到响应时向其他实例发送对冲请求
+// 采用第一个成功的响应并取消其他请求；所有请求都失败时返回最后一个失败结果
+func (r *Router) forwardHedged(ctx kratosHttp.Context, requestCtx context.Context, route *Route, version string, instance *discovery.Instance, done loadbalancer.DoneFunc, tried map[string]bool, requestBody func() io.Reader, cbConfig *circuitbreaker.Config, templateValues map[string]string) (*stdHttp.Response, error) {
+	maxHedges := max(route.Hedging.MaxHedges, 1)
+	results := make(chan hedgeResult, maxHedges+1)
+	cancels := make([]context.CancelCauseFunc, 0, maxHedges+1)
+
+	// 每一路请求使用独立的 context，落败时单独取消
+	launch := func(instance *discovery.Instance, done loadbalancer.DoneFunc, hedge bool) {
+		legCtx, legCancel := context.WithCancelCause(requestCtx)
+		index := len(cancels)
+		cancels = append(cancels, legCancel)
+		targetURL := r.buildTargetURL(ctx, route, instance)
+		body := requestBody()
+		go func() {
+			start := time.Now()
+			resp, err := r.forwardAttempt(ctx, legCtx, route, instance, done, targetURL, body, cbConfig, templateValues)
+			results <- hedgeResult{resp: resp, err: err, cancel: legCancel, index: index, hedge: hedge, latency: time.Since(start)}
+		}()
+	}
+
+	launch(instance, done, false)
+	outstanding, hedges := 1, 0
+
+	var timer *time.Timer
+	var timerC <-chan time.Time
+	if delay := hedgeDelay(route); delay > 0 {
+		timer = time.NewTimer(delay)
+		defer timer.Stop()
+		timerC = timer.C
+	}
+
+	var last hedgeResult
+	for outstanding > 0 {
+		select {
+		case <-timerC:
+			timerC = nil
+			// 对冲请求必须发送到其他实例
+			next, nextDone, err := r.selectInstance(ctx, route, version, tried)
+			if err != nil {
+				continue
+			}
+			if tried[next.Key()] {
+				nextDone(loadbalancer.DoneInfo{})
+				continue
+			}
+			tried[next.Key()] = true
+			launch(next, nextDone, true)
+			outstanding++
+			hedges++
+			r.recordHedge(route.Service)
+			log.Info(ctx, "发送对冲请求",
+				log.String("service", route.Service),
+				log.String("instance", fmt.Sprintf("%s:%d", next.Host, next.Port)),
+				log.Int("hedge", hedges),
+			)
+			if delay := hedgeDelay(route); hedges < maxHedges && delay > 0 {
+				timer.Reset(delay)
+				timerC = timer.C
+			}
+
+		case result := <-results:
+			outstanding--
+			if !result.succeeded() {
+				if last.cancel != nil {
+					last.release()
+				}
+				last = result
+				continue
+			}
+
+			// 取消其他请求，后台关闭落败请求的响应体
+			for i, cancel := range cancels {
+				if i != result.index {
+					cancel(errHedgeLost)
+				}
+			}
+			if last.cancel != nil {
+				last.release()
+			}
+			go func(pending int) {
+				for i := 0; i < pending; i++ {
+					(<-results).release()
+				}
+			}(outstanding)
+
+			if result.hedge {
+				r.recordHedgeWin(route.Service)
+			}
+			if route.hedgeLatency != nil {
+				route.hedgeLatency.Add(result.latency)
+			}
+			result.resp.Body = &cancelOnClose{ReadCloser: result.resp.Body, cancel: result.cancel}
+			return result.resp, nil
+		}
+	}
+
+	// 所有请求都失败
+	if last.resp == nil {
+		last.cancel(nil)
+		return nil, last.err
+	}
+	last.resp.Body = &cancelOnClose{ReadCloser: last.resp.Body, cancel: last.cancel}
+	return last.resp, nil
+}
+
+// recordHedge 记录对冲请求指标
+func (r *Router) recordHedge(service string) {
+	if r.metrics != nil {
+		r.metrics.RecordHedge(service)
+	}
+}
+
+// recordHedgeWin 记录对冲请求胜出指标
+func (r *Router) recordHedgeWin(service string) {
+	if r.metrics != nil {
+		r.metrics.RecordHedgeWin(service)
+	}
+}
+
+// latencyWindow 路由最近请求延迟的滑动窗口（用于计算对冲等待时间）
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	count   int
+	pending int
+	p95     time.Duration
+}
+
+// newLatencyWindow 创建延迟窗口
+func newLatencyWindow() *latencyWindow {
+	return &latencyWindow{samples: make([]time.Duration, hedgeLatencySamples)}
+}
+
+// Add 记录一个延迟样本
+func (w *latencyWindow) Add(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % len(w.samples)
+	w.count = min(w.count+1, len(w.samples))
+	w.pending++
+	if w.count < hedgeLatencyMinSamples || w.pending < hedgeLatencyRefresh {
+		return
+	}
+
+	w.pending = 0
+	sorted := make([]time.Duration, w.count)
+	copy(sorted, w.samples[:w.count])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	w.p95 = sorted[(len(sorted)*95+99)/100-1]
+}
+
+// P95 获取最近请求延迟的 p95（样本不足时返回 0）
+func (w *latencyWindow) P95() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p95
+}
@@ -0,0 +1,50 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
+	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/identity"
+)
+
+// newIdentitySignerFromConfig 从配置创建身份签名器（密钥需与下游服务一致）
+// 未配置 internal_secret 时不签名身份信息，下游服务只能通过 Authorization 请求头自行验证 JWT
+func newIdentitySignerFromConfig(config *conf.GatewayConfig) *identity.Signer {
+	if config == nil || config.JWT == nil || config.JWT.InternalSecret == "" {
+		log.Error(context.Background(), "未配置 jwt.internal_secret，已禁用向下游服务传递签名身份信息")
+		return nil
+	}
+	return identity.NewSigner(config.JWT.InternalSecret)
+}
+
+// setIdentityHeaders 设置转发到下游服务的身份请求头
+// 总是删除客户端传入的内部身份请求头（防止伪造），请求已通过 JWT 认证时写入签名后的身份信息，
+// 签名绑定目标服务和转发请求的方法、路径
+func (r *Router) setIdentityHeaders(in *http.Request, out *http.Request, service string) {
+	identity.Strip(out.Header)
+	if r.identitySigner == nil {
+		return
+	}
+
+	ctx := in.Context()
+	userID, ok := gatewayMiddleware.GetUserID(ctx)
+	if !ok {
+		return
+	}
+	username, _ := gatewayMiddleware.GetUsername(ctx)
+	roles, _ := gatewayMiddleware.GetRoles(ctx)
+
+	target := identity.Target{
+		Service: service,
+		Method:  out.Method,
+		Path:    out.URL.Path,
+	}
+	r.identitySigner.Sign(out.Header, target, &identity.Identity{
+		UserID:   userID,
+		Username: username,
+		Roles:    roles,
+	})
+}
@@ -0,0 +1,67 @@
+package router
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
+	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// 一致性哈希键来源
+const (
+	// HashOnUserID 按用户ID哈希（需要路由开启认证）
+	HashOnUserID = "user_id"
+	// HashOnIP 按客户端真实IP哈希（默认）
+	HashOnIP = "ip"
+	// hashOnHeaderPrefix 按请求头哈希，如 header:X-Session-ID
+	hashOnHeaderPrefix = "header:"
+	// hashOnCookiePrefix 按 Cookie 哈希，如 cookie:session_id
+	hashOnCookiePrefix = "cookie:"
+)
+
+// hashKey 获取请求的一致性哈希键（非 consistent_hash 策略或请求缺少哈希键时返回空字符串）
+func (r *Router) hashKey(ctx kratosHttp.Context, route *Route) string {
+	if route.LoadBalanceStrategy != loadbalancer.StrategyConsistentHash {
+		return ""
+	}
+
+	req := ctx.Request()
+	switch {
+	case strings.HasPrefix(route.HashOn, hashOnHeaderPrefix):
+		return req.Header.Get(strings.TrimPrefix(route.HashOn, hashOnHeaderPrefix))
+	case strings.HasPrefix(route.HashOn, hashOnCookiePrefix):
+		if cookie, err := req.Cookie(strings.TrimPrefix(route.HashOn, hashOnCookiePrefix)); err == nil {
+			return cookie.Value
+		}
+		return ""
+	case route.HashOn == HashOnUserID:
+		if userID, ok := gatewayMiddleware.GetUserID(req.Context()); ok {
+			return strconv.FormatInt(userID, 10)
+		}
+		return ""
+	default:
+		return r.ClientIP(req)
+	}
+}
+
+// doneOnClose 响应体关闭时通知负载均衡器请求已完成（流式响应在传输结束前计为在途请求）
+type doneOnClose struct {
+	io.ReadCloser
+	done loadbalancer.DoneFunc
+	info loadbalancer.DoneInfo
+	once sync.Once
+}
+
+// Close 关闭响应体
+func (d *doneOnClose) Close() error {
+	err := d.ReadCloser.Close()
+	d.once.Do(func() {
+		d.done(d.info)
+	})
+	return err
+}
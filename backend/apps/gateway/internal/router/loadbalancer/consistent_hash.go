@@ -0,0 +1,110 @@
+package loadbalancer
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+)
+
+// consistentHashReplicas 权重最高的实例在哈希环上的虚拟节点数（其他实例按权重比例折算）
+const consistentHashReplicas = 160
+
+// ringNode 哈希环上的虚拟节点
+type ringNode struct {
+	hash uint32
+	key  string
+}
+
+// ConsistentHashLoadBalancer 一致性哈希负载均衡器
+// 相同哈希键的请求始终转发到同一实例（会话亲和），实例上下线时只有该实例的请求会迁移到其他实例；
+// 请求没有哈希键时按权重随机选择
+type ConsistentHashLoadBalancer struct {
+	instances []discovery.Instance
+	// 按哈希值排序的虚拟节点
+	ring []ringNode
+	mu   sync.RWMutex
+}
+
+// NewConsistentHashLoadBalancer 创建一致性哈希负载均衡器
+func NewConsistentHashLoadBalancer() *ConsistentHashLoadBalancer {
+	return &ConsistentHashLoadBalancer{
+		instances: make([]discovery.Instance, 0),
+	}
+}
+
+// hashString 计算字符串的哈希值
+func hashString(s string) uint32 {
+	hash := fnv.New32a()
+	hash.Write([]byte(s))
+	return hash.Sum32()
+}
+
+// rebuild 根据实例列表重建哈希环（调用方需持有写锁）
+func (lb *ConsistentHashLoadBalancer) rebuild(instances []discovery.Instance) {
+	maxWeight := 1
+	for i := range instances {
+		maxWeight = max(maxWeight, instanceWeight(&instances[i]))
+	}
+
+	ring := make([]ringNode, 0, len(instances)*consistentHashReplicas)
+	members := make(map[string]bool, len(instances))
+	for i := range instances {
+		key := instances[i].Key()
+		if members[key] {
+			continue
+		}
+		members[key] = true
+		replicas := max(consistentHashReplicas*instanceWeight(&instances[i])/maxWeight, 1)
+		for j := 0; j < replicas; j++ {
+			ring = append(ring, ringNode{hash: hashString(key + "#" + strconv.Itoa(j)), key: key})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].hash < ring[j].hash })
+	lb.ring = ring
+}
+
+// Select 选择服务实例（一致性哈希）
+// 从哈希键在环上的位置顺时针查找第一个属于候选实例的节点。哈希环只在实例列表更新时重建，
+// 候选实例是哈希环的子集时（如流量拆分、重试时排除已尝试的实例、实例不健康）跳过不在候选中的节点，其他哈希键的分配保持不变
+func (lb *ConsistentHashLoadBalancer) Select(ctx context.Context, instances []discovery.Instance) (*discovery.Instance, DoneFunc) {
+	healthyInstances := filterHealthy(instances)
+	if len(healthyInstances) == 0 {
+		return nil, noopDone
+	}
+
+	hashKey := HashKeyFromContext(ctx)
+	if hashKey == "" {
+		return pickWeighted(healthyInstances), noopDone
+	}
+
+	candidates := make(map[string]*discovery.Instance, len(healthyInstances))
+	for i := range healthyInstances {
+		candidates[healthyInstances[i].Key()] = &healthyInstances[i]
+	}
+
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+
+	hash := hashString(hashKey)
+	start := sort.Search(len(lb.ring), func(i int) bool { return lb.ring[i].hash >= hash })
+	for i := 0; i < len(lb.ring); i++ {
+		if instance, ok := candidates[lb.ring[(start+i)%len(lb.ring)].key]; ok {
+			return instance, noopDone
+		}
+	}
+	// 候选实例都不在哈希环上（实例列表尚未同步）
+	return pickWeighted(healthyInstances), noopDone
+}
+
+// UpdateInstances 更新服务实例列表并重建哈希环
+// 哈希环包含所有实例（不按健康状态过滤），实例健康状态变化时其他哈希键的分配保持不变
+func (lb *ConsistentHashLoadBalancer) UpdateInstances(instances []discovery.Instance) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.instances = instances
+	lb.rebuild(instances)
+}
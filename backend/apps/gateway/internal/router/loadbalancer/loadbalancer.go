@@ -1,67 +1,172 @@
 package loadbalancer
 
 import (
+	"context"
 	"math/rand"
 	"sync"
+	"time"
 
 	"StructForge/backend/apps/gateway/internal/router/discovery"
 )
 
+// 负载均衡策略
+const (
+	// StrategyRoundRobin 轮询（按实例权重平滑加权轮询，权重相同时为普通轮询）
+	StrategyRoundRobin = "round_robin"
+	// StrategyWeightedRoundRobin 平滑加权轮询（与 round_robin 相同）
+	StrategyWeightedRoundRobin = "weighted_round_robin"
+	// StrategyRandom 按权重随机
+	StrategyRandom = "random"
+	// StrategyLeastConnections 最少连接（按权重折算的在途请求数最少）
+	StrategyLeastConnections = "least_connections"
+	// StrategyConsistentHash 一致性哈希（按请求头、Cookie、用户ID等哈希键保持会话亲和）
+	StrategyConsistentHash = "consistent_hash"
+	// StrategyP2C 两次随机选择（Power of Two Choices），比较在途请求数和 EWMA 延迟
+	StrategyP2C = "p2c"
+)
+
+// ValidStrategy 判断负载均衡策略是否有效
+func ValidStrategy(strategy string) bool {
+	switch strategy {
+	case StrategyRoundRobin, StrategyWeightedRoundRobin, StrategyRandom,
+		StrategyLeastConnections, StrategyConsistentHash, StrategyP2C:
+		return true
+	}
+	return false
+}
+
+// DoneInfo 请求完成信息
+type DoneInfo struct {
+	// 请求错误（上游返回 5xx 时也视为错误）
+	Err error
+	// 请求延迟（收到响应头的耗时），为 0 表示请求未发送到实例
+	Latency time.Duration
+}
+
+// DoneFunc 请求完成回调，每次 Select 返回的回调必须且只能调用一次
+type DoneFunc func(info DoneInfo)
+
+// noopDone 不需要统计请求结果的负载均衡器返回的完成回调
+func noopDone(DoneInfo) {}
+
 // LoadBalancer 负载均衡器接口
 type LoadBalancer interface {
-	// Select 选择服务实例
-	Select(instances []discovery.Instance) *discovery.Instance
+	// Select 选择服务实例，返回请求完成时调用的回调（用于统计在途请求数和延迟）
+	Select(ctx context.Context, instances []discovery.Instance) (*discovery.Instance, DoneFunc)
 	// UpdateInstances 更新服务实例列表
 	UpdateInstances(instances []discovery.Instance)
 }
 
-// RoundRobinLoadBalancer 轮询负载均衡器
+// hashKeyContextKey 一致性哈希键在 Context 中的键
+type hashKeyContextKey struct{}
+
+// WithHashKey 在 Context 中设置一致性哈希键
+func WithHashKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKeyContextKey{}, key)
+}
+
+// HashKeyFromContext 从 Context 中获取一致性哈希键
+func HashKeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(hashKeyContextKey{}).(string)
+	return key
+}
+
+// instanceWeight 获取实例权重（未配置权重时按 1 处理）
+func instanceWeight(instance *discovery.Instance) int {
+	if instance.Weight <= 0 {
+		return 1
+	}
+	return instance.Weight
+}
+
+// filterHealthy 过滤健康的实例
+func filterHealthy(instances []discovery.Instance) []discovery.Instance {
+	healthyInstances := make([]discovery.Instance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Healthy {
+			healthyInstances = append(healthyInstances, instance)
+		}
+	}
+	return healthyInstances
+}
+
+// pickWeighted 按权重随机选择实例
+func pickWeighted(instances []discovery.Instance) *discovery.Instance {
+	totalWeight := 0
+	for i := range instances {
+		totalWeight += instanceWeight(&instances[i])
+	}
+
+	point := rand.Intn(totalWeight)
+	for i := range instances {
+		point -= instanceWeight(&instances[i])
+		if point < 0 {
+			return &instances[i]
+		}
+	}
+	return &instances[len(instances)-1]
+}
+
+// RoundRobinLoadBalancer 平滑加权轮询负载均衡器
+// 每次选择时所有实例的当前权重加上各自的权重，选择当前权重最大的实例并减去权重总和，
+// 权重高的实例被选择的次数更多，且选择结果均匀分散（不会连续集中到同一实例）
 type RoundRobinLoadBalancer struct {
 	instances []discovery.Instance
-	current   int
-	mu        sync.Mutex
+	// 实例的当前权重
+	currentWeights map[string]int
+	mu             sync.Mutex
 }
 
-// NewRoundRobinLoadBalancer 创建轮询负载均衡器
+// NewRoundRobinLoadBalancer 创建平滑加权轮询负载均衡器
 func NewRoundRobinLoadBalancer() *RoundRobinLoadBalancer {
 	return &RoundRobinLoadBalancer{
-		instances: make([]discovery.Instance, 0),
-		current:   0,
+		instances:      make([]discovery.Instance, 0),
+		currentWeights: make(map[string]int),
 	}
 }
 
-// Select 选择服务实例（轮询）
-func (lb *RoundRobinLoadBalancer) Select(instances []discovery.Instance) *discovery.Instance {
-	if len(instances) == 0 {
-		return nil
+// Select 选择服务实例（平滑加权轮询）
+func (lb *RoundRobinLoadBalancer) Select(ctx context.Context, instances []discovery.Instance) (*discovery.Instance, DoneFunc) {
+	healthyInstances := filterHealthy(instances)
+	if len(healthyInstances) == 0 {
+		return nil, noopDone
 	}
 
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
-	// 过滤健康的实例
-	healthyInstances := make([]discovery.Instance, 0)
-	for _, instance := range instances {
-		if instance.Healthy {
-			healthyInstances = append(healthyInstances, instance)
+	var selected *discovery.Instance
+	selectedWeight, totalWeight := 0, 0
+	for i := range healthyInstances {
+		key := healthyInstances[i].Key()
+		weight := instanceWeight(&healthyInstances[i])
+		totalWeight += weight
+		lb.currentWeights[key] += weight
+		if selected == nil || lb.currentWeights[key] > selectedWeight {
+			selected = &healthyInstances[i]
+			selectedWeight = lb.currentWeights[key]
 		}
 	}
+	lb.currentWeights[selected.Key()] -= totalWeight
 
-	if len(healthyInstances) == 0 {
-		return nil
-	}
-
-	instance := &healthyInstances[lb.current%len(healthyInstances)]
-	lb.current++
-
-	return instance
+	return selected, noopDone
 }
 
-// UpdateInstances 更新服务实例列表
+// UpdateInstances 更新服务实例列表（清除已下线实例的当前权重）
 func (lb *RoundRobinLoadBalancer) UpdateInstances(instances []discovery.Instance) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 	lb.instances = instances
+
+	current := make(map[string]bool, len(instances))
+	for i := range instances {
+		current[instances[i].Key()] = true
+	}
+	for key := range lb.currentWeights {
+		if !current[key] {
+			delete(lb.currentWeights, key)
+		}
+	}
 }
 
 // RandomLoadBalancer 随机负载均衡器
@@ -77,26 +182,13 @@ func NewRandomLoadBalancer() *RandomLoadBalancer {
 	}
 }
 
-// Select 选择服务实例（随机）
-func (lb *RandomLoadBalancer) Select(instances []discovery.Instance) *discovery.Instance {
-	if len(instances) == 0 {
-		return nil
-	}
-
-	// 过滤健康的实例
-	healthyInstances := make([]discovery.Instance, 0)
-	for _, instance := range instances {
-		if instance.Healthy {
-			healthyInstances = append(healthyInstances, instance)
-		}
-	}
-
+// Select 选择服务实例（按权重随机）
+func (lb *RandomLoadBalancer) Select(ctx context.Context, instances []discovery.Instance) (*discovery.Instance, DoneFunc) {
+	healthyInstances := filterHealthy(instances)
 	if len(healthyInstances) == 0 {
-		return nil
+		return nil, noopDone
 	}
-
-	index := rand.Intn(len(healthyInstances))
-	return &healthyInstances[index]
+	return pickWeighted(healthyInstances), noopDone
 }
 
 // UpdateInstances 更新服务实例列表
@@ -108,7 +200,8 @@ func (rb *RandomLoadBalancer) UpdateInstances(instances []discovery.Instance) {
 
 // LeastConnectionsLoadBalancer 最少连接负载均衡器
 type LeastConnectionsLoadBalancer struct {
-	instances   []discovery.Instance
+	instances []discovery.Instance
+	// 实例的在途请求数（请求完成时减少）
 	connections map[string]int
 	mu          sync.RWMutex
 }
@@ -122,45 +215,43 @@ func NewLeastConnectionsLoadBalancer() *LeastConnectionsLoadBalancer {
 }
 
 // Select 选择服务实例（最少连接）
-func (lb *LeastConnectionsLoadBalancer) Select(instances []discovery.Instance) *discovery.Instance {
-	if len(instances) == 0 {
-		return nil
+// 比较按权重折算的在途请求数，权重高的实例可以承担更多的在途请求
+func (lb *LeastConnectionsLoadBalancer) Select(ctx context.Context, instances []discovery.Instance) (*discovery.Instance, DoneFunc) {
+	healthyInstances := filterHealthy(instances)
+	if len(healthyInstances) == 0 {
+		return nil, noopDone
 	}
 
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 
-	// 过滤健康的实例
-	healthyInstances := make([]discovery.Instance, 0)
-	for _, instance := range instances {
-		if instance.Healthy {
-			healthyInstances = append(healthyInstances, instance)
-		}
-	}
-
-	if len(healthyInstances) == 0 {
-		return nil
-	}
-
 	// 选择连接数最少的实例
 	var selected *discovery.Instance
-	minConnections := -1
-
+	minLoad := 0.0
 	for i := range healthyInstances {
-		instanceID := healthyInstances[i].ID
-		connections := lb.connections[instanceID]
-		if minConnections == -1 || connections < minConnections {
-			minConnections = connections
+		connections := lb.connections[healthyInstances[i].Key()]
+		load := float64(connections+1) / float64(instanceWeight(&healthyInstances[i]))
+		if selected == nil || load < minLoad {
+			minLoad = load
 			selected = &healthyInstances[i]
 		}
 	}
 
-	// 增加连接数
-	if selected != nil {
-		lb.connections[selected.ID]++
+	// 增加连接数，请求完成时减少
+	key := selected.Key()
+	lb.connections[key]++
+	var once sync.Once
+	return selected, func(DoneInfo) {
+		once.Do(func() {
+			lb.mu.Lock()
+			defer lb.mu.Unlock()
+			if lb.connections[key] <= 1 {
+				delete(lb.connections, key)
+				return
+			}
+			lb.connections[key]--
+		})
 	}
-
-	return selected
 }
 
 // UpdateInstances 更新服务实例列表
@@ -173,11 +264,15 @@ func (lb *LeastConnectionsLoadBalancer) UpdateInstances(instances []discovery.In
 // NewLoadBalancer 根据策略创建负载均衡器
 func NewLoadBalancer(strategy string) LoadBalancer {
 	switch strategy {
-	case "random":
+	case StrategyRandom:
 		return NewRandomLoadBalancer()
-	case "least_connections":
+	case StrategyLeastConnections:
 		return NewLeastConnectionsLoadBalancer()
-	case "round_robin":
+	case StrategyConsistentHash:
+		return NewConsistentHashLoadBalancer()
+	case StrategyP2C:
+		return NewP2CLoadBalancer()
+	case StrategyRoundRobin, StrategyWeightedRoundRobin:
 		fallthrough
 	default:
 		return NewRoundRobinLoadBalancer()
@@ -0,0 +1,158 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+)
+
+const (
+	// p2cDecayTime EWMA 延迟的衰减时间常数（距上次更新越久，旧延迟的权重越低）
+	p2cDecayTime = 10 * time.Second
+	// p2cErrorPenalty 请求失败时计入 EWMA 的最小延迟（避免快速失败的实例被误认为延迟低）
+	p2cErrorPenalty = time.Second
+	// p2cForcePick 实例超过此时间未被选择时强制选择一次，使延迟恢复的实例有机会更新 EWMA
+	p2cForcePick = 3 * time.Second
+)
+
+// p2cStats 实例的在途请求数和延迟统计
+type p2cStats struct {
+	// 在途请求数
+	inflight int
+	// EWMA 延迟（纳秒）
+	latency float64
+	// 上次更新延迟的时间
+	updated time.Time
+	// 上次被选择的时间
+	picked time.Time
+}
+
+// P2CLoadBalancer 两次随机选择负载均衡器（Power of Two Choices）
+// 随机选择两个实例，选择负载（EWMA 延迟 × 在途请求数 ÷ 权重）较低的实例，
+// 既能避开慢实例和繁忙实例，又不会像全局最优选择那样让所有请求同时涌向同一实例
+type P2CLoadBalancer struct {
+	instances []discovery.Instance
+	stats     map[string]*p2cStats
+	mu        sync.Mutex
+}
+
+// NewP2CLoadBalancer 创建两次随机选择负载均衡器
+func NewP2CLoadBalancer() *P2CLoadBalancer {
+	return &P2CLoadBalancer{
+		instances: make([]discovery.Instance, 0),
+		stats:     make(map[string]*p2cStats),
+	}
+}
+
+// instanceStats 获取实例的统计信息（调用方需持有锁）
+func (lb *P2CLoadBalancer) instanceStats(key string) *p2cStats {
+	stats, exists := lb.stats[key]
+	if !exists {
+		stats = &p2cStats{}
+		lb.stats[key] = stats
+	}
+	return stats
+}
+
+// load 计算实例的负载（调用方需持有锁）
+func (lb *P2CLoadBalancer) load(instance *discovery.Instance) float64 {
+	stats := lb.instanceStats(instance.Key())
+	// 没有延迟样本的实例按 1 纳秒计算，优先选择以获取样本
+	return (stats.latency + 1) * float64(stats.inflight+1) / float64(instanceWeight(instance))
+}
+
+// Select 选择服务实例（两次随机选择）
+func (lb *P2CLoadBalancer) Select(ctx context.Context, instances []discovery.Instance) (*discovery.Instance, DoneFunc) {
+	healthyInstances := filterHealthy(instances)
+	if len(healthyInstances) == 0 {
+		return nil, noopDone
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if len(healthyInstances) == 1 {
+		return lb.pick(&healthyInstances[0])
+	}
+
+	first := rand.Intn(len(healthyInstances))
+	second := rand.Intn(len(healthyInstances) - 1)
+	if second >= first {
+		second++
+	}
+	selected, other := &healthyInstances[first], &healthyInstances[second]
+	if lb.load(other) < lb.load(selected) {
+		selected, other = other, selected
+	}
+	// 落选的实例长时间未被选择时强制选择一次
+	if stats := lb.instanceStats(other.Key()); !stats.picked.IsZero() && time.Since(stats.picked) > p2cForcePick {
+		selected = other
+	}
+	return lb.pick(selected)
+}
+
+// pick 记录实例被选择并返回完成回调（调用方需持有锁）
+func (lb *P2CLoadBalancer) pick(instance *discovery.Instance) (*discovery.Instance, DoneFunc) {
+	key := instance.Key()
+	stats := lb.instanceStats(key)
+	stats.inflight++
+	stats.picked = time.Now()
+
+	var once sync.Once
+	return instance, func(info DoneInfo) {
+		once.Do(func() {
+			lb.done(key, info)
+		})
+	}
+}
+
+// done 请求完成时减少在途请求数并更新 EWMA 延迟
+// 请求未发送或被取消时只减少在途请求数
+func (lb *P2CLoadBalancer) done(key string, info DoneInfo) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	stats := lb.instanceStats(key)
+	if stats.inflight > 0 {
+		stats.inflight--
+	}
+	if info.Latency <= 0 || errors.Is(info.Err, context.Canceled) {
+		return
+	}
+
+	latency := info.Latency
+	if info.Err != nil {
+		latency = max(latency, p2cErrorPenalty)
+	}
+
+	now := time.Now()
+	if stats.updated.IsZero() {
+		stats.latency = float64(latency)
+	} else {
+		decay := math.Exp(-float64(now.Sub(stats.updated)) / float64(p2cDecayTime))
+		stats.latency = stats.latency*decay + float64(latency)*(1-decay)
+	}
+	stats.updated = now
+}
+
+// UpdateInstances 更新服务实例列表（清除已下线且没有在途请求的实例统计）
+func (lb *P2CLoadBalancer) UpdateInstances(instances []discovery.Instance) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.instances = instances
+
+	current := make(map[string]bool, len(instances))
+	for i := range instances {
+		current[instances[i].Key()] = true
+	}
+	for key, stats := range lb.stats {
+		if !current[key] && stats.inflight == 0 {
+			delete(lb.stats, key)
+		}
+	}
+}
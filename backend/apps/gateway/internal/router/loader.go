@@ -3,14 +3,18 @@ package router
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"StructForge/backend/apps/gateway/internal/conf"
+	"StructForge/backend/apps/gateway/internal/middleware/metrics"
+	"StructForge/backend/apps/gateway/internal/middleware/retry"
 	"StructForge/backend/apps/gateway/internal/router/discovery"
 	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
 )
 
-// LoadRouterFromConfig 从配置加载路由（Wire provider，只返回 Router）
-func LoadRouterFromConfig(config *conf.GatewayConfig, staticDiscovery *discovery.StaticDiscovery) (*Router, error) {
+// LoadRouterFromConfig 从配置加载路由（Wire provider，返回 Router 和停止后台任务的清理函数）
+func LoadRouterFromConfig(config *conf.GatewayConfig, serviceDiscovery discovery.ServiceDiscovery, m *metrics.Metrics) (*Router, func(), error) {
 	ctx := context.Background()
 
 	// 验证配置
@@ -18,66 +22,127 @@ func LoadRouterFromConfig(config *conf.GatewayConfig, staticDiscovery *discovery
 		log.Error(ctx, "网关配置验证失败",
 			log.ErrorField(err),
 		)
-		return nil, fmt.Errorf("配置验证失败: %w", err)
+		return nil, nil, fmt.Errorf("配置验证失败: %w", err)
 	}
 
 	// 创建路由管理器
-	router := NewRouter(staticDiscovery)
+	router := NewRouter(serviceDiscovery)
+	router.metrics = m
+	router.identitySigner = newIdentitySignerFromConfig(config)
+	if config != nil && len(config.TrustedProxies) > 0 {
+		resolver, err := clientip.NewResolver(config.TrustedProxies)
+		if err != nil {
+			return nil, nil, fmt.Errorf("可信代理配置错误: %w", err)
+		}
+		router.clientIP = resolver
+	}
+	if config != nil {
+		router.serviceConcurrency = config.ServiceConcurrency
+		router.adaptiveConcurrency = config.AdaptiveConcurrency
+		router.outlierDetectors = newOutlierDetectors(config.OutlierDetection)
+		if config.RetryBudget != nil {
+			router.retryBudget = retry.NewBudget(&retry.BudgetConfig{
+				Percent:             config.RetryBudget.Percent,
+				MinRetriesPerSecond: config.RetryBudget.MinRetriesPerSecond,
+				Window:              time.Duration(config.RetryBudget.Window) * time.Second,
+			})
+		}
+	}
 
 	// 加载路由规则
 	if config != nil && config.Routes != nil {
-		for _, routeConfig := range config.Routes.Routes {
-			route := &Route{
-				Path:                routeConfig.Path,
-				MatchType:           routeConfig.MatchType,
-				Service:             routeConfig.Service,
-				TargetPath:          routeConfig.TargetPath,
-				RequireAuth:         routeConfig.RequireAuth,
-				Timeout:             routeConfig.Timeout,
-				Retries:             routeConfig.Retries,
-				LoadBalanceStrategy: routeConfig.LoadBalanceStrategy,
-			}
-
-			if routeConfig.RateLimit != nil {
-				route.RateLimit = &RateLimitConfig{
-					QPS:   routeConfig.RateLimit.QPS,
-					Burst: routeConfig.RateLimit.Burst,
-				}
-			}
-
-			if routeConfig.CircuitBreaker != nil {
-				route.CircuitBreaker = routeConfig.CircuitBreaker
-			}
-
-			if routeConfig.Cache != nil {
-				route.Cache = routeConfig.Cache
-			}
-
-			router.AddRoute(route)
+		for i := range config.Routes.Routes {
+			routeConfig := &config.Routes.Routes[i]
+			router.AddRoute(newRouteFromConfig(routeConfig))
 		}
 	}
 
-	// 加载服务实例（静态服务发现）
-	if config != nil && config.Services != nil {
+	// 加载服务实例（静态服务发现，其他服务发现由后台刷新实例）
+	staticDiscovery, isStatic := serviceDiscovery.(*discovery.StaticDiscovery)
+	if isStatic && config != nil && config.Services != nil {
 		for serviceName, instances := range config.Services.Services {
-			discoveryInstances := make([]discovery.Instance, 0, len(instances))
-			for _, instanceConfig := range instances {
-				discoveryInstances = append(discoveryInstances, discovery.Instance{
-					ID:       instanceConfig.ID,
-					Host:     instanceConfig.Host,
-					Port:     instanceConfig.Port,
-					Weight:   instanceConfig.Weight,
-					Healthy:  instanceConfig.Healthy,
-					Metadata: instanceConfig.Metadata,
-				})
-			}
-			staticDiscovery.RegisterService(serviceName, discoveryInstances)
+			staticDiscovery.RegisterService(serviceName, newStaticInstances(instances))
 		}
 	}
 
+	// 服务实例加载完成后启动主动健康检查
+	if config != nil {
+		router.startHealthCheckers(config.HealthCheck)
+	}
+	router.config = config
+
 	log.Info(ctx, "路由配置加载完成",
 		log.Int("routes", len(router.routes)),
 	)
 
-	return router, nil
+	return router, router.Close, nil
+}
+
+// newRouteFromConfig 根据路由规则配置创建路由
+func newRouteFromConfig(routeConfig *conf.RouteRule) *Route {
+	route := &Route{
+		Path:                routeConfig.Path,
+		MatchType:           routeConfig.MatchType,
+		Methods:             routeConfig.Methods,
+		Headers:             routeConfig.Headers,
+		Query:               routeConfig.Query,
+		Host:                routeConfig.Host,
+		Priority:            routeConfig.Priority,
+		Service:             routeConfig.Service,
+		TargetPath:          routeConfig.TargetPath,
+		Rewrite:             routeConfig.Rewrite,
+		StripPrefix:         routeConfig.StripPrefix,
+		AddPrefix:           routeConfig.AddPrefix,
+		HostRewrite:         routeConfig.HostRewrite,
+		RequireAuth:         routeConfig.RequireAuth,
+		WebSocket:           routeConfig.WebSocket,
+		Timeout:             routeConfig.Timeout,
+		IdleTimeout:         routeConfig.IdleTimeout,
+		Retries:             routeConfig.Retries,
+		LoadBalanceStrategy: routeConfig.LoadBalanceStrategy,
+		HashOn:              routeConfig.HashOn,
+		rule:                routeConfig,
+	}
+
+	if routeConfig.RateLimit != nil {
+		route.RateLimit = &RateLimitConfig{
+			Type:  routeConfig.RateLimit.Type,
+			QPS:   routeConfig.RateLimit.QPS,
+			Burst: routeConfig.RateLimit.Burst,
+			KeyBy: routeConfig.RateLimit.KeyBy,
+		}
+		if tier := routeConfig.RateLimit.Anonymous; tier != nil {
+			route.RateLimit.Anonymous = &RateLimitTier{QPS: tier.QPS, Burst: tier.Burst}
+		}
+		if tier := routeConfig.RateLimit.Authenticated; tier != nil {
+			route.RateLimit.Authenticated = &RateLimitTier{QPS: tier.QPS, Burst: tier.Burst}
+		}
+	}
+
+	route.CircuitBreaker = routeConfig.CircuitBreaker
+	route.Cache = routeConfig.Cache
+	route.TrafficSplit = routeConfig.TrafficSplit
+	route.RequestHeaders = routeConfig.RequestHeaders
+	route.ResponseHeaders = routeConfig.ResponseHeaders
+	route.Concurrency = routeConfig.Concurrency
+	route.ShedPriority = routeConfig.ShedPriority
+	route.Retry = routeConfig.Retry
+	route.Hedging = routeConfig.Hedging
+	return route
+}
+
+// newStaticInstances 将静态服务实例配置转换为服务实例
+func newStaticInstances(instances []conf.ServiceInstance) []discovery.Instance {
+	discoveryInstances := make([]discovery.Instance, 0, len(instances))
+	for _, instanceConfig := range instances {
+		discoveryInstances = append(discoveryInstances, discovery.Instance{
+			ID:       instanceConfig.ID,
+			Host:     instanceConfig.Host,
+			Port:     instanceConfig.Port,
+			Weight:   instanceConfig.Weight,
+			Healthy:  instanceConfig.Healthy,
+			Metadata: instanceConfig.Metadata,
+		})
+	}
+	return discoveryInstances
 }
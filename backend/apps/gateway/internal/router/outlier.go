@@ -0,0 +1,171 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	circuitbreaker "StructForge/backend/apps/gateway/internal/middleware/circuitbreaker"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/common/log"
+)
+
+// newOutlierDetectors 根据服务级配置创建异常实例检测器（未启用的服务不创建）
+func newOutlierDetectors(configs map[string]*conf.OutlierDetectionConfig) map[string]*circuitbreaker.OutlierDetector {
+	detectors := make(map[string]*circuitbreaker.OutlierDetector)
+	for service, config := range configs {
+		if config == nil || !config.Enabled {
+			continue
+		}
+		detectors[service] = circuitbreaker.NewOutlierDetector(&circuitbreaker.OutlierConfig{
+			Consecutive5xx:              config.Consecutive5xx,
+			ConsecutiveConnectionErrors: config.ConsecutiveConnectionErrors,
+			LatencyFactor:               config.LatencyFactor,
+			LatencyMinRequests:          config.LatencyMinRequests,
+			BaseEjectionTime:            time.Duration(config.BaseEjectionTime) * time.Second,
+			MaxEjectionTime:             time.Duration(config.MaxEjectionTime) * time.Second,
+			MaxEjectionPercent:          config.MaxEjectionPercent,
+		})
+	}
+	return detectors
+}
+
+// circuitBreakerConfig 获取路由的熔断器配置（未启用时返回 nil）
+func circuitBreakerConfig(route *Route) *circuitbreaker.Config {
+	if route.CircuitBreaker == nil || !route.CircuitBreaker.Enabled {
+		return nil
+	}
+
+	cbConfig := &circuitbreaker.Config{
+		FailureThreshold: route.CircuitBreaker.FailureThreshold,
+		MinRequests:      route.CircuitBreaker.MinRequests,
+		WindowSize:       route.CircuitBreaker.WindowSize,
+		OpenDuration:     route.CircuitBreaker.OpenDuration,
+		HalfOpenRequests: route.CircuitBreaker.HalfOpenRequests,
+		Timeout:          route.CircuitBreaker.Timeout,
+	}
+	// 设置默认值
+	if cbConfig.FailureThreshold == 0 {
+		cbConfig.FailureThreshold = 0.5
+	}
+	if cbConfig.MinRequests == 0 {
+		cbConfig.MinRequests = 10
+	}
+	if cbConfig.WindowSize == 0 {
+		cbConfig.WindowSize = 60
+	}
+	if cbConfig.OpenDuration == 0 {
+		cbConfig.OpenDuration = 30
+	}
+	if cbConfig.HalfOpenRequests == 0 {
+		cbConfig.HalfOpenRequests = 3
+	}
+	if cbConfig.Timeout == 0 {
+		cbConfig.Timeout = route.Timeout
+		if cbConfig.Timeout == 0 {
+			cbConfig.Timeout = 30
+		}
+	}
+	return cbConfig
+}
+
+// breakerKey 获取实例熔断器的键（服务名/实例标识）
+func breakerKey(service string, instance *discovery.Instance) string {
+	return service + "/" + instance.Key()
+}
+
+// outlierDetector 获取服务的异常实例检测器（未启用时返回 nil）
+func (r *Router) outlierDetector(service string) *circuitbreaker.OutlierDetector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.outlierDetectors[service]
+}
+
+// filterInstances 从候选实例中排除被摘除的实例、主动健康检查失败的实例、被驱逐的异常实例和熔断器已打开的实例
+// 异常实例全部被驱逐时回退到全部实例（避免检测误判导致服务不可用）；
+// 所有实例都被摘除时返回错误；所有实例的熔断器都已打开时返回熔断错误
+func (r *Router) filterInstances(ctx context.Context, route *Route, instances []discovery.Instance) ([]discovery.Instance, error) {
+	// 被摘除的实例不再接收新请求
+	instances = r.excludeDrained(route.Service, instances)
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("服务 %s 没有可用实例（实例均已摘除）", route.Service)
+	}
+
+	// 主动健康检查失败的实例标记为不健康，由负载均衡器跳过
+	instances = r.applyHealthChecks(route.Service, instances)
+
+	if detector := r.outlierDetector(route.Service); detector != nil {
+		detector.SetHostCount(len(instances))
+		available := make([]discovery.Instance, 0, len(instances))
+		for i := range instances {
+			if !detector.IsEjected(instances[i].Key()) {
+				available = append(available, instances[i])
+			}
+		}
+		if len(available) > 0 {
+			instances = available
+		} else {
+			log.Warn(ctx, "服务所有实例均被驱逐，回退到全部实例",
+				log.String("service", route.Service),
+			)
+		}
+	}
+
+	if route.CircuitBreaker != nil && route.CircuitBreaker.Enabled {
+		available := make([]discovery.Instance, 0, len(instances))
+		for i := range instances {
+			if !r.circuitBreakers.IsOpen(breakerKey(route.Service, &instances[i])) {
+				available = append(available, instances[i])
+			}
+		}
+		if len(available) == 0 {
+			return nil, fmt.Errorf("服务暂时不可用（熔断器已打开）")
+		}
+		instances = available
+	}
+
+	return instances, nil
+}
+
+// recordInstanceResult 记录实例的请求结果，用于异常实例检测
+// 客户端主动断开导致的失败不计入实例统计
+func (r *Router) recordInstanceResult(requestCtx context.Context, service string, instance *discovery.Instance, statusCode int, err error, duration time.Duration) {
+	detector := r.outlierDetector(service)
+	if detector == nil {
+		return
+	}
+
+	result := circuitbreaker.InstanceResult{StatusCode: statusCode, Duration: duration}
+	if err != nil {
+		if requestCtx.Err() != nil && !errors.Is(context.Cause(requestCtx), errUpstreamTimeout) {
+			return
+		}
+		result.ConnectionError = true
+	}
+
+	key := instance.Key()
+	if ejected, reason := detector.Record(key, result); ejected {
+		log.Warn(requestCtx, "异常实例已被驱逐",
+			log.String("service", service),
+			log.String("instance", key),
+			log.String("reason", reason),
+		)
+		if r.metrics != nil {
+			r.metrics.RecordOutlierEjection(service, reason)
+		}
+	}
+}
+
+// GetOutlierStats 获取各服务异常实例检测的统计信息
+func (r *Router) GetOutlierStats() map[string]map[string]map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := make(map[string]map[string]map[string]interface{}, len(r.outlierDetectors))
+	for service, detector := range r.outlierDetectors {
+		stats[service] = detector.GetStats()
+	}
+	return stats
+}
@@ -0,0 +1,112 @@
+package router
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+)
+
+// 请求头/查询参数匹配方式
+const (
+	// ConditionExact 精确匹配（默认）
+	ConditionExact = "exact"
+	// ConditionRegex 正则匹配
+	ConditionRegex = "regex"
+	// ConditionPresent 存在即可
+	ConditionPresent = "present"
+)
+
+// matchRequest 判断请求是否满足路由的全部匹配条件（路径、方法、Host、请求头、查询参数）
+func (r *Router) matchRequest(req *http.Request, route *Route) bool {
+	return r.matchPath(req.URL.Path, route) &&
+		matchMethod(req.Method, route.Methods) &&
+		matchHost(req.Host, route.Host) &&
+		matchConditions(route.Headers, func(name string) ([]string, bool) {
+			values := req.Header.Values(name)
+			return values, len(values) > 0
+		}) &&
+		matchConditions(route.Query, func(name string) ([]string, bool) {
+			values, ok := req.URL.Query()[name]
+			return values, ok
+		})
+}
+
+// matchMethod 匹配请求方法（未配置时匹配所有方法）
+func matchMethod(method string, methods []string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost 匹配请求 Host（忽略端口和大小写，支持 *.example.com 通配子域名）
+func matchHost(host, pattern string) bool {
+	if pattern == "" {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+	pattern = strings.ToLower(pattern)
+
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+// matchConditions 匹配请求头或查询参数条件（全部条件满足才匹配）
+// lookup 返回指定名称的所有值及是否存在
+func matchConditions(conditions []conf.RouteMatchCondition, lookup func(name string) ([]string, bool)) bool {
+	for _, condition := range conditions {
+		values, exists := lookup(condition.Name)
+		if !matchCondition(condition, values, exists) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchCondition 匹配单个条件（多个值时任意一个满足即可）
+func matchCondition(condition conf.RouteMatchCondition, values []string, exists bool) bool {
+	if !exists {
+		return false
+	}
+
+	switch condition.Type {
+	case ConditionPresent:
+		return true
+	case ConditionRegex:
+		re, err := compileRegex(condition.Value)
+		if err != nil {
+			return false
+		}
+		for _, value := range values {
+			if re.MatchString(value) {
+				return true
+			}
+		}
+		return false
+	default:
+		for _, value := range values {
+			if value == condition.Value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// routeHasHigherPriority 判断路由 a 的优先级是否高于 b（priority 越大越优先）
+// 配合稳定排序使用，优先级相同时保持配置中的顺序
+func routeHasHigherPriority(a, b *Route) bool {
+	return a.Priority > b.Priority
+}
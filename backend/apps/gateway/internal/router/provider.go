@@ -1,29 +1,131 @@
 package router
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"StructForge/backend/apps/gateway/internal/conf"
 	corsMiddleware "StructForge/backend/apps/gateway/internal/middleware/cors"
 	jwtMiddleware "StructForge/backend/apps/gateway/internal/middleware/jwt"
 	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/common/log"
+	nacosClient "StructForge/backend/common/middleware/nacos"
 
 	"github.com/google/wire"
 )
 
 // ProviderSet 路由模块依赖注入
 var ProviderSet = wire.NewSet(
-	NewStaticDiscovery,
+	NewServiceDiscovery,
 	NewJWTManagerFromConfig,
 	NewCORSHandlerFromConfig,
 	LoadRouterFromConfig, // LoadRouterFromConfig 内部会调用 NewRouter
 )
 
+// 服务发现类型
+const (
+	// DiscoveryStatic 静态服务发现（使用 services 中的实例）
+	DiscoveryStatic = "static"
+	// DiscoveryNacos Nacos 服务发现（订阅实例变化，保存实例快照）
+	DiscoveryNacos = "nacos"
+	// DiscoveryDNS DNS 服务发现（SRV 或 A/AAAA 记录）
+	DiscoveryDNS = "dns"
+	// DiscoveryFile 文件服务发现（监听 JSON/YAML 实例文件）
+	DiscoveryFile = "file"
+)
+
 // NewStaticDiscovery 创建静态服务发现
 func NewStaticDiscovery() *discovery.StaticDiscovery {
 	return discovery.NewStaticDiscovery()
 }
 
+// Nacos 实例快照默认配置
+const (
+	defaultSnapshotDir    = "data/gateway/discovery"
+	defaultSnapshotMaxAge = 24 * time.Hour
+)
+
+// NewServiceDiscovery 根据配置创建服务发现（Wire provider，返回停止后台刷新的清理函数）
+// nacosConfig 为启动配置中的 Nacos 连接信息（type 为 nacos 时使用）
+func NewServiceDiscovery(config *conf.GatewayConfig, nacosConfig *nacosClient.StartupConfig) (discovery.ServiceDiscovery, func(), error) {
+	if config == nil || config.Discovery == nil {
+		return NewStaticDiscovery(), func() {}, nil
+	}
+
+	discoveryConfig := config.Discovery
+	switch discoveryConfig.Type {
+	case "", DiscoveryStatic:
+		return NewStaticDiscovery(), func() {}, nil
+	case DiscoveryNacos:
+		snapshotDir := discoveryConfig.SnapshotDir
+		if snapshotDir == "" {
+			snapshotDir = defaultSnapshotDir
+		}
+		snapshotMaxAge := defaultSnapshotMaxAge
+		if discoveryConfig.SnapshotMaxAge > 0 {
+			snapshotMaxAge = time.Duration(discoveryConfig.SnapshotMaxAge) * time.Second
+		}
+		nacosDiscovery, err := discovery.NewNacosDiscovery(nacosConfig,
+			discovery.WithSnapshotDir(snapshotDir),
+			discovery.WithSnapshotMaxAge(snapshotMaxAge),
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建 Nacos 服务发现失败: %w", err)
+		}
+		log.Info(context.Background(), "Nacos 服务发现已启动",
+			log.String("snapshot_dir", snapshotDir),
+			log.String("snapshot_max_age", snapshotMaxAge.String()),
+		)
+		return nacosDiscovery, nacosDiscovery.Stop, nil
+	case DiscoveryDNS:
+		if discoveryConfig.DNS == nil {
+			return nil, nil, fmt.Errorf("dns 服务发现未配置")
+		}
+		dnsConfig := discoveryConfig.DNS
+		services := make(map[string]discovery.DNSService, len(dnsConfig.Services))
+		for serviceName, service := range dnsConfig.Services {
+			if service == nil {
+				continue
+			}
+			services[serviceName] = discovery.DNSService{
+				Type: service.Type,
+				Name: service.Name,
+				Port: service.Port,
+			}
+		}
+		resolver := discovery.NewDNSClient(dnsConfig.Servers, time.Duration(dnsConfig.Timeout)*time.Second)
+		dnsDiscovery := discovery.NewDNSDiscovery(&discovery.DNSConfig{
+			Services:   services,
+			MinRefresh: time.Duration(dnsConfig.MinRefresh) * time.Second,
+			MaxRefresh: time.Duration(dnsConfig.MaxRefresh) * time.Second,
+		}, resolver)
+		dnsDiscovery.Start()
+		log.Info(context.Background(), "DNS 服务发现已启动",
+			log.Int("services", len(services)),
+		)
+		return dnsDiscovery, dnsDiscovery.Stop, nil
+	case DiscoveryFile:
+		if discoveryConfig.File == nil {
+			return nil, nil, fmt.Errorf("file 服务发现未配置")
+		}
+		fileDiscovery, err := discovery.NewFileDiscovery(&discovery.FileConfig{
+			Path:     discoveryConfig.File.Path,
+			Interval: time.Duration(discoveryConfig.File.Interval) * time.Second,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建文件服务发现失败: %w", err)
+		}
+		fileDiscovery.Start()
+		log.Info(context.Background(), "文件服务发现已启动",
+			log.String("path", discoveryConfig.File.Path),
+		)
+		return fileDiscovery, fileDiscovery.Stop, nil
+	default:
+		return nil, nil, fmt.Errorf("无效的服务发现类型: %s", discoveryConfig.Type)
+	}
+}
+
 // NewJWTManagerFromConfig 从配置创建 JWT 管理器（Wire provider）
 func NewJWTManagerFromConfig(config *conf.GatewayConfig) *jwtMiddleware.Manager {
 	if config != nil && config.JWT != nil {
@@ -0,0 +1,419 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/apps/gateway/internal/router/healthcheck"
+	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
+	"StructForge/backend/common/log"
+)
+
+// ConfigDiff 网关配置变更内容
+type ConfigDiff struct {
+	// 新增的路由（按路由标识，如 "GET,POST api.example.com/api/v1/users (prefix)"）
+	AddedRoutes []string `json:"added_routes,omitempty"`
+	// 删除的路由
+	RemovedRoutes []string `json:"removed_routes,omitempty"`
+	// 配置变化的路由
+	ChangedRoutes []string `json:"changed_routes,omitempty"`
+	// 新增的静态服务
+	AddedServices []string `json:"added_services,omitempty"`
+	// 删除的静态服务
+	RemovedServices []string `json:"removed_services,omitempty"`
+	// 实例列表变化的静态服务
+	ChangedServices []string `json:"changed_services,omitempty"`
+	// 变化的服务级策略配置（service_concurrency、adaptive_concurrency、outlier_detection、health_check），热更新生效
+	ChangedPolicies []string `json:"changed_policies,omitempty"`
+	// 变化但需要重启才能生效的配置（jwt、discovery、frontend、cors、trusted_proxies、retry_budget、admin）
+	RestartRequired []string `json:"restart_required,omitempty"`
+}
+
+// Empty 是否没有任何变化
+func (d *ConfigDiff) Empty() bool {
+	return len(d.AddedRoutes) == 0 && len(d.RemovedRoutes) == 0 && len(d.ChangedRoutes) == 0 &&
+		len(d.AddedServices) == 0 && len(d.RemovedServices) == 0 && len(d.ChangedServices) == 0 &&
+		len(d.ChangedPolicies) == 0 && len(d.RestartRequired) == 0
+}
+
+// String 返回变更内容的文本描述（用于日志）
+func (d *ConfigDiff) String() string {
+	if d.Empty() {
+		return "无变化"
+	}
+
+	var parts []string
+	add := func(name string, items []string) {
+		if len(items) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: [%s]", name, strings.Join(items, "; ")))
+		}
+	}
+	add("新增路由", d.AddedRoutes)
+	add("删除路由", d.RemovedRoutes)
+	add("变更路由", d.ChangedRoutes)
+	add("新增服务", d.AddedServices)
+	add("删除服务", d.RemovedServices)
+	add("变更服务", d.ChangedServices)
+	add("变更策略", d.ChangedPolicies)
+	add("需重启生效", d.RestartRequired)
+	return strings.Join(parts, ", ")
+}
+
+// DiffGatewayConfig 比较两份网关配置，返回从 oldConfig 到 newConfig 的变更内容
+// 路由按匹配条件（方法、Host、路径、匹配类型、请求头和查询参数条件）识别，匹配条件相同而其他配置不同的路由视为变更
+func DiffGatewayConfig(oldConfig, newConfig *conf.GatewayConfig) *ConfigDiff {
+	if oldConfig == nil {
+		oldConfig = &conf.GatewayConfig{}
+	}
+	if newConfig == nil {
+		newConfig = &conf.GatewayConfig{}
+	}
+	diff := &ConfigDiff{}
+
+	// 路由
+	oldKeys, oldRules := keyedRouteRules(oldConfig)
+	newKeys, newRules := keyedRouteRules(newConfig)
+	for _, key := range newKeys {
+		oldRule, exists := oldRules[key]
+		if !exists {
+			diff.AddedRoutes = append(diff.AddedRoutes, key)
+		} else if !reflect.DeepEqual(oldRule, newRules[key]) {
+			diff.ChangedRoutes = append(diff.ChangedRoutes, key)
+		}
+	}
+	for _, key := range oldKeys {
+		if _, exists := newRules[key]; !exists {
+			diff.RemovedRoutes = append(diff.RemovedRoutes, key)
+		}
+	}
+
+	// 静态服务
+	oldServices, newServices := staticServices(oldConfig), staticServices(newConfig)
+	for service, instances := range newServices {
+		oldInstances, exists := oldServices[service]
+		if !exists {
+			diff.AddedServices = append(diff.AddedServices, service)
+		} else if !reflect.DeepEqual(oldInstances, instances) {
+			diff.ChangedServices = append(diff.ChangedServices, service)
+		}
+	}
+	for service := range oldServices {
+		if _, exists := newServices[service]; !exists {
+			diff.RemovedServices = append(diff.RemovedServices, service)
+		}
+	}
+	sort.Strings(diff.AddedServices)
+	sort.Strings(diff.RemovedServices)
+	sort.Strings(diff.ChangedServices)
+
+	// 服务级策略（热更新生效）
+	policies := []struct {
+		name     string
+		old, new any
+	}{
+		{"service_concurrency", oldConfig.ServiceConcurrency, newConfig.ServiceConcurrency},
+		{"adaptive_concurrency", oldConfig.AdaptiveConcurrency, newConfig.AdaptiveConcurrency},
+		{"outlier_detection", oldConfig.OutlierDetection, newConfig.OutlierDetection},
+		{"health_check", oldConfig.HealthCheck, newConfig.HealthCheck},
+	}
+	for _, policy := range policies {
+		if !sameConfig(policy.old, policy.new) {
+			diff.ChangedPolicies = append(diff.ChangedPolicies, policy.name)
+		}
+	}
+
+	// 启动时创建的组件使用的配置（需要重启生效）
+	restartRequired := []struct {
+		name     string
+		old, new any
+	}{
+		{"jwt", oldConfig.JWT, newConfig.JWT},
+		{"discovery", oldConfig.Discovery, newConfig.Discovery},
+		{"frontend", oldConfig.Frontend, newConfig.Frontend},
+		{"cors", oldConfig.CORS, newConfig.CORS},
+		{"trusted_proxies", oldConfig.TrustedProxies, newConfig.TrustedProxies},
+		{"retry_budget", oldConfig.RetryBudget, newConfig.RetryBudget},
+		{"admin", oldConfig.Admin, newConfig.Admin},
+	}
+	for _, section := range restartRequired {
+		if !sameConfig(section.old, section.new) {
+			diff.RestartRequired = append(diff.RestartRequired, section.name)
+		}
+	}
+
+	return diff
+}
+
+// sameConfig 比较两个配置是否相同（nil 与空集合视为相同）
+func sameConfig(a, b any) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() == reflect.Map || va.Kind() == reflect.Slice {
+		if va.Len() == 0 && vb.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// staticServices 获取静态服务实例配置
+func staticServices(config *conf.GatewayConfig) map[string][]conf.ServiceInstance {
+	if config.Services == nil {
+		return nil
+	}
+	return config.Services.Services
+}
+
+// keyedRouteRules 按路由标识索引路由规则，返回配置顺序的标识列表
+// 匹配条件完全相同的多条路由按出现顺序追加序号区分
+func keyedRouteRules(config *conf.GatewayConfig) ([]string, map[string]*conf.RouteRule) {
+	rules := make(map[string]*conf.RouteRule)
+	if config.Routes == nil {
+		return nil, rules
+	}
+
+	keys := make([]string, 0, len(config.Routes.Routes))
+	seen := make(map[string]int)
+	for i := range config.Routes.Routes {
+		rule := &config.Routes.Routes[i]
+		key := routeKey(rule)
+		seen[key]++
+		if n := seen[key]; n > 1 {
+			key = fmt.Sprintf("%s #%d", key, n)
+		}
+		keys = append(keys, key)
+		rules[key] = rule
+	}
+	return keys, rules
+}
+
+// routeKey 根据匹配条件生成路由标识
+func routeKey(rule *conf.RouteRule) string {
+	methods := "*"
+	if len(rule.Methods) > 0 {
+		upper := make([]string, 0, len(rule.Methods))
+		for _, method := range rule.Methods {
+			upper = append(upper, strings.ToUpper(method))
+		}
+		slices.Sort(upper)
+		methods = strings.Join(upper, ",")
+	}
+	matchType := rule.MatchType
+	if matchType == "" {
+		matchType = "prefix"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s%s (%s)", methods, rule.Host, rule.Path, matchType)
+	for _, condition := range rule.Headers {
+		fmt.Fprintf(&b, " header:%s", matchConditionKey(condition))
+	}
+	for _, condition := range rule.Query {
+		fmt.Fprintf(&b, " query:%s", matchConditionKey(condition))
+	}
+	return b.String()
+}
+
+// routeKeyOf 根据路由的匹配条件生成路由标识
+func routeKeyOf(route *Route) string {
+	return routeKey(&conf.RouteRule{
+		Path:      route.Path,
+		MatchType: route.MatchType,
+		Methods:   route.Methods,
+		Headers:   route.Headers,
+		Query:     route.Query,
+		Host:      route.Host,
+	})
+}
+
+// matchConditionKey 生成请求头或查询参数匹配条件的标识
+func matchConditionKey(condition conf.RouteMatchCondition) string {
+	switch condition.Type {
+	case "present":
+		return condition.Name
+	case "regex":
+		return condition.Name + "~" + condition.Value
+	default:
+		return condition.Name + "=" + condition.Value
+	}
+}
+
+// Reload 热更新网关配置
+// 新配置验证失败时保持当前配置并返回错误；验证通过后原子替换路由表：
+// 未变化的路由沿用原路由对象，正在处理的请求继续使用替换前的路由完成转发；
+// 熔断器按服务实例保存、限流器按路由路径和配额保存，未变化的路由保留原有状态。
+// 返回的变更内容在验证失败时同样有效（用于记录被拒绝的变更）
+func (r *Router) Reload(config *conf.GatewayConfig) (*ConfigDiff, error) {
+	ctx := context.Background()
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.mu.RLock()
+	current := r.config
+	r.mu.RUnlock()
+
+	diff := DiffGatewayConfig(current, config)
+	if err := ValidateGatewayConfig(config); err != nil {
+		log.Warn(ctx, "网关配置更新验证失败，已拒绝并保持当前配置",
+			log.ErrorField(err),
+			log.String("diff", diff.String()),
+		)
+		return diff, fmt.Errorf("配置验证失败: %w", err)
+	}
+	if diff.Empty() {
+		log.Info(ctx, "网关配置未变化")
+		return diff, nil
+	}
+	if current == nil {
+		current = &conf.GatewayConfig{}
+	}
+
+	// 先注册新增和变化的静态服务实例，保证新路由生效时服务实例已可用
+	// （在锁外调用，服务发现通知监听器时会获取路由管理器的锁）
+	staticDiscovery, isStatic := r.discovery.(*discovery.StaticDiscovery)
+	if isStatic {
+		services := staticServices(config)
+		for _, service := range append(slices.Clone(diff.AddedServices), diff.ChangedServices...) {
+			staticDiscovery.RegisterService(service, newStaticInstances(services[service]))
+		}
+	}
+
+	routes, strategies := r.buildRoutes(current, config)
+
+	r.mu.Lock()
+	// 负载均衡器：策略未变化的服务沿用原负载均衡器；已删除服务的负载均衡器保留，服务重新加入时继续使用
+	loadBalancers := make(map[string]loadbalancer.LoadBalancer, len(r.loadBalancers))
+	for service, lb := range r.loadBalancers {
+		loadBalancers[service] = lb
+	}
+	var watchServices, replacedServices []string
+	for service, strategy := range strategies {
+		if _, exists := loadBalancers[service]; !exists {
+			watchServices = append(watchServices, service)
+		} else if r.lbStrategies[service] == strategy {
+			continue
+		} else {
+			replacedServices = append(replacedServices, service)
+		}
+		loadBalancers[service] = loadbalancer.NewLoadBalancer(strategy)
+		r.lbStrategies[service] = strategy
+	}
+
+	// 异常实例检测器：配置未变化的服务保留检测状态
+	detectors := newOutlierDetectors(config.OutlierDetection)
+	for service := range detectors {
+		if reflect.DeepEqual(current.OutlierDetection[service], config.OutlierDetection[service]) {
+			if detector := r.outlierDetectors[service]; detector != nil {
+				detectors[service] = detector
+			}
+		}
+	}
+
+	// 主动健康检查器：配置未变化的服务保留检查状态，其余重新创建
+	checkers := make(map[string]*healthcheck.Checker)
+	for service, checkConfig := range config.HealthCheck {
+		if checkConfig == nil || !checkConfig.Enabled {
+			continue
+		}
+		if checker := r.healthCheckers[service]; checker != nil && reflect.DeepEqual(current.HealthCheck[service], checkConfig) {
+			checkers[service] = checker
+			continue
+		}
+		checkers[service] = r.startHealthChecker(service, checkConfig)
+	}
+	var stoppedCheckers []*healthcheck.Checker
+	for service, checker := range r.healthCheckers {
+		if checkers[service] != checker {
+			stoppedCheckers = append(stoppedCheckers, checker)
+		}
+	}
+
+	r.routes = routes
+	r.loadBalancers = loadBalancers
+	r.serviceConcurrency = config.ServiceConcurrency
+	r.adaptiveConcurrency = config.AdaptiveConcurrency
+	r.outlierDetectors = detectors
+	r.healthCheckers = checkers
+	r.config = config
+	r.mu.Unlock()
+
+	// 以下操作在锁外执行
+	for _, checker := range stoppedCheckers {
+		checker.Stop()
+	}
+	for _, service := range watchServices {
+		r.watchService(service)
+	}
+	// 新建的负载均衡器使用当前实例列表初始化
+	for _, service := range append(watchServices, replacedServices...) {
+		if instances, err := r.discovery.GetInstances(ctx, service); err == nil {
+			r.UpdateServiceInstances(service, instances)
+		}
+	}
+	for _, service := range diff.RemovedServices {
+		r.circuitBreakers.Retain(service, nil)
+		if isStatic {
+			staticDiscovery.RemoveService(service)
+		}
+	}
+
+	log.Info(ctx, "网关配置已热更新",
+		log.Int("routes", len(routes)),
+		log.String("diff", diff.String()),
+	)
+	if len(diff.RestartRequired) > 0 {
+		log.Warn(ctx, "部分网关配置变更需要重启后生效",
+			log.String("sections", strings.Join(diff.RestartRequired, ", ")),
+		)
+	}
+	return diff, nil
+}
+
+// buildRoutes 根据新配置构建按优先级排序的路由表，并返回各服务的负载均衡策略
+// 规则未变化的路由沿用原路由对象（保留对冲请求的延迟统计等运行时状态）
+func (r *Router) buildRoutes(current, config *conf.GatewayConfig) ([]*Route, map[string]string) {
+	r.mu.RLock()
+	existing := make(map[*conf.RouteRule]*Route, len(r.routes))
+	for _, route := range r.routes {
+		if route.rule != nil {
+			existing[route.rule] = route
+		}
+	}
+	r.mu.RUnlock()
+
+	_, oldRules := keyedRouteRules(current)
+	keys, rules := keyedRouteRules(config)
+	routes := make([]*Route, 0, len(keys))
+	strategies := make(map[string]string)
+	for _, key := range keys {
+		rule := rules[key]
+		var route *Route
+		if oldRule, exists := oldRules[key]; exists && reflect.DeepEqual(oldRule, rule) {
+			route = existing[oldRule]
+		}
+		if route != nil {
+			// 只由 Reload 读写（串行执行），与请求处理并发安全
+			route.rule = rule
+		} else {
+			route = newRouteFromConfig(rule)
+			route.Key = key
+			prepareRoute(route)
+		}
+		routes = append(routes, route)
+
+		// 服务的负载均衡策略以配置中该服务的第一条路由为准（与逐条 AddRoute 一致）
+		if _, exists := strategies[route.Service]; !exists {
+			strategies[route.Service] = route.LoadBalanceStrategy
+		}
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routeHasHigherPriority(routes[i], routes[j])
+	})
+	return routes, strategies
+}
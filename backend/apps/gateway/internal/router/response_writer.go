@@ -2,6 +2,7 @@ package router
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 
@@ -127,3 +128,83 @@ func (t *TeeWriter) Write(p []byte) (n int, err error) {
 	}
 	return len(p), nil
 }
+
+// streamBufferSize 流式转发时每次读取的缓冲区大小
+const streamBufferSize = 32 * 1024
+
+// LimitedBuffer 有上限的缓冲区（用于在流式转发时旁路收集可缓存的响应体）
+// 写入数据超过上限后丢弃已收集的内容，并标记为溢出，不再收集
+type LimitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+// NewLimitedBuffer 创建有上限的缓冲区
+func NewLimitedBuffer(limit int64) *LimitedBuffer {
+	return &LimitedBuffer{
+		limit: limit,
+	}
+}
+
+// Write 写入数据（超过上限时丢弃，始终返回写入成功，避免中断流式转发）
+func (b *LimitedBuffer) Write(p []byte) (int, error) {
+	if b.overflow {
+		return len(p), nil
+	}
+	if int64(b.buf.Len()+len(p)) > b.limit {
+		b.overflow = true
+		b.buf = bytes.Buffer{}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Overflow 是否已超过上限
+func (b *LimitedBuffer) Overflow() bool {
+	return b.overflow
+}
+
+// Bytes 获取已收集的数据（溢出时返回 nil）
+func (b *LimitedBuffer) Bytes() []byte {
+	if b.overflow {
+		return nil
+	}
+	return b.buf.Bytes()
+}
+
+// StreamCopy 将上游响应体流式复制到客户端
+// 每次读取到数据后立即写入并刷新，避免整个响应体在网关内存中缓冲；
+// tee 不为 nil 时同时写入 tee（用于缓存）
+func StreamCopy(w http.ResponseWriter, body io.Reader, tee io.Writer) (int64, error) {
+	rc := http.NewResponseController(w)
+	buf := make([]byte, streamBufferSize)
+
+	var written int64
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			nw, writeErr := w.Write(buf[:n])
+			written += int64(nw)
+			if writeErr != nil {
+				return written, writeErr
+			}
+			if nw != n {
+				return written, io.ErrShortWrite
+			}
+			if tee != nil {
+				tee.Write(buf[:n])
+			}
+			// 刷新到客户端（底层 ResponseWriter 不支持刷新时忽略）
+			if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return written, err
+			}
+		}
+		if readErr != nil {
+			if readErr == io.EOF {
+				return written, nil
+			}
+			return written, readErr
+		}
+	}
+}
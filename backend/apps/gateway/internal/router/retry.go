@@ -0,0 +1,190 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	stdHttp "net/http"
+	"time"
+
+	circuitbreaker "StructForge/backend/apps/gateway/internal/middleware/circuitbreaker"
+	"StructForge/backend/apps/gateway/internal/middleware/retry"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// retryDrainLimit 重试前读取并丢弃的最大响应体大小（读完后连接可以复用）
+const retryDrainLimit = 64 << 10
+
+// retryReasonCircuitOpen 实例熔断器已打开（请求未发送，可以换实例重试）
+const retryReasonCircuitOpen = "circuit_open"
+
+// newRetryPolicy 根据路由配置创建重试策略
+func newRetryPolicy(route *Route) *retry.Policy {
+	config := &retry.Config{MaxRetries: route.Retries}
+	if route.Retry != nil {
+		if route.Retry.Attempts > 0 {
+			config.MaxRetries = route.Retry.Attempts
+		}
+		config.RetryOn = route.Retry.RetryOn
+		config.RetryOnStatus = route.Retry.RetryOnStatus
+		config.RetryNonIdempotent = route.Retry.RetryNonIdempotent
+		config.MaxBodySize = route.Retry.MaxBodySize
+		config.BaseInterval = time.Duration(route.Retry.BaseInterval) * time.Millisecond
+		config.MaxInterval = time.Duration(route.Retry.MaxInterval) * time.Millisecond
+	}
+	return retry.NewPolicy(config)
+}
+
+// routeRetryPolicy 获取路由的重试策略（未通过 AddRoute 添加的路由按配置临时创建）
+func (r *Router) routeRetryPolicy(route *Route) *retry.Policy {
+	if route.retryPolicy != nil {
+		return route.retryPolicy
+	}
+	return newRetryPolicy(route)
+}
+
+// prepareRequestBody 准备转发的请求体，返回每次尝试获取请求体的函数以及请求体是否可以重放
+// 允许重试时将不超过上限的请求体缓冲在内存中，超过上限时拼接已读部分继续流式转发（只能发送一次）
+func prepareRequestBody(req *stdHttp.Request, policy *retry.Policy, buffer bool) (func() io.Reader, bool, error) {
+	if req.Body == nil || req.Body == stdHttp.NoBody {
+		return func() io.Reader { return nil }, true, nil
+	}
+
+	once := func(body io.Reader) func() io.Reader {
+		return func() io.Reader { return body }
+	}
+	if !buffer || req.ContentLength > policy.MaxBodySize {
+		return once(req.Body), false, nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(req.Body, policy.MaxBodySize+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(buf)) > policy.MaxBodySize {
+		return once(io.MultiReader(bytes.NewReader(buf), req.Body)), false, nil
+	}
+	return func() io.Reader { return bytes.NewReader(buf) }, true, nil
+}
+
+// buildTargetURL 构建转发到实例的目标 URL
+func (r *Router) buildTargetURL(ctx kratosHttp.Context, route *Route, instance *discovery.Instance) string {
+	targetURL := fmt.Sprintf("http://%s:%d%s", instance.Host, instance.Port, r.buildTargetPath(ctx, route))
+	if ctx.Request().URL.RawQuery != "" {
+		targetURL += "?" + ctx.Request().URL.RawQuery
+	}
+	return targetURL
+}
+
+// forwardAttempt 向选中的实例发送一次请求
+// 上游返回响应时（包括 5xx）始终返回响应，5xx 只作为失败计入实例熔断器；
+// 请求失败或响应体关闭时调用负载均衡器的 done 回调
+func (r *Router) forwardAttempt(ctx kratosHttp.Context, requestCtx context.Context, route *Route, instance *discovery.Instance, done loadbalancer.DoneFunc, targetURL string, body io.Reader, cbConfig *circuitbreaker.Config, templateValues map[string]string) (*stdHttp.Response, error) {
+	// 创建代理请求
+	req, err := stdHttp.NewRequestWithContext(requestCtx, ctx.Request().Method, targetURL, body)
+	if err != nil {
+		done(loadbalancer.DoneInfo{Err: err})
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	if body != nil && req.ContentLength == 0 {
+		req.ContentLength = ctx.Request().ContentLength
+	}
+
+	// 复制请求头（去除逐跳请求头）并按路由配置转换
+	req.Header = ctx.Request().Header.Clone()
+	removeHopByHopHeaders(req.Header)
+	r.setIdentityHeaders(ctx.Request(), req, route.Service)
+	r.setForwardedHeaders(ctx.Request(), req.Header)
+	applyHeaderTransform(req.Header, route.RequestHeaders, templateValues)
+
+	// 覆盖 Host 请求头
+	if route.HostRewrite != "" {
+		req.Host = route.HostRewrite
+	}
+
+	var resp *stdHttp.Response
+	var latency time.Duration
+	send := func() error {
+		// 发送请求，并按实例记录结果用于异常实例检测
+		start := time.Now()
+		var sendErr error
+		resp, sendErr = r.httpClient.Do(req)
+		latency = time.Since(start)
+		statusCode := 0
+		if resp != nil {
+			statusCode = resp.StatusCode
+		}
+		r.recordInstanceResult(requestCtx, route.Service, instance, statusCode, sendErr, latency)
+		if sendErr != nil {
+			// 请求被取消时返回取消原因：超时计入熔断器失败，客户端断开和对冲请求落败不计入
+			if cause := context.Cause(requestCtx); cause != nil {
+				return cause
+			}
+			return sendErr
+		}
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("server error: %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	// 使用熔断器执行请求（如果启用），熔断器按实例隔离，单个异常实例不影响同服务的其他实例
+	if cbConfig != nil {
+		err = r.circuitBreakers.Execute(requestCtx, breakerKey(route.Service, instance), cbConfig, send)
+	} else {
+		err = send()
+	}
+	if resp != nil {
+		resp.Body = &doneOnClose{ReadCloser: resp.Body, done: done, info: loadbalancer.DoneInfo{Err: err, Latency: latency}}
+		return resp, nil
+	}
+	done(loadbalancer.DoneInfo{Err: err, Latency: latency})
+	return nil, err
+}
+
+// shouldRetry 判断本次尝试的结果是否可以重试，返回重试原因
+// 连接失败和熔断器拒绝时请求未发送到上游，任何方法都可以重试；
+// 连接重置和可重试状态码只对幂等请求（或策略允许的非幂等请求）重试
+func (r *Router) shouldRetry(requestCtx context.Context, policy *retry.Policy, req *stdHttp.Request, resp *stdHttp.Response, err error) (string, bool) {
+	// 客户端断开或总超时后不再重试
+	if requestCtx.Err() != nil {
+		return "", false
+	}
+
+	if resp != nil {
+		if policy.RetryableStatus(resp.StatusCode) && policy.AllowsRequest(req) {
+			return fmt.Sprintf("status_%d", resp.StatusCode), true
+		}
+		return "", false
+	}
+
+	if circuitbreaker.IsCircuitBreakerError(err) {
+		return retryReasonCircuitOpen, true
+	}
+	reason, retryable := policy.RetryableError(err)
+	if !retryable {
+		return "", false
+	}
+	if reason == retry.OnReset && !policy.AllowsRequest(req) {
+		return "", false
+	}
+	return reason, true
+}
+
+// recordRetry 记录重试指标
+func (r *Router) recordRetry(service, reason string) {
+	if r.metrics != nil {
+		r.metrics.RecordRetry(service, reason)
+	}
+}
+
+// recordRetryBudgetExhausted 记录重试预算耗尽指标
+func (r *Router) recordRetryBudgetExhausted(service string) {
+	if r.metrics != nil {
+		r.metrics.RecordRetryBudgetExhausted(service)
+	}
+}
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rewriteGroupPattern 匹配重写模板中的捕获组引用（$1、$name、${1}、${name}）和转义的 $$
+// 与 regexp.Expand 一致，$ 后的组名取最长的字母、数字和下划线序列
+var rewriteGroupPattern = regexp.MustCompile(`\$\$|\$(\w+)|\$\{(\w+)\}`)
+
+// rewritePath 根据路由的重写规则计算转发到目标服务的路径
+// 处理顺序：regex 路由的 rewrite（捕获组替换）或 target_path 确定基础路径，再去除 strip_prefix，最后添加 add_prefix
+func rewritePath(path string, route *Route) string {
+	targetPath := route.TargetPath
+
+	if route.Rewrite != "" && route.MatchType == "regex" {
+		// 正则重写：使用匹配时的捕获组替换模板中的 $1、${name}
+		if re, err := compileRegex(route.Path); err == nil {
+			if match := re.FindStringSubmatchIndex(path); match != nil {
+				targetPath = string(re.ExpandString(nil, route.Rewrite, path, match))
+			}
+		}
+	}
+
+	if targetPath == "" {
+		// 如果未指定目标路径，使用原始路径
+		// 对于前缀匹配，需要保留完整路径；对于精确匹配，直接使用路径
+		if route.MatchType == "exact" {
+			targetPath = route.Path
+		} else {
+			// 前缀匹配：保留完整路径
+			targetPath = path
+		}
+	}
+
+	// 去除前缀（按路径段匹配）
+	if route.StripPrefix != "" && matchPrefix(targetPath, route.StripPrefix) {
+		targetPath = targetPath[len(route.StripPrefix):]
+	}
+
+	// 确保路径以 / 开头
+	if !strings.HasPrefix(targetPath, "/") {
+		targetPath = "/" + targetPath
+	}
+
+	// 添加前缀
+	if route.AddPrefix != "" {
+		targetPath = strings.TrimSuffix(route.AddPrefix, "/") + targetPath
+	}
+
+	return targetPath
+}
+
+// validateRewrite 验证正则重写模板引用的捕获组在匹配规则中存在
+func validateRewrite(pattern, rewrite string) error {
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return fmt.Errorf("无效的正则表达式: %w", err)
+	}
+
+	for _, ref := range rewriteGroupPattern.FindAllStringSubmatch(rewrite, -1) {
+		if ref[0] == "$$" {
+			continue
+		}
+		name := ref[1]
+		if name == "" {
+			name = ref[2]
+		} else if _, err := strconv.Atoi(name); err != nil && name[0] >= '0' && name[0] <= '9' {
+			// $1abc 会被解析为名为 1abc 的捕获组，编号后紧跟字母、数字或下划线时需要写成 ${1}abc
+			return fmt.Errorf("重写规则引用了不存在的捕获组: $%s（编号捕获组后紧跟字母、数字或下划线时请使用 ${N}）", name)
+		}
+		if index, err := strconv.Atoi(name); err == nil {
+			if index > re.NumSubexp() {
+				return fmt.Errorf("重写规则引用了不存在的捕获组: $%d (共 %d 个)", index, re.NumSubexp())
+			}
+			continue
+		}
+		if re.SubexpIndex(name) < 0 {
+			return fmt.Errorf("重写规则引用了不存在的命名捕获组: %s", name)
+		}
+	}
+
+	return nil
+}
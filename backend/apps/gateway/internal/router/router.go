@@ -2,47 +2,102 @@ package router
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	stdHttp "net/http"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"StructForge/backend/apps/gateway/internal/conf"
 	circuitbreaker "StructForge/backend/apps/gateway/internal/middleware/circuitbreaker"
+	"StructForge/backend/apps/gateway/internal/middleware/metrics"
+	"StructForge/backend/apps/gateway/internal/middleware/retry"
 	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/apps/gateway/internal/router/healthcheck"
 	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
 	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
+	"StructForge/backend/common/middleware/identity"
 
 	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
 )
 
 // Route 路由规则
 type Route struct {
+	// 路由标识（由匹配条件生成，匹配条件相同的路由按配置顺序追加序号），同一路径的多条路由标识不同
+	Key string `yaml:"-" json:"key"`
 	// 路径匹配规则（支持前缀匹配和精确匹配）
 	Path string `yaml:"path" json:"path"`
 	// 路径匹配类型：prefix（前缀匹配）、exact（精确匹配）、regex（正则匹配）
 	MatchType string `yaml:"match_type" json:"match_type"`
+	// 允许的请求方法（为空则匹配所有方法）
+	Methods []string `yaml:"methods" json:"methods"`
+	// 请求头匹配条件（全部满足才匹配）
+	Headers []conf.RouteMatchCondition `yaml:"headers" json:"headers"`
+	// 查询参数匹配条件（全部满足才匹配）
+	Query []conf.RouteMatchCondition `yaml:"query" json:"query"`
+	// 请求 Host 匹配（支持 *.example.com 通配子域名）
+	Host string `yaml:"host" json:"host"`
+	// 路由优先级，多个路由匹配时优先级高的生效，优先级相同时按配置顺序
+	Priority int `yaml:"priority" json:"priority"`
 	// 目标服务名称
 	Service string `yaml:"service" json:"service"`
 	// 目标服务路径（可选，如果不指定则使用原始路径）
 	TargetPath string `yaml:"target_path" json:"target_path"`
+	// 正则重写模板（仅 regex 匹配类型），支持 $1、${name} 引用捕获组，如 /v2/$1
+	Rewrite string `yaml:"rewrite" json:"rewrite"`
+	// 转发前去除的路径前缀（按路径段匹配）
+	StripPrefix string `yaml:"strip_prefix" json:"strip_prefix"`
+	// 转发前添加的路径前缀
+	AddPrefix string `yaml:"add_prefix" json:"add_prefix"`
+	// 转发时覆盖的 Host 请求头（为空则使用目标实例地址）
+	HostRewrite string `yaml:"host_rewrite" json:"host_rewrite"`
 	// 是否需要认证
 	RequireAuth bool `yaml:"require_auth" json:"require_auth"`
+	// 是否允许 WebSocket 升级（建立双向隧道）
+	WebSocket bool `yaml:"websocket" json:"websocket"`
 	// 超时时间（秒）
 	Timeout int `yaml:"timeout" json:"timeout"`
+	// 流式响应（SSE）空闲超时时间（秒），超过此时间未收到数据则断开，默认60秒
+	IdleTimeout int `yaml:"idle_timeout" json:"idle_timeout"`
 	// 重试次数
 	Retries int `yaml:"retries" json:"retries"`
-	// 负载均衡策略：round_robin, random, least_connections
+	// 负载均衡策略：round_robin（按权重平滑轮询）, weighted_round_robin, random, least_connections, consistent_hash, p2c
 	LoadBalanceStrategy string `yaml:"load_balance_strategy" json:"load_balance_strategy"`
+	// 一致性哈希键来源（仅 consistent_hash 策略）：header:<name>、cookie:<name>、user_id、ip，默认 ip
+	HashOn string `yaml:"hash_on" json:"hash_on"`
 	// 限流配置
 	RateLimit *RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
 	// 熔断器配置
 	CircuitBreaker *conf.CircuitBreakerConfig `yaml:"circuit_breaker" json:"circuit_breaker"`
 	// 缓存配置
 	Cache *conf.CacheConfig `yaml:"cache" json:"cache"`
+	// 流量拆分配置（按实例版本分配流量）
+	TrafficSplit *conf.TrafficSplitConfig `yaml:"traffic_split" json:"traffic_split"`
+	// 转发到上游的请求头转换
+	RequestHeaders *conf.HeaderTransformConfig `yaml:"request_headers" json:"request_headers"`
+	// 返回给客户端的响应头转换
+	ResponseHeaders *conf.HeaderTransformConfig `yaml:"response_headers" json:"response_headers"`
+	// 并发限制配置
+	Concurrency *conf.ConcurrencyConfig `yaml:"concurrency" json:"concurrency"`
+	// 负载削减优先级：critical、normal、low
+	ShedPriority string `yaml:"shed_priority" json:"shed_priority"`
+	// 重试策略
+	Retry *conf.RetryPolicyConfig `yaml:"retry" json:"retry"`
+	// 对冲请求配置
+	Hedging *conf.HedgingConfig `yaml:"hedging" json:"hedging"`
+
+	// 路由的配置来源（从配置加载时设置，热更新时用于判断路由是否变化）
+	rule *conf.RouteRule
+	// 根据重试配置创建的重试策略（AddRoute 时创建）
+	retryPolicy *retry.Policy
+	// 最近请求延迟（启用对冲请求时用于计算等待时间）
+	hedgeLatency *latencyWindow
 }
 
 // CircuitBreakerConfig 熔断器配置（与 conf.CircuitBreakerConfig 相同，避免循环依赖）
@@ -65,10 +120,24 @@ type CircuitBreakerConfig struct {
 
 // RateLimitConfig 限流配置
 type RateLimitConfig struct {
+	// 限流器类型：local（默认）、distributed
+	Type string `yaml:"type" json:"type"`
 	// 每秒允许的请求数
 	QPS int `yaml:"qps" json:"qps"`
 	// 突发请求数
 	Burst int `yaml:"burst" json:"burst"`
+	// 限流维度：route、ip、user、header:<name>
+	KeyBy []string `yaml:"key_by" json:"key_by"`
+	// 未认证调用方的配额
+	Anonymous *RateLimitTier `yaml:"anonymous" json:"anonymous"`
+	// 已认证调用方的配额
+	Authenticated *RateLimitTier `yaml:"authenticated" json:"authenticated"`
+}
+
+// RateLimitTier 限流配额档位
+type RateLimitTier struct {
+	QPS   int `yaml:"qps" json:"qps"`
+	Burst int `yaml:"burst" json:"burst"`
 }
 
 // Router 路由管理器
@@ -78,18 +147,45 @@ type Router struct {
 	loadBalancers   map[string]loadbalancer.LoadBalancer
 	circuitBreakers *circuitbreaker.CircuitBreakerManager
 	httpClient      *stdHttp.Client
-	mu              sync.RWMutex
+	metrics         *metrics.Metrics
+	identitySigner  *identity.Signer
+	clientIP        *clientip.Resolver
+	// 服务级并发限制配置
+	serviceConcurrency map[string]*conf.ConcurrencyConfig
+	// 服务级自适应并发限制配置
+	adaptiveConcurrency map[string]*conf.AdaptiveConcurrencyConfig
+	// 服务级异常实例检测器
+	outlierDetectors map[string]*circuitbreaker.OutlierDetector
+	// 服务级主动健康检查器
+	healthCheckers map[string]*healthcheck.Checker
+	// 全局重试预算
+	retryBudget *retry.Budget
+	// 各服务被摘除的实例（不再转发新请求）
+	drained map[string]map[string]bool
+	// 各服务负载均衡器使用的策略（热更新时判断是否需要重建负载均衡器）
+	lbStrategies map[string]string
+	// 当前生效的网关配置（从配置加载时设置）
+	config *conf.GatewayConfig
+	mu     sync.RWMutex
+	// 串行化配置热更新
+	reloadMu sync.Mutex
 }
 
 // NewRouter 创建路由管理器
 func NewRouter(discovery discovery.ServiceDiscovery) *Router {
 	return &Router{
-		routes:          make([]*Route, 0),
-		discovery:       discovery,
-		loadBalancers:   make(map[string]loadbalancer.LoadBalancer),
-		circuitBreakers: circuitbreaker.NewCircuitBreakerManager(),
+		routes:           make([]*Route, 0),
+		discovery:        discovery,
+		loadBalancers:    make(map[string]loadbalancer.LoadBalancer),
+		circuitBreakers:  circuitbreaker.NewCircuitBreakerManager(),
+		clientIP:         &clientip.Resolver{},
+		outlierDetectors: make(map[string]*circuitbreaker.OutlierDetector),
+		healthCheckers:   make(map[string]*healthcheck.Checker),
+		retryBudget:      retry.NewBudget(nil),
+		lbStrategies:     make(map[string]string),
+		drained:          make(map[string]map[string]bool),
+		// 不设置客户端总超时：超时由路由配置控制，流式响应使用空闲超时
 		httpClient: &stdHttp.Client{
-			Timeout: 30 * time.Second,
 			Transport: &stdHttp.Transport{
 				MaxIdleConns:        100,
 				MaxIdleConnsPerHost: 10,
@@ -99,30 +195,57 @@ func NewRouter(discovery discovery.ServiceDiscovery) *Router {
 	}
 }
 
-// AddRoute 添加路由规则
-func (r *Router) AddRoute(route *Route) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	// 设置默认值
+// prepareRoute 设置路由默认值并创建路由的重试策略等运行时状态
+func prepareRoute(route *Route) {
 	if route.MatchType == "" {
 		route.MatchType = "prefix"
 	}
 	if route.Timeout == 0 {
 		route.Timeout = 30
 	}
-	if route.Retries == 0 {
-		route.Retries = 0
-	}
 	if route.LoadBalanceStrategy == "" {
 		route.LoadBalanceStrategy = "round_robin"
 	}
+	route.retryPolicy = newRetryPolicy(route)
+	if route.Hedging != nil && route.Hedging.Enabled {
+		route.hedgeLatency = newLatencyWindow()
+	}
+}
+
+// AddRoute 添加路由规则
+func (r *Router) AddRoute(route *Route) {
+	r.mu.Lock()
+
+	// 设置默认值
+	prepareRoute(route)
+	if route.Key == "" {
+		route.Key = r.uniqueRouteKey(routeKeyOf(route))
+	}
 
+	// 按优先级插入，FindRoute 按顺序匹配时第一个匹配的即为优先级最高的路由
 	r.routes = append(r.routes, route)
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return routeHasHigherPriority(r.routes[i], r.routes[j])
+	})
 
 	// 为每个服务创建负载均衡器
-	if _, exists := r.loadBalancers[route.Service]; !exists {
+	_, exists := r.loadBalancers[route.Service]
+	if !exists {
 		r.loadBalancers[route.Service] = loadbalancer.NewLoadBalancer(route.LoadBalanceStrategy)
+		r.lbStrategies[route.Service] = route.LoadBalanceStrategy
+	}
+	r.mu.Unlock()
+
+	// 监听服务实例变化，实例变化时更新负载均衡器
+	// （在锁外调用，服务发现通知监听器时会获取路由管理器的锁）
+	if !exists {
+		r.watchService(route.Service)
+		// 新建的负载均衡器使用当前实例列表初始化（与热更新一致）
+		if r.discovery != nil {
+			if instances, err := r.discovery.GetInstances(context.Background(), route.Service); err == nil {
+				r.UpdateServiceInstances(route.Service, instances)
+			}
+		}
 	}
 
 	log.Info(context.Background(), "路由规则已添加",
@@ -132,6 +255,30 @@ func (r *Router) AddRoute(route *Route) {
 	)
 }
 
+// watchService 监听服务实例变化，实例变化时更新负载均衡器
+func (r *Router) watchService(service string) {
+	if r.discovery == nil {
+		return
+	}
+	if err := r.discovery.Watch(service, func(instances []discovery.Instance) {
+		r.UpdateServiceInstances(service, instances)
+	}); err != nil {
+		log.Warn(context.Background(), "监听服务实例变化失败",
+			log.String("service", service),
+			log.ErrorField(err),
+		)
+	}
+}
+
+// uniqueRouteKey 生成不与已添加路由重复的路由标识（与 keyedRouteRules 一致，重复时追加序号）
+func (r *Router) uniqueRouteKey(base string) string {
+	key := base
+	for n := 2; slices.ContainsFunc(r.routes, func(route *Route) bool { return route.Key == key }); n++ {
+		key = fmt.Sprintf("%s #%d", base, n)
+	}
+	return key
+}
+
 // AddRoutes 批量添加路由规则
 func (r *Router) AddRoutes(routes []*Route) {
 	for _, route := range routes {
@@ -139,14 +286,39 @@ func (r *Router) AddRoutes(routes []*Route) {
 	}
 }
 
+// Routes 获取当前生效的路由（按匹配优先级排序）
+func (r *Router) Routes() []*Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.routes)
+}
+
+// ServiceConcurrency 获取服务级并发限制配置（未配置时返回 nil）
+func (r *Router) ServiceConcurrency(service string) *conf.ConcurrencyConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.serviceConcurrency[service]
+}
+
+// AdaptiveConcurrency 获取服务级自适应并发限制配置（未配置或未启用时返回 nil）
+func (r *Router) AdaptiveConcurrency(service string) *conf.AdaptiveConcurrencyConfig {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if config := r.adaptiveConcurrency[service]; config != nil && config.Enabled {
+		return config
+	}
+	return nil
+}
+
 // FindRoute 查找匹配的路由
-func (r *Router) FindRoute(path string) *Route {
+// 匹配路径、方法、Host、请求头和查询参数，多个路由匹配时返回优先级最高的路由
+func (r *Router) FindRoute(req *stdHttp.Request) *Route {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	// 按添加顺序匹配，第一个匹配的路由生效
+	// 路由已按优先级排序，第一个匹配的路由生效
 	for _, route := range r.routes {
-		if r.matchPath(path, route) {
+		if r.matchRequest(req, route) {
 			return route
 		}
 	}
@@ -159,230 +331,168 @@ func (r *Router) matchPath(path string, route *Route) bool {
 	switch route.MatchType {
 	case "exact":
 		return path == route.Path
-	case "prefix":
-		return strings.HasPrefix(path, route.Path)
 	case "regex":
 		// 实现正则匹配
 		return r.matchRegex(path, route.Path)
 	default:
-		return strings.HasPrefix(path, route.Path)
+		return matchPrefix(path, route.Path)
 	}
 }
 
-// Forward 转发请求到目标服务
+// matchPrefix 按路径段匹配前缀
+// 前缀必须在路径段边界处结束，如 /api/v1/users 匹配 /api/v1/users 和 /api/v1/users/1，不匹配 /api/v1/userss
+func matchPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	if len(path) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	return path[len(prefix)] == '/'
+}
+
+// defaultMaxCacheBodySize 默认允许缓存的最大响应体大小（1MB）
+const defaultMaxCacheBodySize int64 = 1 << 20
+
 // CacheCallback 缓存回调函数类型
 type CacheCallback func(statusCode int, headers map[string][]string, body []byte)
 
+// Forward 转发请求到目标服务
+// 响应体以流式方式写回客户端；仅当设置了 cacheCallback 且响应体未超过缓存上限时，
+// 才会旁路收集响应体并在转发完成后回调
 func (r *Router) Forward(ctx kratosHttp.Context, route *Route, cacheCallback CacheCallback) error {
 	// 从 HTTP 请求中获取标准 context
 	requestCtx := ctx.Request().Context()
 
-	// 获取服务实例
-	instances, err := r.discovery.GetInstances(requestCtx, route.Service)
+	// 选择服务实例（流量拆分版本只选择一次，重试和对冲请求沿用）
+	version := r.splitVersion(ctx, route)
+	instance, done, err := r.selectInstance(ctx, route, version, nil)
 	if err != nil {
-		log.Error(ctx, "获取服务实例失败",
-			log.ErrorField(err),
-			log.String("service", route.Service),
-		)
-		return fmt.Errorf("服务不可用: %s", route.Service)
-	}
-
-	if len(instances) == 0 {
-		log.Error(ctx, "服务实例为空",
-			log.String("service", route.Service),
-		)
-		return fmt.Errorf("服务 %s 没有可用实例", route.Service)
+		return err
 	}
 
-	// 使用负载均衡选择实例
-	lb := r.loadBalancers[route.Service]
-	instance := lb.Select(instances)
-
-	if instance == nil {
-		return fmt.Errorf("无法选择服务实例: %s", route.Service)
-	}
-
-	// 构建目标URL
-	targetPath := route.TargetPath
-	if targetPath == "" {
-		// 如果未指定目标路径，使用原始路径
-		// 对于前缀匹配，需要保留完整路径；对于精确匹配，直接使用路径
-		if route.MatchType == "exact" {
-			targetPath = route.Path
-		} else {
-			// 前缀匹配：保留完整路径
-			targetPath = ctx.Request().URL.Path
-		}
-		// 确保路径以 / 开头
-		if !strings.HasPrefix(targetPath, "/") {
-			targetPath = "/" + targetPath
-		}
-	}
-
-	targetURL := fmt.Sprintf("http://%s:%d%s", instance.Host, instance.Port, targetPath)
-	if ctx.Request().URL.RawQuery != "" {
-		targetURL += "?" + ctx.Request().URL.RawQuery
-	}
-
-	log.Info(ctx, "转发请求",
-		log.String("from", ctx.Request().URL.Path),
-		log.String("to", targetURL),
-		log.String("service", route.Service),
-		log.String("instance", fmt.Sprintf("%s:%d", instance.Host, instance.Port)),
-	)
-
 	// 设置超时
+	// 上游请求的 context 派生自客户端请求，客户端断开连接时会同时取消上游请求；
+	// 总超时使用计时器实现，以便在识别出 SSE 事件流后切换为空闲超时
+	requestCtx, cancel := context.WithCancelCause(requestCtx)
+	defer cancel(nil)
 	timeout := time.Duration(route.Timeout) * time.Second
+	var totalTimer *time.Timer
 	if timeout > 0 {
-		var cancel context.CancelFunc
-		requestCtx, cancel = context.WithTimeout(requestCtx, timeout)
-		defer cancel()
+		totalTimer = time.AfterFunc(timeout, func() {
+			cancel(errUpstreamTimeout)
+		})
+		defer totalTimer.Stop()
 	}
 
-	// 创建代理请求
-	req, err := stdHttp.NewRequestWithContext(requestCtx, ctx.Request().Method, targetURL, ctx.Request().Body)
+	// 准备请求体：允许重试时缓冲请求体以便重放，超过缓冲上限的请求不重试
+	policy := r.routeRetryPolicy(route)
+	maxRetries := policy.MaxRetries
+	hedging := hedgingEnabled(route, ctx.Request())
+	requestBody, replayable, err := prepareRequestBody(ctx.Request(), policy, maxRetries > 0 || hedging)
 	if err != nil {
-		return fmt.Errorf("创建请求失败: %w", err)
+		done(loadbalancer.DoneInfo{})
+		return fmt.Errorf("读取请求体失败: %w", err)
+	}
+	if !replayable {
+		maxRetries = 0
+		hedging = false
 	}
 
-	// 复制请求头
-	for key, values := range ctx.Request().Header {
-		for _, value := range values {
-			req.Header.Add(key, value)
-		}
+	var templateValues map[string]string
+	if route.RequestHeaders != nil || route.ResponseHeaders != nil {
+		templateValues = headerTemplateValues(ctx.Request())
 	}
+	cbConfig := circuitBreakerConfig(route)
 
-	// 检查熔断器配置
-	var cbConfig *circuitbreaker.Config
-	if route.CircuitBreaker != nil && route.CircuitBreaker.Enabled {
-		cbConfig = &circuitbreaker.Config{
-			FailureThreshold: route.CircuitBreaker.FailureThreshold,
-			MinRequests:      route.CircuitBreaker.MinRequests,
-			WindowSize:       route.CircuitBreaker.WindowSize,
-			OpenDuration:     route.CircuitBreaker.OpenDuration,
-			HalfOpenRequests: route.CircuitBreaker.HalfOpenRequests,
-			Timeout:          route.CircuitBreaker.Timeout,
-		}
-		// 设置默认值
-		if cbConfig.FailureThreshold == 0 {
-			cbConfig.FailureThreshold = 0.5
-		}
-		if cbConfig.MinRequests == 0 {
-			cbConfig.MinRequests = 10
+	// 发送请求（每次重试选择未尝试过的实例，并受全局重试预算限制）
+	var resp *stdHttp.Response
+	var httpErr error
+	var targetURL string
+	tried := make(map[string]bool)
+	r.retryBudget.RecordRequest()
+	for attempt := 0; ; attempt++ {
+		tried[instance.Key()] = true
+		targetURL = r.buildTargetURL(ctx, route, instance)
+
+		log.Info(ctx, "转发请求",
+			log.String("from", ctx.Request().URL.Path),
+			log.String("to", targetURL),
+			log.String("service", route.Service),
+			log.String("instance", fmt.Sprintf("%s:%d", instance.Host, instance.Port)),
+			log.Int("attempt", attempt),
+		)
+
+		if hedging {
+			resp, httpErr = r.forwardHedged(ctx, requestCtx, route, version, instance, done, tried, requestBody, cbConfig, templateValues)
+		} else {
+			resp, httpErr = r.forwardAttempt(ctx, requestCtx, route, instance, done, targetURL, requestBody(), cbConfig, templateValues)
 		}
-		if cbConfig.WindowSize == 0 {
-			cbConfig.WindowSize = 60
+		if attempt >= maxRetries {
+			break
 		}
-		if cbConfig.OpenDuration == 0 {
-			cbConfig.OpenDuration = 30
+
+		reason, retryable := r.shouldRetry(requestCtx, policy, ctx.Request(), resp, httpErr)
+		if !retryable {
+			break
 		}
-		if cbConfig.HalfOpenRequests == 0 {
-			cbConfig.HalfOpenRequests = 3
+		if !r.retryBudget.TryRetry() {
+			log.Warn(ctx, "重试预算已耗尽，不再重试",
+				log.String("service", route.Service),
+				log.String("target", targetURL),
+			)
+			r.recordRetryBudgetExhausted(route.Service)
+			break
 		}
-		if cbConfig.Timeout == 0 {
-			cbConfig.Timeout = route.Timeout
-			if cbConfig.Timeout == 0 {
-				cbConfig.Timeout = 30
-			}
+
+		// 选择未尝试过的实例（没有其他实例时重试同一实例），选择失败时保留本次结果
+		next, nextDone, err := r.selectInstance(ctx, route, version, tried)
+		if err != nil {
+			break
 		}
-	}
 
-	// 发送请求（支持重试和熔断保护）
-	var resp *stdHttp.Response
-	var httpErr error
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, retryDrainLimit))
+			resp.Body.Close()
+			resp = nil
+		}
 
-	maxRetries := route.Retries
-	if maxRetries < 0 {
-		maxRetries = 0
+		backoff := policy.Backoff(attempt + 1)
+		log.Info(ctx, "重试请求",
+			log.Int("attempt", attempt+1),
+			log.Int("max_retries", maxRetries),
+			log.String("reason", reason),
+			log.String("target", targetURL),
+			log.Duration("backoff", backoff),
+		)
+		r.recordRetry(route.Service, reason)
+		if err := retry.Wait(requestCtx, backoff); err != nil {
+			nextDone(loadbalancer.DoneInfo{})
+			httpErr = err
+			break
+		}
+		instance, done = next, nextDone
 	}
 
-	// 执行请求的函数
-	executeRequest := func() error {
-		var attemptErr error
-		for attempt := 0; attempt <= maxRetries; attempt++ {
-			if attempt > 0 {
-				// 重试前等待（指数退避）
-				backoff := time.Duration(attempt) * 100 * time.Millisecond
-				if backoff > 1*time.Second {
-					backoff = 1 * time.Second
-				}
-				time.Sleep(backoff)
-
-				log.Info(ctx, "重试请求",
-					log.Int("attempt", attempt),
-					log.Int("max_retries", maxRetries),
-					log.String("target", targetURL),
-				)
-			}
-
-			// 发送请求
-			resp, attemptErr = r.httpClient.Do(req)
-			if attemptErr == nil {
-				// 请求成功，检查状态码
-				if resp.StatusCode < 500 {
-					// 4xx 错误不重试（客户端错误），但视为失败
-					if resp.StatusCode >= 400 {
-						return fmt.Errorf("client error: %d", resp.StatusCode)
-					}
-					// 2xx/3xx 成功
-					return nil
-				}
-				// 5xx 错误需要重试
-				if attempt < maxRetries {
-					resp.Body.Close()
-					resp = nil
-					continue
-				}
-				// 最后一次尝试仍然失败
-				return fmt.Errorf("server error: %d", resp.StatusCode)
-			} else {
-				// 网络错误，需要重试
-				if attempt < maxRetries {
-					// 检查是否是可重试的错误
-					if isRetryableError(attemptErr) {
-						continue
-					}
-					// 不可重试的错误，直接返回
-					return attemptErr
-				}
-				// 最后一次尝试仍然失败
-				return attemptErr
-			}
-		}
-		return attemptErr
-	}
-
-	// 使用熔断器执行请求（如果启用）
-	if cbConfig != nil {
-		httpErr = r.circuitBreakers.Execute(requestCtx, route.Service, cbConfig, executeRequest)
-		if httpErr != nil {
-			// 检查是否是熔断器打开错误
-			if circuitbreaker.IsCircuitBreakerError(httpErr) {
-				log.Warn(ctx, "熔断器已打开，拒绝请求",
-					log.String("service", route.Service),
-					log.String("target", targetURL),
-				)
-				return fmt.Errorf("服务暂时不可用（熔断器已打开）")
-			}
-			// 其他错误
-			log.Error(ctx, "转发请求失败",
-				log.ErrorField(httpErr),
-				log.String("target", targetURL),
-				log.Int("retries", maxRetries),
-			)
-			return fmt.Errorf("转发请求失败: %w", httpErr)
+	if httpErr != nil {
+		// 超时取消时返回明确的超时错误（而不是 context canceled）
+		if cause := context.Cause(requestCtx); errors.Is(cause, context.DeadlineExceeded) {
+			httpErr = cause
 		}
-	} else {
-		// 不使用熔断器，直接执行
-		httpErr = executeRequest()
-		if httpErr != nil {
-			log.Error(ctx, "转发请求失败",
-				log.ErrorField(httpErr),
+		// 检查是否是熔断器打开错误
+		if circuitbreaker.IsCircuitBreakerError(httpErr) {
+			log.Warn(ctx, "熔断器已打开，拒绝请求",
+				log.String("service", route.Service),
 				log.String("target", targetURL),
-				log.Int("retries", maxRetries),
 			)
-			return fmt.Errorf("转发请求失败: %w", httpErr)
+			return fmt.Errorf("服务暂时不可用（熔断器已打开）")
 		}
+		log.Error(ctx, "转发请求失败",
+			log.ErrorField(httpErr),
+			log.String("target", targetURL),
+			log.Int("retries", maxRetries),
+		)
+		return fmt.Errorf("转发请求失败: %w", httpErr)
 	}
 
 	if resp == nil {
@@ -391,63 +501,158 @@ func (r *Router) Forward(ctx kratosHttp.Context, route *Route, cacheCallback Cac
 
 	defer resp.Body.Close()
 
-	// 读取响应体（用于缓存）
-	responseBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("读取响应体失败: %w", err)
-	}
-
-	// 复制响应头
-	responseHeaders := make(map[string][]string)
-	for key, values := range resp.Header {
-		responseHeaders[key] = values
-		for _, value := range values {
-			ctx.Response().Header().Set(key, value)
+	// SSE 事件流：改用空闲超时，禁用缓存和中间代理缓冲
+	var body io.Reader = resp.Body
+	eventStream := IsEventStream(resp.Header)
+	if eventStream {
+		if totalTimer != nil {
+			totalTimer.Stop()
+		}
+		idleReader := newIdleTimeoutReader(resp.Body, streamIdleTimeout(route), func() {
+			cancel(errStreamIdleTimeout)
+		})
+		defer idleReader.Stop()
+		body = idleReader
+	}
+
+	// 复制响应头（去除逐跳请求头）并按路由配置转换
+	responseHeaders := resp.Header.Clone()
+	removeHopByHopHeaders(responseHeaders)
+	applyHeaderTransform(responseHeaders, route.ResponseHeaders, templateValues)
+	for key, values := range responseHeaders {
+		if key == "Set-Cookie" {
+			// 保留网关设置的 Cookie（如流量拆分粘性分配）
+			ctx.Response().Header()[key] = append(ctx.Response().Header()[key], values...)
+			continue
 		}
+		ctx.Response().Header()[key] = values
+	}
+	if eventStream {
+		ctx.Response().Header().Del("Content-Length")
+		ctx.Response().Header().Set("Cache-Control", "no-cache")
+		ctx.Response().Header().Set("X-Accel-Buffering", "no")
 	}
 
 	// 设置状态码
 	ctx.Response().WriteHeader(resp.StatusCode)
 
-	// 写入响应体
-	_, err = ctx.Response().Write(responseBody)
+	// 仅在需要缓存、不是事件流且响应体未超过上限时收集响应体
+	var cacheBuffer *LimitedBuffer
+	if cacheCallback != nil && !eventStream {
+		limit := cacheBodyLimit(route)
+		if resp.ContentLength <= limit {
+			cacheBuffer = NewLimitedBuffer(limit)
+		}
+	}
+
+	// 流式写入响应体（边读边写，不在网关内存中缓冲整个响应）
+	var tee io.Writer
+	if cacheBuffer != nil {
+		tee = cacheBuffer
+	}
+	written, err := StreamCopy(ctx.Response(), body, tee)
 	if err != nil {
-		return fmt.Errorf("写入响应失败: %w", err)
+		// 响应头已经写出，无法再返回错误响应，只记录日志
+		if clientErr := ctx.Request().Context().Err(); clientErr != nil {
+			log.Info(ctx, "客户端已断开连接，已取消上游请求",
+				log.String("target", targetURL),
+				log.Int64("written", written),
+			)
+			return nil
+		}
+		log.Warn(ctx, "流式转发响应体中断",
+			log.ErrorField(err),
+			log.String("target", targetURL),
+			log.Int64("written", written),
+			log.Bool("event_stream", eventStream),
+		)
+		return nil
 	}
 
-	// 如果提供了缓存回调，调用它
-	if cacheCallback != nil {
-		cacheCallback(resp.StatusCode, responseHeaders, responseBody)
+	// 如果提供了缓存回调且响应体未超过上限，调用它
+	if cacheBuffer != nil && !cacheBuffer.Overflow() {
+		cacheCallback(resp.StatusCode, responseHeaders, cacheBuffer.Bytes())
 	}
 
 	return nil
 }
 
-// isRetryableError 判断错误是否可重试
-func isRetryableError(err error) bool {
-	if err == nil {
-		return false
+// cacheBodyLimit 获取路由允许缓存的最大响应体大小
+func cacheBodyLimit(route *Route) int64 {
+	if route.Cache != nil && route.Cache.MaxBodySize > 0 {
+		return route.Cache.MaxBodySize
+	}
+	return defaultMaxCacheBodySize
+}
+
+// selectInstance 获取服务实例并使用负载均衡选择一个实例，返回的 done 回调必须在请求完成时调用
+// version 不为空时只选择流量拆分目标版本的实例；
+// exclude 不为空时优先从未排除的实例中选择（用于重试时选择其他实例），没有其他实例时不排除
+func (r *Router) selectInstance(ctx kratosHttp.Context, route *Route, version string, exclude map[string]bool) (*discovery.Instance, loadbalancer.DoneFunc, error) {
+	instances, err := r.discovery.GetInstances(ctx.Request().Context(), route.Service)
+	if err != nil {
+		log.Error(ctx, "获取服务实例失败",
+			log.ErrorField(err),
+			log.String("service", route.Service),
+		)
+		return nil, nil, fmt.Errorf("服务不可用: %s", route.Service)
+	}
+
+	if len(instances) == 0 {
+		log.Error(ctx, "服务实例为空",
+			log.String("service", route.Service),
+		)
+		return nil, nil, fmt.Errorf("服务 %s 没有可用实例", route.Service)
+	}
+
+	// 排除被驱逐的异常实例和熔断器已打开的实例
+	instances, err = r.filterInstances(ctx, route, instances)
+	if err != nil {
+		log.Warn(ctx, "服务没有可转发的实例（实例均已摘除或熔断器均已打开），拒绝请求",
+			log.String("service", route.Service),
+			log.ErrorField(err),
+		)
+		return nil, nil, err
 	}
 
-	errStr := err.Error()
-	// 网络超时、连接错误等可重试
-	retryableErrors := []string{
-		"timeout",
-		"deadline exceeded",
-		"connection refused",
-		"connection reset",
-		"no such host",
-		"network is unreachable",
-		"temporary failure",
+	// 按流量拆分规则筛选目标版本的实例
+	if version != "" {
+		instances = r.splitInstances(ctx, route, version, instances, exclude == nil)
 	}
 
-	for _, retryableErr := range retryableErrors {
-		if strings.Contains(strings.ToLower(errStr), retryableErr) {
-			return true
+	// 排除已尝试过的实例（只保留健康实例中未尝试过的实例）
+	if len(exclude) > 0 {
+		remaining := make([]discovery.Instance, 0, len(instances))
+		for i := range instances {
+			if instances[i].Healthy && !exclude[instances[i].Key()] {
+				remaining = append(remaining, instances[i])
+			}
 		}
+		if len(remaining) > 0 {
+			instances = remaining
+		}
+	}
+
+	// 使用负载均衡选择实例
+	r.mu.RLock()
+	lb := r.loadBalancers[route.Service]
+	r.mu.RUnlock()
+	selectCtx := ctx.Request().Context()
+	if key := r.hashKey(ctx, route); key != "" {
+		selectCtx = loadbalancer.WithHashKey(selectCtx, key)
+	}
+	instance, done := lb.Select(selectCtx, instances)
+
+	if instance == nil {
+		return nil, nil, fmt.Errorf("无法选择服务实例: %s", route.Service)
 	}
 
-	return false
+	return instance, done, nil
+}
+
+// buildTargetPath 构建转发到目标服务的路径
+func (r *Router) buildTargetPath(ctx kratosHttp.Context, route *Route) string {
+	return rewritePath(ctx.Request().URL.Path, route)
 }
 
 // regexCache 正则表达式缓存（避免重复编译）
@@ -458,36 +663,50 @@ var (
 
 // matchRegex 正则匹配
 func (r *Router) matchRegex(path, pattern string) bool {
-	// 从缓存获取或编译正则表达式
+	re, err := compileRegex(pattern)
+	if err != nil {
+		// 正则表达式编译失败，记录日志并返回false
+		log.Warn(context.Background(), "正则表达式编译失败",
+			log.String("pattern", pattern),
+			log.ErrorField(err),
+		)
+		return false
+	}
+
+	return re.MatchString(path)
+}
+
+// compileRegex 从缓存获取或编译正则表达式
+func compileRegex(pattern string) (*regexp.Regexp, error) {
 	regexMu.RLock()
 	re, exists := regexCache[pattern]
 	regexMu.RUnlock()
 
-	if !exists {
-		// 编译正则表达式
-		var err error
-		re, err = regexp.Compile(pattern)
-		if err != nil {
-			// 正则表达式编译失败，记录日志并返回false
-			log.Warn(context.Background(), "正则表达式编译失败",
-				log.String("pattern", pattern),
-				log.ErrorField(err),
-			)
-			return false
-		}
+	if exists {
+		return re, nil
+	}
 
-		// 存入缓存
-		regexMu.Lock()
-		regexCache[pattern] = re
-		regexMu.Unlock()
+	// 编译正则表达式
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
 	}
 
-	return re.MatchString(path)
+	// 存入缓存
+	regexMu.Lock()
+	regexCache[pattern] = re
+	regexMu.Unlock()
+
+	return re, nil
 }
 
-// GetServiceInstances 获取服务实例（用于健康检查）
+// GetServiceInstances 获取服务实例（用于健康检查，健康状态包含主动健康检查结果）
 func (r *Router) GetServiceInstances(ctx context.Context, serviceName string) ([]discovery.Instance, error) {
-	return r.discovery.GetInstances(ctx, serviceName)
+	instances, err := r.discovery.GetInstances(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return r.applyHealthChecks(serviceName, instances), nil
 }
 
 // GetAllServiceNames 获取所有已注册的服务名称
@@ -513,6 +732,16 @@ func (r *Router) UpdateServiceInstances(serviceName string, instances []discover
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	instanceIDs := make([]string, 0, len(instances))
+	for i := range instances {
+		instanceIDs = append(instanceIDs, instances[i].Key())
+	}
+	// 已下线实例的熔断器和异常检测统计不再保留
+	r.circuitBreakers.Retain(serviceName, instanceIDs)
+	if detector, exists := r.outlierDetectors[serviceName]; exists {
+		detector.Retain(instanceIDs)
+	}
+
 	if lb, exists := r.loadBalancers[serviceName]; exists {
 		lb.UpdateInstances(instances)
 		log.Info(context.Background(), "服务实例已更新",
@@ -526,3 +755,17 @@ func (r *Router) UpdateServiceInstances(serviceName string, instances []discover
 func (r *Router) GetCircuitBreakerStats() map[string]map[string]interface{} {
 	return r.circuitBreakers.GetBreakerStats()
 }
+
+// ForceCircuitBreaker 强制打开或关闭熔断器（key 为 服务名/实例标识）
+func (r *Router) ForceCircuitBreaker(key string, open bool) error {
+	state := circuitbreaker.StateClosed
+	if open {
+		state = circuitbreaker.StateOpen
+	}
+	return r.circuitBreakers.Force(key, state)
+}
+
+// ResetCircuitBreaker 取消熔断器的强制状态并重置统计（key 为 服务名/实例标识）
+func (r *Router) ResetCircuitBreaker(key string) error {
+	return r.circuitBreakers.Reset(key)
+}
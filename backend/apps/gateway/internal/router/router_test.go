@@ -1,10 +1,32 @@
 package router
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	stdHttp "net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"StructForge/backend/apps/gateway/internal/conf"
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
+	"StructForge/backend/apps/gateway/internal/middleware/circuitbreaker"
 	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/apps/gateway/internal/router/healthcheck"
 	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
+	"StructForge/backend/common/middleware/clientip"
+	"StructForge/backend/common/middleware/identity"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
 )
 
 // TestRouteMatchExact 测试精确匹配
@@ -19,7 +41,7 @@ func TestRouteMatchExact(t *testing.T) {
 	router.AddRoute(route)
 
 	// 测试精确匹配
-	found := router.FindRoute("/api/v1/users")
+	found := router.FindRoute(httptest.NewRequest("GET", "/api/v1/users", nil))
 	if found == nil {
 		t.Fatal("应该找到路由")
 	}
@@ -28,7 +50,7 @@ func TestRouteMatchExact(t *testing.T) {
 	}
 
 	// 测试不匹配
-	notFound := router.FindRoute("/api/v1/users/123")
+	notFound := router.FindRoute(httptest.NewRequest("GET", "/api/v1/users/123", nil))
 	if notFound != nil {
 		t.Error("不应该找到路由")
 	}
@@ -58,7 +80,7 @@ func TestRouteMatchPrefix(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		found := router.FindRoute(tc.path)
+		found := router.FindRoute(httptest.NewRequest("GET", tc.path, nil))
 		if tc.expected && found == nil {
 			t.Errorf("路径 %s 应该匹配", tc.path)
 		}
@@ -91,7 +113,7 @@ func TestRouteMatchRegex(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		found := router.FindRoute(tc.path)
+		found := router.FindRoute(httptest.NewRequest("GET", tc.path, nil))
 		if tc.expected && found == nil {
 			t.Errorf("路径 %s 应该匹配正则", tc.path)
 		}
@@ -123,13 +145,13 @@ func TestRoutePriority(t *testing.T) {
 	router.AddRoute(route2)
 
 	// 精确匹配应该优先
-	found := router.FindRoute("/api/v1/users/me")
+	found := router.FindRoute(httptest.NewRequest("GET", "/api/v1/users/me", nil))
 	if found == nil || found.Service != "user-service" {
 		t.Error("应该匹配到更具体的路由")
 	}
 
 	// 前缀匹配应该匹配到第二个路由
-	found2 := router.FindRoute("/api/v1/users/123")
+	found2 := router.FindRoute(httptest.NewRequest("GET", "/api/v1/users/123", nil))
 	if found2 == nil || found2.Service != "user-service-v2" {
 		t.Error("应该匹配到前缀路由")
 	}
@@ -149,7 +171,7 @@ func TestLoadBalancer(t *testing.T) {
 
 	selected := make(map[string]int)
 	for i := 0; i < 30; i++ {
-		instance := lb.Select(instances)
+		instance, _ := lb.Select(context.Background(), instances)
 		if instance == nil {
 			t.Fatal("应该选择到实例")
 		}
@@ -180,7 +202,7 @@ func TestLoadBalancerWeight(t *testing.T) {
 
 	selected := make(map[string]int)
 	for i := 0; i < 400; i++ {
-		instance := lb.Select(instances)
+		instance, _ := lb.Select(context.Background(), instances)
 		if instance == nil {
 			t.Fatal("应该选择到实例")
 		}
@@ -213,7 +235,7 @@ func TestLoadBalancerUnhealthy(t *testing.T) {
 	}
 
 	for i := 0; i < 20; i++ {
-		instance := lb.Select(healthyInstances)
+		instance, _ := lb.Select(context.Background(), healthyInstances)
 		if instance == nil {
 			t.Fatal("应该选择到实例")
 		}
@@ -222,3 +244,1308 @@ func TestLoadBalancerUnhealthy(t *testing.T) {
 		}
 	}
 }
+
+// TestLoadBalancerLeastConnections 测试最少连接负载均衡器在请求完成后释放连接数
+func TestLoadBalancerLeastConnections(t *testing.T) {
+	instances := []discovery.Instance{
+		{ID: "1", Host: "localhost", Port: 8001, Weight: 100, Healthy: true},
+		{ID: "2", Host: "localhost", Port: 8002, Weight: 100, Healthy: true},
+	}
+
+	lb := loadbalancer.NewLoadBalancer("least_connections")
+	first, firstDone := lb.Select(context.Background(), instances)
+	second, _ := lb.Select(context.Background(), instances)
+	if first.ID == second.ID {
+		t.Fatalf("两个在途请求应该分配到不同实例: %s", first.ID)
+	}
+
+	// 第一个请求完成后，下一个请求应该选择连接数更少的第一个实例
+	firstDone(loadbalancer.DoneInfo{Latency: time.Millisecond})
+	firstDone(loadbalancer.DoneInfo{Latency: time.Millisecond})
+	for i := 0; i < 3; i++ {
+		next, done := lb.Select(context.Background(), instances)
+		if next.ID != first.ID {
+			t.Errorf("应该选择连接数更少的实例 %s，实际 %s", first.ID, next.ID)
+		}
+		done(loadbalancer.DoneInfo{Latency: time.Millisecond})
+	}
+}
+
+// TestLoadBalancerConsistentHash 测试一致性哈希负载均衡器
+func TestLoadBalancerConsistentHash(t *testing.T) {
+	instances := []discovery.Instance{
+		{ID: "1", Host: "localhost", Port: 8001, Weight: 1, Healthy: true},
+		{ID: "2", Host: "localhost", Port: 8002, Weight: 1, Healthy: true},
+		{ID: "3", Host: "localhost", Port: 8003, Weight: 1, Healthy: true},
+	}
+
+	lb := loadbalancer.NewLoadBalancer("consistent_hash")
+	lb.UpdateInstances(instances)
+
+	assigned := make(map[string]string)
+	used := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("session-%d", i)
+		ctx := loadbalancer.WithHashKey(context.Background(), key)
+		instance, _ := lb.Select(ctx, instances)
+		again, _ := lb.Select(ctx, instances)
+		if instance.ID != again.ID {
+			t.Fatalf("相同哈希键应该选择相同实例: %s, %s", instance.ID, again.ID)
+		}
+		assigned[key] = instance.ID
+		used[instance.ID] = true
+	}
+	if len(used) != 3 {
+		t.Errorf("哈希键应该分布到所有实例: %v", used)
+	}
+
+	// 候选实例是子集时（重试排除、实例不健康）其他哈希键的分配不变
+	for key, id := range assigned {
+		ctx := loadbalancer.WithHashKey(context.Background(), key)
+		for _, excluded := range instances {
+			if excluded.ID == id {
+				continue
+			}
+			candidates := slices.DeleteFunc(slices.Clone(instances), func(instance discovery.Instance) bool {
+				return instance.ID == excluded.ID
+			})
+			if instance, _ := lb.Select(ctx, candidates); instance.ID != id {
+				t.Errorf("排除实例 %s 后哈希键 %s 不应该迁移: %s -> %s", excluded.ID, key, id, instance.ID)
+			}
+
+			unhealthy := slices.Clone(instances)
+			for i := range unhealthy {
+				unhealthy[i].Healthy = unhealthy[i].ID != excluded.ID
+			}
+			if instance, _ := lb.Select(ctx, unhealthy); instance.ID != id {
+				t.Errorf("实例 %s 不健康时哈希键 %s 不应该迁移: %s -> %s", excluded.ID, key, id, instance.ID)
+			}
+		}
+	}
+
+	// 实例下线后只有分配到该实例的哈希键迁移
+	remaining := instances[:2]
+	lb.UpdateInstances(remaining)
+	for key, id := range assigned {
+		instance, _ := lb.Select(loadbalancer.WithHashKey(context.Background(), key), remaining)
+		if id != "3" && instance.ID != id {
+			t.Errorf("哈希键 %s 不应该迁移: %s -> %s", key, id, instance.ID)
+		}
+		if instance.ID == "3" {
+			t.Errorf("不应该选择已下线的实例")
+		}
+	}
+}
+
+// TestLoadBalancerP2C 测试两次随机选择负载均衡器优先选择延迟低的实例
+func TestLoadBalancerP2C(t *testing.T) {
+	instances := []discovery.Instance{
+		{ID: "fast", Host: "localhost", Port: 8001, Weight: 1, Healthy: true},
+		{ID: "slow", Host: "localhost", Port: 8002, Weight: 1, Healthy: true},
+	}
+
+	lb := loadbalancer.NewLoadBalancer("p2c")
+	latencies := map[string]time.Duration{"fast": 5 * time.Millisecond, "slow": 200 * time.Millisecond}
+
+	// 预热：两个实例都获得延迟样本
+	for _, id := range []string{"fast", "slow"} {
+		for {
+			instance, done := lb.Select(context.Background(), instances)
+			done(loadbalancer.DoneInfo{Latency: latencies[instance.ID]})
+			if instance.ID == id {
+				break
+			}
+		}
+	}
+
+	selected := make(map[string]int)
+	for i := 0; i < 100; i++ {
+		instance, done := lb.Select(context.Background(), instances)
+		selected[instance.ID]++
+		done(loadbalancer.DoneInfo{Latency: latencies[instance.ID]})
+	}
+	if selected["fast"] <= selected["slow"] {
+		t.Errorf("延迟低的实例应该被选择更多次: %v", selected)
+	}
+}
+
+// TestStreamCopyWithLimitedBuffer 测试流式复制与缓存缓冲区上限
+func TestStreamCopyWithLimitedBuffer(t *testing.T) {
+	body := strings.Repeat("a", 100)
+
+	// 未超过上限：完整收集响应体
+	rec := httptest.NewRecorder()
+	buf := NewLimitedBuffer(1024)
+	written, err := StreamCopy(rec, strings.NewReader(body), buf)
+	if err != nil {
+		t.Fatalf("流式复制失败: %v", err)
+	}
+	if written != int64(len(body)) || rec.Body.String() != body {
+		t.Errorf("客户端应该收到完整响应体，实际写入 %d", written)
+	}
+	if buf.Overflow() || string(buf.Bytes()) != body {
+		t.Error("未超过上限时应该收集完整响应体")
+	}
+	if !rec.Flushed {
+		t.Error("流式复制应该刷新响应")
+	}
+
+	// 超过上限：仍然完整转发，但不收集
+	rec = httptest.NewRecorder()
+	buf = NewLimitedBuffer(10)
+	if _, err := StreamCopy(rec, strings.NewReader(body), buf); err != nil {
+		t.Fatalf("流式复制失败: %v", err)
+	}
+	if rec.Body.String() != body {
+		t.Error("超过缓存上限时客户端仍应收到完整响应体")
+	}
+	if !buf.Overflow() || buf.Bytes() != nil {
+		t.Error("超过上限时不应收集响应体")
+	}
+}
+
+// TestEventStreamDetection 测试 SSE 事件流识别
+func TestEventStreamDetection(t *testing.T) {
+	header := stdHttp.Header{}
+	header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	if !IsEventStream(header) {
+		t.Error("应该识别为事件流")
+	}
+	header.Set("Content-Type", "application/json")
+	if IsEventStream(header) {
+		t.Error("JSON 响应不应该识别为事件流")
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/ai/stream", nil)
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if !AcceptsEventStream(req) {
+		t.Error("应该识别为事件流请求")
+	}
+}
+
+// TestIdleTimeoutReader 测试空闲超时读取器
+func TestIdleTimeoutReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	timedOut := make(chan struct{})
+	reader := newIdleTimeoutReader(pr, 50*time.Millisecond, func() {
+		close(timedOut)
+		pw.CloseWithError(errStreamIdleTimeout)
+	})
+	defer reader.Stop()
+
+	// 持续收到数据时不应超时
+	go func() {
+		for i := 0; i < 3; i++ {
+			pw.Write([]byte("data: token\n\n"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	buf := make([]byte, 64)
+	for i := 0; i < 3; i++ {
+		if _, err := reader.Read(buf); err != nil {
+			t.Fatalf("读取失败: %v", err)
+		}
+	}
+
+	// 停止发送数据后应该触发空闲超时
+	select {
+	case <-timedOut:
+	case <-time.After(time.Second):
+		t.Fatal("应该触发空闲超时")
+	}
+	if _, err := reader.Read(buf); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("超时后读取应该返回超时错误，实际 %v", err)
+	}
+}
+
+// TestForwardEventStream 测试 SSE 事件流转发：持续收到数据时不受路由超时限制、空闲超时后断开、不缓存、客户端断开时取消上游请求
+func TestForwardEventStream(t *testing.T) {
+	// 上游每 200 毫秒发送一个事件，events 为 0 时持续发送；hang 不为空时发送完后保持连接不再发送数据
+	upstreamCanceled := make(chan struct{}, 3)
+	upstream := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+		events, _ := strconv.Atoi(req.URL.Query().Get("events"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(stdHttp.StatusOK)
+		w.(stdHttp.Flusher).Flush()
+		for i := 0; events == 0 || i < events; i++ {
+			select {
+			case <-req.Context().Done():
+				upstreamCanceled <- struct{}{}
+				return
+			case <-time.After(200 * time.Millisecond):
+			}
+			fmt.Fprintf(w, "data: %d\n\n", i)
+			w.(stdHttp.Flusher).Flush()
+		}
+		if req.URL.Query().Get("hang") != "" {
+			<-req.Context().Done()
+			upstreamCanceled <- struct{}{}
+		}
+	}))
+	defer upstream.Close()
+
+	staticDiscovery := discovery.NewStaticDiscovery()
+	staticDiscovery.RegisterService("ai-service", []discovery.Instance{
+		{ID: "ai-1", Host: "127.0.0.1", Port: upstream.Listener.Addr().(*net.TCPAddr).Port, Healthy: true},
+	})
+	router := NewRouter(staticDiscovery)
+	route := &Route{
+		Path:        "/api/v1/ai",
+		MatchType:   "prefix",
+		Service:     "ai-service",
+		Timeout:     1,
+		IdleTimeout: 1,
+	}
+	router.AddRoute(route)
+
+	var cached atomic.Bool
+	cacheCallback := func(statusCode int, headers map[string][]string, body []byte) {
+		cached.Store(true)
+	}
+	forward := func(req *stdHttp.Request) (*httptest.ResponseRecorder, time.Duration) {
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		if err := router.Forward(&testContext{req: req, w: rec}, route, cacheCallback); err != nil {
+			t.Fatalf("转发失败: %v", err)
+		}
+		return rec, time.Since(start)
+	}
+	waitCanceled := func() bool {
+		select {
+		case <-upstreamCanceled:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	t.Run("持续收到数据时超过路由超时", func(t *testing.T) {
+		rec, elapsed := forward(httptest.NewRequest(stdHttp.MethodGet, "/api/v1/ai/stream?events=8", nil))
+		if !strings.Contains(rec.Body.String(), "data: 7\n\n") {
+			t.Errorf("事件流应该完整转发，实际 %q", rec.Body.String())
+		}
+		if elapsed < time.Duration(route.Timeout)*time.Second {
+			t.Errorf("事件流应该持续超过路由超时，实际 %v", elapsed)
+		}
+		if rec.Header().Get("Cache-Control") != "no-cache" || rec.Header().Get("X-Accel-Buffering") != "no" {
+			t.Errorf("事件流应该禁用缓存和代理缓冲: %v", rec.Header())
+		}
+		if cached.Load() {
+			t.Error("事件流不应该被缓存")
+		}
+	})
+
+	t.Run("空闲超时后断开", func(t *testing.T) {
+		rec, elapsed := forward(httptest.NewRequest(stdHttp.MethodGet, "/api/v1/ai/stream?events=2&hang=1", nil))
+		if !strings.Contains(rec.Body.String(), "data: 1\n\n") {
+			t.Errorf("空闲前的事件应该转发，实际 %q", rec.Body.String())
+		}
+		if elapsed > 3*time.Second {
+			t.Errorf("空闲超时后应该断开事件流，实际 %v", elapsed)
+		}
+		if !waitCanceled() {
+			t.Error("空闲超时后应该取消上游请求")
+		}
+		if cached.Load() {
+			t.Error("事件流不应该被缓存")
+		}
+	})
+
+	t.Run("客户端断开时取消上游请求", func(t *testing.T) {
+		clientCtx, disconnect := context.WithCancel(context.Background())
+		time.AfterFunc(500*time.Millisecond, disconnect)
+		rec, elapsed := forward(httptest.NewRequest(stdHttp.MethodGet, "/api/v1/ai/stream", nil).WithContext(clientCtx))
+		if !strings.Contains(rec.Body.String(), "data: 0\n\n") {
+			t.Errorf("断开前的事件应该转发，实际 %q", rec.Body.String())
+		}
+		if elapsed > 2*time.Second {
+			t.Errorf("客户端断开后应该结束转发，实际 %v", elapsed)
+		}
+		if !waitCanceled() {
+			t.Error("客户端断开后应该取消上游请求")
+		}
+	})
+}
+
+// TestIsWebSocketUpgrade 测试 WebSocket 升级请求识别
+func TestIsWebSocketUpgrade(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/v1/workflows/1/status", nil)
+	req.Header.Set("Connection", "keep-alive, Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if !IsWebSocketUpgrade(req) {
+		t.Error("应该识别为 WebSocket 升级请求")
+	}
+
+	req.Header.Set("Connection", "keep-alive")
+	if IsWebSocketUpgrade(req) {
+		t.Error("缺少 Connection: Upgrade 时不应该识别为升级请求")
+	}
+}
+
+// TestForwardWebSocket 测试 WebSocket 握手转发、双向隧道和连接关闭
+func TestForwardWebSocket(t *testing.T) {
+	handshakes := make(chan stdHttp.Header, 4)
+	upstreamClosed := make(chan struct{}, 4)
+	upstream := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+		if req.URL.Path == "/ws/reject" {
+			w.Header().Set("Keep-Alive", "timeout=5")
+			stdHttp.Error(w, "forbidden", stdHttp.StatusForbidden)
+			return
+		}
+		handshakes <- req.Header.Clone()
+
+		conn, buf, err := stdHttp.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer func() {
+			conn.Close()
+			upstreamClosed <- struct{}{}
+		}()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Keep-Alive: timeout=5\r\nSec-WebSocket-Accept: accept\r\n\r\n")
+		buf.Flush()
+
+		// /ws/close：发送数据后主动关闭；其他路径：回显数据直到客户端关闭
+		if req.URL.Path == "/ws/close" {
+			conn.Write([]byte("bye"))
+			return
+		}
+		io.Copy(conn, buf.Reader)
+	}))
+	defer upstream.Close()
+
+	staticDiscovery := discovery.NewStaticDiscovery()
+	staticDiscovery.RegisterService("chat-service", []discovery.Instance{
+		{ID: "1", Host: "127.0.0.1", Port: upstream.Listener.Addr().(*net.TCPAddr).Port, Healthy: true},
+	})
+	router := NewRouter(staticDiscovery)
+	route := &Route{Path: "/ws", MatchType: "prefix", Service: "chat-service", WebSocket: true}
+	router.AddRoute(route)
+
+	forwarded := make(chan bool, 4)
+	gateway := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+		opened := false
+		err := router.ForwardWebSocket(&testContext{req: req, w: w}, route, func() { opened = true })
+		if err != nil {
+			stdHttp.Error(w, err.Error(), stdHttp.StatusBadGateway)
+		}
+		forwarded <- opened
+	}))
+	defer gateway.Close()
+
+	// dial 发送握手请求（携带需要删除的逐跳请求头）并读取握手响应
+	dial := func(path string) (net.Conn, *bufio.Reader, *stdHttp.Response) {
+		conn, err := net.Dial("tcp", gateway.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("连接网关失败: %v", err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		req, _ := stdHttp.NewRequest(stdHttp.MethodGet, gateway.URL+path, nil)
+		req.Header.Set("Connection", "Upgrade, X-Hop")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Key", "key")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Keep-Alive", "timeout=5")
+		req.Header.Set("Proxy-Authorization", "Basic secret")
+		req.Header.Set("X-Hop", "1")
+		if err := req.Write(conn); err != nil {
+			t.Fatalf("发送握手请求失败: %v", err)
+		}
+		reader := bufio.NewReader(conn)
+		resp, err := stdHttp.ReadResponse(reader, req)
+		if err != nil {
+			t.Fatalf("读取握手响应失败: %v", err)
+		}
+		return conn, reader, resp
+	}
+	waitClosed := func(ch <-chan struct{}, name string) {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s 应该被关闭", name)
+		}
+	}
+
+	// 握手：转发 WebSocket 请求头，删除其他逐跳请求头
+	conn, reader, resp := dial("/ws/echo")
+	if resp.StatusCode != stdHttp.StatusSwitchingProtocols {
+		t.Fatalf("应该返回 101，实际 %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Upgrade") != "websocket" || resp.Header.Get("Sec-WebSocket-Accept") != "accept" || resp.Header.Get("Keep-Alive") != "" {
+		t.Errorf("握手响应头不正确: %v", resp.Header)
+	}
+	handshake := <-handshakes
+	if handshake.Get("Upgrade") != "websocket" || handshake.Get("Connection") != "Upgrade" ||
+		handshake.Get("Sec-WebSocket-Key") != "key" || handshake.Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("WebSocket 握手请求头应该被转发: %v", handshake)
+	}
+	for _, name := range []string{"Keep-Alive", "Proxy-Authorization", "X-Hop"} {
+		if handshake.Get(name) != "" {
+			t.Errorf("逐跳请求头 %s 不应该被转发", name)
+		}
+	}
+	if handshake.Get("X-Forwarded-For") == "" {
+		t.Error("应该设置 X-Forwarded-For")
+	}
+
+	// 双向转发数据
+	for _, message := range []string{"hello", "world"} {
+		if _, err := conn.Write([]byte(message)); err != nil {
+			t.Fatalf("发送数据失败: %v", err)
+		}
+		echo := make([]byte, len(message))
+		if _, err := io.ReadFull(reader, echo); err != nil || string(echo) != message {
+			t.Fatalf("应该收到回显 %q，实际 %q (%v)", message, echo, err)
+		}
+	}
+
+	// 客户端关闭后上游连接也被关闭，隧道结束
+	conn.Close()
+	waitClosed(upstreamClosed, "上游连接")
+	if opened := <-forwarded; !opened {
+		t.Error("隧道建立后应该调用 onOpen")
+	}
+
+	// 上游关闭后客户端连接也被关闭
+	conn, reader, resp = dial("/ws/close")
+	defer conn.Close()
+	if resp.StatusCode != stdHttp.StatusSwitchingProtocols {
+		t.Fatalf("应该返回 101，实际 %d", resp.StatusCode)
+	}
+	<-handshakes
+	data, err := io.ReadAll(reader)
+	if err != nil || string(data) != "bye" {
+		t.Errorf("应该收到上游数据后连接关闭，实际 %q (%v)", data, err)
+	}
+	<-forwarded
+
+	// 上游拒绝升级：按普通响应写回，不接管客户端连接
+	conn, reader, resp = dial("/ws/reject")
+	defer conn.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != stdHttp.StatusForbidden || strings.TrimSpace(string(body)) != "forbidden" {
+		t.Errorf("应该写回上游的拒绝响应，实际 %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("Keep-Alive") != "" {
+		t.Error("拒绝响应中的逐跳响应头不应该被转发")
+	}
+	if opened := <-forwarded; opened {
+		t.Error("上游拒绝升级时不应该建立隧道")
+	}
+
+	// 上游不可用：返回错误由调用方写回错误响应
+	upstream.Close()
+	conn, _, resp = dial("/ws/echo")
+	defer conn.Close()
+	if resp.StatusCode != stdHttp.StatusBadGateway {
+		t.Errorf("上游不可用时应该返回错误响应，实际 %d", resp.StatusCode)
+	}
+	<-forwarded
+}
+
+// TestRewritePath 测试路径重写
+func TestRewritePath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		route    *Route
+		path     string
+		expected string
+	}{
+		{"原始路径", &Route{Path: "/api/v1/users", MatchType: "prefix"}, "/api/v1/users/1", "/api/v1/users/1"},
+		{"目标路径", &Route{Path: "/api/v1/me", MatchType: "exact", TargetPath: "/api/v1/users/me"}, "/api/v1/me", "/api/v1/users/me"},
+		{"去除前缀", &Route{Path: "/api/v1/wf", MatchType: "prefix", StripPrefix: "/api/v1/wf"}, "/api/v1/wf/list", "/list"},
+		{"去除整个路径", &Route{Path: "/api/v1/wf", MatchType: "prefix", StripPrefix: "/api/v1/wf"}, "/api/v1/wf", "/"},
+		{"不按段边界不去除", &Route{Path: "/api", MatchType: "prefix", StripPrefix: "/api/v1/wf"}, "/api/v1/wfx", "/api/v1/wfx"},
+		{"去除并添加前缀", &Route{Path: "/api/v1/wf", MatchType: "prefix", StripPrefix: "/api/v1/wf", AddPrefix: "/v2/"}, "/api/v1/wf/list", "/v2/list"},
+		{"正则重写", &Route{Path: `^/api/v1/wf/(.*)$`, MatchType: "regex", Rewrite: "/v2/$1"}, "/api/v1/wf/a/b", "/v2/a/b"},
+		{"命名捕获组", &Route{Path: `^/api/v1/wf/(?P<id>\d+)/run$`, MatchType: "regex", Rewrite: "/internal/run/${id}"}, "/api/v1/wf/42/run", "/internal/run/42"},
+	}
+
+	for _, tc := range testCases {
+		if got := rewritePath(tc.path, tc.route); got != tc.expected {
+			t.Errorf("%s: 路径 %s 应该重写为 %s，实际 %s", tc.name, tc.path, tc.expected, got)
+		}
+	}
+}
+
+// TestValidateRewriteConfig 测试路径重写配置验证
+func TestValidateRewriteConfig(t *testing.T) {
+	invalid := []conf.RouteRule{
+		{Path: "/api/v1/wf", MatchType: "prefix", Service: "wf", Rewrite: "/v2/$1"},
+		{Path: `^/api/v1/wf/(.*)$`, MatchType: "regex", Service: "wf", Rewrite: "/v2/$2"},
+		{Path: `^/api/v1/wf/(.*)$`, MatchType: "regex", Service: "wf", Rewrite: "/v2/${id}"},
+		{Path: `^/api/v1/wf/(.*)$`, MatchType: "regex", Service: "wf", Rewrite: "/v2/$1abc"},
+		{Path: `^/api/v1/wf/(.*)$`, MatchType: "regex", Service: "wf", Rewrite: "/v2/$id"},
+		{Path: "/api/v1/wf", MatchType: "prefix", Service: "wf", StripPrefix: "api"},
+		{Path: "/api/v1/wf", MatchType: "prefix", Service: "wf", HostRewrite: "http://wf.internal"},
+	}
+	for i, route := range invalid {
+		if err := validateRoute(route, i); err == nil {
+			t.Errorf("路由配置 %d 应该验证失败", i)
+		}
+	}
+
+	valid := []conf.RouteRule{
+		{Path: `^/api/v1/wf/(?P<id>\d+)/(.*)$`, MatchType: "regex", Service: "wf", Timeout: 10, Rewrite: "/v2/${id}/$2", HostRewrite: "wf.internal:8080"},
+		{Path: `^/api/v1/wf/(?P<id>\d+)/(.*)$`, MatchType: "regex", Service: "wf", Timeout: 10, Rewrite: "/v2/$id/${2}abc/$$1"},
+	}
+	for i, route := range valid {
+		if err := validateRoute(route, i); err != nil {
+			t.Errorf("路由配置 %d 应该验证通过: %v", i, err)
+		}
+	}
+}
+
+// TestRoutePredicates 测试方法、请求头、查询参数和 Host 匹配条件
+func TestRoutePredicates(t *testing.T) {
+	router := NewRouter(discovery.NewStaticDiscovery())
+	// 优先级相同时按配置顺序匹配，更具体的路由配置在通用路由之前
+	router.AddRoute(&Route{
+		Path:      "/api/v1/wf",
+		MatchType: "prefix",
+		Service:   "wf-canary",
+		Headers:   []conf.RouteMatchCondition{{Name: "X-Canary", Value: "true"}},
+	})
+	router.AddRoute(&Route{Path: "/api/v1/wf", MatchType: "prefix", Service: "wf-write", Methods: []string{"POST"}})
+	router.AddRoute(&Route{
+		Path:      "/api/v1/wf",
+		MatchType: "prefix",
+		Service:   "wf-tenant",
+		Query:     []conf.RouteMatchCondition{{Name: "tenant", Type: ConditionRegex, Value: `^t-\d+$`}},
+		Host:      "*.example.com",
+	})
+	router.AddRoute(&Route{Path: "/api/v1/wf", MatchType: "prefix", Service: "wf"})
+
+	newRequest := func(method, target string, header map[string]string) *stdHttp.Request {
+		req := httptest.NewRequest(method, target, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+		return req
+	}
+
+	testCases := []struct {
+		name     string
+		req      *stdHttp.Request
+		expected string
+	}{
+		{"默认路由", newRequest("GET", "/api/v1/wf/1", nil), "wf"},
+		{"灰度请求头", newRequest("GET", "/api/v1/wf/1", map[string]string{"X-Canary": "true"}), "wf-canary"},
+		{"请求头值不匹配", newRequest("GET", "/api/v1/wf/1", map[string]string{"X-Canary": "false"}), "wf"},
+		{"POST 请求", newRequest("POST", "/api/v1/wf", nil), "wf-write"},
+		{"租户查询参数和 Host", newRequest("GET", "http://a.example.com:8080/api/v1/wf?tenant=t-1", nil), "wf-tenant"},
+		{"Host 不匹配", newRequest("GET", "http://example.org/api/v1/wf?tenant=t-1", nil), "wf"},
+	}
+
+	for _, tc := range testCases {
+		found := router.FindRoute(tc.req)
+		if found == nil || found.Service != tc.expected {
+			t.Errorf("%s: 应该匹配到 %s，实际 %v", tc.name, tc.expected, found)
+		}
+	}
+}
+
+// TestRouteExplicitPriority 测试显式优先级，优先级相同时保持配置顺序
+func TestRouteExplicitPriority(t *testing.T) {
+	router := NewRouter(discovery.NewStaticDiscovery())
+	router.AddRoute(&Route{Path: "/api/v1/users", MatchType: "prefix", Service: "users"})
+	router.AddRoute(&Route{Path: "/api/v1/users/me", MatchType: "exact", Service: "me"})
+	router.AddRoute(&Route{Path: "/api/v1", MatchType: "prefix", Service: "fallback"})
+
+	// 优先级相同时不按匹配类型或前缀长度重排，先配置的路由生效
+	if found := router.FindRoute(httptest.NewRequest("GET", "/api/v1/users/me", nil)); found == nil || found.Service != "users" {
+		t.Error("优先级相同时应该按配置顺序匹配")
+	}
+	if found := router.FindRoute(httptest.NewRequest("GET", "/api/v1/orders", nil)); found == nil || found.Service != "fallback" {
+		t.Error("应该匹配到通用路由")
+	}
+	services := make([]string, 0, 3)
+	for _, route := range router.Routes() {
+		services = append(services, route.Service)
+	}
+	if !slices.Equal(services, []string{"users", "me", "fallback"}) {
+		t.Errorf("优先级相同时应该保持配置顺序，实际 %v", services)
+	}
+
+	// 显式优先级高的路由优先
+	router.AddRoute(&Route{Path: "/api/v1/users/me", MatchType: "exact", Service: "me-v2", Priority: 5})
+	router.AddRoute(&Route{Path: "/api", MatchType: "prefix", Service: "maintenance", Priority: 10})
+	if found := router.FindRoute(httptest.NewRequest("GET", "/api/v1/users/me", nil)); found == nil || found.Service != "maintenance" {
+		t.Error("显式优先级高的路由应该优先")
+	}
+	services = services[:0]
+	for _, route := range router.Routes() {
+		services = append(services, route.Service)
+	}
+	if !slices.Equal(services, []string{"maintenance", "me-v2", "users", "me", "fallback"}) {
+		t.Errorf("路由应该按优先级排序，实际 %v", services)
+	}
+}
+
+// TestPickSplitVersion 测试流量拆分版本选择
+func TestPickSplitVersion(t *testing.T) {
+	targets := []conf.TrafficSplitTarget{
+		{Version: "v1", Weight: 90},
+		{Version: "v2", Weight: 10},
+		{Version: "v3", Weight: 0},
+	}
+
+	// 随机分配应该大致符合权重
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		counts[pickSplitVersion(targets, "")]++
+	}
+	if counts["v3"] != 0 {
+		t.Errorf("权重为0的版本不应该被选择: %v", counts)
+	}
+	if counts["v2"] < 700 || counts["v2"] > 1300 {
+		t.Errorf("v2 应该分配约10%%的流量: %v", counts)
+	}
+
+	// 粘性分配：同一个 key 始终分配到同一版本
+	for _, key := range []string{"1", "42", "10086"} {
+		version := pickSplitVersion(targets, key)
+		for i := 0; i < 10; i++ {
+			if got := pickSplitVersion(targets, key); got != version {
+				t.Errorf("用户 %s 应该始终分配到 %s，实际 %s", key, version, got)
+			}
+		}
+	}
+
+	if pickSplitVersion([]conf.TrafficSplitTarget{{Version: "v1", Weight: 0}}, "") != "" {
+		t.Error("权重之和为0时不应该选择任何版本")
+	}
+}
+
+// TestTrafficSplitRetry 测试重试时沿用首次分配的流量拆分版本，且只写入一次 Cookie
+func TestTrafficSplitRetry(t *testing.T) {
+	var mu sync.Mutex
+	var versions []string
+	staticDiscovery := discovery.NewStaticDiscovery()
+	instances := make([]discovery.Instance, 0, 4)
+	for i, version := range []string{"v1", "v1", "v2", "v2"} {
+		server := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+			mu.Lock()
+			versions = append(versions, version)
+			mu.Unlock()
+			w.WriteHeader(stdHttp.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		address := server.Listener.Addr().(*net.TCPAddr)
+		instances = append(instances, discovery.Instance{
+			ID: strconv.Itoa(i), Host: "127.0.0.1", Port: address.Port, Healthy: true,
+			Metadata: map[string]string{"version": version},
+		})
+	}
+	staticDiscovery.RegisterService("user-service", instances)
+
+	router := NewRouter(staticDiscovery)
+	route := &Route{
+		Path:    "/api/v1/users",
+		Service: "user-service",
+		Retry:   &conf.RetryPolicyConfig{Attempts: 3, BaseInterval: 1, MaxInterval: 1},
+		TrafficSplit: &conf.TrafficSplitConfig{
+			Targets: []conf.TrafficSplitTarget{{Version: "v1", Weight: 50}, {Version: "v2", Weight: 50}},
+			Sticky:  StickyCookie,
+		},
+	}
+	router.AddRoute(route)
+
+	for i := 0; i < 4; i++ {
+		versions = nil
+		rec := httptest.NewRecorder()
+		ctx := &testContext{req: httptest.NewRequest(stdHttp.MethodGet, "/api/v1/users/1", nil), w: rec}
+		if err := router.Forward(ctx, route, nil); err != nil {
+			t.Fatalf("转发失败: %v", err)
+		}
+
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || cookies[0].Name != defaultSplitCookieName {
+			t.Fatalf("应该只写入一次版本 Cookie，实际 %v", rec.Header().Values("Set-Cookie"))
+		}
+		if len(versions) != 4 {
+			t.Fatalf("应该重试 3 次，实际请求 %d 次", len(versions))
+		}
+		for _, version := range versions {
+			if version != cookies[0].Value {
+				t.Errorf("重试应该沿用版本 %s，实际请求了 %v", cookies[0].Value, versions)
+				break
+			}
+		}
+	}
+}
+
+// TestHeaderTransform 测试请求头转换和逐跳请求头删除
+func TestHeaderTransform(t *testing.T) {
+	header := stdHttp.Header{}
+	header.Set("Connection", "keep-alive, X-Internal-Hop")
+	header.Set("X-Internal-Hop", "1")
+	header.Set("Keep-Alive", "timeout=5")
+	header.Set("X-User-ID", "spoofed")
+	header.Set("X-Old", "value")
+	header.Set("Server", "user-service/1.0")
+	header.Set("Accept", "application/json")
+
+	removeHopByHopHeaders(header)
+	for _, name := range []string{"Connection", "X-Internal-Hop", "Keep-Alive"} {
+		if header.Get(name) != "" {
+			t.Errorf("逐跳请求头 %s 应该被删除", name)
+		}
+	}
+
+	values := map[string]string{"trace_id": "trace-1", "client_ip": "10.0.0.1"}
+	applyHeaderTransform(header, &conf.HeaderTransformConfig{
+		Set:    map[string]string{"X-User-ID": "${user_id}", "X-Trace": "gw-${trace_id}"},
+		Add:    map[string]string{"X-Forwarded-For": "${client_ip}"},
+		Remove: []string{"Server"},
+		Rename: map[string]string{"X-Old": "X-New"},
+	}, values)
+
+	if header.Get("X-User-ID") != "" {
+		t.Error("未认证请求的 X-User-ID 应该被删除")
+	}
+	if header.Get("X-Trace") != "gw-trace-1" {
+		t.Errorf("X-Trace 应该为 gw-trace-1，实际 %s", header.Get("X-Trace"))
+	}
+	if header.Get("X-Forwarded-For") != "10.0.0.1" {
+		t.Errorf("X-Forwarded-For 应该为 10.0.0.1，实际 %s", header.Get("X-Forwarded-For"))
+	}
+	if header.Get("Server") != "" || header.Get("X-Old") != "" || header.Get("X-New") != "value" {
+		t.Error("Server 应该被删除，X-Old 应该重命名为 X-New")
+	}
+	if header.Get("Accept") != "application/json" {
+		t.Error("其他请求头应该保留")
+	}
+
+	if err := validateHeaderTemplate("${user_id}-${unknown}"); err == nil {
+		t.Error("不支持的模板变量应该验证失败")
+	}
+}
+
+// TestIdentityHeaders 测试身份请求头签名（删除客户端伪造的身份请求头）
+func TestIdentityHeaders(t *testing.T) {
+	router := NewRouter(discovery.NewStaticDiscovery())
+	router.identitySigner = identity.NewSigner("test-secret")
+	verifier := identity.NewSigner("test-secret")
+	target := identity.Target{Service: "user-service", Method: "GET", Path: "/v1/users/me"}
+
+	// 未认证请求：伪造的身份请求头应该被删除
+	req := httptest.NewRequest("GET", "/api/v1/users/me", nil)
+	out := httptest.NewRequest("GET", "http://127.0.0.1:8001/v1/users/me", nil)
+	out.Header.Set(identity.HeaderUserID, "1")
+	out.Header.Set(identity.HeaderSignature, "forged")
+	router.setIdentityHeaders(req, out, "user-service")
+	if out.Header.Get(identity.HeaderUserID) != "" || out.Header.Get(identity.HeaderSignature) != "" {
+		t.Error("未认证请求的身份请求头应该被删除")
+	}
+
+	// 已认证请求：写入签名后的身份信息，签名绑定目标服务和转发路径
+	req = req.WithContext(gatewayMiddleware.WithUser(req.Context(), 42, "张三", []string{"admin", "user"}))
+	router.setIdentityHeaders(req, out, "user-service")
+	id, err := verifier.Verify(out.Header, target)
+	if err != nil {
+		t.Fatalf("身份签名应该验证通过: %v", err)
+	}
+	if id.UserID != 42 || id.Username != "张三" || len(id.Roles) != 2 {
+		t.Errorf("身份信息不正确: %+v", id)
+	}
+
+	// 重放到其他服务或接口时签名验证失败
+	other := target
+	other.Service = "order-service"
+	if _, err := verifier.Verify(out.Header, other); !errors.Is(err, identity.ErrInvalidSignature) {
+		t.Errorf("重放到其他服务应该返回签名错误，实际 %v", err)
+	}
+
+	// 篡改用户ID后签名验证失败
+	out.Header.Set(identity.HeaderUserID, "1")
+	if _, err := verifier.Verify(out.Header, target); !errors.Is(err, identity.ErrInvalidSignature) {
+		t.Errorf("篡改后应该返回签名错误，实际 %v", err)
+	}
+
+	// 未配置 internal_secret 时不签名
+	if signer := newIdentitySignerFromConfig(&conf.GatewayConfig{JWT: &conf.JWTConfig{SecretKey: "jwt-secret"}}); signer != nil {
+		t.Error("未配置 internal_secret 时不应该创建签名器")
+	}
+}
+
+// TestClientIPAndForwardedHeaders 测试可信代理下的客户端IP解析和转发请求头
+func TestClientIPAndForwardedHeaders(t *testing.T) {
+	router := NewRouter(discovery.NewStaticDiscovery())
+	resolver, err := clientip.NewResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("创建解析器失败: %v", err)
+	}
+	router.clientIP = resolver
+
+	// 直连地址不可信：忽略客户端伪造的转发请求头
+	req := httptest.NewRequest("GET", "http://api.example.com/api/v1/users", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	if ip := router.ClientIP(req); ip != "203.0.113.7" {
+		t.Errorf("不可信直连地址应该作为客户端IP，实际 %s", ip)
+	}
+	header := req.Header.Clone()
+	router.setForwardedHeaders(req, header)
+	if header.Get("X-Forwarded-For") != "203.0.113.7" {
+		t.Errorf("伪造的 X-Forwarded-For 应该被替换，实际 %s", header.Get("X-Forwarded-For"))
+	}
+
+	// 直连地址可信：从右向左跳过可信代理
+	req = httptest.NewRequest("GET", "http://api.example.com/api/v1/users", nil)
+	req.RemoteAddr = "10.0.0.5:51234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.9, 192.168.1.1")
+	if ip := router.ClientIP(req); ip != "198.51.100.9" {
+		t.Errorf("客户端IP应该为 198.51.100.9，实际 %s", ip)
+	}
+	req = req.WithContext(clientip.NewContext(req.Context(), router.ClientIP(req)))
+	header = req.Header.Clone()
+	router.setForwardedHeaders(req, header)
+	if header.Get("X-Forwarded-For") != "1.2.3.4, 198.51.100.9, 192.168.1.1, 10.0.0.5" {
+		t.Errorf("可信代理应该追加转发链，实际 %s", header.Get("X-Forwarded-For"))
+	}
+	if header.Get("X-Real-IP") != "198.51.100.9" {
+		t.Errorf("X-Real-IP 应该为客户端IP，实际 %s", header.Get("X-Real-IP"))
+	}
+	if header.Get("Forwarded") != `for=10.0.0.5;host="api.example.com";proto=http` {
+		t.Errorf("Forwarded 请求头不正确: %s", header.Get("Forwarded"))
+	}
+
+	// 可信代理追加了新的 X-Forwarded-For 请求头行：合并所有行，不能只读取客户端伪造的第一行
+	req = httptest.NewRequest("GET", "http://api.example.com/api/v1/users", nil)
+	req.RemoteAddr = "10.0.0.5:51234"
+	req.Header.Add("X-Forwarded-For", "1.2.3.4")
+	req.Header.Add("X-Forwarded-For", "198.51.100.9")
+	if ip := router.ClientIP(req); ip != "198.51.100.9" {
+		t.Errorf("多行 X-Forwarded-For 应该合并解析，实际 %s", ip)
+	}
+	header = req.Header.Clone()
+	router.setForwardedHeaders(req, header)
+	if values := header.Values("X-Forwarded-For"); len(values) != 1 || values[0] != "1.2.3.4, 198.51.100.9, 10.0.0.5" {
+		t.Errorf("转发时应该合并为一行并追加直连地址，实际 %v", values)
+	}
+
+	// Forwarded 请求头（IPv6）
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.5:51234"
+	req.Header.Set("Forwarded", `for="[2001:db8::1]:4711";proto=https`)
+	if ip := router.ClientIP(req); ip != "2001:db8::1" {
+		t.Errorf("应该从 Forwarded 解析客户端IP，实际 %s", ip)
+	}
+}
+
+// TestPrepareRequestBody 测试重试时请求体的缓冲与重放
+func TestPrepareRequestBody(t *testing.T) {
+	policy := newRetryPolicy(&Route{Retry: &conf.RetryPolicyConfig{Attempts: 2, MaxBodySize: 8}})
+
+	// 未超过上限：可以多次重放
+	req := httptest.NewRequest(stdHttp.MethodPut, "/api/v1/users/1", strings.NewReader("small"))
+	body, replayable, err := prepareRequestBody(req, policy, true)
+	if err != nil || !replayable {
+		t.Fatalf("小请求体应该可以重放: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		data, _ := io.ReadAll(body())
+		if string(data) != "small" {
+			t.Errorf("第 %d 次重放的请求体不正确: %q", i+1, data)
+		}
+	}
+
+	// 超过上限：不可重放，但仍然完整转发
+	req = httptest.NewRequest(stdHttp.MethodPut, "/api/v1/users/1", strings.NewReader("larger than limit"))
+	req.ContentLength = -1
+	body, replayable, err = prepareRequestBody(req, policy, true)
+	if err != nil || replayable {
+		t.Fatal("超过上限的请求体不应该可以重放")
+	}
+	if data, _ := io.ReadAll(body()); string(data) != "larger than limit" {
+		t.Errorf("超过上限的请求体应该完整转发，实际 %q", data)
+	}
+
+	// 没有请求体：可以重放
+	req = httptest.NewRequest(stdHttp.MethodGet, "/api/v1/users/1", nil)
+	if body, replayable, _ = prepareRequestBody(req, policy, true); !replayable || body() != nil {
+		t.Error("没有请求体的请求应该可以重放")
+	}
+}
+
+// testContext 测试用的 kratos HTTP Context（只实现转发用到的方法）
+type testContext struct {
+	kratosHttp.Context
+	req *stdHttp.Request
+	w   stdHttp.ResponseWriter
+}
+
+func (c *testContext) Request() *stdHttp.Request         { return c.req }
+func (c *testContext) Response() stdHttp.ResponseWriter  { return c.w }
+func (c *testContext) Deadline() (time.Time, bool)       { return c.req.Context().Deadline() }
+func (c *testContext) Done() <-chan struct{}             { return c.req.Context().Done() }
+func (c *testContext) Err() error                        { return c.req.Context().Err() }
+func (c *testContext) Value(key interface{}) interface{} { return c.req.Context().Value(key) }
+
+// TestForwardHedging 测试慢实例超过等待时间后向其他实例发送对冲请求
+func TestForwardHedging(t *testing.T) {
+	var slowCanceled atomic.Bool
+	slow := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+		select {
+		case <-req.Context().Done():
+			slowCanceled.Store(true)
+		case <-time.After(2 * time.Second):
+			w.Write([]byte("slow"))
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+		w.Write([]byte("fast"))
+	}))
+	defer fast.Close()
+
+	staticDiscovery := discovery.NewStaticDiscovery()
+	instances := make([]discovery.Instance, 0, 2)
+	for i, server := range []*httptest.Server{slow, fast} {
+		address := server.Listener.Addr().(*net.TCPAddr)
+		instances = append(instances, discovery.Instance{ID: strconv.Itoa(i), Host: "127.0.0.1", Port: address.Port, Healthy: true})
+	}
+	staticDiscovery.RegisterService("user-service", instances)
+
+	router := NewRouter(staticDiscovery)
+	route := &Route{
+		Path:    "/api/v1/users",
+		Service: "user-service",
+		Hedging: &conf.HedgingConfig{Enabled: true, Delay: 20},
+	}
+	router.AddRoute(route)
+
+	rec := httptest.NewRecorder()
+	ctx := &testContext{req: httptest.NewRequest(stdHttp.MethodGet, "/api/v1/users/1", nil), w: rec}
+	start := time.Now()
+	if err := router.Forward(ctx, route, nil); err != nil {
+		t.Fatalf("转发失败: %v", err)
+	}
+	if rec.Body.String() != "fast" {
+		t.Errorf("应该采用对冲请求的响应，实际 %q", rec.Body.String())
+	}
+	if time.Since(start) > time.Second {
+		t.Error("对冲请求应该避免等待慢实例")
+	}
+
+	// 落败的请求应该被取消
+	deadline := time.Now().Add(time.Second)
+	for !slowCanceled.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !slowCanceled.Load() {
+		t.Error("落败的请求应该被取消")
+	}
+}
+
+// TestActiveHealthCheck 测试主动健康检查按阈值标记和恢复实例健康状态
+func TestActiveHealthCheck(t *testing.T) {
+	var failing atomic.Bool
+	unstable := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+		if req.URL.Path != "/healthz" {
+			w.WriteHeader(stdHttp.StatusNotFound)
+			return
+		}
+		if failing.Load() {
+			w.WriteHeader(stdHttp.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(stdHttp.StatusOK)
+	}))
+	defer unstable.Close()
+	stable := httptest.NewServer(stdHttp.HandlerFunc(func(w stdHttp.ResponseWriter, req *stdHttp.Request) {
+		w.WriteHeader(stdHttp.StatusOK)
+	}))
+	defer stable.Close()
+
+	staticDiscovery := discovery.NewStaticDiscovery()
+	instances := make([]discovery.Instance, 0, 2)
+	for i, server := range []*httptest.Server{unstable, stable} {
+		address := server.Listener.Addr().(*net.TCPAddr)
+		instances = append(instances, discovery.Instance{ID: strconv.Itoa(i), Host: "127.0.0.1", Port: address.Port, Healthy: true})
+	}
+	staticDiscovery.RegisterService("user-service", instances)
+
+	router := NewRouter(staticDiscovery)
+	checker := healthcheck.NewChecker("user-service", &healthcheck.Config{
+		Path:               "/healthz",
+		Timeout:            time.Second,
+		HealthyThreshold:   2,
+		UnhealthyThreshold: 2,
+	}, func(ctx context.Context) ([]discovery.Instance, error) {
+		return staticDiscovery.GetInstances(ctx, "user-service")
+	}, nil)
+	router.healthCheckers["user-service"] = checker
+
+	healthy := func() map[string]bool {
+		result := make(map[string]bool)
+		current, err := router.GetServiceInstances(context.Background(), "user-service")
+		if err != nil {
+			t.Fatalf("获取服务实例失败: %v", err)
+		}
+		for _, instance := range current {
+			result[instance.ID] = instance.Healthy
+		}
+		return result
+	}
+
+	checker.CheckOnce()
+	if status := healthy(); !status["0"] || !status["1"] {
+		t.Fatalf("检查通过的实例应该健康: %v", status)
+	}
+
+	// 连续失败达到阈值后标记为不健康
+	failing.Store(true)
+	checker.CheckOnce()
+	if status := healthy(); !status["0"] {
+		t.Fatalf("未达到不健康阈值时不应该标记为不健康: %v", status)
+	}
+	checker.CheckOnce()
+	if status := healthy(); status["0"] || !status["1"] {
+		t.Fatalf("连续失败的实例应该标记为不健康: %v", status)
+	}
+	if status := checker.Statuses()["0"]; status.LastStatusCode != stdHttp.StatusServiceUnavailable || status.LastError == "" {
+		t.Errorf("应该记录最后一次检查结果: %+v", status)
+	}
+
+	// 负载均衡器跳过不健康的实例
+	lb := loadbalancer.NewLoadBalancer("round_robin")
+	for i := 0; i < 4; i++ {
+		current, _ := router.GetServiceInstances(context.Background(), "user-service")
+		instance, _ := lb.Select(context.Background(), current)
+		if instance == nil || instance.ID != "1" {
+			t.Fatalf("应该只选择健康的实例: %v", instance)
+		}
+	}
+
+	// 连续成功达到阈值后恢复
+	failing.Store(false)
+	checker.CheckOnce()
+	if status := healthy(); status["0"] {
+		t.Fatalf("未达到健康阈值时不应该恢复: %v", status)
+	}
+	checker.CheckOnce()
+	if status := healthy(); !status["0"] {
+		t.Fatalf("连续成功的实例应该恢复健康: %v", status)
+	}
+}
+
+// TestRouteKey 测试同一路径的多条路由生成不同的路由标识，热更新后标识不变
+func TestRouteKey(t *testing.T) {
+	config := &conf.GatewayConfig{Routes: &conf.RouteConfig{Routes: []conf.RouteRule{
+		{Path: "/api/users", Service: "user-service", Headers: []conf.RouteMatchCondition{{Name: "X-Canary", Value: "true"}}},
+		{Path: "/api/users", Service: "user-service"},
+		{Path: "/api/users", Service: "user-service-v2"},
+	}}}
+	router, cleanup, err := LoadRouterFromConfig(config, discovery.NewStaticDiscovery(), nil)
+	if err != nil {
+		t.Fatalf("LoadRouterFromConfig failed: %v", err)
+	}
+	defer cleanup()
+
+	keys := func() []string {
+		keys := make([]string, 0, 3)
+		for _, route := range router.Routes() {
+			keys = append(keys, route.Key)
+		}
+		return keys
+	}
+	expected := []string{
+		"* /api/users (prefix) header:X-Canary=true",
+		"* /api/users (prefix)",
+		"* /api/users (prefix) #2",
+	}
+	if got := keys(); !slices.Equal(got, expected) {
+		t.Fatalf("路由标识不正确: %v", got)
+	}
+
+	// 只修改一条路由，新建的路由使用与配置比较一致的标识
+	reloaded := &conf.GatewayConfig{Routes: &conf.RouteConfig{Routes: slices.Clone(config.Routes.Routes)}}
+	reloaded.Routes.Routes[2].Timeout = 5
+	if _, err := router.Reload(reloaded); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if got := keys(); !slices.Equal(got, expected) {
+		t.Errorf("热更新后路由标识不应该变化: %v", got)
+	}
+}
+
+// TestRouterReload 测试配置热更新
+func TestRouterReload(t *testing.T) {
+	newConfig := func(ordersTimeout int) *conf.GatewayConfig {
+		return &conf.GatewayConfig{
+			Routes: &conf.RouteConfig{Routes: []conf.RouteRule{
+				{Path: "/api/users", Service: "user-service"},
+				{Path: "/api/orders", Service: "order-service", Timeout: ordersTimeout},
+			}},
+			Services: &conf.ServiceConfig{Services: map[string][]conf.ServiceInstance{
+				"user-service":  {{ID: "user-1", Host: "127.0.0.1", Port: 8001, Healthy: true}},
+				"order-service": {{ID: "order-1", Host: "127.0.0.1", Port: 8002, Healthy: true}},
+			}},
+		}
+	}
+
+	staticDiscovery := discovery.NewStaticDiscovery()
+	router, cleanup, err := LoadRouterFromConfig(newConfig(10), staticDiscovery, nil)
+	if err != nil {
+		t.Fatalf("LoadRouterFromConfig failed: %v", err)
+	}
+	defer cleanup()
+
+	find := func(path string) *Route {
+		return router.FindRoute(httptest.NewRequest("GET", path, nil))
+	}
+	users, orders := find("/api/users"), find("/api/orders")
+
+	// 修改一条路由、删除服务实例、新增路由和服务
+	config := newConfig(20)
+	config.Routes.Routes = append(config.Routes.Routes, conf.RouteRule{Path: "/api/items", Service: "item-service"})
+	config.Services.Services["item-service"] = []conf.ServiceInstance{{ID: "item-1", Host: "127.0.0.1", Port: 8003, Healthy: true}}
+	delete(config.Services.Services, "user-service")
+	diff, err := router.Reload(config)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if len(diff.AddedRoutes) != 1 || len(diff.ChangedRoutes) != 1 || len(diff.RemovedRoutes) != 0 ||
+		len(diff.AddedServices) != 1 || len(diff.RemovedServices) != 1 {
+		t.Errorf("Unexpected diff: %s", diff)
+	}
+
+	// 未变化的路由沿用原路由对象，变化的路由使用新配置
+	if find("/api/users") != users {
+		t.Error("Expected unchanged route to be kept")
+	}
+	if route := find("/api/orders"); route == orders || route.Timeout != 20 {
+		t.Errorf("Expected changed route to be replaced, got %+v", route)
+	}
+	if route := find("/api/items"); route == nil || route.Service != "item-service" {
+		t.Errorf("Expected added route, got %+v", route)
+	}
+	if instances, err := staticDiscovery.GetInstances(context.Background(), "item-service"); err != nil || len(instances) != 1 {
+		t.Errorf("Expected added service instances, got %+v, err %v", instances, err)
+	}
+	if _, err := staticDiscovery.GetInstances(context.Background(), "user-service"); err == nil {
+		t.Error("Expected removed service to be unregistered")
+	}
+
+	// 相同配置不产生变化
+	if diff, err := router.Reload(config); err != nil || !diff.Empty() {
+		t.Errorf("Expected empty diff, got %s, err %v", diff, err)
+	}
+
+	// 无效配置被拒绝，保持当前路由
+	invalid := newConfig(20)
+	invalid.Routes.Routes = append(invalid.Routes.Routes, conf.RouteRule{Path: "/api/broken"})
+	diff, err = router.Reload(invalid)
+	if err == nil {
+		t.Fatal("Expected invalid config to be rejected")
+	}
+	if len(diff.AddedRoutes) != 1 || len(diff.RemovedRoutes) != 1 {
+		t.Errorf("Expected diff of rejected config, got %s", diff)
+	}
+	if find("/api/items") == nil || find("/api/broken") != nil {
+		t.Error("Expected routes to be unchanged after rejected reload")
+	}
+}
+
+// TestDrainInstance 测试摘除和恢复服务实例
+func TestDrainInstance(t *testing.T) {
+	config := &conf.GatewayConfig{
+		Routes: &conf.RouteConfig{Routes: []conf.RouteRule{
+			{Path: "/api/users", Service: "user-service"},
+		}},
+		Services: &conf.ServiceConfig{Services: map[string][]conf.ServiceInstance{
+			"user-service": {
+				{ID: "user-1", Host: "127.0.0.1", Port: 8001, Healthy: true},
+				{ID: "user-2", Host: "127.0.0.1", Port: 8002, Healthy: true},
+			},
+		}},
+	}
+	router, cleanup, err := LoadRouterFromConfig(config, discovery.NewStaticDiscovery(), nil)
+	if err != nil {
+		t.Fatalf("LoadRouterFromConfig failed: %v", err)
+	}
+	defer cleanup()
+
+	ctx := context.Background()
+	route := router.FindRoute(httptest.NewRequest("GET", "/api/users", nil))
+	instances, err := router.GetServiceInstances(ctx, "user-service")
+	if err != nil {
+		t.Fatalf("GetServiceInstances failed: %v", err)
+	}
+
+	// 摘除的实例被排除
+	router.DrainInstance("user-service", "user-1")
+	available, err := router.filterInstances(ctx, route, instances)
+	if err != nil {
+		t.Fatalf("filterInstances failed: %v", err)
+	}
+	if len(available) != 1 || available[0].Key() != "user-2" {
+		t.Errorf("Expected only user-2 available, got %v", available)
+	}
+	if drained := router.DrainedInstances("user-service"); len(drained) != 1 || drained[0] != "user-1" {
+		t.Errorf("Expected user-1 drained, got %v", drained)
+	}
+
+	// 实例全部摘除时返回错误
+	router.DrainInstance("user-service", "user-2")
+	if _, err := router.filterInstances(ctx, route, instances); err == nil {
+		t.Error("Expected error when all instances are drained")
+	}
+
+	// 恢复后实例重新可用
+	if !router.UndrainInstance("user-service", "user-1") {
+		t.Error("Expected user-1 to be undrained")
+	}
+	if router.UndrainInstance("user-service", "user-1") {
+		t.Error("Expected second undrain to report not drained")
+	}
+	available, err = router.filterInstances(ctx, route, instances)
+	if err != nil || len(available) != 1 || available[0].Key() != "user-1" {
+		t.Errorf("Expected only user-1 available, got %v (err: %v)", available, err)
+	}
+}
+
+// TestUpdateServiceInstancesPrunesBreakers 测试实例下线后删除其熔断器
+func TestUpdateServiceInstancesPrunesBreakers(t *testing.T) {
+	config := &conf.GatewayConfig{
+		Routes: &conf.RouteConfig{Routes: []conf.RouteRule{
+			{Path: "/api/users", Service: "user-service"},
+		}},
+		Services: &conf.ServiceConfig{Services: map[string][]conf.ServiceInstance{
+			"user-service": {
+				{ID: "user-1", Host: "127.0.0.1", Port: 8001, Healthy: true},
+				{ID: "user-2", Host: "127.0.0.1", Port: 8002, Healthy: true},
+			},
+		}},
+	}
+	router, cleanup, err := LoadRouterFromConfig(config, discovery.NewStaticDiscovery(), nil)
+	if err != nil {
+		t.Fatalf("LoadRouterFromConfig failed: %v", err)
+	}
+	defer cleanup()
+
+	instances, err := router.GetServiceInstances(context.Background(), "user-service")
+	if err != nil {
+		t.Fatalf("GetServiceInstances failed: %v", err)
+	}
+	for i := range instances {
+		router.circuitBreakers.GetBreaker(breakerKey("user-service", &instances[i]), &circuitbreaker.Config{})
+	}
+
+	// user-2 下线后其熔断器不再出现在统计中
+	remaining := slices.DeleteFunc(slices.Clone(instances), func(instance discovery.Instance) bool {
+		return instance.Key() == "user-2"
+	})
+	router.UpdateServiceInstances("user-service", remaining)
+
+	stats := router.GetCircuitBreakerStats()
+	if _, exists := stats["user-service/user-2"]; exists {
+		t.Error("Expected breaker of removed instance to be pruned")
+	}
+	if _, exists := stats["user-service/user-1"]; !exists {
+		t.Error("Expected breaker of remaining instance to be kept")
+	}
+}
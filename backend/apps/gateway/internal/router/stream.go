@@ -0,0 +1,92 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStreamIdleTimeout 流式响应（SSE）默认空闲超时时间
+const defaultStreamIdleTimeout = 60 * time.Second
+
+var (
+	// errUpstreamTimeout 上游请求超过路由总超时时间
+	errUpstreamTimeout = fmt.Errorf("upstream request timeout: %w", context.DeadlineExceeded)
+	// errStreamIdleTimeout 流式响应超过空闲超时时间未收到数据
+	errStreamIdleTimeout = fmt.Errorf("upstream stream idle timeout: %w", context.DeadlineExceeded)
+)
+
+// IsEventStream 判断响应是否为 SSE 事件流（Content-Type: text/event-stream）
+func IsEventStream(header http.Header) bool {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/event-stream"
+}
+
+// AcceptsEventStream 判断客户端是否期望 SSE 事件流（Accept: text/event-stream）
+func AcceptsEventStream(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err == nil && mediaType == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamIdleTimeout 获取路由的流式响应空闲超时时间
+func streamIdleTimeout(route *Route) time.Duration {
+	if route.IdleTimeout > 0 {
+		return time.Duration(route.IdleTimeout) * time.Second
+	}
+	return defaultStreamIdleTimeout
+}
+
+// idleTimeoutReader 空闲超时读取器
+// 每次读取到数据后重置计时器，超过空闲时间未读取到数据时调用 onTimeout（通常用于取消上游请求）
+type idleTimeoutReader struct {
+	reader io.Reader
+	idle   time.Duration
+	timer  *time.Timer
+	mu     sync.Mutex
+}
+
+// newIdleTimeoutReader 创建空闲超时读取器
+func newIdleTimeoutReader(reader io.Reader, idle time.Duration, onTimeout func()) *idleTimeoutReader {
+	return &idleTimeoutReader{
+		reader: reader,
+		idle:   idle,
+		timer:  time.AfterFunc(idle, onTimeout),
+	}
+}
+
+// Read 读取数据（读取到数据时重置空闲计时器）
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.mu.Lock()
+		r.timer.Reset(r.idle)
+		r.mu.Unlock()
+	}
+	return n, err
+}
+
+// Stop 停止空闲计时器
+func (r *idleTimeoutReader) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timer.Stop()
+}
@@ -0,0 +1,157 @@
+package router
+
+import (
+	"hash/fnv"
+	"math/rand"
+	stdHttp "net/http"
+	"strconv"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	gatewayMiddleware "StructForge/backend/apps/gateway/internal/middleware"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/common/log"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+const (
+	// defaultSplitMetadataKey 流量拆分默认使用的实例元数据键
+	defaultSplitMetadataKey = "version"
+	// defaultSplitCookieName 流量拆分粘性分配默认使用的 Cookie 名称
+	defaultSplitCookieName = "gateway_version"
+	// splitFallbackVersion 目标版本没有可用实例时记录的版本标签
+	splitFallbackVersion = "fallback"
+)
+
+// 流量拆分粘性分配方式
+const (
+	// StickyUserID 按用户ID分配（需要路由开启认证）
+	StickyUserID = "user_id"
+	// StickyCookie 按 Cookie 分配（首次分配后写入 Cookie）
+	StickyCookie = "cookie"
+)
+
+// splitVersion 为请求选择流量拆分的目标版本，未配置流量拆分时返回空
+// 每个请求只选择一次，重试和对冲请求沿用同一版本，避免切换版本或重复写入 Cookie
+func (r *Router) splitVersion(ctx kratosHttp.Context, route *Route) string {
+	if route.TrafficSplit == nil {
+		return ""
+	}
+	return r.assignVersion(ctx, route.TrafficSplit)
+}
+
+// splitInstances 返回目标版本的实例，record 为 true 时记录流量拆分指标（每个请求只记录一次）
+// 目标版本没有实例时回退到全部实例，避免灰度配置错误导致服务不可用
+func (r *Router) splitInstances(ctx kratosHttp.Context, route *Route, version string, instances []discovery.Instance, record bool) []discovery.Instance {
+	metadataKey := route.TrafficSplit.MetadataKey
+	if metadataKey == "" {
+		metadataKey = defaultSplitMetadataKey
+	}
+
+	selected := make([]discovery.Instance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.Metadata[metadataKey] == version {
+			selected = append(selected, instance)
+		}
+	}
+
+	if len(selected) == 0 {
+		if record {
+			log.Warn(ctx, "流量拆分目标版本没有可用实例，回退到全部实例",
+				log.String("service", route.Service),
+				log.String("version", version),
+			)
+			r.recordTrafficSplit(route.Service, splitFallbackVersion)
+		}
+		return instances
+	}
+
+	if record {
+		r.recordTrafficSplit(route.Service, version)
+	}
+	return selected
+}
+
+// assignVersion 为请求分配目标版本
+// 粘性分配时同一用户（或同一 Cookie）在权重不变的情况下始终分配到同一版本
+func (r *Router) assignVersion(ctx kratosHttp.Context, split *conf.TrafficSplitConfig) string {
+	switch split.Sticky {
+	case StickyUserID:
+		if userID, ok := gatewayMiddleware.GetUserID(ctx.Request().Context()); ok {
+			return pickSplitVersion(split.Targets, strconv.FormatInt(userID, 10))
+		}
+	case StickyCookie:
+		cookieName := split.CookieName
+		if cookieName == "" {
+			cookieName = defaultSplitCookieName
+		}
+		// 已分配且版本仍有流量时沿用 Cookie 中的版本
+		if cookie, err := ctx.Request().Cookie(cookieName); err == nil && hasSplitTarget(split.Targets, cookie.Value) {
+			return cookie.Value
+		}
+		version := pickSplitVersion(split.Targets, "")
+		if version != "" {
+			stdHttp.SetCookie(ctx.Response(), &stdHttp.Cookie{
+				Name:     cookieName,
+				Value:    version,
+				Path:     "/",
+				HttpOnly: true,
+			})
+		}
+		return version
+	}
+
+	return pickSplitVersion(split.Targets, "")
+}
+
+// pickSplitVersion 按权重选择版本
+// key 不为空时按 key 的哈希值确定性选择，否则随机选择
+func pickSplitVersion(targets []conf.TrafficSplitTarget, key string) string {
+	totalWeight := 0
+	for _, target := range targets {
+		if target.Weight > 0 {
+			totalWeight += target.Weight
+		}
+	}
+	if totalWeight == 0 {
+		return ""
+	}
+
+	var point int
+	if key != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		point = int(hash.Sum32() % uint32(totalWeight))
+	} else {
+		point = rand.Intn(totalWeight)
+	}
+
+	for _, target := range targets {
+		if target.Weight <= 0 {
+			continue
+		}
+		if point < target.Weight {
+			return target.Version
+		}
+		point -= target.Weight
+	}
+
+	return ""
+}
+
+// hasSplitTarget 判断版本是否为权重大于 0 的拆分目标
+func hasSplitTarget(targets []conf.TrafficSplitTarget, version string) bool {
+	for _, target := range targets {
+		if target.Version == version && target.Weight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTrafficSplit 记录流量拆分指标
+func (r *Router) recordTrafficSplit(service, version string) {
+	if r.metrics != nil {
+		r.metrics.RecordTrafficSplit(service, version)
+	}
+}
@@ -3,10 +3,18 @@ package router
 import (
 	"context"
 	"fmt"
+	"net"
+	"path/filepath"
 	"strings"
 
 	"StructForge/backend/apps/gateway/internal/conf"
+	"StructForge/backend/apps/gateway/internal/middleware/concurrency"
+	"StructForge/backend/apps/gateway/internal/middleware/ratelimit"
+	"StructForge/backend/apps/gateway/internal/middleware/retry"
+	"StructForge/backend/apps/gateway/internal/router/discovery"
+	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
 	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
 )
 
 // ValidateGatewayConfig 验证网关配置
@@ -33,6 +41,43 @@ func ValidateGatewayConfig(config *conf.GatewayConfig) error {
 		}
 	}
 
+	// 验证服务发现配置
+	if err := validateDiscovery(config.Discovery); err != nil {
+		return fmt.Errorf("服务发现配置错误: %w", err)
+	}
+
+	// 验证服务级并发限制配置
+	for serviceName, concurrency := range config.ServiceConcurrency {
+		if err := validateConcurrency(concurrency); err != nil {
+			return fmt.Errorf("服务并发限制配置错误 [%s]: %w", serviceName, err)
+		}
+	}
+	for serviceName, adaptive := range config.AdaptiveConcurrency {
+		if err := validateAdaptiveConcurrency(adaptive); err != nil {
+			return fmt.Errorf("服务自适应并发限制配置错误 [%s]: %w", serviceName, err)
+		}
+	}
+	for serviceName, outlier := range config.OutlierDetection {
+		if err := validateOutlierDetection(outlier); err != nil {
+			return fmt.Errorf("服务异常实例检测配置错误 [%s]: %w", serviceName, err)
+		}
+	}
+	for serviceName, healthCheck := range config.HealthCheck {
+		if err := validateHealthCheck(healthCheck); err != nil {
+			return fmt.Errorf("服务主动健康检查配置错误 [%s]: %w", serviceName, err)
+		}
+	}
+
+	// 验证重试预算配置
+	if err := validateRetryBudget(config.RetryBudget); err != nil {
+		return fmt.Errorf("重试预算配置错误: %w", err)
+	}
+
+	// 验证管理接口配置
+	if err := validateAdmin(config.Admin); err != nil {
+		return fmt.Errorf("管理接口配置错误: %w", err)
+	}
+
 	// 验证JWT配置
 	if config.JWT != nil {
 		if err := validateJWT(config.JWT); err != nil {
@@ -40,6 +85,11 @@ func ValidateGatewayConfig(config *conf.GatewayConfig) error {
 		}
 	}
 
+	// 验证可信代理配置
+	if _, err := clientip.NewResolver(config.TrustedProxies); err != nil {
+		return err
+	}
+
 	// 验证CORS配置
 	if config.CORS != nil {
 		if err := validateCORS(config.CORS); err != nil {
@@ -56,7 +106,7 @@ func validateRoute(route conf.RouteRule, index int) error {
 	if route.Path == "" {
 		return fmt.Errorf("路径不能为空")
 	}
-	if !strings.HasPrefix(route.Path, "/") {
+	if !strings.HasPrefix(route.Path, "/") && !(route.MatchType == "regex" && strings.HasPrefix(route.Path, "^/")) {
 		return fmt.Errorf("路径必须以 / 开头")
 	}
 
@@ -70,6 +120,23 @@ func validateRoute(route conf.RouteRule, index int) error {
 		return fmt.Errorf("无效的匹配类型: %s (支持: exact, prefix, regex)", route.MatchType)
 	}
 
+	// 验证正则表达式
+	if route.MatchType == "regex" {
+		if _, err := compileRegex(route.Path); err != nil {
+			return fmt.Errorf("无效的正则表达式: %w", err)
+		}
+	}
+
+	// 验证请求匹配条件
+	if err := validatePredicates(route); err != nil {
+		return err
+	}
+
+	// 验证路径重写配置
+	if err := validateRewriteConfig(route); err != nil {
+		return err
+	}
+
 	// 验证服务名称
 	if route.Service == "" {
 		return fmt.Errorf("服务名称不能为空")
@@ -86,6 +153,11 @@ func validateRoute(route conf.RouteRule, index int) error {
 		)
 	}
 
+	// 验证流式响应空闲超时时间
+	if route.IdleTimeout < 0 {
+		return fmt.Errorf("空闲超时时间不能为负数")
+	}
+
 	// 验证重试次数
 	if route.Retries < 0 {
 		return fmt.Errorf("重试次数不能为负数")
@@ -96,15 +168,19 @@ func validateRoute(route conf.RouteRule, index int) error {
 			log.Int("retries", route.Retries),
 		)
 	}
+	if err := validateRetryPolicy(route.Retry); err != nil {
+		return fmt.Errorf("重试策略配置错误: %w", err)
+	}
+	if err := validateHedging(route.Hedging); err != nil {
+		return fmt.Errorf("对冲请求配置错误: %w", err)
+	}
 
 	// 验证负载均衡策略
-	validStrategies := map[string]bool{
-		"round_robin":       true,
-		"random":            true,
-		"least_connections": true,
+	if route.LoadBalanceStrategy != "" && !loadbalancer.ValidStrategy(route.LoadBalanceStrategy) {
+		return fmt.Errorf("无效的负载均衡策略: %s (支持: round_robin, weighted_round_robin, random, least_connections, consistent_hash, p2c)", route.LoadBalanceStrategy)
 	}
-	if route.LoadBalanceStrategy != "" && !validStrategies[route.LoadBalanceStrategy] {
-		return fmt.Errorf("无效的负载均衡策略: %s (支持: round_robin, random, least_connections)", route.LoadBalanceStrategy)
+	if err := validateHashOn(route.HashOn); err != nil {
+		return fmt.Errorf("一致性哈希配置错误: %w", err)
 	}
 
 	// 验证限流配置
@@ -115,6 +191,20 @@ func validateRoute(route conf.RouteRule, index int) error {
 		if route.RateLimit.Burst < 0 {
 			return fmt.Errorf("Burst不能为负数")
 		}
+		if route.RateLimit.Type != "" && route.RateLimit.Type != ratelimit.TypeLocal && route.RateLimit.Type != ratelimit.TypeDistributed {
+			return fmt.Errorf("无效的限流器类型: %s (支持: local, distributed)", route.RateLimit.Type)
+		}
+		for _, keyBy := range route.RateLimit.KeyBy {
+			if !ratelimit.ValidKeyBy(keyBy) {
+				return fmt.Errorf("无效的限流维度: %s (支持: route, ip, user, header:<name>)", keyBy)
+			}
+		}
+		if tier := route.RateLimit.Anonymous; tier != nil && (tier.QPS < 0 || tier.Burst < 0) {
+			return fmt.Errorf("anonymous 档位的 QPS 和 Burst 不能为负数")
+		}
+		if tier := route.RateLimit.Authenticated; tier != nil && (tier.QPS < 0 || tier.Burst < 0) {
+			return fmt.Errorf("authenticated 档位的 QPS 和 Burst 不能为负数")
+		}
 		if route.RateLimit.Burst < route.RateLimit.QPS {
 			log.Warn(context.TODO(), "Burst小于QPS，建议Burst >= QPS",
 				log.String("path", route.Path),
@@ -156,6 +246,9 @@ func validateRoute(route conf.RouteRule, index int) error {
 				log.String("path", route.Path),
 			)
 		}
+		if route.Cache.MaxBodySize < 0 {
+			return fmt.Errorf("缓存最大响应体大小不能为负数")
+		}
 		if len(route.Cache.Methods) == 0 {
 			log.Warn(context.TODO(), "缓存方法列表为空，将默认只缓存GET请求",
 				log.String("path", route.Path),
@@ -163,6 +256,350 @@ func validateRoute(route conf.RouteRule, index int) error {
 		}
 	}
 
+	// 验证请求头/响应头转换配置
+	if err := validateHeaderTransform(route.RequestHeaders); err != nil {
+		return fmt.Errorf("请求头转换配置错误: %w", err)
+	}
+	if err := validateHeaderTransform(route.ResponseHeaders); err != nil {
+		return fmt.Errorf("响应头转换配置错误: %w", err)
+	}
+
+	// 验证流量拆分配置
+	if route.TrafficSplit != nil {
+		if err := validateTrafficSplit(route.TrafficSplit); err != nil {
+			return fmt.Errorf("流量拆分配置错误: %w", err)
+		}
+	}
+
+	// 验证并发限制配置
+	if err := validateConcurrency(route.Concurrency); err != nil {
+		return fmt.Errorf("并发限制配置错误: %w", err)
+	}
+	if !concurrency.ValidPriority(route.ShedPriority) {
+		return fmt.Errorf("无效的负载削减优先级: %s (支持: critical, normal, low)", route.ShedPriority)
+	}
+
+	return nil
+}
+
+// validateAdaptiveConcurrency 验证自适应并发限制配置
+func validateAdaptiveConcurrency(config *conf.AdaptiveConcurrencyConfig) error {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	if config.InitialLimit < 0 || config.MinLimit < 0 || config.MaxLimit < 0 || config.WindowSize < 0 {
+		return fmt.Errorf("并发上限和窗口样本数不能为负数")
+	}
+	if config.MinLimit > 0 && config.MaxLimit > 0 && config.MinLimit > config.MaxLimit {
+		return fmt.Errorf("min_limit 不能大于 max_limit")
+	}
+	if config.Tolerance != 0 && config.Tolerance <= 1 {
+		return fmt.Errorf("延迟容忍倍数必须大于1")
+	}
+	if config.Backoff != 0 && (config.Backoff <= 0 || config.Backoff >= 1) {
+		return fmt.Errorf("收缩系数必须在0-1之间")
+	}
+	return nil
+}
+
+// validateOutlierDetection 验证异常实例检测配置
+func validateOutlierDetection(config *conf.OutlierDetectionConfig) error {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	if config.Consecutive5xx < 0 || config.ConsecutiveConnectionErrors < 0 || config.LatencyMinRequests < 0 {
+		return fmt.Errorf("次数阈值不能为负数")
+	}
+	if config.LatencyFactor != 0 && config.LatencyFactor <= 1 {
+		return fmt.Errorf("延迟倍数阈值必须大于1")
+	}
+	if config.BaseEjectionTime < 0 || config.MaxEjectionTime < 0 {
+		return fmt.Errorf("驱逐时间不能为负数")
+	}
+	if config.BaseEjectionTime > 0 && config.MaxEjectionTime > 0 && config.BaseEjectionTime > config.MaxEjectionTime {
+		return fmt.Errorf("base_ejection_time 不能大于 max_ejection_time")
+	}
+	if config.MaxEjectionPercent < 0 || config.MaxEjectionPercent > 100 {
+		return fmt.Errorf("最大驱逐百分比必须在0-100之间")
+	}
+	return nil
+}
+
+// validateHealthCheck 验证主动健康检查配置
+func validateHealthCheck(config *conf.HealthCheckConfig) error {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	if config.Path != "" && !strings.HasPrefix(config.Path, "/") {
+		return fmt.Errorf("健康检查路径必须以 / 开头: %s", config.Path)
+	}
+	for _, status := range config.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("无效的状态码: %d", status)
+		}
+	}
+	if config.Interval < 0 || config.Timeout < 0 {
+		return fmt.Errorf("检查间隔和超时时间不能为负数")
+	}
+	if config.Interval > 0 && config.Timeout > config.Interval {
+		return fmt.Errorf("超时时间不能大于检查间隔")
+	}
+	if config.HealthyThreshold < 0 || config.UnhealthyThreshold < 0 {
+		return fmt.Errorf("健康阈值不能为负数")
+	}
+	return nil
+}
+
+// validateRetryPolicy 验证路由重试策略配置
+func validateRetryPolicy(config *conf.RetryPolicyConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Attempts < 0 {
+		return fmt.Errorf("重试次数不能为负数")
+	}
+	for _, on := range config.RetryOn {
+		if !retry.ValidRetryOn(on) {
+			return fmt.Errorf("无效的重试错误类型: %s (支持: connect_failure, reset)", on)
+		}
+	}
+	for _, status := range config.RetryOnStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("无效的重试状态码: %d", status)
+		}
+	}
+	if config.MaxBodySize < 0 {
+		return fmt.Errorf("最大请求体大小不能为负数")
+	}
+	if config.BaseInterval < 0 || config.MaxInterval < 0 {
+		return fmt.Errorf("退避间隔不能为负数")
+	}
+	if config.BaseInterval > 0 && config.MaxInterval > 0 && config.BaseInterval > config.MaxInterval {
+		return fmt.Errorf("base_interval 不能大于 max_interval")
+	}
+	return nil
+}
+
+// validateHedging 验证对冲请求配置
+func validateHedging(config *conf.HedgingConfig) error {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	if config.Delay < 0 || config.MinDelay < 0 {
+		return fmt.Errorf("等待时间不能为负数")
+	}
+	if config.MaxHedges < 0 {
+		return fmt.Errorf("对冲请求数不能为负数")
+	}
+	if config.MaxHedges > 3 {
+		return fmt.Errorf("对冲请求数不能超过3")
+	}
+	return nil
+}
+
+// validateHashOn 验证一致性哈希键来源
+func validateHashOn(hashOn string) error {
+	switch {
+	case hashOn == "", hashOn == HashOnUserID, hashOn == HashOnIP:
+		return nil
+	case strings.HasPrefix(hashOn, hashOnHeaderPrefix):
+		if strings.TrimPrefix(hashOn, hashOnHeaderPrefix) == "" {
+			return fmt.Errorf("请求头名称不能为空")
+		}
+		return nil
+	case strings.HasPrefix(hashOn, hashOnCookiePrefix):
+		if strings.TrimPrefix(hashOn, hashOnCookiePrefix) == "" {
+			return fmt.Errorf("Cookie 名称不能为空")
+		}
+		return nil
+	}
+	return fmt.Errorf("无效的哈希键来源: %s (支持: header:<name>, cookie:<name>, user_id, ip)", hashOn)
+}
+
+// validateRetryBudget 验证重试预算配置
+func validateRetryBudget(config *conf.RetryBudgetConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Percent < 0 || config.Percent > 100 {
+		return fmt.Errorf("重试百分比必须在0-100之间")
+	}
+	if config.MinRetriesPerSecond < 0 {
+		return fmt.Errorf("每秒最少重试次数不能为负数")
+	}
+	if config.Window < 0 {
+		return fmt.Errorf("统计时间窗口不能为负数")
+	}
+	return nil
+}
+
+// validateConcurrency 验证并发限制配置
+func validateConcurrency(config *conf.ConcurrencyConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.MaxConcurrency < 0 {
+		return fmt.Errorf("最大并发数不能为负数")
+	}
+	if config.QueueSize < 0 {
+		return fmt.Errorf("等待队列长度不能为负数")
+	}
+	if config.QueueTimeout < 0 {
+		return fmt.Errorf("排队超时时间不能为负数")
+	}
+	if config.MaxConcurrency == 0 && config.QueueSize > 0 {
+		return fmt.Errorf("未设置最大并发数时不能配置等待队列")
+	}
+	return nil
+}
+
+// validateHeaderTransform 验证请求头/响应头转换配置
+func validateHeaderTransform(transform *conf.HeaderTransformConfig) error {
+	if transform == nil {
+		return nil
+	}
+
+	for _, values := range []map[string]string{transform.Set, transform.Add} {
+		for name, value := range values {
+			if name == "" {
+				return fmt.Errorf("请求头名称不能为空")
+			}
+			if err := validateHeaderTemplate(value); err != nil {
+				return fmt.Errorf("%w [%s]", err, name)
+			}
+		}
+	}
+	for _, name := range transform.Remove {
+		if name == "" {
+			return fmt.Errorf("请求头名称不能为空")
+		}
+	}
+	for from, to := range transform.Rename {
+		if from == "" || to == "" {
+			return fmt.Errorf("重命名的请求头名称不能为空")
+		}
+	}
+
+	return nil
+}
+
+// validateTrafficSplit 验证流量拆分配置
+func validateTrafficSplit(split *conf.TrafficSplitConfig) error {
+	if len(split.Targets) == 0 {
+		return fmt.Errorf("目标版本列表不能为空")
+	}
+
+	totalWeight := 0
+	versions := make(map[string]bool, len(split.Targets))
+	for _, target := range split.Targets {
+		if target.Version == "" {
+			return fmt.Errorf("目标版本不能为空")
+		}
+		if versions[target.Version] {
+			return fmt.Errorf("目标版本重复: %s", target.Version)
+		}
+		versions[target.Version] = true
+		if target.Weight < 0 {
+			return fmt.Errorf("权重不能为负数 [版本 %s]", target.Version)
+		}
+		totalWeight += target.Weight
+	}
+	if totalWeight == 0 {
+		return fmt.Errorf("目标版本权重之和必须大于0")
+	}
+
+	switch split.Sticky {
+	case "", StickyUserID, StickyCookie:
+	default:
+		return fmt.Errorf("无效的粘性分配方式: %s (支持: user_id, cookie)", split.Sticky)
+	}
+
+	return nil
+}
+
+// validatePredicates 验证方法、Host、请求头和查询参数匹配条件
+func validatePredicates(route conf.RouteRule) error {
+	validMethods := map[string]bool{
+		"GET":     true,
+		"POST":    true,
+		"PUT":     true,
+		"DELETE":  true,
+		"PATCH":   true,
+		"OPTIONS": true,
+		"HEAD":    true,
+	}
+	for _, method := range route.Methods {
+		if !validMethods[strings.ToUpper(method)] {
+			return fmt.Errorf("无效的请求方法: %s", method)
+		}
+	}
+
+	if route.Host != "" && strings.ContainsAny(route.Host, "/ ") {
+		return fmt.Errorf("无效的 host: %s", route.Host)
+	}
+
+	for _, condition := range route.Headers {
+		if err := validateMatchCondition(condition); err != nil {
+			return fmt.Errorf("请求头匹配条件错误: %w", err)
+		}
+	}
+	for _, condition := range route.Query {
+		if err := validateMatchCondition(condition); err != nil {
+			return fmt.Errorf("查询参数匹配条件错误: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validateMatchCondition 验证单个匹配条件
+func validateMatchCondition(condition conf.RouteMatchCondition) error {
+	if condition.Name == "" {
+		return fmt.Errorf("名称不能为空")
+	}
+
+	switch condition.Type {
+	case "", ConditionExact:
+		if condition.Value == "" {
+			return fmt.Errorf("精确匹配的值不能为空 [%s]", condition.Name)
+		}
+	case ConditionRegex:
+		if _, err := compileRegex(condition.Value); err != nil {
+			return fmt.Errorf("无效的正则表达式 [%s]: %w", condition.Name, err)
+		}
+	case ConditionPresent:
+	default:
+		return fmt.Errorf("无效的匹配方式: %s (支持: exact, regex, present) [%s]", condition.Type, condition.Name)
+	}
+
+	return nil
+}
+
+// validateRewriteConfig 验证路径重写配置
+func validateRewriteConfig(route conf.RouteRule) error {
+	if route.Rewrite != "" {
+		if route.MatchType != "regex" {
+			return fmt.Errorf("rewrite 仅支持 regex 匹配类型")
+		}
+		if route.TargetPath != "" {
+			return fmt.Errorf("rewrite 与 target_path 不能同时配置")
+		}
+		if !strings.HasPrefix(route.Rewrite, "/") && !strings.HasPrefix(route.Rewrite, "$") {
+			return fmt.Errorf("rewrite 必须以 / 或捕获组引用开头")
+		}
+		if err := validateRewrite(route.Path, route.Rewrite); err != nil {
+			return err
+		}
+	}
+	if route.StripPrefix != "" && !strings.HasPrefix(route.StripPrefix, "/") {
+		return fmt.Errorf("strip_prefix 必须以 / 开头")
+	}
+	if route.AddPrefix != "" && !strings.HasPrefix(route.AddPrefix, "/") {
+		return fmt.Errorf("add_prefix 必须以 / 开头")
+	}
+	if route.HostRewrite != "" && strings.ContainsAny(route.HostRewrite, "/ \t") {
+		return fmt.Errorf("无效的 host_rewrite: %s (只能包含主机名和端口)", route.HostRewrite)
+	}
 	return nil
 }
 
@@ -208,6 +645,76 @@ func validateService(serviceName string, instances []conf.ServiceInstance) error
 	return nil
 }
 
+// validateAdmin 验证管理接口配置
+func validateAdmin(config *conf.AdminConfig) error {
+	if config == nil || !config.Enabled {
+		return nil
+	}
+	if config.Token == "" {
+		return fmt.Errorf("启用管理接口时必须配置访问令牌")
+	}
+	if config.Addr != "" {
+		if _, _, err := net.SplitHostPort(config.Addr); err != nil {
+			return fmt.Errorf("无效的监听地址 %s: %w", config.Addr, err)
+		}
+	}
+	return nil
+}
+
+// validateDiscovery 验证服务发现配置
+func validateDiscovery(config *conf.DiscoveryConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.SnapshotMaxAge < 0 {
+		return fmt.Errorf("快照最大有效期不能为负数")
+	}
+	switch config.Type {
+	case "", DiscoveryStatic, DiscoveryNacos:
+		return nil
+	case DiscoveryDNS:
+		if config.DNS == nil || len(config.DNS.Services) == 0 {
+			return fmt.Errorf("dns 服务发现必须配置服务的 DNS 记录")
+		}
+		if config.DNS.Timeout < 0 || config.DNS.MinRefresh < 0 || config.DNS.MaxRefresh < 0 {
+			return fmt.Errorf("超时时间和刷新间隔不能为负数")
+		}
+		if config.DNS.MinRefresh > 0 && config.DNS.MaxRefresh > 0 && config.DNS.MinRefresh > config.DNS.MaxRefresh {
+			return fmt.Errorf("min_refresh 不能大于 max_refresh")
+		}
+		for serviceName, service := range config.DNS.Services {
+			if service == nil || service.Name == "" {
+				return fmt.Errorf("服务 %s 的 DNS 域名不能为空", serviceName)
+			}
+			switch service.Type {
+			case "", discovery.DNSRecordSRV:
+			case discovery.DNSRecordA:
+				if service.Port <= 0 || service.Port > 65535 {
+					return fmt.Errorf("服务 %s 的端口号无效: %d (范围: 1-65535)", serviceName, service.Port)
+				}
+			default:
+				return fmt.Errorf("服务 %s 的 DNS 记录类型无效: %s (支持: srv, a)", serviceName, service.Type)
+			}
+		}
+		return nil
+	case DiscoveryFile:
+		if config.File == nil || config.File.Path == "" {
+			return fmt.Errorf("file 服务发现必须配置实例文件路径")
+		}
+		switch strings.ToLower(filepath.Ext(config.File.Path)) {
+		case ".json", ".yaml", ".yml":
+		default:
+			return fmt.Errorf("实例文件必须是 .json、.yaml 或 .yml 文件: %s", config.File.Path)
+		}
+		if config.File.Interval < 0 {
+			return fmt.Errorf("检查间隔不能为负数")
+		}
+		return nil
+	default:
+		return fmt.Errorf("无效的服务发现类型: %s (支持: static, nacos, dns, file)", config.Type)
+	}
+}
+
 // validateJWT 验证JWT配置
 func validateJWT(jwt *conf.JWTConfig) error {
 	if jwt.SecretKey == "" {
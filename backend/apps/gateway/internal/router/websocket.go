@@ -0,0 +1,253 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	stdHttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"StructForge/backend/apps/gateway/internal/router/loadbalancer"
+	"StructForge/backend/common/log"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// defaultWebSocketHandshakeTimeout WebSocket 握手默认超时时间
+const defaultWebSocketHandshakeTimeout = 10 * time.Second
+
+// IsWebSocketUpgrade 判断请求是否为 WebSocket 升级请求
+func IsWebSocketUpgrade(req *stdHttp.Request) bool {
+	return headerContainsToken(req.Header, "Connection", "upgrade") &&
+		strings.EqualFold(req.Header.Get("Upgrade"), "websocket")
+}
+
+// headerContainsToken 判断逗号分隔的请求头中是否包含指定的 token（不区分大小写）
+func headerContainsToken(header stdHttp.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ForwardWebSocket 建立 WebSocket 隧道
+// 连接选中的服务实例并转发握手请求，上游返回 101 后接管（Hijack）客户端连接，双向转发数据帧；
+// 上游拒绝升级时按普通响应写回客户端。限流、认证和指标由调用方在握手阶段处理，
+// onOpen 不为 nil 时在隧道建立后调用
+func (r *Router) ForwardWebSocket(ctx kratosHttp.Context, route *Route, onOpen func()) error {
+	// 选择服务实例
+	instance, done, err := r.selectInstance(ctx, route, r.splitVersion(ctx, route), nil)
+	if err != nil {
+		return err
+	}
+	// 隧道关闭后通知负载均衡器（隧道存续期间计为在途请求），延迟按握手耗时统计
+	var doneInfo loadbalancer.DoneInfo
+	defer func() {
+		done(doneInfo)
+	}()
+
+	address := net.JoinHostPort(instance.Host, strconv.Itoa(instance.Port))
+	targetPath := r.buildTargetPath(ctx, route)
+
+	log.Info(ctx, "转发 WebSocket 请求",
+		log.String("from", ctx.Request().URL.Path),
+		log.String("to", address+targetPath),
+		log.String("service", route.Service),
+	)
+
+	// 握手超时（连接建立后不再受路由超时限制）
+	handshakeTimeout := time.Duration(route.Timeout) * time.Second
+	if handshakeTimeout <= 0 {
+		handshakeTimeout = defaultWebSocketHandshakeTimeout
+	}
+	dialCtx, cancel := context.WithTimeout(ctx.Request().Context(), handshakeTimeout)
+	defer cancel()
+
+	// 连接上游实例
+	dialStart := time.Now()
+	dialer := &net.Dialer{}
+	upstreamConn, err := dialer.DialContext(dialCtx, "tcp", address)
+	if err != nil {
+		doneInfo = loadbalancer.DoneInfo{Err: err, Latency: time.Since(dialStart)}
+		return fmt.Errorf("连接上游 WebSocket 服务失败: %w", err)
+	}
+
+	// 转发握手请求
+	outReq := ctx.Request().Clone(dialCtx)
+	outReq.URL = &url.URL{
+		Scheme:   "http",
+		Host:     address,
+		Path:     targetPath,
+		RawQuery: ctx.Request().URL.RawQuery,
+	}
+	outReq.Host = address
+	setUpgradeHeaders(outReq.Header)
+	r.setIdentityHeaders(ctx.Request(), outReq, route.Service)
+	r.setForwardedHeaders(ctx.Request(), outReq.Header)
+	if route.RequestHeaders != nil {
+		applyHeaderTransform(outReq.Header, route.RequestHeaders, headerTemplateValues(ctx.Request()))
+	}
+	if route.HostRewrite != "" {
+		outReq.Host = route.HostRewrite
+	}
+	outReq.RequestURI = ""
+
+	upstreamConn.SetDeadline(time.Now().Add(handshakeTimeout))
+	if err := outReq.Write(upstreamConn); err != nil {
+		doneInfo = loadbalancer.DoneInfo{Err: err, Latency: time.Since(dialStart)}
+		upstreamConn.Close()
+		return fmt.Errorf("发送 WebSocket 握手请求失败: %w", err)
+	}
+
+	upstreamReader := bufio.NewReader(upstreamConn)
+	resp, err := stdHttp.ReadResponse(upstreamReader, outReq)
+	doneInfo = loadbalancer.DoneInfo{Err: err, Latency: time.Since(dialStart)}
+	if err != nil {
+		upstreamConn.Close()
+		return fmt.Errorf("读取 WebSocket 握手响应失败: %w", err)
+	}
+	upstreamConn.SetDeadline(time.Time{})
+
+	// 上游拒绝升级：按普通响应写回客户端
+	if resp.StatusCode != stdHttp.StatusSwitchingProtocols {
+		defer upstreamConn.Close()
+		defer resp.Body.Close()
+		removeHopByHopHeaders(resp.Header)
+		for key, values := range resp.Header {
+			for _, value := range values {
+				ctx.Response().Header().Add(key, value)
+			}
+		}
+		ctx.Response().WriteHeader(resp.StatusCode)
+		_, err := StreamCopy(ctx.Response(), resp.Body, nil)
+		if err != nil {
+			log.Warn(ctx, "写回 WebSocket 握手响应失败",
+				log.ErrorField(err),
+				log.Int("status_code", resp.StatusCode),
+			)
+		}
+		return nil
+	}
+
+	// 接管客户端连接
+	clientConn, clientBuf, err := stdHttp.NewResponseController(ctx.Response()).Hijack()
+	if err != nil {
+		upstreamConn.Close()
+		return fmt.Errorf("接管客户端连接失败: %w", err)
+	}
+
+	// 写回 101 握手响应
+	setUpgradeHeaders(resp.Header)
+	if err := writeSwitchingProtocols(clientBuf.Writer, resp); err != nil {
+		clientConn.Close()
+		upstreamConn.Close()
+		log.Warn(ctx, "写回 WebSocket 握手响应失败",
+			log.ErrorField(err),
+		)
+		return nil
+	}
+
+	log.Info(ctx, "WebSocket 隧道已建立",
+		log.String("service", route.Service),
+		log.String("instance", address),
+	)
+	if onOpen != nil {
+		onOpen()
+	}
+
+	// 双向转发数据帧，任意一端关闭后关闭两端连接
+	idleTimeout := time.Duration(route.IdleTimeout) * time.Second
+	startTime := time.Now()
+	var once sync.Once
+	closeBoth := func() {
+		once.Do(func() {
+			clientConn.Close()
+			upstreamConn.Close()
+		})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		tunnelCopy(upstreamConn, clientConn, clientBuf.Reader, idleTimeout)
+	}()
+	go func() {
+		defer wg.Done()
+		defer closeBoth()
+		tunnelCopy(clientConn, upstreamConn, upstreamReader, idleTimeout)
+	}()
+	wg.Wait()
+
+	log.Info(ctx, "WebSocket 隧道已关闭",
+		log.String("service", route.Service),
+		log.String("instance", address),
+		log.Duration("duration", time.Since(startTime)),
+	)
+
+	return nil
+}
+
+// setUpgradeHeaders 删除握手请求 / 响应中的逐跳请求头，只保留协议升级所需的 Connection 和 Upgrade
+func setUpgradeHeaders(header stdHttp.Header) {
+	upgrade := header.Get("Upgrade")
+	removeHopByHopHeaders(header)
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", upgrade)
+}
+
+// writeSwitchingProtocols 写回 101 Switching Protocols 握手响应
+func writeSwitchingProtocols(w *bufio.Writer, resp *stdHttp.Response) error {
+	if _, err := fmt.Fprintf(w, "HTTP/1.1 %s\r\n", resp.Status); err != nil {
+		return err
+	}
+	if err := resp.Header.Write(w); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// tunnelCopy 从 src 向 dst 转发数据
+// buffered 为 src 上已被缓冲读取的数据，优先转发；idle 大于 0 时，超过此时间未收到数据则断开
+func tunnelCopy(dst net.Conn, src net.Conn, buffered *bufio.Reader, idle time.Duration) {
+	if n := buffered.Buffered(); n > 0 {
+		data, _ := buffered.Peek(n)
+		if _, err := dst.Write(data); err != nil {
+			return
+		}
+		buffered.Discard(n)
+	}
+
+	if idle <= 0 {
+		io.Copy(dst, src)
+		return
+	}
+
+	buf := make([]byte, streamBufferSize)
+	for {
+		src.SetReadDeadline(time.Now().Add(idle))
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, writeErr := dst.Write(buf[:n]); writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
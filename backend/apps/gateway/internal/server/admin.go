@@ -0,0 +1,68 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+	"StructForge/backend/common/log"
+
+	"github.com/go-kratos/kratos/v2/middleware/recovery"
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// defaultAdminAddr 管理接口默认监听地址（只允许本机访问）
+const defaultAdminAddr = "127.0.0.1:9001"
+
+// AdminServer 管理接口 HTTP 服务器（独立于网关业务端口）
+type AdminServer struct {
+	*kratosHttp.Server
+}
+
+// NewAdminServer 创建管理接口 HTTP 服务器（未启用管理接口时返回 nil）
+func NewAdminServer(c *conf.Bootstrap) *AdminServer {
+	if c.Gateway == nil || c.Gateway.Admin == nil || !c.Gateway.Admin.Enabled {
+		return nil
+	}
+	config := c.Gateway.Admin
+
+	addr := config.Addr
+	if addr == "" {
+		addr = defaultAdminAddr
+	}
+
+	srv := kratosHttp.NewServer(
+		kratosHttp.Address(addr),
+		kratosHttp.Middleware(recovery.Recovery()),
+		kratosHttp.Filter(adminAuthFilter(config.Token)),
+	)
+	return &AdminServer{Server: srv}
+}
+
+// adminAuthFilter 校验管理接口访问令牌（Authorization: Bearer <token>）
+// 令牌按常量时间比较；未配置令牌时拒绝所有请求
+func adminAuthFilter(token string) kratosHttp.FilterFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				log.Warn(r.Context(), "管理接口认证失败",
+					log.String("method", r.Method),
+					log.String("path", r.URL.Path),
+					log.String("remote_addr", r.RemoteAddr),
+				)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gateway-admin"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"code":    http.StatusUnauthorized,
+					"message": "需要认证",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"StructForge/backend/apps/gateway/internal/conf"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+)
+
+// TestNewAdminServer 测试未启用管理接口时不创建服务器
+func TestNewAdminServer(t *testing.T) {
+	configs := []*conf.Bootstrap{
+		{},
+		{Gateway: &conf.GatewayConfig{}},
+		{Gateway: &conf.GatewayConfig{Admin: &conf.AdminConfig{Token: "secret"}}},
+	}
+	for _, c := range configs {
+		if NewAdminServer(c) != nil {
+			t.Errorf("未启用管理接口时不应该创建服务器: %+v", c.Gateway)
+		}
+	}
+}
+
+// TestAdminAuthFilter 测试管理接口访问令牌校验
+func TestAdminAuthFilter(t *testing.T) {
+	srv := NewAdminServer(&conf.Bootstrap{Gateway: &conf.GatewayConfig{
+		Admin: &conf.AdminConfig{Enabled: true, Token: "admin-secret"},
+	}})
+	if srv == nil {
+		t.Fatal("启用管理接口时应该创建服务器")
+	}
+	srv.Route("/admin").GET("/routes", func(ctx kratosHttp.Context) error {
+		return ctx.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"缺少令牌", "", http.StatusUnauthorized},
+		{"错误令牌", "Bearer wrong-secret", http.StatusUnauthorized},
+		{"令牌前缀", "Bearer admin", http.StatusUnauthorized},
+		{"令牌超长", "Bearer admin-secret-extra", http.StatusUnauthorized},
+		{"非 Bearer 认证", "Basic admin-secret", http.StatusUnauthorized},
+		{"空令牌", "Bearer ", http.StatusUnauthorized},
+		{"正确令牌", "Bearer admin-secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/routes", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("应该返回 %d，实际 %d", tt.status, w.Code)
+			}
+			if tt.status == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("认证失败时应该返回 WWW-Authenticate 响应头")
+			}
+		})
+	}
+
+	// 未配置令牌时拒绝所有请求
+	handler := adminAuthFilter("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/admin/routes", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("未配置令牌时应该返回 401，实际 %d", w.Code)
+	}
+}
@@ -122,5 +122,8 @@ func NewHTTPServer(c *conf.Bootstrap, corsHandler *corsMiddleware.CORSHandler) *
 
 	opts = append(opts, kratosHttp.Address(addr))
 
+	// 关闭 Kratos 默认的 1 秒请求超时，超时由路由配置控制（SSE 等长连接使用空闲超时）
+	opts = append(opts, kratosHttp.Timeout(0))
+
 	return kratosHttp.NewServer(opts...)
 }
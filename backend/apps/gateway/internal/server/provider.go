@@ -5,4 +5,4 @@ import (
 )
 
 // ProviderSet 是 server 模块的依赖注入提供者集合
-var ProviderSet = wire.NewSet(NewHTTPServer)
+var ProviderSet = wire.NewSet(NewHTTPServer, NewAdminServer)
@@ -60,6 +60,54 @@ func getConfigPath() string {
 	return configPath
 }
 
+// newNacosStartupConfig 将配置文件中的 Nacos 配置转换为 Nacos 客户端需要的配置格式
+func newNacosStartupConfig(nacos *conf.Nacos) *nacosClient.StartupConfig {
+	startupConfig := &nacosClient.StartupConfig{}
+	if nacos == nil {
+		return startupConfig
+	}
+
+	// 转换服务器配置
+	serverConfigs := make([]nacosClient.ServerConfig, 0, len(nacos.ServerConfigs))
+	for _, sc := range nacos.ServerConfigs {
+		serverConfigs = append(serverConfigs, nacosClient.ServerConfig{
+			IpAddr:      sc.IpAddr,
+			Port:        sc.Port,
+			ContextPath: sc.ContextPath,
+			Scheme:      sc.Scheme,
+		})
+	}
+	startupConfig.Nacos.ServerConfigs = serverConfigs
+
+	// 转换客户端配置
+	if nacos.ClientConfig != nil {
+		startupConfig.Nacos.ClientConfig = nacosClient.ClientConfig{
+			NamespaceId:         nacos.ClientConfig.NamespaceId,
+			TimeoutMs:           nacos.ClientConfig.TimeoutMs,
+			NotLoadCacheAtStart: nacos.ClientConfig.NotLoadCacheAtStart,
+			LogDir:              nacos.ClientConfig.LogDir,
+			CacheDir:            nacos.ClientConfig.CacheDir,
+			LogLevel:            nacos.ClientConfig.LogLevel,
+			Username:            nacos.ClientConfig.Username,
+			Password:            nacos.ClientConfig.Password,
+			AccessKey:           nacos.ClientConfig.AccessKey,
+			SecretKey:           nacos.ClientConfig.SecretKey,
+		}
+	}
+
+	// 转换配置中心配置
+	if nacos.ConfigCenter != nil {
+		startupConfig.Nacos.ConfigCenter = nacosClient.ConfigCenter{
+			Enabled:   nacos.ConfigCenter.Enabled,
+			DataId:    nacos.ConfigCenter.DataId,
+			Group:     nacos.ConfigCenter.Group,
+			Namespace: nacos.ConfigCenter.Namespace,
+		}
+	}
+
+	return startupConfig
+}
+
 func main() {
 	flag.Parse()
 
@@ -121,50 +169,10 @@ func main() {
 	if bc.Nacos != nil && bc.Nacos.ConfigCenter != nil && bc.Nacos.ConfigCenter.Enabled {
 		log.Info(ctx, "正在创建 Nacos 配置客户端")
 
-		// 转换服务器配置
-		serverConfigs := make([]nacosClient.ServerConfig, 0, len(bc.Nacos.ServerConfigs))
-		for _, sc := range bc.Nacos.ServerConfigs {
-			serverConfigs = append(serverConfigs, nacosClient.ServerConfig{
-				IpAddr:      sc.IpAddr,
-				Port:        sc.Port,
-				ContextPath: sc.ContextPath,
-				Scheme:      sc.Scheme,
-			})
-		}
-
-		// 转换客户端配置
-		clientConfig := nacosClient.ClientConfig{
-			NamespaceId:         bc.Nacos.ClientConfig.NamespaceId,
-			TimeoutMs:           bc.Nacos.ClientConfig.TimeoutMs,
-			NotLoadCacheAtStart: bc.Nacos.ClientConfig.NotLoadCacheAtStart,
-			LogDir:              bc.Nacos.ClientConfig.LogDir,
-			CacheDir:            bc.Nacos.ClientConfig.CacheDir,
-			LogLevel:            bc.Nacos.ClientConfig.LogLevel,
-			Username:            bc.Nacos.ClientConfig.Username,
-			Password:            bc.Nacos.ClientConfig.Password,
-			AccessKey:           bc.Nacos.ClientConfig.AccessKey,
-			SecretKey:           bc.Nacos.ClientConfig.SecretKey,
-		}
-
-		// 转换配置中心配置
-		configCenter := nacosClient.ConfigCenter{
-			Enabled:   bc.Nacos.ConfigCenter.Enabled,
-			DataId:    bc.Nacos.ConfigCenter.DataId,
-			Group:     bc.Nacos.ConfigCenter.Group,
-			Namespace: bc.Nacos.ConfigCenter.Namespace,
-		}
-
-		// 构建 StartupConfig（Nacos 客户端需要的配置格式）
-		startupConfig := nacosClient.StartupConfig{
-			Nacos: nacosClient.NacosConfig{
-				ServerConfigs: serverConfigs,
-				ClientConfig:  clientConfig,
-				ConfigCenter:  configCenter,
-			},
-		}
+		startupConfig := newNacosStartupConfig(bc.Nacos)
 
 		// 创建 Nacos 配置客户端
-		configClient, err := nacosClient.NewNacosConfigClient(&startupConfig)
+		configClient, err := nacosClient.NewNacosConfigClient(startupConfig)
 		if err != nil {
 			log.Error(ctx, "Nacos 配置客户端创建失败",
 				log.ErrorField(err),
@@ -21,6 +21,10 @@ import (
 	"StructForge/backend/common/data/database"
 	"StructForge/backend/common/email"
 	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
+	"StructForge/backend/common/middleware/identity"
+	nacosClient "StructForge/backend/common/middleware/nacos"
+	"StructForge/backend/common/registry"
 )
 
 // wireApp 初始化应用（Wire 会自动生成 wire_gen.go）
@@ -31,6 +35,10 @@ func wireApp(bc *conf.Bootstrap) (*kratos.App, func(), error) {
 		// JWT 配置提供者
 		jwtSecretKeyProvider,
 		jwtTokenDurationProvider,
+		// 网关身份签名验证
+		identitySignerProvider,
+		// 客户端IP解析（信任配置的网关等代理）
+		clientIPResolverProvider,
 		// 邮件服务
 		emailProvider,
 		// 业务逻辑层（包含 JWT Manager）
@@ -41,6 +49,8 @@ func wireApp(bc *conf.Bootstrap) (*kratos.App, func(), error) {
 		server.ProviderSet,
 		// 数据库
 		databaseProvider,
+		// 服务注册
+		registrarProvider,
 		// 日志
 		logProvider,
 		// 应用
@@ -74,6 +84,24 @@ func jwtTokenDurationProvider() time.Duration {
 	return 24 * time.Hour
 }
 
+// identitySignerProvider 提供网关身份签名验证器（密钥需与网关 jwt.internal_secret 一致）
+// 未配置 auth.internal_secret 时返回 nil，不信任网关传递的内部身份请求头，只通过 Bearer Token 认证
+func identitySignerProvider(bc *conf.Bootstrap) *identity.Signer {
+	if bc.Auth == nil || bc.Auth.InternalSecret == "" {
+		log.Warn(context.Background(), "未配置 auth.internal_secret，不信任网关传递的内部身份请求头")
+		return nil
+	}
+	return identity.NewSigner(bc.Auth.InternalSecret)
+}
+
+// clientIPResolverProvider 提供客户端IP解析器（信任配置的 trusted_proxies，未配置时只信任本机）
+func clientIPResolverProvider(bc *conf.Bootstrap) (*clientip.Resolver, error) {
+	if len(bc.TrustedProxies) == 0 {
+		return clientip.NewResolver(clientip.DefaultTrustedProxies)
+	}
+	return clientip.NewResolver(bc.TrustedProxies)
+}
+
 // logProvider 提供日志实例（返回 Kratos 兼容的日志接口）
 func logProvider() kratosLog.Logger {
 	// 使用全局日志实例（通过包级别的函数）
@@ -175,13 +203,40 @@ func databaseProvider() (database.Database, func(), error) {
 	return db, func() {}, nil
 }
 
+// registrarProvider 提供服务注册器（未启用服务注册时返回 nil）
+func registrarProvider(bc *conf.Bootstrap) (*registry.Registrar, error) {
+	if bc.Registry == nil || !bc.Registry.Enabled {
+		return nil, nil
+	}
+
+	// 使用 Nacos 作为服务注册中心
+	namingClient, err := nacosClient.NewNacosNamingClient(newNacosStartupConfig(bc.Nacos))
+	if err != nil {
+		return nil, fmt.Errorf("创建 Nacos 命名客户端失败: %w", err)
+	}
+
+	// 网关按服务名（默认 server.id）路由到本服务
+	serviceName := server.ServiceName(bc)
+	return registry.NewRegistrar(registry.NewNacosRegistry(namingClient, bc.Registry.Group), &registry.Config{
+		ServiceName:       serviceName,
+		Host:              bc.Registry.Host,
+		Region:            bc.Registry.Region,
+		Weight:            int(bc.Registry.Weight),
+		Metadata:          bc.Registry.Metadata,
+		HeartbeatInterval: time.Duration(bc.Registry.HeartbeatInterval) * time.Second,
+		DeregisterDelay:   time.Duration(bc.Registry.DeregisterDelay) * time.Second,
+	}), nil
+}
+
 // newApp 创建应用实例
+// 启用服务注册时，Kratos 在服务器启动后注册实例，在停止服务器之前注销实例
 func newApp(
 	logger kratosLog.Logger,
 	grpcServer *server.GRPCServer,
 	httpServer *server.HTTPServer,
+	registrar *registry.Registrar,
 ) *kratos.App {
-	return kratos.New(
+	options := []kratos.Option{
 		kratos.Name("user"),
 		kratos.Version("v1.0.0"),
 		kratos.Logger(logger),
@@ -189,7 +244,11 @@ func newApp(
 			grpcServer,
 			httpServer,
 		),
-	)
+	}
+	if registrar != nil {
+		options = append(options, kratos.Registrar(registrar))
+	}
+	return kratos.New(options...)
 }
 
 // 注意：需要运行以下命令生成 wire_gen.go：
@@ -15,6 +15,10 @@ import (
 	"StructForge/backend/common/data/database"
 	"StructForge/backend/common/email"
 	log2 "StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
+	"StructForge/backend/common/middleware/identity"
+	"StructForge/backend/common/middleware/nacos"
+	"StructForge/backend/common/registry"
 	"context"
 	"fmt"
 	"github.com/go-kratos/kratos/v2"
@@ -46,9 +50,22 @@ func wireApp(bc *conf.Bootstrap) (*kratos.App, func(), error) {
 	duration := jwtTokenDurationProvider()
 	jwtManager := biz.NewJWTManager(string2, duration)
 	userService := service.NewUserService(userUseCase, jwtManager)
-	grpcServer := server.NewGRPCServer(bc, userService)
-	httpServer := server.NewHTTPServer(bc, userService)
-	app := newApp(logger, grpcServer, httpServer)
+	signer := identitySignerProvider(bc)
+	resolver, err := clientIPResolverProvider(bc)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	grpcServer := server.NewGRPCServer(bc, userService, jwtManager, signer, resolver)
+	httpServer := server.NewHTTPServer(bc, userService, jwtManager, signer, resolver)
+	registrar, err := registrarProvider(bc)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	app := newApp(logger, grpcServer, httpServer, registrar)
 	return app, func() {
 		cleanup2()
 		cleanup()
@@ -77,6 +94,24 @@ func jwtTokenDurationProvider() time.Duration {
 	return 24 * time.Hour
 }
 
+// identitySignerProvider 提供网关身份签名验证器（密钥需与网关 jwt.internal_secret 一致）
+// 未配置 auth.internal_secret 时返回 nil，不信任网关传递的内部身份请求头，只通过 Bearer Token 认证
+func identitySignerProvider(bc *conf.Bootstrap) *identity.Signer {
+	if bc.Auth == nil || bc.Auth.InternalSecret == "" {
+		log2.Warn(context.Background(), "未配置 auth.internal_secret，不信任网关传递的内部身份请求头")
+		return nil
+	}
+	return identity.NewSigner(bc.Auth.InternalSecret)
+}
+
+// clientIPResolverProvider 提供客户端IP解析器（信任配置的 trusted_proxies，未配置时只信任本机）
+func clientIPResolverProvider(bc *conf.Bootstrap) (*clientip.Resolver, error) {
+	if len(bc.TrustedProxies) == 0 {
+		return clientip.NewResolver(clientip.DefaultTrustedProxies)
+	}
+	return clientip.NewResolver(bc.TrustedProxies)
+}
+
 // logProvider 提供日志实例（返回 Kratos 兼容的日志接口）
 func logProvider() log.Logger {
 
@@ -172,15 +207,44 @@ func databaseProvider() (database.Database, func(), error) {
 	return db, func() {}, nil
 }
 
+// registrarProvider 提供服务注册器（未启用服务注册时返回 nil）
+func registrarProvider(bc *conf.Bootstrap) (*registry.Registrar, error) {
+	if bc.Registry == nil || !bc.Registry.Enabled {
+		return nil, nil
+	}
+
+	namingClient, err := nacos.NewNacosNamingClient(newNacosStartupConfig(bc.Nacos))
+	if err != nil {
+		return nil, fmt.Errorf("创建 Nacos 命名客户端失败: %w", err)
+	}
+
+	serviceName := server.ServiceName(bc)
+	return registry.NewRegistrar(registry.NewNacosRegistry(namingClient, bc.Registry.Group), &registry.Config{
+		ServiceName:       serviceName,
+		Host:              bc.Registry.Host,
+		Region:            bc.Registry.Region,
+		Weight:            int(bc.Registry.Weight),
+		Metadata:          bc.Registry.Metadata,
+		HeartbeatInterval: time.Duration(bc.Registry.HeartbeatInterval) * time.Second,
+		DeregisterDelay:   time.Duration(bc.Registry.DeregisterDelay) * time.Second,
+	}), nil
+}
+
 // newApp 创建应用实例
+// 启用服务注册时，Kratos 在服务器启动后注册实例，在停止服务器之前注销实例
 func newApp(
 	logger log.Logger,
 	grpcServer *server.GRPCServer,
 	httpServer *server.HTTPServer,
+	registrar *registry.Registrar,
 ) *kratos.App {
-	return kratos.New(kratos.Name("user"), kratos.Version("v1.0.0"), kratos.Logger(logger), kratos.Server(
+	options := []kratos.Option{kratos.Name("user"), kratos.Version("v1.0.0"), kratos.Logger(logger), kratos.Server(
 		grpcServer,
 		httpServer,
 	),
-	)
+	}
+	if registrar != nil {
+		options = append(options, kratos.Registrar(registrar))
+	}
+	return kratos.New(options...)
 }
@@ -14,8 +14,9 @@ var (
 
 // JWTClaims JWT 声明
 type JWTClaims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"username"`
+	UserID   int64    `json:"user_id"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles,omitempty"`
 	jwt.RegisteredClaims
 }
 
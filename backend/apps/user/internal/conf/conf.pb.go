@@ -12,6 +12,12 @@ type Bootstrap struct {
 	Database *Database `protobuf:"bytes,2,opt,name=database,proto3" json:"database,omitempty"`
 	// Nacos 配置
 	Nacos *Nacos `protobuf:"bytes,3,opt,name=nacos,proto3" json:"nacos,omitempty"`
+	// 服务注册配置
+	Registry *Registry `protobuf:"bytes,4,opt,name=registry,proto3" json:"registry,omitempty"`
+	// 认证配置
+	Auth *Auth `protobuf:"bytes,5,opt,name=auth,proto3" json:"auth,omitempty"`
+	// 可信代理（CIDR 或 IP），只有直连地址属于可信代理时才信任转发请求头解析客户端IP（为空时只信任本机）
+	TrustedProxies []string `protobuf:"bytes,6,rep,name=trusted_proxies,json=trustedProxies,proto3" json:"trusted_proxies,omitempty"`
 }
 
 // Server 服务器配置
@@ -89,3 +95,31 @@ type NacosConfigCenter struct {
 	Group     string `protobuf:"bytes,3,opt,name=group,proto3" json:"group,omitempty"`
 	Namespace string `protobuf:"bytes,4,opt,name=namespace,proto3" json:"namespace,omitempty"`
 }
+
+// Registry 服务注册配置（启动后将 HTTP/gRPC 端点注册到 Nacos，停止前注销）
+type Registry struct {
+	// 是否启用
+	Enabled bool `protobuf:"varint,1,opt,name=enabled,proto3" json:"enabled,omitempty"`
+	// 注册的服务名（为空时使用 server.id）
+	ServiceName string `protobuf:"bytes,2,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	// Nacos 分组（为空时使用 DEFAULT_GROUP）
+	Group string `protobuf:"bytes,3,opt,name=group,proto3" json:"group,omitempty"`
+	// 对外公布的主机地址（为空时使用监听地址解析出的地址）
+	Host string `protobuf:"bytes,4,opt,name=host,proto3" json:"host,omitempty"`
+	// 区域
+	Region string `protobuf:"bytes,5,opt,name=region,proto3" json:"region,omitempty"`
+	// 权重（默认100）
+	Weight int32 `protobuf:"varint,6,opt,name=weight,proto3" json:"weight,omitempty"`
+	// 附加元数据
+	Metadata map[string]string `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// 心跳续约间隔（秒，默认30）
+	HeartbeatInterval int64 `protobuf:"varint,8,opt,name=heartbeat_interval,json=heartbeatInterval,proto3" json:"heartbeat_interval,omitempty"`
+	// 注销后等待网关刷新实例列表的时间（秒，默认3）
+	DeregisterDelay int64 `protobuf:"varint,9,opt,name=deregister_delay,json=deregisterDelay,proto3" json:"deregister_delay,omitempty"`
+}
+
+// Auth 认证配置
+type Auth struct {
+	// 网关身份签名密钥（需与网关 jwt.internal_secret 一致，为空时不信任网关传递的内部身份请求头）
+	InternalSecret string `protobuf:"bytes,1,opt,name=internal_secret,json=internalSecret,proto3" json:"internal_secret,omitempty"`
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+
+	"StructForge/backend/apps/user/internal/biz"
+	"StructForge/backend/apps/user/internal/conf"
+	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/identity"
+)
+
+// Auth 认证中间件
+// 优先使用网关签名的内部身份请求头（签名需绑定本服务及当前请求的方法和路径），其次验证 Authorization 中的 Bearer Token（直连访问），
+// 认证成功后将身份信息存入 Context；未认证的请求继续处理，由具体接口决定是否需要认证
+// signer 为空时（未配置 auth.internal_secret）不信任任何内部身份请求头
+func Auth(jwtManager *biz.JWTManager, signer *identity.Signer, serviceName string) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+
+			if id := authenticate(ctx, tr, jwtManager, signer, serviceName); id != nil {
+				ctx = identity.NewContext(ctx, id)
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// authenticate 从请求头中解析用户身份
+func authenticate(ctx context.Context, tr transport.Transporter, jwtManager *biz.JWTManager, signer *identity.Signer, serviceName string) *identity.Identity {
+	header := tr.RequestHeader()
+
+	// 网关签名的身份信息（网关只通过 HTTP 转发请求）
+	if ht, ok := tr.(kratosHttp.Transporter); ok && signer != nil && header.Get(identity.HeaderSignature) != "" {
+		id, err := signer.Verify(header, identity.Target{
+			Service: serviceName,
+			Method:  ht.Request().Method,
+			Path:    ht.Request().URL.Path,
+		})
+		if err == nil {
+			return id
+		}
+		log.Warn(ctx, "内部身份签名验证失败",
+			log.String("path", ht.Request().URL.Path),
+			log.ErrorField(err),
+		)
+	}
+
+	// Bearer Token
+	if jwtManager == nil {
+		return nil
+	}
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	claims, err := jwtManager.ValidateToken(token)
+	if err != nil {
+		log.Debug(ctx, "Token 验证失败",
+			log.ErrorField(err),
+		)
+		return nil
+	}
+
+	return &identity.Identity{
+		UserID:   claims.UserID,
+		Username: claims.Username,
+		Roles:    claims.Roles,
+	}
+}
+
+// ServiceName 获取服务名（注册到服务中心以及网关路由使用的名称，为空时使用 server.id）
+func ServiceName(c *conf.Bootstrap) string {
+	if c.Registry != nil && c.Registry.ServiceName != "" {
+		return c.Registry.ServiceName
+	}
+	if c.Server != nil {
+		return c.Server.Id
+	}
+	return ""
+}
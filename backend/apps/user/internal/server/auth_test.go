@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+
+	"StructForge/backend/apps/user/internal/biz"
+	"StructForge/backend/common/middleware/identity"
+)
+
+// newAuthTestServer 创建使用认证中间件的测试服务器，/whoami 返回认证得到的用户ID（未认证时为 0）
+func newAuthTestServer(t *testing.T, jwtManager *biz.JWTManager, signer *identity.Signer) *httptest.Server {
+	srv := kratosHttp.NewServer(kratosHttp.Middleware(Auth(jwtManager, signer, "user-service")))
+	srv.Route("/").GET("/whoami", func(ctx kratosHttp.Context) error {
+		handler := ctx.Middleware(func(ctx context.Context, req interface{}) (interface{}, error) {
+			var userID int64
+			if id, ok := identity.FromContext(ctx); ok {
+				userID = id.UserID
+			}
+			return map[string]int64{"user_id": userID}, nil
+		})
+		out, err := handler(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(200, out)
+	})
+
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// whoami 请求 /whoami 并返回认证得到的用户ID
+func whoami(t *testing.T, ts *httptest.Server, header http.Header) int64 {
+	req, _ := http.NewRequest("GET", ts.URL+"/whoami", nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		UserID int64 `json:"user_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	return body.UserID
+}
+
+// TestAuthInternalIdentity 测试只信任网关签名且绑定当前请求的内部身份请求头
+func TestAuthInternalIdentity(t *testing.T) {
+	signer := identity.NewSigner("test-secret")
+	ts := newAuthTestServer(t, nil, signer)
+	target := identity.Target{Service: "user-service", Method: "GET", Path: "/whoami"}
+
+	// 未签名的身份请求头
+	header := http.Header{}
+	header.Set(identity.HeaderUserID, "1")
+	header.Set(identity.HeaderRoles, "admin")
+	if userID := whoami(t, ts, header); userID != 0 {
+		t.Errorf("未签名的身份请求头不应该被信任，实际用户ID %d", userID)
+	}
+
+	// 伪造的签名
+	header.Set(identity.HeaderTimestamp, "0")
+	header.Set(identity.HeaderSignature, "forged")
+	if userID := whoami(t, ts, header); userID != 0 {
+		t.Errorf("伪造签名的身份请求头不应该被信任，实际用户ID %d", userID)
+	}
+
+	// 为其他接口签名的身份请求头不能重放
+	header = http.Header{}
+	signer.Sign(header, identity.Target{Service: "user-service", Method: "GET", Path: "/admin"}, &identity.Identity{UserID: 42})
+	if userID := whoami(t, ts, header); userID != 0 {
+		t.Errorf("为其他接口签名的身份请求头不应该被信任，实际用户ID %d", userID)
+	}
+
+	// 网关签名的身份请求头
+	header = http.Header{}
+	signer.Sign(header, target, &identity.Identity{UserID: 42, Username: "alice"})
+	if userID := whoami(t, ts, header); userID != 42 {
+		t.Errorf("网关签名的身份请求头应该被信任，实际用户ID %d", userID)
+	}
+
+	// 未配置签名密钥时不信任任何内部身份请求头
+	if userID := whoami(t, newAuthTestServer(t, nil, nil), header); userID != 0 {
+		t.Errorf("未配置签名密钥时不应该信任内部身份请求头，实际用户ID %d", userID)
+	}
+}
+
+// TestAuthBearerToken 测试直连访问时验证 Bearer Token
+func TestAuthBearerToken(t *testing.T) {
+	jwtManager := biz.NewJWTManager("jwt-secret", time.Hour)
+	ts := newAuthTestServer(t, jwtManager, nil)
+
+	token, err := jwtManager.GenerateToken(7, "bob")
+	if err != nil {
+		t.Fatalf("生成 Token 失败: %v", err)
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	if userID := whoami(t, ts, header); userID != 7 {
+		t.Errorf("有效 Token 应该认证通过，实际用户ID %d", userID)
+	}
+
+	header.Set("Authorization", "Bearer invalid")
+	if userID := whoami(t, ts, header); userID != 0 {
+		t.Errorf("无效 Token 不应该认证通过，实际用户ID %d", userID)
+	}
+}
@@ -7,18 +7,23 @@ import (
 	"github.com/go-kratos/kratos/v2/transport/grpc"
 
 	v1 "StructForge/backend/api/user/v1"
+	"StructForge/backend/apps/user/internal/biz"
 	"StructForge/backend/apps/user/internal/conf"
 	"StructForge/backend/apps/user/internal/service"
+	"StructForge/backend/common/middleware/clientip"
+	"StructForge/backend/common/middleware/identity"
 )
 
 // GRPCServer gRPC 服务器类型别名
 type GRPCServer = grpc.Server
 
 // NewGRPCServer 创建 gRPC 服务器
-func NewGRPCServer(c *conf.Bootstrap, userService *service.UserService) *grpc.Server {
+func NewGRPCServer(c *conf.Bootstrap, userService *service.UserService, jwtManager *biz.JWTManager, signer *identity.Signer, resolver *clientip.Resolver) *grpc.Server {
 	var opts = []grpc.ServerOption{
 		grpc.Middleware(
 			recovery.Recovery(),
+			clientip.Server(resolver),
+			Auth(jwtManager, signer, ServiceName(c)),
 		),
 	}
 
@@ -7,19 +7,24 @@ import (
 	"github.com/go-kratos/kratos/v2/transport/http"
 
 	v1 "StructForge/backend/api/user/v1"
+	"StructForge/backend/apps/user/internal/biz"
 	"StructForge/backend/apps/user/internal/conf"
 	"StructForge/backend/apps/user/internal/handler"
 	"StructForge/backend/apps/user/internal/service"
+	"StructForge/backend/common/middleware/clientip"
+	"StructForge/backend/common/middleware/identity"
 )
 
 // HTTPServer HTTP 服务器类型别名
 type HTTPServer = http.Server
 
 // NewHTTPServer 创建 HTTP 服务器（用于 HTTP Gateway）
-func NewHTTPServer(c *conf.Bootstrap, userService *service.UserService) *http.Server {
+func NewHTTPServer(c *conf.Bootstrap, userService *service.UserService, jwtManager *biz.JWTManager, signer *identity.Signer, resolver *clientip.Resolver) *http.Server {
 	var opts = []http.ServerOption{
 		http.Middleware(
 			recovery.Recovery(),
+			clientip.Server(resolver),
+			Auth(jwtManager, signer, ServiceName(c)),
 		),
 	}
 
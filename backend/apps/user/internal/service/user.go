@@ -12,6 +12,8 @@ import (
 	"StructForge/backend/apps/user/internal/biz"
 	"StructForge/backend/apps/user/internal/data"
 	"StructForge/backend/common/log"
+	"StructForge/backend/common/middleware/clientip"
+	"StructForge/backend/common/middleware/identity"
 )
 
 // UserService 用户服务实现
@@ -521,15 +523,17 @@ func (s *UserService) toProtoUserProfile(profile *data.UserProfile) *v1.UserProf
 	return pbProfile
 }
 
-// getUserIDFromContext 从 context 中获取用户ID
+// getUserIDFromContext 从 context 中获取用户ID（由认证中间件注入）
 func getUserIDFromContext(ctx context.Context) (int64, bool) {
-	// TODO: 从 context 中获取用户ID（需要中间件注入）
-	// 这里先返回一个示例值，实际应该从 JWT Token 中解析
-	return 0, false
+	id, ok := identity.FromContext(ctx)
+	if !ok {
+		return 0, false
+	}
+	return id.UserID, true
 }
 
-// getClientIP 从 context 中获取客户端IP
+// getClientIP 从 context 中获取客户端IP（由 clientip 中间件注入，未知时返回空字符串）
 func getClientIP(ctx context.Context) string {
-	// TODO: 从 context 中获取客户端IP（需要中间件注入）
-	return "127.0.0.1"
+	ip, _ := clientip.FromContext(ctx)
+	return ip
 }
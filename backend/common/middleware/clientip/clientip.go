@@ -0,0 +1,199 @@
+// Package clientip 客户端真实IP解析
+// 只有直连地址属于可信代理时才信任 X-Forwarded-For / X-Real-IP / Forwarded 请求头，
+// 从右向左跳过可信代理地址，第一个不可信的地址即为客户端IP，防止客户端伪造转发请求头
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+	kratosHttp "github.com/go-kratos/kratos/v2/transport/http"
+	"google.golang.org/grpc/peer"
+)
+
+// 转发请求头
+const (
+	HeaderXForwardedFor   = "X-Forwarded-For"
+	HeaderXForwardedProto = "X-Forwarded-Proto"
+	HeaderXForwardedHost  = "X-Forwarded-Host"
+	HeaderXRealIP         = "X-Real-IP"
+	HeaderForwarded       = "Forwarded"
+)
+
+// DefaultTrustedProxies 默认可信代理（只信任本机地址）
+// 不默认信任整个内网网段，否则同一网络中的任何服务都可以伪造转发请求头
+var DefaultTrustedProxies = []string{
+	"127.0.0.0/8",
+	"::1/128",
+}
+
+// HeaderReader 请求头读取接口（兼容 http.Header 和 Kratos transport.Header）
+type HeaderReader interface {
+	Get(key string) string
+	Values(key string) []string
+}
+
+// Resolver 客户端IP解析器
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver 创建客户端IP解析器
+// trustedProxies 为可信代理的 CIDR 或 IP 列表，为空时不信任任何转发请求头
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	r := &Resolver{
+		trusted: make([]*net.IPNet, 0, len(trustedProxies)),
+	}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的可信代理地址: %s", proxy)
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("无效的可信代理地址: %s", proxy)
+		}
+		r.trusted = append(r.trusted, ipNet)
+	}
+	return r, nil
+}
+
+// IsTrusted 判断地址是否为可信代理
+func (r *Resolver) IsTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, ipNet := range r.trusted {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 解析客户端真实IP
+// remoteAddr 为直连地址（host:port 或 IP），header 为请求头
+func (r *Resolver) ClientIP(remoteAddr string, header HeaderReader) string {
+	remote := HostOnly(remoteAddr)
+	if !r.IsTrusted(remote) {
+		return remote
+	}
+
+	// 转发链（从左到右依次为客户端、各级代理）
+	chain := forwardedFor(header)
+	if len(chain) == 0 {
+		if realIP := strings.TrimSpace(header.Get(HeaderXRealIP)); net.ParseIP(realIP) != nil {
+			return realIP
+		}
+		return remote
+	}
+
+	// 从右向左跳过可信代理
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !r.IsTrusted(chain[i]) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+// FromRequest 解析 HTTP 请求的客户端IP
+func (r *Resolver) FromRequest(req *http.Request) string {
+	return r.ClientIP(req.RemoteAddr, req.Header)
+}
+
+// forwardedFor 解析转发链（优先 X-Forwarded-For，其次 Forwarded 中的 for 参数），忽略无效地址
+func forwardedFor(header HeaderReader) []string {
+	chain := make([]string, 0)
+	if xff := JoinValues(header, HeaderXForwardedFor); xff != "" {
+		for _, part := range strings.Split(xff, ",") {
+			if ip := HostOnly(strings.TrimSpace(part)); net.ParseIP(ip) != nil {
+				chain = append(chain, ip)
+			}
+		}
+		return chain
+	}
+
+	if forwarded := JoinValues(header, HeaderForwarded); forwarded != "" {
+		for _, element := range strings.Split(forwarded, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+				value = strings.Trim(value, `"`)
+				if ip := HostOnly(value); net.ParseIP(ip) != nil {
+					chain = append(chain, ip)
+				}
+			}
+		}
+	}
+	return chain
+}
+
+// JoinValues 合并多行请求头为逗号分隔的列表
+// 代理可能追加新的请求头行而不是合并到已有行，只读取第一行会漏掉可信代理追加的地址
+func JoinValues(header HeaderReader, key string) string {
+	return strings.Join(header.Values(key), ", ")
+}
+
+// HostOnly 去除地址中的端口和 IPv6 方括号
+func HostOnly(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+// clientIPKey Context 中存储客户端IP的键
+type clientIPKey struct{}
+
+// NewContext 将客户端IP存入 Context
+func NewContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// FromContext 从 Context 中获取客户端IP
+func FromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey{}).(string)
+	return ip, ok && ip != ""
+}
+
+// Server 服务端中间件：解析客户端IP并存入 Context（支持 HTTP 和 gRPC）
+func Server(resolver *Resolver) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			if tr, ok := transport.FromServerContext(ctx); ok {
+				if ip := resolver.ClientIP(remoteAddr(ctx), tr.RequestHeader()); ip != "" {
+					ctx = NewContext(ctx, ip)
+				}
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// remoteAddr 获取直连地址
+func remoteAddr(ctx context.Context) string {
+	if req, ok := kratosHttp.RequestFromServerContext(ctx); ok {
+		return req.RemoteAddr
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
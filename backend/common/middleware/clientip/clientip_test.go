@@ -0,0 +1,156 @@
+package clientip
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestClientIP 测试根据可信代理解析客户端IP
+func TestClientIP(t *testing.T) {
+	resolver, err := NewResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("创建解析器失败: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     map[string][]string
+		want       string
+	}{
+		{
+			name:       "直连客户端",
+			remoteAddr: "203.0.113.7:51234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "直连 IPv6 客户端",
+			remoteAddr: "[2001:db8::7]:51234",
+			want:       "2001:db8::7",
+		},
+		{
+			name:       "可信代理链",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"X-Forwarded-For": {"198.51.100.9, 192.168.1.1"}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "客户端伪造最左侧 X-Forwarded-For",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.9"}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "可信代理追加新的 X-Forwarded-For 行",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"X-Forwarded-For": {"1.2.3.4", "198.51.100.9"}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "链上全部为可信代理",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"X-Forwarded-For": {"10.0.0.9, 192.168.1.1"}},
+			want:       "10.0.0.9",
+		},
+		{
+			name:       "忽略无效地址",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"X-Forwarded-For": {"198.51.100.9, unknown"}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "不可信直连地址携带转发请求头",
+			remoteAddr: "203.0.113.7:51234",
+			header: map[string][]string{
+				"X-Forwarded-For": {"1.2.3.4"},
+				"X-Real-Ip":       {"1.2.3.4"},
+				"Forwarded":       {"for=1.2.3.4"},
+			},
+			want: "203.0.113.7",
+		},
+		{
+			name:       "Forwarded 带引号的 IPv6 和端口",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"Forwarded": {`for="[2001:db8::1]:4711";proto=https, for=10.0.0.9`}},
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "Forwarded 带端口的 IPv4 和大小写混合参数名",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"Forwarded": {`For="198.51.100.9:8080";host=example.com`}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "Forwarded 多行",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"Forwarded": {"for=1.2.3.4", "for=198.51.100.9"}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "X-Forwarded-For 优先于 Forwarded",
+			remoteAddr: "10.0.0.5:51234",
+			header: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.9"},
+				"Forwarded":       {"for=198.51.100.10"},
+			},
+			want: "198.51.100.9",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"X-Real-Ip": {"198.51.100.9"}},
+			want:       "198.51.100.9",
+		},
+		{
+			name:       "无效的 X-Real-IP",
+			remoteAddr: "10.0.0.5:51234",
+			header:     map[string][]string{"X-Real-Ip": {"not-an-ip"}},
+			want:       "10.0.0.5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for key, values := range tt.header {
+				for _, value := range values {
+					header.Add(key, value)
+				}
+			}
+			if got := resolver.ClientIP(tt.remoteAddr, header); got != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNewResolver 测试可信代理地址解析
+func TestNewResolver(t *testing.T) {
+	resolver, err := NewResolver([]string{" 127.0.0.1 ", "::1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("创建解析器失败: %v", err)
+	}
+	for _, ip := range []string{"127.0.0.1", "::1", "10.1.2.3"} {
+		if !resolver.IsTrusted(ip) {
+			t.Errorf("%s 应该是可信代理", ip)
+		}
+	}
+	for _, ip := range []string{"127.0.0.2", "192.168.1.1", "invalid"} {
+		if resolver.IsTrusted(ip) {
+			t.Errorf("%s 不应该是可信代理", ip)
+		}
+	}
+
+	for _, proxy := range []string{"not-an-ip", "10.0.0.0/33"} {
+		if _, err := NewResolver([]string{proxy}); err == nil {
+			t.Errorf("无效地址 %s 应该返回错误", proxy)
+		}
+	}
+
+	// 未配置可信代理时不信任任何转发请求头
+	resolver, _ = NewResolver(nil)
+	header := http.Header{}
+	header.Set("X-Forwarded-For", "1.2.3.4")
+	if ip := resolver.ClientIP("127.0.0.1:8000", header); ip != "127.0.0.1" {
+		t.Errorf("未配置可信代理时应该使用直连地址，实际 %s", ip)
+	}
+}
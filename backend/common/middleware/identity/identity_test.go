@@ -0,0 +1,127 @@
+package identity
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestSignVerify 测试签名后验证得到相同的身份信息
+func TestSignVerify(t *testing.T) {
+	signer := NewSigner("test-secret")
+	target := Target{Service: "user-service", Method: "GET", Path: "/api/v1/users/me"}
+
+	header := http.Header{}
+	signer.Sign(header, target, &Identity{UserID: 42, Username: "张三 & co", Roles: []string{"admin", "user"}})
+
+	id, err := signer.Verify(header, target)
+	if err != nil {
+		t.Fatalf("签名应该验证通过: %v", err)
+	}
+	if id.UserID != 42 || id.Username != "张三 & co" || len(id.Roles) != 2 || id.Roles[0] != "admin" || id.Roles[1] != "user" {
+		t.Errorf("身份信息不正确: %+v", id)
+	}
+
+	// 没有角色时角色列表为空
+	header = http.Header{}
+	signer.Sign(header, target, &Identity{UserID: 1})
+	if id, err := signer.Verify(header, target); err != nil || len(id.Roles) != 0 {
+		t.Errorf("没有角色时应该验证通过且角色为空: %+v, %v", id, err)
+	}
+}
+
+// TestVerifyTampered 测试篡改身份信息或签名目标后验证失败
+func TestVerifyTampered(t *testing.T) {
+	signer := NewSigner("test-secret")
+	target := Target{Service: "user-service", Method: "GET", Path: "/api/v1/users/me"}
+
+	tests := []struct {
+		name   string
+		tamper func(header http.Header, target *Target)
+	}{
+		{"用户ID", func(h http.Header, _ *Target) { h.Set(HeaderUserID, "1") }},
+		{"用户名", func(h http.Header, _ *Target) { h.Set(HeaderUsername, "admin") }},
+		{"角色", func(h http.Header, _ *Target) { h.Set(HeaderRoles, "admin%2Csuperuser") }},
+		{"时间戳", func(h http.Header, _ *Target) { h.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix()+1, 10)) }},
+		{"签名", func(h http.Header, _ *Target) { h.Set(HeaderSignature, "00"+h.Get(HeaderSignature)[2:]) }},
+		{"目标服务", func(_ http.Header, t *Target) { t.Service = "order-service" }},
+		{"请求方法", func(_ http.Header, t *Target) { t.Method = "DELETE" }},
+		{"请求路径", func(_ http.Header, t *Target) { t.Path = "/api/v1/users/1" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			signer.Sign(header, target, &Identity{UserID: 42, Username: "张三", Roles: []string{"user"}})
+			verifyTarget := target
+			tt.tamper(header, &verifyTarget)
+			if _, err := signer.Verify(header, verifyTarget); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("篡改%s后应该返回签名错误，实际 %v", tt.name, err)
+			}
+		})
+	}
+
+	// 使用不同密钥签名
+	header := http.Header{}
+	NewSigner("other-secret").Sign(header, target, &Identity{UserID: 42})
+	if _, err := signer.Verify(header, target); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("不同密钥的签名应该验证失败，实际 %v", err)
+	}
+}
+
+// TestVerifyExpired 测试过期和未来时间戳的签名验证失败
+func TestVerifyExpired(t *testing.T) {
+	signer := NewSigner("test-secret")
+	target := Target{Service: "user-service", Method: "GET", Path: "/api/v1/users/me"}
+
+	for _, offset := range []time.Duration{-DefaultMaxAge - 5*time.Second, DefaultMaxAge + 5*time.Second} {
+		header := http.Header{}
+		userID, username, roles := "42", "alice", "user"
+		timestamp := strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+		header.Set(HeaderUserID, userID)
+		header.Set(HeaderUsername, username)
+		header.Set(HeaderRoles, roles)
+		header.Set(HeaderTimestamp, timestamp)
+		header.Set(HeaderSignature, signer.signature(target, userID, username, roles, timestamp))
+
+		if _, err := signer.Verify(header, target); !errors.Is(err, ErrExpiredIdentity) {
+			t.Errorf("时间偏差 %s 应该返回过期错误，实际 %v", offset, err)
+		}
+	}
+}
+
+// TestVerifyMissing 测试缺少身份信息或签名时验证失败
+func TestVerifyMissing(t *testing.T) {
+	signer := NewSigner("test-secret")
+	target := Target{Service: "user-service", Method: "GET", Path: "/api/v1/users/me"}
+
+	header := http.Header{}
+	if _, err := signer.Verify(header, target); !errors.Is(err, ErrMissingIdentity) {
+		t.Errorf("没有身份请求头应该返回缺少身份错误，实际 %v", err)
+	}
+
+	// 只有身份信息没有签名（伪造的请求头）
+	header.Set(HeaderUserID, "1")
+	header.Set(HeaderRoles, "admin")
+	if _, err := signer.Verify(header, target); !errors.Is(err, ErrMissingIdentity) {
+		t.Errorf("缺少签名应该返回缺少身份错误，实际 %v", err)
+	}
+}
+
+// TestStrip 测试删除内部身份请求头
+func TestStrip(t *testing.T) {
+	header := http.Header{}
+	NewSigner("test-secret").Sign(header, Target{}, &Identity{UserID: 42, Username: "alice", Roles: []string{"user"}})
+	header.Set("Authorization", "Bearer token")
+
+	Strip(header)
+	for _, key := range []string{HeaderUserID, HeaderUsername, HeaderRoles, HeaderTimestamp, HeaderSignature} {
+		if header.Get(key) != "" {
+			t.Errorf("请求头 %s 应该被删除", key)
+		}
+	}
+	if header.Get("Authorization") == "" {
+		t.Error("其他请求头不应该被删除")
+	}
+}
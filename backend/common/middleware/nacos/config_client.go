@@ -176,6 +176,23 @@ func (c *NacosConfigClient) ListenConfig(dataId, group string, onChange func(nam
 	return nil
 }
 
+// CancelListenConfig 取消监听配置变化
+func (c *NacosConfigClient) CancelListenConfig(dataId, group string) error {
+	if group == "" {
+		group = "DEFAULT_GROUP"
+	}
+
+	err := c.client.CancelListenConfig(vo.ConfigParam{
+		DataId: dataId,
+		Group:  group,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel listen config from nacos: %w", err)
+	}
+
+	return nil
+}
+
 // GetClient 获取底层配置客户端
 func (c *NacosConfigClient) GetClient() config_client.IConfigClient {
 	return c.client
@@ -2,6 +2,7 @@ package nacos
 
 import (
 	"fmt"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -11,6 +12,9 @@ type ConfigManager struct {
 	configClient  *NacosConfigClient
 	startupConfig *StartupConfig
 	bootstrap     interface{}
+	// 是否正在监听配置变化
+	watching bool
+	mu       sync.Mutex
 }
 
 // NewConfigManager 创建配置管理器
@@ -93,8 +97,35 @@ func (m *ConfigManager) Reload() error {
 	return nil
 }
 
-// Close 关闭配置管理器
-func (m *ConfigManager) Close() error {
-	// 目前没有需要关闭的资源
+// Watch 监听配置变化，配置变化时回调新的配置内容
+// 回调不会修改 GetBootstrap 返回的配置，由调用方解析新配置并决定是否应用
+func (m *ConfigManager) Watch(onChange func(content string)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.watching {
+		return fmt.Errorf("config is already being watched")
+	}
+
+	configCenter := m.startupConfig.Nacos.ConfigCenter
+	err := m.configClient.ListenConfig(configCenter.DataId, configCenter.Group, func(namespace, group, dataId, data string) {
+		onChange(data)
+	})
+	if err != nil {
+		return err
+	}
+	m.watching = true
 	return nil
 }
+
+// Close 关闭配置管理器（取消监听配置变化）
+func (m *ConfigManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.watching {
+		return nil
+	}
+
+	configCenter := m.startupConfig.Nacos.ConfigCenter
+	m.watching = false
+	return m.configClient.CancelListenConfig(configCenter.DataId, configCenter.Group)
+}
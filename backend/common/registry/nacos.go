@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// defaultNacosGroup Nacos 默认分组
+const defaultNacosGroup = "DEFAULT_GROUP"
+
+// NacosNamingClient Nacos 命名客户端（由 common/middleware/nacos.NacosNamingClient 实现）
+type NacosNamingClient interface {
+	RegisterInstance(param vo.RegisterInstanceParam) (bool, error)
+	DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error)
+}
+
+// NacosRegistry 基于 Nacos 的服务注册中心
+// 注册为临时实例，Nacos 客户端会自动发送心跳，实例失联后由 Nacos 自动摘除
+type NacosRegistry struct {
+	client NacosNamingClient
+	group  string
+}
+
+// NewNacosRegistry 创建 Nacos 服务注册中心（group 为空时使用 DEFAULT_GROUP）
+func NewNacosRegistry(client NacosNamingClient, group string) *NacosRegistry {
+	if group == "" {
+		group = defaultNacosGroup
+	}
+	return &NacosRegistry{
+		client: client,
+		group:  group,
+	}
+}
+
+// Register 注册端点
+func (r *NacosRegistry) Register(ctx context.Context, endpoint *Endpoint) error {
+	metadata := make(map[string]string, len(endpoint.Metadata)+1)
+	for key, value := range endpoint.Metadata {
+		metadata[key] = value
+	}
+	if endpoint.ID != "" {
+		metadata["instance_id"] = endpoint.ID
+	}
+
+	ok, err := r.client.RegisterInstance(vo.RegisterInstanceParam{
+		Ip:          endpoint.Host,
+		Port:        uint64(endpoint.Port),
+		Weight:      float64(endpoint.Weight),
+		Enable:      true,
+		Healthy:     true,
+		Ephemeral:   true,
+		Metadata:    metadata,
+		ServiceName: endpoint.ServiceName,
+		GroupName:   r.group,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Nacos 拒绝注册实例 %s", endpoint.Address())
+	}
+	return nil
+}
+
+// Deregister 注销端点
+func (r *NacosRegistry) Deregister(ctx context.Context, endpoint *Endpoint) error {
+	ok, err := r.client.DeregisterInstance(vo.DeregisterInstanceParam{
+		Ip:          endpoint.Host,
+		Port:        uint64(endpoint.Port),
+		ServiceName: endpoint.ServiceName,
+		GroupName:   r.group,
+		Ephemeral:   true,
+	})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Nacos 拒绝注销实例 %s", endpoint.Address())
+	}
+	return nil
+}
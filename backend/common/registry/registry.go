@@ -0,0 +1,282 @@
+// Package registry 服务自注册
+// 服务启动后将 HTTP/gRPC 端点连同版本、区域、权重等元数据注册到服务注册中心，并定期心跳续约；
+// 停止时先注销实例并等待调用方（如网关）刷新实例列表，再停止接收请求。
+// Registrar 实现 Kratos 的 registry.Registrar 接口，通过 kratos.Registrar 选项接入，
+// Kratos 在服务器启动后注册实例，在停止服务器之前注销实例
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kratosRegistry "github.com/go-kratos/kratos/v2/registry"
+
+	"StructForge/backend/common/log"
+)
+
+// 实例元数据键
+const (
+	MetadataVersion = "version"
+	MetadataRegion  = "region"
+	MetadataWeight  = "weight"
+	MetadataScheme  = "scheme"
+)
+
+// Endpoint 注册到服务注册中心的服务端点
+type Endpoint struct {
+	// 服务名（HTTP 端点使用服务名本身，其他协议的端点为 服务名.协议，如 user-service.grpc）
+	ServiceName string
+	// 实例ID
+	ID string
+	// 协议（http、grpc）
+	Scheme string
+	// 主机地址
+	Host string
+	// 端口
+	Port int
+	// 权重
+	Weight int
+	// 元数据
+	Metadata map[string]string
+}
+
+// Address 获取端点地址（host:port）
+func (e *Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// Registry 服务注册中心
+type Registry interface {
+	// Register 注册端点（重复注册同一端点时更新注册信息）
+	Register(ctx context.Context, endpoint *Endpoint) error
+	// Deregister 注销端点
+	Deregister(ctx context.Context, endpoint *Endpoint) error
+}
+
+// Config 服务注册配置
+type Config struct {
+	// 注册的服务名（为空时使用 Kratos 应用名）
+	ServiceName string
+	// 对外公布的主机地址（为空时使用服务器监听地址解析出的地址，容器等场景需要显式配置）
+	Host string
+	// 区域
+	Region string
+	// 权重，默认100
+	Weight int
+	// 附加元数据
+	Metadata map[string]string
+	// 心跳续约间隔（定期重新注册，注册中心重启后自动恢复注册），默认30秒
+	HeartbeatInterval time.Duration
+	// 注销后等待调用方刷新实例列表的时间（之后才停止接收请求），默认3秒
+	DeregisterDelay time.Duration
+}
+
+// withDefaults 返回填充默认值后的配置
+func (c *Config) withDefaults() Config {
+	result := *c
+	if result.Weight <= 0 {
+		result.Weight = 100
+	}
+	if result.HeartbeatInterval <= 0 {
+		result.HeartbeatInterval = 30 * time.Second
+	}
+	if result.DeregisterDelay < 0 {
+		result.DeregisterDelay = 0
+	} else if result.DeregisterDelay == 0 {
+		result.DeregisterDelay = 3 * time.Second
+	}
+	return result
+}
+
+// Registrar 服务注册器
+type Registrar struct {
+	registry Registry
+	config   Config
+
+	mu        sync.Mutex
+	endpoints []*Endpoint
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewRegistrar 创建服务注册器
+func NewRegistrar(registry Registry, config *Config) *Registrar {
+	if config == nil {
+		config = &Config{}
+	}
+	return &Registrar{
+		registry: registry,
+		config:   config.withDefaults(),
+	}
+}
+
+// Register 注册服务实例的所有端点并启动心跳续约（实现 Kratos registry.Registrar）
+func (r *Registrar) Register(ctx context.Context, instance *kratosRegistry.ServiceInstance) error {
+	endpoints, err := r.buildEndpoints(instance)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.endpoints != nil {
+		return fmt.Errorf("服务实例已注册")
+	}
+
+	for i, endpoint := range endpoints {
+		if err := r.registry.Register(ctx, endpoint); err != nil {
+			// 回滚已注册的端点
+			for _, registered := range endpoints[:i] {
+				r.registry.Deregister(ctx, registered)
+			}
+			return fmt.Errorf("注册端点 %s://%s 失败: %w", endpoint.Scheme, endpoint.Address(), err)
+		}
+		log.Info(ctx, "服务端点已注册",
+			log.String("service", endpoint.ServiceName),
+			log.String("scheme", endpoint.Scheme),
+			log.String("address", endpoint.Address()),
+			log.Int("weight", endpoint.Weight),
+		)
+	}
+
+	r.endpoints = endpoints
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.heartbeat(endpoints, r.stop, r.done)
+	return nil
+}
+
+// Deregister 停止心跳并注销服务实例的所有端点，然后等待调用方刷新实例列表（实现 Kratos registry.Registrar）
+// 未注册或已注销时直接返回
+func (r *Registrar) Deregister(ctx context.Context, instance *kratosRegistry.ServiceInstance) error {
+	r.mu.Lock()
+	endpoints, stop, done := r.endpoints, r.stop, r.done
+	r.endpoints, r.stop, r.done = nil, nil, nil
+	r.mu.Unlock()
+	if endpoints == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		if err := r.registry.Deregister(ctx, endpoint); err != nil {
+			errs = append(errs, fmt.Errorf("注销端点 %s://%s 失败: %w", endpoint.Scheme, endpoint.Address(), err))
+			continue
+		}
+		log.Info(ctx, "服务端点已注销",
+			log.String("service", endpoint.ServiceName),
+			log.String("scheme", endpoint.Scheme),
+			log.String("address", endpoint.Address()),
+		)
+	}
+
+	// 等待调用方刷新实例列表，避免停止服务器后仍有请求转发到本实例
+	if r.config.DeregisterDelay > 0 {
+		timer := time.NewTimer(r.config.DeregisterDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// heartbeat 定期重新注册端点（心跳续约）
+func (r *Registrar) heartbeat(endpoints []*Endpoint, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(r.config.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, endpoint := range endpoints {
+			ctx, cancel := context.WithTimeout(context.Background(), r.config.HeartbeatInterval)
+			if err := r.registry.Register(ctx, endpoint); err != nil {
+				log.Warn(ctx, "服务端点心跳续约失败",
+					log.String("service", endpoint.ServiceName),
+					log.String("address", endpoint.Address()),
+					log.ErrorField(err),
+				)
+			}
+			cancel()
+		}
+	}
+}
+
+// buildEndpoints 将 Kratos 服务实例的端点（如 http://10.0.0.1:8001）转换为注册端点
+func (r *Registrar) buildEndpoints(instance *kratosRegistry.ServiceInstance) ([]*Endpoint, error) {
+	if instance == nil || len(instance.Endpoints) == 0 {
+		return nil, fmt.Errorf("服务实例没有可注册的端点")
+	}
+
+	serviceName := r.config.ServiceName
+	if serviceName == "" {
+		serviceName = instance.Name
+	}
+	if serviceName == "" {
+		return nil, fmt.Errorf("服务名不能为空")
+	}
+
+	metadata := make(map[string]string, len(instance.Metadata)+len(r.config.Metadata)+4)
+	maps.Copy(metadata, instance.Metadata)
+	maps.Copy(metadata, r.config.Metadata)
+	if instance.Version != "" {
+		metadata[MetadataVersion] = instance.Version
+	}
+	if r.config.Region != "" {
+		metadata[MetadataRegion] = r.config.Region
+	}
+	metadata[MetadataWeight] = strconv.Itoa(r.config.Weight)
+
+	endpoints := make([]*Endpoint, 0, len(instance.Endpoints))
+	for _, rawURL := range instance.Endpoints {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("无效的端点 %s: %w", rawURL, err)
+		}
+		port, err := strconv.Atoi(u.Port())
+		if err != nil || port <= 0 {
+			return nil, fmt.Errorf("端点 %s 缺少有效端口", rawURL)
+		}
+		host := u.Hostname()
+		if r.config.Host != "" {
+			host = r.config.Host
+		}
+
+		scheme := strings.ToLower(u.Scheme)
+		name := serviceName
+		if scheme != "http" {
+			name = serviceName + "." + scheme
+		}
+
+		endpointMetadata := maps.Clone(metadata)
+		endpointMetadata[MetadataScheme] = scheme
+		endpoints = append(endpoints, &Endpoint{
+			ServiceName: name,
+			ID:          instance.ID,
+			Scheme:      scheme,
+			Host:        host,
+			Port:        port,
+			Weight:      r.config.Weight,
+			Metadata:    endpointMetadata,
+		})
+	}
+	return endpoints, nil
+}
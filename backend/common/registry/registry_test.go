@@ -0,0 +1,258 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	kratosRegistry "github.com/go-kratos/kratos/v2/registry"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+)
+
+// fakeNamingClient 记录注册/注销参数的 Nacos 命名客户端
+type fakeNamingClient struct {
+	mu           sync.Mutex
+	registered   []vo.RegisterInstanceParam
+	deregistered []vo.DeregisterInstanceParam
+	// 接下来失败的注册次数
+	failRegisters int
+	// 注册失败的端口
+	failPort uint64
+}
+
+func (c *fakeNamingClient) RegisterInstance(param vo.RegisterInstanceParam) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registered = append(c.registered, param)
+	if param.Port == c.failPort {
+		return false, errors.New("register rejected")
+	}
+	if c.failRegisters > 0 {
+		c.failRegisters--
+		return false, errors.New("nacos unavailable")
+	}
+	return true, nil
+}
+
+func (c *fakeNamingClient) DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deregistered = append(c.deregistered, param)
+	return true, nil
+}
+
+// registerCount 获取注册调用次数
+func (c *fakeNamingClient) registerCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.registered)
+}
+
+// setFailRegisters 设置接下来失败的注册次数
+func (c *fakeNamingClient) setFailRegisters(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failRegisters = n
+}
+
+// waitFor 等待条件满足
+func waitFor(t *testing.T, condition func() bool, message string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal(message)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// testInstance 创建测试用的 Kratos 服务实例
+func testInstance() *kratosRegistry.ServiceInstance {
+	return &kratosRegistry.ServiceInstance{
+		ID:        "user-1",
+		Name:      "user",
+		Version:   "v1.2.0",
+		Metadata:  map[string]string{"zone": "a"},
+		Endpoints: []string{"http://10.0.0.1:8001", "grpc://10.0.0.1:9001"},
+	}
+}
+
+// TestRegistrarRegisterDeregister 测试注册和注销 Nacos 实例的参数
+func TestRegistrarRegisterDeregister(t *testing.T) {
+	client := &fakeNamingClient{}
+	registrar := NewRegistrar(NewNacosRegistry(client, ""), &Config{
+		ServiceName:       "user-service",
+		Region:            "cn-east",
+		Weight:            50,
+		Metadata:          map[string]string{"env": "test"},
+		HeartbeatInterval: time.Hour,
+		DeregisterDelay:   -1,
+	})
+
+	if err := registrar.Register(context.Background(), testInstance()); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	if len(client.registered) != 2 {
+		t.Fatalf("应该注册 HTTP 和 gRPC 两个端点，实际 %d", len(client.registered))
+	}
+
+	httpParam, grpcParam := client.registered[0], client.registered[1]
+	if httpParam.ServiceName != "user-service" || grpcParam.ServiceName != "user-service.grpc" {
+		t.Errorf("服务名不正确: %s, %s", httpParam.ServiceName, grpcParam.ServiceName)
+	}
+	if httpParam.Ip != "10.0.0.1" || httpParam.Port != 8001 || grpcParam.Port != 9001 {
+		t.Errorf("地址不正确: %s:%d, %d", httpParam.Ip, httpParam.Port, grpcParam.Port)
+	}
+	if httpParam.Weight != 50 || !httpParam.Ephemeral || !httpParam.Enable || httpParam.GroupName != defaultNacosGroup {
+		t.Errorf("注册参数不正确: %+v", httpParam)
+	}
+	expected := map[string]string{
+		"zone":          "a",
+		"env":           "test",
+		MetadataVersion: "v1.2.0",
+		MetadataRegion:  "cn-east",
+		MetadataWeight:  "50",
+		MetadataScheme:  "http",
+		"instance_id":   "user-1",
+	}
+	for key, value := range expected {
+		if httpParam.Metadata[key] != value {
+			t.Errorf("元数据 %s 应该为 %q，实际 %q", key, value, httpParam.Metadata[key])
+		}
+	}
+	if grpcParam.Metadata[MetadataScheme] != "grpc" {
+		t.Errorf("gRPC 端点的 scheme 应该为 grpc，实际 %q", grpcParam.Metadata[MetadataScheme])
+	}
+
+	if err := registrar.Register(context.Background(), testInstance()); err == nil {
+		t.Error("重复注册应该返回错误")
+	}
+
+	if err := registrar.Deregister(context.Background(), testInstance()); err != nil {
+		t.Fatalf("注销失败: %v", err)
+	}
+	if len(client.deregistered) != 2 {
+		t.Fatalf("应该注销两个端点，实际 %d", len(client.deregistered))
+	}
+	for i, param := range client.deregistered {
+		if param.Ip != "10.0.0.1" || param.Port != client.registered[i].Port ||
+			param.ServiceName != client.registered[i].ServiceName || !param.Ephemeral || param.GroupName != defaultNacosGroup {
+			t.Errorf("注销参数不正确: %+v", param)
+		}
+	}
+
+	// 已注销时再次注销直接返回
+	if err := registrar.Deregister(context.Background(), testInstance()); err != nil || len(client.deregistered) != 2 {
+		t.Errorf("重复注销不应该调用注册中心: %v", err)
+	}
+}
+
+// TestRegistrarHeartbeat 测试心跳续约失败后继续重新注册
+func TestRegistrarHeartbeat(t *testing.T) {
+	client := &fakeNamingClient{}
+	registrar := NewRegistrar(NewNacosRegistry(client, "test-group"), &Config{
+		HeartbeatInterval: 10 * time.Millisecond,
+		DeregisterDelay:   -1,
+	})
+	instance := &kratosRegistry.ServiceInstance{Name: "user-service", Endpoints: []string{"http://10.0.0.1:8001"}}
+	if err := registrar.Register(context.Background(), instance); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+
+	// 注册中心不可用期间心跳失败，恢复后重新注册
+	client.setFailRegisters(2)
+	waitFor(t, func() bool { return client.registerCount() >= 5 }, "心跳失败后应该继续重新注册")
+
+	client.mu.Lock()
+	for _, param := range client.registered {
+		if param.ServiceName != "user-service" || param.GroupName != "test-group" || param.Port != 8001 {
+			t.Errorf("心跳注册参数不正确: %+v", param)
+		}
+	}
+	client.mu.Unlock()
+
+	// 注销后停止心跳
+	if err := registrar.Deregister(context.Background(), instance); err != nil {
+		t.Fatalf("注销失败: %v", err)
+	}
+	count := client.registerCount()
+	time.Sleep(50 * time.Millisecond)
+	if client.registerCount() != count {
+		t.Error("注销后不应该继续心跳")
+	}
+}
+
+// TestRegistrarRegisterFailure 测试注册失败时回滚已注册的端点且不启动心跳
+func TestRegistrarRegisterFailure(t *testing.T) {
+	// HTTP 端点注册成功，gRPC 端点注册失败
+	client := &fakeNamingClient{failPort: 9001}
+	registrar := NewRegistrar(NewNacosRegistry(client, ""), &Config{
+		HeartbeatInterval: 10 * time.Millisecond,
+		DeregisterDelay:   -1,
+	})
+
+	if err := registrar.Register(context.Background(), testInstance()); err == nil {
+		t.Fatal("注册失败时应该返回错误")
+	}
+	if len(client.deregistered) != 1 || client.deregistered[0].Port != 8001 {
+		t.Errorf("应该回滚已注册的端点: %+v", client.deregistered)
+	}
+
+	count := client.registerCount()
+	time.Sleep(50 * time.Millisecond)
+	if client.registerCount() != count {
+		t.Error("注册失败后不应该启动心跳")
+	}
+
+	// 注册中心恢复后可以重新注册
+	client.mu.Lock()
+	client.failPort = 0
+	client.mu.Unlock()
+	if err := registrar.Register(context.Background(), testInstance()); err != nil {
+		t.Fatalf("重新注册失败: %v", err)
+	}
+	if err := registrar.Deregister(context.Background(), testInstance()); err != nil {
+		t.Fatalf("注销失败: %v", err)
+	}
+}
+
+// TestRegistrarDeregisterDelay 测试注销后等待调用方刷新实例列表
+func TestRegistrarDeregisterDelay(t *testing.T) {
+	client := &fakeNamingClient{}
+	registrar := NewRegistrar(NewNacosRegistry(client, ""), &Config{
+		HeartbeatInterval: time.Hour,
+		DeregisterDelay:   100 * time.Millisecond,
+	})
+	instance := &kratosRegistry.ServiceInstance{Name: "user-service", Endpoints: []string{"http://10.0.0.1:8001"}}
+
+	if err := registrar.Register(context.Background(), instance); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	start := time.Now()
+	if err := registrar.Deregister(context.Background(), instance); err != nil {
+		t.Fatalf("注销失败: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("注销后应该等待 DeregisterDelay，实际 %v", elapsed)
+	}
+	if len(client.deregistered) != 1 {
+		t.Errorf("应该在等待前注销端点，实际 %d", len(client.deregistered))
+	}
+
+	// 停止超时（context 取消）时不再等待
+	if err := registrar.Register(context.Background(), instance); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	if err := registrar.Deregister(ctx, instance); err != nil {
+		t.Fatalf("注销失败: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("context 取消后不应该继续等待，实际 %v", elapsed)
+	}
+}
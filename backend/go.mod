@@ -12,6 +12,7 @@ require (
 	github.com/nacos-group/nacos-sdk-go v1.1.6
 	go.uber.org/automaxprocs v1.6.0
 	golang.org/x/crypto v0.44.0
+	golang.org/x/net v0.46.0
 	google.golang.org/grpc v1.71.0
 	google.golang.org/protobuf v1.36.10
 	gopkg.in/yaml.v3 v3.0.1
@@ -85,7 +86,6 @@ require (
 	go.uber.org/zap v1.21.0 // indirect
 	go.yaml.in/yaml/v2 v2.4.2 // indirect
 	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
-	golang.org/x/net v0.46.0 // indirect
 	golang.org/x/sync v0.18.0 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.31.0 // indirect